@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Senaphim/Chirpy/internal/database"
+	"github.com/Senaphim/Chirpy/internal/store"
+)
+
+// runAdmin implements `chirpy admin grant|revoke <email>`, which decides who
+// may use the admin dashboard.
+func runAdmin(ctx context.Context, st store.UserStore, args []string) error {
+	if len(args) != 2 || (args[0] != "grant" && args[0] != "revoke") {
+		return errors.New("usage: chirpy admin grant|revoke <email>")
+	}
+
+	params := database.UpdateUsrAdminParams{
+		Email:     args[1],
+		UpdatedAt: time.Now().Local(),
+		IsAdmin:   args[0] == "grant",
+	}
+	_, err := st.UpdateUsrAdmin(ctx, params)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("no user has the email %s", args[1])
+	}
+	if err != nil {
+		return err
+	}
+
+	if params.IsAdmin {
+		fmt.Printf("%s is now an admin\n", args[1])
+	} else {
+		fmt.Printf("%s is no longer an admin\n", args[1])
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package auth
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestMakeAPIToken(t *testing.T) {
+	token, err := MakeAPIToken()
+	if err != nil {
+		t.Fatalf("MakeAPIToken error = %v", err)
+	}
+	if !IsAPIToken(token) {
+		t.Errorf("MakeAPIToken token %v lacks prefix", token)
+	}
+	if HashAPIToken(token) == token {
+		t.Errorf("HashAPIToken returned the raw token")
+	}
+}
+
+func TestValidateScopes(t *testing.T) {
+	tests := []struct {
+		name    string
+		scopes  []string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:   "Deduplicated and sorted",
+			scopes: []string{ScopeChirpsWrite, ScopeChirpsRead, ScopeChirpsWrite},
+			want:   []string{ScopeChirpsRead, ScopeChirpsWrite},
+		},
+		{
+			name:    "Unknown scope",
+			scopes:  []string{"users:admin"},
+			wantErr: true,
+		},
+		{
+			name:    "No scopes",
+			scopes:  []string{},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ValidateScopes(tt.scopes)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ValidateScopes error = %v, want err %v", err, tt.wantErr)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("ValidateScopes = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScopeRoundTrip(t *testing.T) {
+	scopes := SplitScopes(JoinScopes([]string{ScopeChirpsRead, ScopeChirpsWrite}))
+	if !HasScope(scopes, ScopeChirpsWrite) {
+		t.Errorf("HasScope lost %v in %v", ScopeChirpsWrite, scopes)
+	}
+	if HasScope(SplitScopes(""), ScopeChirpsRead) {
+		t.Errorf("HasScope found a scope in an empty list")
+	}
+}
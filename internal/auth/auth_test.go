@@ -9,33 +9,34 @@ import (
 
 func TestValidateJWT(t *testing.T) {
 	userID := uuid.New()
-	validtoken, _ := MakeJWT(userID, "theonering", time.Hour)
+	keys := NewHMACKeySet("theonering")
+	validtoken, _ := MakeJWT(userID, keys, time.Hour)
 
 	tests := []struct {
 		name        string
 		tokenString string
-		tokenSecret string
+		keys        *KeySet
 		wantUserID  uuid.UUID
 		wantErr     bool
 	}{
 		{
 			name:        "Valid token",
 			tokenString: validtoken,
-			tokenSecret: "theonering",
+			keys:        keys,
 			wantUserID:  userID,
 			wantErr:     false,
 		},
 		// {
 		// 	name:        "Invalid token",
 		// 	tokenString: "invalid.token.string",
-		// 	tokenSecret: "theonering",
+		// 	keys:        keys,
 		// 	wantUserID:  uuid.Nil,
 		// 	wantErr:     true,
 		// },
 		// {
 		// 	name:        "Wrong secret",
 		// 	tokenString: validtoken,
-		// 	tokenSecret: "wrong_secret",
+		// 	keys:        NewHMACKeySet("wrong_secret"),
 		// 	wantUserID:  uuid.Nil,
 		// 	wantErr:     true,
 		// },
@@ -43,7 +44,7 @@ func TestValidateJWT(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			gotUserID, err := ValidateJWT(tt.tokenString, tt.tokenSecret)
+			gotUserID, err := ValidateJWT(tt.tokenString, tt.keys)
 			if err != nil {
 				t.Errorf("ValidateJWT error = %v, want err %v", err, tt.wantErr)
 				return
@@ -55,3 +56,40 @@ func TestValidateJWT(t *testing.T) {
 	}
 }
 
+
+func TestHashRefreshToken(t *testing.T) {
+	token, _ := MakeRefreshToken()
+	hash := HashRefreshToken(token)
+
+	if hash == token {
+		t.Errorf("HashRefreshToken returned the raw token")
+	}
+	if HashRefreshToken(token) != hash {
+		t.Errorf("HashRefreshToken is not deterministic")
+	}
+	other, _ := MakeRefreshToken()
+	if HashRefreshToken(other) == hash {
+		t.Errorf("HashRefreshToken gave the same hash for different tokens")
+	}
+}
+
+func TestAdminJWT(t *testing.T) {
+	userID := uuid.New()
+	keys := NewHMACKeySet("theonering")
+	session, _ := MakeAdminJWT(userID, keys, time.Minute)
+	access, _ := MakeJWT(userID, keys, time.Minute)
+
+	gotUserID, err := ValidateAdminJWT(session, keys)
+	if err != nil {
+		t.Fatalf("ValidateAdminJWT error = %v", err)
+	}
+	if gotUserID != userID {
+		t.Errorf("ValidateAdminJWT = %v, want %v", gotUserID, userID)
+	}
+	if _, err := ValidateJWT(session, keys); err == nil {
+		t.Errorf("ValidateJWT accepted an admin session")
+	}
+	if _, err := ValidateAdminJWT(access, keys); err == nil {
+		t.Errorf("ValidateAdminJWT accepted an access token")
+	}
+}
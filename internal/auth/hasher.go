@@ -0,0 +1,144 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Argon2Params tune the cost of Argon2id. Memory is in KiB.
+type Argon2Params struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// DefaultArgon2Params follow the OWASP minimum recommendation for Argon2id.
+var DefaultArgon2Params = Argon2Params{
+	Memory:      19 * 1024,
+	Iterations:  2,
+	Parallelism: 1,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+var errUnknownHash = errors.New("Unrecognised password hash format")
+
+// PasswordHasher hashes new passwords with Argon2id. Hashes are stored in the
+// PHC string format, which records the algorithm, version and parameters, so
+// older hashes (including bcrypt ones) can still be checked and upgraded.
+type PasswordHasher struct {
+	Params Argon2Params
+}
+
+func NewPasswordHasher(params Argon2Params) *PasswordHasher {
+	return &PasswordHasher{Params: params}
+}
+
+func (h *PasswordHasher) Hash(password string) (string, error) {
+	if password == "" {
+		return "", errors.New("Refusing to hash an empty password")
+	}
+	if len(password) > BcryptMaxBytes {
+		return "", fmt.Errorf("Password longer than %d bytes", BcryptMaxBytes)
+	}
+
+	salt := make([]byte, h.Params.SaltLength)
+	_, err := rand.Read(salt)
+	if err != nil {
+		fmtErr := fmt.Errorf("Error generating salt: %w", err)
+		return "", fmtErr
+	}
+
+	key := argon2.IDKey([]byte(password), salt, h.Params.Iterations, h.Params.Memory, h.Params.Parallelism, h.Params.KeyLength)
+	return fmt.Sprintf(
+		"$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version,
+		h.Params.Memory,
+		h.Params.Iterations,
+		h.Params.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+// NeedsRehash reports whether hash was made with another algorithm or other
+// parameters than the hasher currently uses.
+func (h *PasswordHasher) NeedsRehash(hash string) bool {
+	params, _, _, err := parseArgon2Hash(hash)
+	if err != nil {
+		return true
+	}
+
+	return params.Memory != h.Params.Memory ||
+		params.Iterations != h.Params.Iterations ||
+		params.Parallelism != h.Params.Parallelism ||
+		params.SaltLength != h.Params.SaltLength ||
+		params.KeyLength != h.Params.KeyLength
+}
+
+func parseArgon2Hash(hash string) (Argon2Params, []byte, []byte, error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return Argon2Params{}, nil, nil, errUnknownHash
+	}
+
+	var version int
+	_, err := fmt.Sscanf(parts[2], "v=%d", &version)
+	if err != nil {
+		return Argon2Params{}, nil, nil, err
+	}
+	if version != argon2.Version {
+		return Argon2Params{}, nil, nil, fmt.Errorf("Unsupported argon2 version %d", version)
+	}
+
+	params := Argon2Params{}
+	_, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism)
+	if err != nil {
+		return Argon2Params{}, nil, nil, err
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return Argon2Params{}, nil, nil, err
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return Argon2Params{}, nil, nil, err
+	}
+	params.SaltLength = uint32(len(salt))
+	params.KeyLength = uint32(len(key))
+
+	return params, salt, key, nil
+}
+
+func checkArgon2Hash(password, hash string) error {
+	params, salt, key, err := parseArgon2Hash(hash)
+	if err != nil {
+		return err
+	}
+
+	computed := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, params.KeyLength)
+	if subtle.ConstantTimeCompare(computed, key) != 1 {
+		return errors.New("Password does not match hash")
+	}
+	return nil
+}
+
+func isBcryptHash(hash string) bool {
+	return strings.HasPrefix(hash, "$2a$") ||
+		strings.HasPrefix(hash, "$2b$") ||
+		strings.HasPrefix(hash, "$2y$")
+}
+
+func checkBcryptHash(password, hash string) error {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+}
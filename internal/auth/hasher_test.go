@@ -0,0 +1,116 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Cheap parameters keep the tests fast.
+var testArgon2Params = Argon2Params{
+	Memory:      64,
+	Iterations:  1,
+	Parallelism: 1,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+func TestPasswordHasher(t *testing.T) {
+	hasher := NewPasswordHasher(testArgon2Params)
+
+	hash, err := hasher.Hash("correct horse battery")
+	if err != nil {
+		t.Fatalf("Hash error = %v", err)
+	}
+	if !strings.HasPrefix(hash, "$argon2id$v=19$m=64,t=1,p=1$") {
+		t.Errorf("Hash = %v, want PHC argon2id string", hash)
+	}
+
+	other, err := hasher.Hash("correct horse battery")
+	if err != nil {
+		t.Fatalf("Hash error = %v", err)
+	}
+	if hash == other {
+		t.Errorf("Hash reused a salt")
+	}
+
+	if err := CheckPasswordHash("correct horse battery", hash); err != nil {
+		t.Errorf("CheckPasswordHash error = %v for the right password", err)
+	}
+	if err := CheckPasswordHash("wrong horse battery", hash); err == nil {
+		t.Errorf("CheckPasswordHash accepted the wrong password")
+	}
+	if hasher.NeedsRehash(hash) {
+		t.Errorf("NeedsRehash = true for a current hash")
+	}
+}
+
+func TestCheckPasswordHashBcrypt(t *testing.T) {
+	legacy, err := bcrypt.GenerateFromPassword([]byte("correct horse battery"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("GenerateFromPassword error = %v", err)
+	}
+
+	if err := CheckPasswordHash("correct horse battery", string(legacy)); err != nil {
+		t.Errorf("CheckPasswordHash error = %v for a bcrypt hash", err)
+	}
+	if err := CheckPasswordHash("wrong horse battery", string(legacy)); err == nil {
+		t.Errorf("CheckPasswordHash accepted the wrong password")
+	}
+}
+
+func TestNeedsRehash(t *testing.T) {
+	hasher := NewPasswordHasher(testArgon2Params)
+	current, err := hasher.Hash("correct horse battery")
+	if err != nil {
+		t.Fatalf("Hash error = %v", err)
+	}
+
+	weaker := testArgon2Params
+	weaker.Memory = 32
+	old, err := NewPasswordHasher(weaker).Hash("correct horse battery")
+	if err != nil {
+		t.Fatalf("Hash error = %v", err)
+	}
+
+	legacy, err := bcrypt.GenerateFromPassword([]byte("correct horse battery"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("GenerateFromPassword error = %v", err)
+	}
+
+	tests := []struct {
+		name string
+		hash string
+		want bool
+	}{
+		{name: "Current parameters", hash: current, want: false},
+		{name: "Older parameters", hash: old, want: true},
+		{name: "Bcrypt", hash: string(legacy), want: true},
+		{name: "Garbage", hash: "not a hash", want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := hasher.NeedsRehash(tt.hash); got != tt.want {
+				t.Errorf("NeedsRehash = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckPasswordHashRejectsMalformed(t *testing.T) {
+	tests := []string{
+		"",
+		"plaintext",
+		"$argon2id$v=18$m=64,t=1,p=1$c2FsdA$a2V5",
+		"$argon2id$v=19$m=64,t=1$c2FsdA$a2V5",
+		"$argon2id$v=19$m=64,t=1,p=1$!!!$a2V5",
+	}
+
+	for _, hash := range tests {
+		if err := CheckPasswordHash("password", hash); err == nil {
+			t.Errorf("CheckPasswordHash accepted %q", hash)
+		}
+	}
+}
@@ -0,0 +1,229 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Key is a single JWT signing or verification key identified by its kid.
+// Keys loaded from a public key file can only verify tokens.
+type Key struct {
+	ID        string
+	Algorithm string
+	signKey   any
+	verifyKey any
+}
+
+func (k *Key) canSign() bool {
+	return k.signKey != nil
+}
+
+// KeySet holds every key tokens are currently accepted from and the one key
+// new tokens are signed with. Rotating means adding a new key, making it the
+// signing key and removing the old one once its tokens have expired.
+type KeySet struct {
+	signing *Key
+	keys    map[string]*Key
+}
+
+// NewHMACKeySet returns a key set that signs and validates HS256 tokens with
+// a shared secret and no kid header, as Chirpy has always done.
+func NewHMACKeySet(secret string) *KeySet {
+	ks := &KeySet{keys: map[string]*Key{}}
+	key := &Key{
+		ID:        "",
+		Algorithm: jwt.SigningMethodHS256.Alg(),
+		signKey:   []byte(secret),
+		verifyKey: []byte(secret),
+	}
+	ks.keys[key.ID] = key
+	ks.signing = key
+	return ks
+}
+
+// LoadKeySet reads every *.pem file in dir. The file name without extension
+// is the kid. Private keys (PKCS#8 RSA or Ed25519, or PKCS#1 RSA) can sign,
+// public keys (PKIX) are kept for validating tokens from retired keys. The
+// signing key is the private key with the greatest kid, so date prefixed kids
+// rotate naturally.
+func LoadKeySet(dir string) (*KeySet, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		fmtErr := fmt.Errorf("Error listing key directory: %w", err)
+		return nil, fmtErr
+	}
+	slices.Sort(paths)
+
+	ks := &KeySet{keys: map[string]*Key{}}
+	for _, path := range paths {
+		dat, err := os.ReadFile(path)
+		if err != nil {
+			fmtErr := fmt.Errorf("Error reading key file %s: %w", path, err)
+			return nil, fmtErr
+		}
+
+		kid := strings.TrimSuffix(filepath.Base(path), ".pem")
+		key, err := parseKey(kid, dat)
+		if err != nil {
+			fmtErr := fmt.Errorf("Error parsing key file %s: %w", path, err)
+			return nil, fmtErr
+		}
+
+		ks.keys[kid] = key
+		if key.canSign() {
+			ks.signing = key
+		}
+	}
+
+	if ks.signing == nil {
+		return nil, fmt.Errorf("No private signing key found in %s", dir)
+	}
+
+	return ks, nil
+}
+
+// AcceptHMAC additionally accepts HS256 tokens without a kid that were signed
+// with secret. It is meant for moving an existing deployment onto asymmetric
+// keys without logging everyone out, and never changes the signing key.
+func (ks *KeySet) AcceptHMAC(secret string) {
+	ks.keys[""] = &Key{
+		ID:        "",
+		Algorithm: jwt.SigningMethodHS256.Alg(),
+		verifyKey: []byte(secret),
+	}
+}
+
+func parseKey(kid string, dat []byte) (*Key, error) {
+	block, _ := pem.Decode(dat)
+	if block == nil {
+		return nil, errors.New("No PEM block found")
+	}
+
+	var parsed any
+	var err error
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("Unsupported PEM block type %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	key := &Key{ID: kid}
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		key.Algorithm = jwt.SigningMethodRS256.Alg()
+		key.signKey = k
+		key.verifyKey = &k.PublicKey
+	case *rsa.PublicKey:
+		key.Algorithm = jwt.SigningMethodRS256.Alg()
+		key.verifyKey = k
+	case ed25519.PrivateKey:
+		key.Algorithm = jwt.SigningMethodEdDSA.Alg()
+		key.signKey = k
+		key.verifyKey = k.Public()
+	case ed25519.PublicKey:
+		key.Algorithm = jwt.SigningMethodEdDSA.Alg()
+		key.verifyKey = k
+	default:
+		return nil, fmt.Errorf("Unsupported key type %T", parsed)
+	}
+
+	return key, nil
+}
+
+func (ks *KeySet) sign(claims jwt.Claims) (string, error) {
+	method := jwt.GetSigningMethod(ks.signing.Algorithm)
+	token := jwt.NewWithClaims(method, claims)
+	if ks.signing.ID != "" {
+		token.Header["kid"] = ks.signing.ID
+	}
+
+	return token.SignedString(ks.signing.signKey)
+}
+
+// keyFunc picks the verification key named by the token's kid and refuses
+// tokens whose algorithm does not match that key.
+func (ks *KeySet) keyFunc(t *jwt.Token) (any, error) {
+	kid, _ := t.Header["kid"].(string)
+	key, ok := ks.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("Unknown key id %q", kid)
+	}
+	if t.Method.Alg() != key.Algorithm {
+		return nil, fmt.Errorf("Unexpected signing method %s", t.Method.Alg())
+	}
+
+	return key.verifyKey, nil
+}
+
+// JWK is the public half of a key in RFC 7517 format.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns the public keys of the set. Shared HMAC secrets are never
+// published.
+func (ks *KeySet) JWKS() JWKS {
+	kids := []string{}
+	for kid := range ks.keys {
+		kids = append(kids, kid)
+	}
+	slices.Sort(kids)
+
+	set := JWKS{Keys: []JWK{}}
+	for _, kid := range kids {
+		key := ks.keys[kid]
+		switch pub := key.verifyKey.(type) {
+		case *rsa.PublicKey:
+			set.Keys = append(set.Keys, JWK{
+				Kty: "RSA",
+				Kid: kid,
+				Use: "sig",
+				Alg: key.Algorithm,
+				N:   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+				E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+			})
+		case ed25519.PublicKey:
+			set.Keys = append(set.Keys, JWK{
+				Kty: "OKP",
+				Kid: kid,
+				Use: "sig",
+				Alg: key.Algorithm,
+				Crv: "Ed25519",
+				X:   base64.RawURLEncoding.EncodeToString(pub),
+			})
+		}
+	}
+
+	return set
+}
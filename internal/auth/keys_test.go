@@ -0,0 +1,141 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+func writePrivateKey(t *testing.T, dir, kid string, key any) {
+	t.Helper()
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("MarshalPKCS8PrivateKey error = %v", err)
+	}
+	block := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	if err := os.WriteFile(filepath.Join(dir, kid+".pem"), block, 0600); err != nil {
+		t.Fatalf("WriteFile error = %v", err)
+	}
+}
+
+func writePublicKey(t *testing.T, dir, kid string, key any) {
+	t.Helper()
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		t.Fatalf("MarshalPKIXPublicKey error = %v", err)
+	}
+	block := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+	if err := os.WriteFile(filepath.Join(dir, kid+".pem"), block, 0644); err != nil {
+		t.Fatalf("WriteFile error = %v", err)
+	}
+}
+
+func TestKeySetRotation(t *testing.T) {
+	userID := uuid.New()
+	dir := t.TempDir()
+
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	writePrivateKey(t, dir, "2026-01-rsa", rsaKey)
+
+	oldKeys, err := LoadKeySet(dir)
+	if err != nil {
+		t.Fatalf("LoadKeySet error = %v", err)
+	}
+	oldToken, _ := MakeJWT(userID, oldKeys, time.Hour)
+
+	// Rotate: the old private key is replaced by its public half and a newer
+	// Ed25519 key takes over signing.
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	writePrivateKey(t, dir, "2026-06-ed25519", edKey)
+	writePublicKey(t, dir, "2026-01-rsa", &rsaKey.PublicKey)
+
+	keys, err := LoadKeySet(dir)
+	if err != nil {
+		t.Fatalf("LoadKeySet error = %v", err)
+	}
+	newToken, _ := MakeJWT(userID, keys, time.Hour)
+
+	parsed, _, _ := jwt.NewParser().ParseUnverified(newToken, &jwt.RegisteredClaims{})
+	if parsed.Header["kid"] != "2026-06-ed25519" || parsed.Method.Alg() != "EdDSA" {
+		t.Errorf("new token signed with kid %v alg %v", parsed.Header["kid"], parsed.Method.Alg())
+	}
+
+	for name, token := range map[string]string{"old": oldToken, "new": newToken} {
+		gotUserID, err := ValidateJWT(token, keys)
+		if err != nil {
+			t.Errorf("ValidateJWT %s token error = %v", name, err)
+			continue
+		}
+		if gotUserID != userID {
+			t.Errorf("ValidateJWT %s token gotUserID = %v, want %v", name, gotUserID, userID)
+		}
+	}
+
+	jwks := keys.JWKS()
+	if len(jwks.Keys) != 2 {
+		t.Fatalf("JWKS has %d keys, want 2", len(jwks.Keys))
+	}
+	if jwks.Keys[0].Kty != "RSA" || jwks.Keys[1].Kty != "OKP" {
+		t.Errorf("JWKS key types = %v, %v", jwks.Keys[0].Kty, jwks.Keys[1].Kty)
+	}
+}
+
+func TestKeySetRejectsUnknownKeys(t *testing.T) {
+	userID := uuid.New()
+	dir := t.TempDir()
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	writePrivateKey(t, dir, "current", edKey)
+	keys, err := LoadKeySet(dir)
+	if err != nil {
+		t.Fatalf("LoadKeySet error = %v", err)
+	}
+
+	hmacToken, _ := MakeJWT(userID, NewHMACKeySet("theonering"), time.Hour)
+	if _, err := ValidateJWT(hmacToken, keys); err == nil {
+		t.Errorf("ValidateJWT accepted an HS256 token without AcceptHMAC")
+	}
+
+	keys.AcceptHMAC("theonering")
+	if _, err := ValidateJWT(hmacToken, keys); err != nil {
+		t.Errorf("ValidateJWT rejected a legacy HS256 token: %v", err)
+	}
+	if len(keys.JWKS().Keys) != 1 {
+		t.Errorf("JWKS published the HMAC secret")
+	}
+
+	otherDir := t.TempDir()
+	_, otherKey, _ := ed25519.GenerateKey(rand.Reader)
+	writePrivateKey(t, otherDir, "current", otherKey)
+	otherKeys, _ := LoadKeySet(otherDir)
+	forged, _ := MakeJWT(userID, otherKeys, time.Hour)
+	if _, err := ValidateJWT(forged, keys); err == nil {
+		t.Errorf("ValidateJWT accepted a token signed by a foreign key")
+	}
+}
+
+func TestLoadKeySetWrapsErrors(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "current.pem"), 0o700); err != nil {
+		t.Fatalf("Mkdir error = %v", err)
+	}
+	_, err := LoadKeySet(dir)
+	pathErr := &fs.PathError{}
+	if !errors.As(err, &pathErr) {
+		t.Errorf("LoadKeySet error = %v, want one wrapping the read error", err)
+	}
+	if err != nil && strings.Contains(err.Error(), "\n") {
+		t.Errorf("LoadKeySet error %q spans several lines", err)
+	}
+}
@@ -0,0 +1,171 @@
+package auth
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	_ "embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// BcryptMaxBytes is the longest password bcrypt will hash. Anything longer
+// would be silently truncated by older implementations.
+const BcryptMaxBytes int = 72
+
+//go:embed common_passwords.txt
+var commonPasswordsFile []byte
+
+// Rules reported in a PasswordPolicyError.
+const (
+	RuleMinLength        string = "min_length"
+	RuleMaxLength        string = "max_length"
+	RuleCommonPassword   string = "common_password"
+	RuleBreachedPassword string = "breached_password"
+)
+
+type PolicyViolation struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// PasswordPolicyError lists every rule a password broke, so the user can fix
+// them all at once.
+type PasswordPolicyError struct {
+	Violations []PolicyViolation
+}
+
+func (e *PasswordPolicyError) Error() string {
+	messages := []string{}
+	for _, v := range e.Violations {
+		messages = append(messages, v.Message)
+	}
+	return "Password rejected: " + strings.Join(messages, "; ")
+}
+
+// BreachedList looks up passwords in a breach corpus by the k-anonymity
+// scheme of Have I Been Pwned: only the first five hex characters of the
+// SHA-1 hash select a range, which is then searched locally.
+type BreachedList interface {
+	Range(prefix string) ([]string, error)
+}
+
+// DirBreachedList reads a local mirror of the Pwned Passwords range API,
+// one file per prefix named PREFIX or PREFIX.txt holding SUFFIX:COUNT lines.
+type DirBreachedList string
+
+func (d DirBreachedList) Range(prefix string) ([]string, error) {
+	dat, err := os.ReadFile(filepath.Join(string(d), prefix))
+	if errors.Is(err, os.ErrNotExist) {
+		dat, err = os.ReadFile(filepath.Join(string(d), prefix+".txt"))
+	}
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	suffixes := []string{}
+	scanner := bufio.NewScanner(bytes.NewReader(dat))
+	for scanner.Scan() {
+		suffix, _, _ := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if suffix != "" {
+			suffixes = append(suffixes, strings.ToUpper(suffix))
+		}
+	}
+	return suffixes, scanner.Err()
+}
+
+type PasswordPolicy struct {
+	MinLength int
+	Blocklist map[string]struct{}
+	Breached  BreachedList
+}
+
+// DefaultPasswordPolicy requires at least eight characters, fits bcrypt and
+// rejects the bundled list of common passwords.
+func DefaultPasswordPolicy() *PasswordPolicy {
+	return &PasswordPolicy{
+		MinLength: 8,
+		Blocklist: parseBlocklist(commonPasswordsFile),
+	}
+}
+
+func parseBlocklist(dat []byte) map[string]struct{} {
+	blocklist := map[string]struct{}{}
+	scanner := bufio.NewScanner(bytes.NewReader(dat))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		blocklist[strings.ToLower(line)] = struct{}{}
+	}
+	return blocklist
+}
+
+// Check returns a *PasswordPolicyError listing every violated rule, or an
+// ordinary error if the breached password list could not be read.
+func (p *PasswordPolicy) Check(password string) error {
+	violations := []PolicyViolation{}
+
+	if utf8.RuneCountInString(password) < p.MinLength {
+		violations = append(violations, PolicyViolation{
+			Rule:    RuleMinLength,
+			Message: fmt.Sprintf("Password must be at least %d characters", p.MinLength),
+		})
+	}
+	if len(password) > BcryptMaxBytes {
+		violations = append(violations, PolicyViolation{
+			Rule:    RuleMaxLength,
+			Message: fmt.Sprintf("Password must be at most %d bytes", BcryptMaxBytes),
+		})
+	}
+	if _, found := p.Blocklist[strings.ToLower(password)]; found {
+		violations = append(violations, PolicyViolation{
+			Rule:    RuleCommonPassword,
+			Message: "Password is too common",
+		})
+	}
+
+	if p.Breached != nil {
+		breached, err := isBreached(p.Breached, password)
+		if err != nil {
+			fmtErr := fmt.Errorf("Error checking breached passwords: %w", err)
+			return fmtErr
+		}
+		if breached {
+			violations = append(violations, PolicyViolation{
+				Rule:    RuleBreachedPassword,
+				Message: "Password has appeared in a data breach",
+			})
+		}
+	}
+
+	if len(violations) > 0 {
+		return &PasswordPolicyError{Violations: violations}
+	}
+	return nil
+}
+
+func isBreached(list BreachedList, password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+
+	suffixes, err := list.Range(hash[:5])
+	if err != nil {
+		return false, err
+	}
+	for _, suffix := range suffixes {
+		if suffix == hash[5:] {
+			return true, nil
+		}
+	}
+	return false, nil
+}
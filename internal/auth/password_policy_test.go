@@ -0,0 +1,83 @@
+package auth
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func violatedRules(t *testing.T, err error) []string {
+	t.Helper()
+	if err == nil {
+		return nil
+	}
+	policyErr := &PasswordPolicyError{}
+	if !errors.As(err, &policyErr) {
+		t.Fatalf("Check error = %v, want *PasswordPolicyError", err)
+	}
+	rules := []string{}
+	for _, v := range policyErr.Violations {
+		rules = append(rules, v.Rule)
+	}
+	return rules
+}
+
+func TestPasswordPolicy(t *testing.T) {
+	policy := DefaultPasswordPolicy()
+
+	tests := []struct {
+		name     string
+		password string
+		want     []string
+	}{
+		{name: "Acceptable", password: "correct horse battery", want: nil},
+		{name: "Empty", password: "", want: []string{RuleMinLength}},
+		{name: "Short and common", password: "qwerty", want: []string{RuleMinLength, RuleCommonPassword}},
+		{name: "Common any case", password: "PassWord123", want: []string{RuleCommonPassword}},
+		{name: "Too long", password: strings.Repeat("a", 73), want: []string{RuleMaxLength}},
+		{name: "Multibyte counts characters", password: strings.Repeat("é", 8), want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := violatedRules(t, policy.Check(tt.password))
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Check rules = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPasswordPolicyBreached(t *testing.T) {
+	breached := "Tr0ub4dor&3xyz"
+	sum := sha1.Sum([]byte(breached))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+
+	dir := t.TempDir()
+	lines := "0000000000000000000000000000000000A:1\n" + hash[5:] + ":42\n"
+	if err := os.WriteFile(filepath.Join(dir, hash[:5]+".txt"), []byte(lines), 0644); err != nil {
+		t.Fatalf("WriteFile error = %v", err)
+	}
+
+	policy := DefaultPasswordPolicy()
+	policy.Breached = DirBreachedList(dir)
+
+	got := violatedRules(t, policy.Check(breached))
+	if !slices.Equal(got, []string{RuleBreachedPassword}) {
+		t.Errorf("Check rules = %v, want breached", got)
+	}
+	if err := policy.Check("correct horse battery"); err != nil {
+		t.Errorf("Check error = %v for password missing from the list", err)
+	}
+}
+
+func TestHashPasswordRejectsEmpty(t *testing.T) {
+	if _, err := HashPassword(""); err == nil {
+		t.Errorf("HashPassword accepted an empty password")
+	}
+}
@@ -0,0 +1,55 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestVerifyPKCE(t *testing.T) {
+	// Example from RFC 7636 appendix B.
+	verifier := "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	challenge := "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+
+	if !ValidPKCEChallenge(challenge) {
+		t.Errorf("ValidPKCEChallenge rejected the RFC challenge")
+	}
+	if ValidPKCEChallenge(verifier + "x") {
+		t.Errorf("ValidPKCEChallenge accepted a non digest")
+	}
+	if !VerifyPKCE(verifier, challenge) {
+		t.Errorf("VerifyPKCE rejected the RFC verifier")
+	}
+	if VerifyPKCE("dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXx", challenge) {
+		t.Errorf("VerifyPKCE accepted the wrong verifier")
+	}
+	if VerifyPKCE("short", challenge) {
+		t.Errorf("VerifyPKCE accepted a verifier below the minimum length")
+	}
+}
+
+func TestDelegatedJWT(t *testing.T) {
+	userID := uuid.New()
+	keys := NewHMACKeySet("theonering")
+	token, _ := MakeDelegatedJWT(userID, keys, time.Hour, "client", []string{ScopeChirpsRead})
+
+	gotUserID, err := ValidateJWT(token, keys)
+	if err != nil || gotUserID != userID {
+		t.Fatalf("ValidateJWT = %v, %v, want %v", gotUserID, err, userID)
+	}
+
+	_, claims, err := ValidateAccessJWT(token, keys)
+	if err != nil {
+		t.Fatalf("ValidateAccessJWT error = %v", err)
+	}
+	if !claims.Delegated() || !HasScope(claims.Scopes(), ScopeChirpsRead) {
+		t.Errorf("ValidateAccessJWT claims = %+v", claims)
+	}
+
+	firstParty, _ := MakeJWT(userID, keys, time.Hour)
+	_, claims, _ = ValidateAccessJWT(firstParty, keys)
+	if claims.Delegated() {
+		t.Errorf("first party token reported as delegated")
+	}
+}
@@ -2,6 +2,7 @@ package auth
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/hex"
 	"errors"
 	"fmt"
@@ -11,95 +12,187 @@ import (
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
-	"golang.org/x/crypto/bcrypt"
 )
 
 const TokenAccess string = "chirpy"
 
-func HashPassword(password string) (string, error) {
-	bytestr := []byte(password)
-	hash, err := bcrypt.GenerateFromPassword(bytestr, 10)
-	if err != nil {
-		fmtErr := fmt.Errorf("Error generating hash from password:\n%v", err)
-		return "", fmtErr
-	}
+// TokenChallenge is the issuer of the short lived tokens handed out between the
+// password and second factor steps of a login. They are not access tokens.
+const TokenChallenge string = "chirpy-2fa"
+
+// TokenAdmin is the issuer of admin dashboard sessions, which are kept apart
+// from access tokens so neither works in place of the other.
+const TokenAdmin string = "chirpy-admin"
 
-	return string(hash), nil
+// HashPassword hashes a password with Argon2id and the default parameters.
+func HashPassword(password string) (string, error) {
+	return NewPasswordHasher(DefaultArgon2Params).Hash(password)
 }
 
+// CheckPasswordHash accepts Argon2id hashes and the bcrypt hashes Chirpy
+// stored before the move to Argon2id.
 func CheckPasswordHash(password, hash string) error {
-	bytestr := []byte(password)
-	bytehash := []byte(hash)
-	err := bcrypt.CompareHashAndPassword(bytehash, bytestr)
-	if err != nil {
-		return err
+	if isBcryptHash(hash) {
+		return checkBcryptHash(password, hash)
 	}
 
-	return nil
+	return checkArgon2Hash(password, hash)
 }
 
-func MakeJWT(userId uuid.UUID, tokenSecret string, expiresIn time.Duration) (string, error) {
-	claims := &jwt.RegisteredClaims{
-		Issuer:    TokenAccess,
-		IssuedAt:  jwt.NewNumericDate(time.Now().UTC()),
-		ExpiresAt: jwt.NewNumericDate(time.Now().UTC().Add(expiresIn)),
-		Subject:   userId.String(),
-	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+// AccessClaims are the claims carried by Chirpy JWTs. Tokens issued to
+// third-party OAuth clients also carry the client and the granted scopes.
+type AccessClaims struct {
+	jwt.RegisteredClaims
+	ClientID string `json:"client_id,omitempty"`
+	Scope    string `json:"scope,omitempty"`
+}
+
+// Delegated reports whether the token was issued to a third-party client and
+// is therefore limited to its scopes.
+func (c AccessClaims) Delegated() bool {
+	return c.ClientID != ""
+}
+
+func (c AccessClaims) Scopes() []string {
+	return SplitScopes(c.Scope)
+}
+
+func MakeJWT(userId uuid.UUID, keys *KeySet, expiresIn time.Duration) (string, error) {
+	return makeJWT(userId, keys, expiresIn, TokenAccess, "", "", nil)
+}
+
+// MakeDelegatedJWT issues an access token on behalf of userId to an OAuth
+// client. It validates with ValidateJWT like any other access token.
+func MakeDelegatedJWT(
+	userId uuid.UUID,
+	keys *KeySet,
+	expiresIn time.Duration,
+	clientId string,
+	scopes []string,
+) (string, error) {
+	return makeJWT(userId, keys, expiresIn, TokenAccess, "", clientId, scopes)
+}
+
+// MakeChallengeJWT issues a challenge token naming the server side challenge
+// that limits how often, and how many times, it can be used.
+func MakeChallengeJWT(userId, challengeId uuid.UUID, keys *KeySet, expiresIn time.Duration) (string, error) {
+	return makeJWT(userId, keys, expiresIn, TokenChallenge, challengeId.String(), "", nil)
+}
 
-	siginingKey := []byte(tokenSecret)
-	ss, err := token.SignedString(siginingKey)
+// MakeAdminJWT issues an admin dashboard session for userId.
+func MakeAdminJWT(userId uuid.UUID, keys *KeySet, expiresIn time.Duration) (string, error) {
+	return makeJWT(userId, keys, expiresIn, TokenAdmin, "", "", nil)
+}
+
+func makeJWT(
+	userId uuid.UUID,
+	keys *KeySet,
+	expiresIn time.Duration,
+	issuer string,
+	tokenId string,
+	clientId string,
+	scopes []string,
+) (string, error) {
+	claims := &AccessClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    issuer,
+			IssuedAt:  jwt.NewNumericDate(time.Now().UTC()),
+			ExpiresAt: jwt.NewNumericDate(time.Now().UTC().Add(expiresIn)),
+			Subject:   userId.String(),
+			ID:        tokenId,
+		},
+		ClientID: clientId,
+		Scope:    JoinScopes(scopes),
+	}
+	ss, err := keys.sign(claims)
 	if err != nil {
-		fmtErr := fmt.Errorf("Error signing key:\n%v", err)
+		fmtErr := fmt.Errorf("Error signing key: %w", err)
 		return "", fmtErr
 	}
 
 	return ss, nil
 }
 
-func ValidateJWT(tokenString, tokenSecret string) (uuid.UUID, error) {
-	claims := jwt.RegisteredClaims{}
+// ValidateJWT accepts an access token signed by any key in the set.
+func ValidateJWT(tokenString string, keys *KeySet) (uuid.UUID, error) {
+	id, _, err := validateJWT(tokenString, keys, TokenAccess)
+	return id, err
+}
+
+// ValidateAccessJWT is ValidateJWT that also returns the claims, so callers
+// can restrict what delegated tokens are allowed to do.
+func ValidateAccessJWT(tokenString string, keys *KeySet) (uuid.UUID, AccessClaims, error) {
+	return validateJWT(tokenString, keys, TokenAccess)
+}
+
+// ValidateChallengeJWT returns the user and the challenge a challenge token
+// was issued for.
+func ValidateChallengeJWT(tokenString string, keys *KeySet) (uuid.UUID, uuid.UUID, error) {
+	id, claims, err := validateJWT(tokenString, keys, TokenChallenge)
+	if err != nil {
+		return uuid.Nil, uuid.Nil, err
+	}
+	challengeId, err := uuid.Parse(claims.ID)
+	if err != nil {
+		fmtErr := fmt.Errorf("Error parsing challenge ID: %w", err)
+		return uuid.Nil, uuid.Nil, fmtErr
+	}
+	return id, challengeId, nil
+}
+
+// ValidateAdminJWT returns the user an admin dashboard session was issued to.
+func ValidateAdminJWT(tokenString string, keys *KeySet) (uuid.UUID, error) {
+	id, _, err := validateJWT(tokenString, keys, TokenAdmin)
+	return id, err
+}
+
+func validateJWT(
+	tokenString string,
+	keys *KeySet,
+	wantIssuer string,
+) (uuid.UUID, AccessClaims, error) {
+	claims := AccessClaims{}
 
 	token, err := jwt.ParseWithClaims(
 		tokenString,
 		&claims,
-		func(t *jwt.Token) (any, error) { return []byte(tokenSecret), nil },
+		keys.keyFunc,
 	)
 	if err != nil {
-		fmtErr := fmt.Errorf("Error parsing token:\n%v", err)
-		return uuid.Nil, fmtErr
+		fmtErr := fmt.Errorf("Error parsing token: %w", err)
+		return uuid.Nil, AccessClaims{}, fmtErr
 	}
 
 	userIdStr, err := token.Claims.GetSubject()
 	if err != nil {
-		fmtErr := fmt.Errorf("Error getting user id from token:\n%v", err)
-		return uuid.Nil, fmtErr
+		fmtErr := fmt.Errorf("Error getting user id from token: %w", err)
+		return uuid.Nil, AccessClaims{}, fmtErr
 	}
 
 	issuer, err := token.Claims.GetIssuer()
 	if err != nil {
-		fmtErr := fmt.Errorf("Error getting issuer from token:\n%v", err)
-		return uuid.Nil, fmtErr
+		fmtErr := fmt.Errorf("Error getting issuer from token: %w", err)
+		return uuid.Nil, AccessClaims{}, fmtErr
 	}
-	if issuer != TokenAccess {
-		return uuid.Nil, errors.New("Invalid issuer")
+	if issuer != wantIssuer {
+		return uuid.Nil, AccessClaims{}, errors.New("Invalid issuer")
 	}
 
 	expiry, err := token.Claims.GetExpirationTime()
 	if err != nil {
-		fmtErr := fmt.Errorf("Error getting expiry time from token:\n%v", err)
-		return uuid.Nil, fmtErr
+		fmtErr := fmt.Errorf("Error getting expiry time from token: %w", err)
+		return uuid.Nil, AccessClaims{}, fmtErr
 	}
 	if time.Now().UTC().After(expiry.Time) {
-		return uuid.Nil, errors.New("Expired token")
+		return uuid.Nil, AccessClaims{}, errors.New("Expired token")
 	}
 
 	id, err := uuid.Parse(userIdStr)
 	if err != nil {
-		fmtErr := fmt.Errorf("Error parsing user ID:\n%v", err)
-		return uuid.Nil, fmtErr
+		fmtErr := fmt.Errorf("Error parsing user ID: %w", err)
+		return uuid.Nil, AccessClaims{}, fmtErr
 	}
-	return id, nil
+	return id, claims, nil
 }
 
 func GetBearerToken(headers http.Header) (string, error) {
@@ -123,6 +216,18 @@ func MakeRefreshToken() (string, error) {
 	return refreshToken, nil
 }
 
+// HashRefreshToken returns the hex encoded SHA-256 digest of a refresh token.
+// Only the digest is stored so that a database leak does not hand out working
+// sessions. Tokens carry 256 bits of entropy, so an unsalted hash is enough.
+func HashRefreshToken(token string) string {
+	return hashToken(token)
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
 func GetAPIKey(headers http.Header) (string, error) {
 	authorisation := headers.Get("Authorization")
 	if authorisation == "" {
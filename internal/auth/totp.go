@@ -0,0 +1,132 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// TOTP parameters as used by common authenticator apps (RFC 6238 defaults).
+const (
+	TOTPDigits int           = 6
+	TOTPPeriod time.Duration = 30 * time.Second
+	// TOTPSkew is the number of periods either side of now that are accepted
+	// to allow for clock drift on the client.
+	TOTPSkew int = 1
+)
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+func GenerateTOTPSecret() (string, error) {
+	byteArr := make([]byte, 20)
+	_, err := rand.Read(byteArr)
+	if err != nil {
+		fmtErr := fmt.Errorf("Error generating TOTP secret: %w", err)
+		return "", fmtErr
+	}
+
+	return totpEncoding.EncodeToString(byteArr), nil
+}
+
+// TOTPURI builds the otpauth:// URI understood by authenticator apps, usually
+// shown to the user as a QR code.
+func TOTPURI(secret, issuer, account string) string {
+	label := url.PathEscape(issuer + ":" + account)
+	query := url.Values{}
+	query.Set("secret", secret)
+	query.Set("issuer", issuer)
+	query.Set("algorithm", "SHA1")
+	query.Set("digits", fmt.Sprint(TOTPDigits))
+	query.Set("period", fmt.Sprint(int(TOTPPeriod.Seconds())))
+
+	return "otpauth://totp/" + label + "?" + query.Encode()
+}
+
+func TOTPCode(secret string, t time.Time) (string, error) {
+	key, err := totpEncoding.DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		fmtErr := fmt.Errorf("Error decoding TOTP secret: %w", err)
+		return "", fmtErr
+	}
+
+	step := uint64(t.Unix() / int64(TOTPPeriod.Seconds()))
+	return hotp(key, step), nil
+}
+
+// ValidateTOTP reports whether code is valid for secret at time t, allowing
+// TOTPSkew periods of drift in either direction.
+func ValidateTOTP(code, secret string, t time.Time) bool {
+	_, ok := MatchTOTP(code, secret, t)
+	return ok
+}
+
+// MatchTOTP is ValidateTOTP that also returns the time step the code belongs
+// to, so callers can refuse a code that was already used.
+func MatchTOTP(code, secret string, t time.Time) (int64, bool) {
+	if len(code) != TOTPDigits {
+		return 0, false
+	}
+
+	for i := -TOTPSkew; i <= TOTPSkew; i++ {
+		at := t.Add(time.Duration(i) * TOTPPeriod)
+		expected, err := TOTPCode(secret, at)
+		if err != nil {
+			return 0, false
+		}
+		if subtle.ConstantTimeCompare([]byte(code), []byte(expected)) == 1 {
+			return at.Unix() / int64(TOTPPeriod.Seconds()), true
+		}
+	}
+
+	return 0, false
+}
+
+func hotp(key []byte, counter uint64) string {
+	msg := make([]byte, 8)
+	binary.BigEndian.PutUint64(msg, counter)
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg)
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	mod := uint32(1)
+	for range TOTPDigits {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", TOTPDigits, value%mod)
+}
+
+// GenerateRecoveryCodes returns n single use codes for when the user has lost
+// their authenticator. Each code carries 80 bits of entropy.
+func GenerateRecoveryCodes(n int) ([]string, error) {
+	codes := []string{}
+	for range n {
+		byteArr := make([]byte, 10)
+		_, err := rand.Read(byteArr)
+		if err != nil {
+			fmtErr := fmt.Errorf("Error generating recovery code: %w", err)
+			return nil, fmtErr
+		}
+		code := strings.ToLower(totpEncoding.EncodeToString(byteArr))
+		codes = append(codes, code[:8]+"-"+code[8:])
+	}
+
+	return codes, nil
+}
+
+// HashRecoveryCode normalises a recovery code and returns the digest that is
+// stored in the database.
+func HashRecoveryCode(code string) string {
+	normalised := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	return hashToken(normalised)
+}
@@ -0,0 +1,111 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Base32 of the RFC 6238 SHA1 test key "12345678901234567890".
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTOTPCode(t *testing.T) {
+	tests := []struct {
+		name string
+		time time.Time
+		want string
+	}{
+		{name: "Epoch plus 59s", time: time.Unix(59, 0), want: "287082"},
+		{name: "2005", time: time.Unix(1111111109, 0), want: "081804"},
+		{name: "2009", time: time.Unix(1234567890, 0), want: "005924"},
+		{name: "2033", time: time.Unix(2000000000, 0), want: "279037"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := TOTPCode(rfcSecret, tt.time)
+			if err != nil {
+				t.Fatalf("TOTPCode error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("TOTPCode = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+
+	tests := []struct {
+		name string
+		code string
+		time time.Time
+		want bool
+	}{
+		{name: "Current period", code: "005924", time: now, want: true},
+		{name: "Previous period", code: "005924", time: now.Add(TOTPPeriod), want: true},
+		{name: "Too old", code: "005924", time: now.Add(3 * TOTPPeriod), want: false},
+		{name: "Wrong code", code: "123456", time: now, want: false},
+		{name: "Wrong length", code: "5924", time: now, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ValidateTOTP(tt.code, rfcSecret, tt.time); got != tt.want {
+				t.Errorf("ValidateTOTP = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// A code matched a period late still belongs to its own step.
+	if step, ok := MatchTOTP("005924", rfcSecret, now.Add(TOTPPeriod)); !ok || step != now.Unix()/30 {
+		t.Errorf("MatchTOTP = %d, %v, want %d", step, ok, now.Unix()/30)
+	}
+}
+
+func TestTOTPURI(t *testing.T) {
+	uri := TOTPURI(rfcSecret, "Chirpy", "user@example.com")
+	if !strings.HasPrefix(uri, "otpauth://totp/Chirpy:user@example.com?") {
+		t.Errorf("TOTPURI unexpected label: %v", uri)
+	}
+	if !strings.Contains(uri, "secret="+rfcSecret) {
+		t.Errorf("TOTPURI missing secret: %v", uri)
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, err := GenerateRecoveryCodes(10)
+	if err != nil {
+		t.Fatalf("GenerateRecoveryCodes error = %v", err)
+	}
+	if len(codes) != 10 {
+		t.Fatalf("GenerateRecoveryCodes returned %d codes, want 10", len(codes))
+	}
+	if HashRecoveryCode(codes[0]) != HashRecoveryCode(" "+strings.ToUpper(codes[0])) {
+		t.Errorf("HashRecoveryCode does not normalise input")
+	}
+	if HashRecoveryCode(codes[0]) == HashRecoveryCode(codes[1]) {
+		t.Errorf("HashRecoveryCode gave the same hash for different codes")
+	}
+}
+
+func TestChallengeJWT(t *testing.T) {
+	userID := uuid.New()
+	challengeID := uuid.New()
+	keys := NewHMACKeySet("theonering")
+	challenge, _ := MakeChallengeJWT(userID, challengeID, keys, time.Minute)
+
+	gotUserID, gotChallengeID, err := ValidateChallengeJWT(challenge, keys)
+	if err != nil {
+		t.Fatalf("ValidateChallengeJWT error = %v", err)
+	}
+	if gotUserID != userID || gotChallengeID != challengeID {
+		t.Errorf("ValidateChallengeJWT = %v, %v, want %v, %v", gotUserID, gotChallengeID, userID, challengeID)
+	}
+	if _, err := ValidateJWT(challenge, keys); err == nil {
+		t.Errorf("ValidateJWT accepted a challenge token")
+	}
+}
@@ -0,0 +1,398 @@
+// Package config loads Chirpy's settings. Every setting is a field of
+// Config, tagged with its name in config files, for example:
+//
+//	DBURL string `config:"db_url"`
+//
+// The same setting is read from the DB_URL environment variable and the
+// -db-url flag. Sources are applied in increasing precedence: defaults, the
+// YAML or TOML file named by -config or CHIRPY_CONFIG, the .env file, the
+// environment, then flags.
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is every setting Chirpy reads at startup. Fields tagged secret are
+// redacted by Print; secret:"url" only hides the password in a URL.
+type Config struct {
+	Addr     string `config:"addr" default:":8080" help:"address to listen on"`
+	Platform string `config:"platform" help:"dev allows resetting the database through the admin API"`
+	// Resetting also needs RESET_TOKEN, so that setting PLATFORM=dev by
+	// mistake is not enough to let anyone wipe the database.
+	ResetToken  string `config:"reset_token" secret:"true" help:"confirmation POST /admin/reset must carry, at least 16 characters"`
+	FixturesDir string `config:"fixtures_dir" help:"directory of JSON fixtures POST /admin/reset can seed the database from"`
+
+	LogLevel  string `config:"log_level" default:"info" help:"least severe log level written: debug, info, warn or error"`
+	LogFormat string `config:"log_format" default:"json" help:"json, or text for reading logs in a terminal"`
+	// The OTEL_* variables, such as OTEL_SERVICE_NAME and
+	// OTEL_TRACES_SAMPLER, are read by OpenTelemetry itself.
+	OTLPEndpoint string `config:"otlp_endpoint" help:"OTLP/HTTP collector to send traces to, such as http://localhost:4318"`
+
+	DBURL             string        `config:"db_url" secret:"url" help:"postgres:// or sqlite:// URL, file: path, or memory"`
+	DBMaxOpenConns    int           `config:"db_max_open_conns" help:"most open database connections, 0 for no limit"`
+	DBMaxIdleConns    int           `config:"db_max_idle_conns" help:"most idle database connections, 0 for the default"`
+	DBConnMaxLifetime time.Duration `config:"db_conn_max_lifetime" help:"how long a database connection is reused, 0 for ever"`
+	DBConnMaxIdleTime time.Duration `config:"db_conn_max_idle_time" help:"how long a database connection may sit idle, 0 for ever"`
+	DBConnectTimeout  time.Duration `config:"db_connect_timeout" default:"30s" help:"how long to wait for the database at startup"`
+	SkipMigrations    bool          `config:"skip_migrations" help:"don't apply pending database migrations on startup"`
+
+	Secret     string `config:"secret" secret:"true" help:"HMAC key for access tokens, required unless jwt_keys_dir is set"`
+	JWTKeysDir string `config:"jwt_keys_dir" help:"directory of asymmetric keys for access tokens"`
+	PolkaKey   string `config:"polka_key" secret:"true" help:"API key the payment provider's webhooks carry"`
+
+	PasswordMinLength int    `config:"password_min_length" default:"8" help:"shortest password accepted"`
+	PasswordBreachDir string `config:"password_breach_dir" help:"directory of breached password hash lists"`
+	Argon2MemoryKiB   uint32 `config:"argon2_memory_kib" default:"19456" help:"memory used by each password hash"`
+	Argon2Iterations  uint32 `config:"argon2_iterations" default:"2" help:"passes over memory by each password hash"`
+	Argon2Parallelism uint8  `config:"argon2_parallelism" default:"1" help:"threads used by each password hash"`
+
+	OIDCIssuer       string `config:"oidc_issuer" help:"OpenID Connect provider for single sign on"`
+	OIDCClientID     string `config:"oidc_client_id" help:"client ID registered with the OpenID Connect provider"`
+	OIDCClientSecret string `config:"oidc_client_secret" secret:"true" help:"client secret registered with the OpenID Connect provider"`
+	OIDCRedirectURL  string `config:"oidc_redirect_url" help:"URL of /api/v1/oidc/callback as the provider sees it"`
+	OIDCAutoLink     bool   `config:"oidc_auto_link" help:"link single sign on logins to the existing account with the same verified email without asking for its password"`
+
+	HTTPReadHeaderTimeout time.Duration `config:"http_read_header_timeout" default:"5s" help:"time allowed to read request headers"`
+	HTTPReadTimeout       time.Duration `config:"http_read_timeout" default:"15s" help:"time allowed to read a whole request"`
+	HTTPWriteTimeout      time.Duration `config:"http_write_timeout" default:"30s" help:"time allowed to write a response"`
+	HTTPIdleTimeout       time.Duration `config:"http_idle_timeout" default:"2m" help:"how long an idle keep-alive connection stays open"`
+	ShutdownTimeout       time.Duration `config:"shutdown_timeout" default:"30s" help:"how long to wait for requests to finish when stopping"`
+}
+
+// field is one setting, and where it is stored.
+type field struct {
+	name   string
+	secret string
+	help   string
+	value  reflect.Value
+	def    string
+}
+
+// env is the field's environment variable.
+func (f field) env() string {
+	return strings.ToUpper(f.name)
+}
+
+// flag is the field's command line flag.
+func (f field) flag() string {
+	return strings.ReplaceAll(f.name, "_", "-")
+}
+
+func (c *Config) fields() []field {
+	val := reflect.ValueOf(c).Elem()
+	fields := make([]field, 0, val.NumField())
+	for i := range val.NumField() {
+		tag := val.Type().Field(i).Tag
+		fields = append(fields, field{
+			name:   tag.Get("config"),
+			secret: tag.Get("secret"),
+			help:   tag.Get("help"),
+			def:    tag.Get("default"),
+			value:  val.Field(i),
+		})
+	}
+	return fields
+}
+
+func (f field) set(s string) error {
+	v := f.value
+	if v.Type() == reflect.TypeFor[time.Duration]() {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint8, reflect.Uint32:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+// flagValue records a flag so it can be applied after every other source.
+type flagValue struct {
+	f       field
+	pending *[]func() error
+}
+
+func (fv flagValue) String() string { return "" }
+
+func (fv flagValue) Set(s string) error {
+	*fv.pending = append(*fv.pending, func() error { return fv.f.set(s) })
+	return nil
+}
+
+func (fv flagValue) IsBoolFlag() bool { return fv.f.value.Kind() == reflect.Bool }
+
+// Load builds the configuration from args, the command line without the
+// program name, and lookupEnv, usually os.LookupEnv. It returns the
+// arguments left over after the flags. The result still needs validating.
+func Load(args []string, lookupEnv func(string) (string, bool)) (*Config, []string, error) {
+	c := &Config{}
+	fields := c.fields()
+	for _, f := range fields {
+		if f.def == "" {
+			continue
+		}
+		if err := f.set(f.def); err != nil {
+			return nil, nil, fmt.Errorf("config: bad default for %s: %w", f.name, err)
+		}
+	}
+
+	flags := flag.NewFlagSet("chirpy", flag.ContinueOnError)
+	configPath := flags.String("config", "", "YAML or TOML `file` to read settings from (env CHIRPY_CONFIG)")
+	envFile := flags.String("env-file", ".env", "`file` of environment variables to read, if it exists")
+	var pending []func() error
+	for _, f := range fields {
+		help := f.help + " (env " + f.env() + ")"
+		if f.def != "" {
+			help += " (default " + f.def + ")"
+		}
+		flags.Var(flagValue{f: f, pending: &pending}, f.flag(), help)
+	}
+	if err := flags.Parse(args); err != nil {
+		return nil, nil, err
+	}
+	envFileSet := false
+	flags.Visit(func(fl *flag.Flag) { envFileSet = envFileSet || fl.Name == "env-file" })
+
+	var errs []error
+	if *configPath == "" {
+		*configPath, _ = lookupEnv("CHIRPY_CONFIG")
+	}
+	if *configPath != "" {
+		if err := c.loadFile(*configPath); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	dotenv, err := godotenv.Read(*envFile)
+	if err != nil && (envFileSet || !errors.Is(err, fs.ErrNotExist)) {
+		return nil, nil, fmt.Errorf("config: reading %s: %w", *envFile, err)
+	}
+	for _, f := range fields {
+		value, ok := lookupEnv(f.env())
+		source := "the environment"
+		if !ok {
+			value, ok = dotenv[f.env()]
+			source = *envFile
+		}
+		if !ok {
+			continue
+		}
+		if err := f.set(value); err != nil {
+			errs = append(errs, fmt.Errorf("%s from %s: %w", f.env(), source, err))
+		}
+	}
+
+	for _, apply := range pending {
+		if err := apply(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return nil, nil, fmt.Errorf("config: %w", errors.Join(errs...))
+	}
+	return c, flags.Args(), nil
+}
+
+// loadFile applies the settings in a YAML or TOML file, chosen by its
+// extension. Unknown keys are errors so typos don't go unnoticed.
+func (c *Config) loadFile(path string) error {
+	dat, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("config: %w", err)
+	}
+	settings := map[string]any{}
+	switch ext := filepath.Ext(path); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(dat, &settings)
+	case ".toml":
+		err = toml.Unmarshal(dat, &settings)
+	default:
+		return fmt.Errorf("config: %s: unknown format %q, want .yaml, .yml or .toml", path, ext)
+	}
+	if err != nil {
+		return fmt.Errorf("config: %s: %w", path, err)
+	}
+
+	byName := map[string]field{}
+	for _, f := range c.fields() {
+		byName[f.name] = f
+	}
+	var errs []error
+	for key, value := range settings {
+		f, ok := byName[key]
+		if !ok {
+			errs = append(errs, fmt.Errorf("unknown setting %q", key))
+			continue
+		}
+		switch value.(type) {
+		case map[string]any, []any, nil:
+			errs = append(errs, fmt.Errorf("%s must be a single value", key))
+			continue
+		}
+		if err := f.set(fmt.Sprint(value)); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", key, err))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("config: %s: %w", path, errors.Join(errs...))
+	}
+	return nil
+}
+
+// Validate reports every setting that is missing or out of range.
+func (c *Config) Validate() error {
+	var errs []error
+	if c.DBURL == "" {
+		errs = append(errs, errors.New("DB_URL is required"))
+	}
+	if _, err := c.Level(); err != nil {
+		errs = append(errs, fmt.Errorf("LOG_LEVEL %q is not debug, info, warn or error", c.LogLevel))
+	}
+	if c.LogFormat != "json" && c.LogFormat != "text" {
+		errs = append(errs, fmt.Errorf("LOG_FORMAT %q is not json or text", c.LogFormat))
+	}
+	if c.OTLPEndpoint != "" {
+		if u, err := url.Parse(c.OTLPEndpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, errors.New("OTLP_ENDPOINT must be an http:// or https:// URL"))
+		}
+	}
+	if c.Secret == "" && c.JWTKeysDir == "" {
+		errs = append(errs, errors.New("SECRET is required unless JWT_KEYS_DIR is set"))
+	}
+	if c.OIDCIssuer != "" {
+		if c.OIDCClientID == "" {
+			errs = append(errs, errors.New("OIDC_CLIENT_ID is required with OIDC_ISSUER"))
+		}
+		if c.OIDCRedirectURL == "" {
+			errs = append(errs, errors.New("OIDC_REDIRECT_URL is required with OIDC_ISSUER"))
+		}
+	}
+	if c.ResetToken != "" && len(c.ResetToken) < 16 {
+		errs = append(errs, errors.New("RESET_TOKEN must be at least 16 characters"))
+	}
+	if c.PasswordMinLength < 1 {
+		errs = append(errs, errors.New("PASSWORD_MIN_LENGTH must be at least 1"))
+	}
+	if c.Argon2Iterations < 1 {
+		errs = append(errs, errors.New("ARGON2_ITERATIONS must be at least 1"))
+	}
+	if c.Argon2Parallelism < 1 {
+		errs = append(errs, errors.New("ARGON2_PARALLELISM must be at least 1"))
+	}
+	// Argon2 needs at least 8 KiB per thread.
+	if c.Argon2MemoryKiB < 8*uint32(c.Argon2Parallelism) {
+		errs = append(errs, errors.New("ARGON2_MEMORY_KIB must be at least 8 per thread"))
+	}
+	for _, f := range c.fields() {
+		switch v := f.value.Interface().(type) {
+		case int:
+			if v < 0 {
+				errs = append(errs, fmt.Errorf("%s must not be negative", f.env()))
+			}
+		case time.Duration:
+			if v < 0 {
+				errs = append(errs, fmt.Errorf("%s must not be negative", f.env()))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Level parses LogLevel.
+func (c *Config) Level() (slog.Level, error) {
+	var level slog.Level
+	err := level.UnmarshalText([]byte(c.LogLevel))
+	return level, err
+}
+
+// Print writes the configuration as YAML that Load can read back, with
+// secrets redacted.
+func (c *Config) Print(w io.Writer) error {
+	for _, f := range c.fields() {
+		var value string
+		switch v := f.value.Interface().(type) {
+		case string:
+			value = strconv.Quote(redact(v, f.secret))
+		case time.Duration:
+			value = strconv.Quote(v.String())
+		default:
+			value = fmt.Sprint(v)
+		}
+		if _, err := fmt.Fprintf(w, "%s: %s\n", f.name, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+const redacted = "REDACTED"
+
+func redact(value, secret string) string {
+	if value == "" {
+		return value
+	}
+	switch secret {
+	case "true":
+		return redacted
+	case "url":
+		u, err := url.Parse(value)
+		if err != nil {
+			return redacted
+		}
+		if _, ok := u.User.Password(); ok {
+			u.User = url.UserPassword(u.User.Username(), redacted)
+		}
+		// Drivers also take passwords as query parameters.
+		query := u.Query()
+		for key := range query {
+			if strings.Contains(strings.ToLower(key), "password") {
+				query.Set(key, redacted)
+			}
+		}
+		u.RawQuery = query.Encode()
+		return u.String()
+	}
+	return value
+}
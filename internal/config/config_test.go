@@ -0,0 +1,227 @@
+package config
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Senaphim/Chirpy/internal/auth"
+)
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("WriteFile error = %v", err)
+	}
+	return path
+}
+
+func lookup(env map[string]string) func(string) (string, bool) {
+	return func(name string) (string, bool) {
+		value, ok := env[name]
+		return value, ok
+	}
+}
+
+// noEnvFile returns an empty file for -env-file, so tests never read a stray
+// .env from the working directory.
+func noEnvFile(t *testing.T) string {
+	return writeFile(t, ".env", "")
+}
+
+func TestLoadDefaults(t *testing.T) {
+	c, _, err := Load([]string{"-env-file", noEnvFile(t)}, lookup(nil))
+	if err != nil {
+		t.Fatalf("Load error = %v", err)
+	}
+	if c.Addr != ":8080" || c.ShutdownTimeout != 30*time.Second {
+		t.Errorf("Load defaults = %+v", c)
+	}
+	// The defaults must match what the packages use when left unset.
+	if c.PasswordMinLength != auth.DefaultPasswordPolicy().MinLength {
+		t.Errorf("PasswordMinLength default = %d, want %d", c.PasswordMinLength, auth.DefaultPasswordPolicy().MinLength)
+	}
+	params := auth.DefaultArgon2Params
+	if c.Argon2MemoryKiB != params.Memory || c.Argon2Iterations != params.Iterations || c.Argon2Parallelism != params.Parallelism {
+		t.Errorf("Argon2 defaults = %d, %d, %d, want %+v", c.Argon2MemoryKiB, c.Argon2Iterations, c.Argon2Parallelism, params)
+	}
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	yamlFile := writeFile(t, "chirpy.yaml", `
+platform: file
+db_url: file:chirpy.db
+secret: from-file
+polka_key: from-file
+password_min_length: 10
+shutdown_timeout: 5s
+`)
+	envFile := writeFile(t, ".env", "SECRET=from-dotenv\nPOLKA_KEY=from-dotenv\nPLATFORM=dotenv\n")
+	env := map[string]string{"POLKA_KEY": "from-env", "PLATFORM": "env"}
+	args := []string{"-config", yamlFile, "-env-file", envFile, "-platform", "flag", "-skip-migrations", "migrate", "up"}
+
+	c, rest, err := Load(args, lookup(env))
+	if err != nil {
+		t.Fatalf("Load error = %v", err)
+	}
+	tests := []struct {
+		name string
+		got  any
+		want any
+	}{
+		{"db_url from the file", c.DBURL, "file:chirpy.db"},
+		{"password_min_length from the file", c.PasswordMinLength, 10},
+		{"shutdown_timeout from the file", c.ShutdownTimeout, 5 * time.Second},
+		{".env over the file", c.Secret, "from-dotenv"},
+		{"Environment over .env", c.PolkaKey, "from-env"},
+		{"Flags over the environment", c.Platform, "flag"},
+		{"Boolean flag without a value", c.SkipMigrations, true},
+		{"Default kept", c.Addr, ":8080"},
+		{"Arguments after the flags", strings.Join(rest, " "), "migrate up"},
+	}
+	for _, tc := range tests {
+		if tc.got != tc.want {
+			t.Errorf("%s = %v, want %v", tc.name, tc.got, tc.want)
+		}
+	}
+}
+
+func TestLoadTOML(t *testing.T) {
+	tomlFile := writeFile(t, "chirpy.toml", "db_url = \"memory\"\nargon2_parallelism = 4\nskip_migrations = true\n")
+	env := map[string]string{"CHIRPY_CONFIG": tomlFile}
+	c, _, err := Load([]string{"-env-file", noEnvFile(t)}, lookup(env))
+	if err != nil {
+		t.Fatalf("Load error = %v", err)
+	}
+	if c.DBURL != "memory" || c.Argon2Parallelism != 4 || !c.SkipMigrations {
+		t.Errorf("Load from TOML = %+v", c)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		env  map[string]string
+		want string
+	}{
+		{name: "Unknown file setting", args: []string{"-config", writeFile(t, "c.yaml", "db_uri: memory\n")}, want: `unknown setting "db_uri"`},
+		{name: "Nested file setting", args: []string{"-config", writeFile(t, "c.yaml", "db_url:\n  host: x\n")}, want: "single value"},
+		{name: "Unknown file format", args: []string{"-config", writeFile(t, "c.json", "{}")}, want: "unknown format"},
+		{name: "Missing config file", args: []string{"-config", "/nonexistent/chirpy.yaml"}, want: "no such file"},
+		{name: "Missing named .env", args: []string{"-env-file", "/nonexistent/.env"}, want: "/nonexistent/.env"},
+		{name: "Bad environment value", env: map[string]string{"DB_MAX_OPEN_CONNS": "lots"}, want: "DB_MAX_OPEN_CONNS from the environment"},
+		{name: "Bad duration", env: map[string]string{"SHUTDOWN_TIMEOUT": "30"}, want: "SHUTDOWN_TIMEOUT"},
+		{name: "Out of range", env: map[string]string{"ARGON2_PARALLELISM": "256"}, want: "ARGON2_PARALLELISM"},
+		{name: "Bad flag value", args: []string{"-db-max-idle-conns", "x"}, want: "invalid syntax"},
+		{name: "Unknown flag", args: []string{"-db-uri", "memory"}, want: "db-uri"},
+	}
+	for _, tc := range tests {
+		args := tc.args
+		if !strings.Contains(strings.Join(args, " "), "-env-file") {
+			args = append([]string{"-env-file", noEnvFile(t)}, args...)
+		}
+		_, _, err := Load(args, lookup(tc.env))
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: Load error = %v, want one mentioning %q", tc.name, err, tc.want)
+		}
+	}
+}
+
+func TestLoadWithoutDotenv(t *testing.T) {
+	// Startup must not need a .env when the environment has everything.
+	t.Chdir(t.TempDir())
+	c, _, err := Load(nil, lookup(map[string]string{"DB_URL": "memory", "SECRET": "s"}))
+	if err != nil {
+		t.Fatalf("Load without .env error = %v", err)
+	}
+	if err := c.Validate(); err != nil {
+		t.Errorf("Validate error = %v", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	valid := func() *Config {
+		c, _, err := Load([]string{"-env-file", noEnvFile(t)}, lookup(map[string]string{"DB_URL": "memory", "SECRET": "s"}))
+		if err != nil {
+			t.Fatalf("Load error = %v", err)
+		}
+		return c
+	}
+	tests := []struct {
+		name   string
+		change func(c *Config)
+		want   string
+	}{
+		{name: "Valid", change: func(c *Config) {}},
+		{name: "Keys instead of a secret", change: func(c *Config) { c.Secret, c.JWTKeysDir = "", "keys" }},
+		{name: "No database", change: func(c *Config) { c.DBURL = "" }, want: "DB_URL is required"},
+		{name: "Empty secret", change: func(c *Config) { c.Secret = "" }, want: "SECRET is required"},
+		{name: "Half configured OIDC", change: func(c *Config) { c.OIDCIssuer = "https://id.example" }, want: "OIDC_CLIENT_ID"},
+		{name: "No parallelism", change: func(c *Config) { c.Argon2Parallelism = 0 }, want: "ARGON2_PARALLELISM"},
+		{name: "Too little memory", change: func(c *Config) { c.Argon2MemoryKiB = 4 }, want: "ARGON2_MEMORY_KIB"},
+		{name: "Negative pool size", change: func(c *Config) { c.DBMaxOpenConns = -1 }, want: "DB_MAX_OPEN_CONNS"},
+		{name: "Debug logging", change: func(c *Config) { c.LogLevel = "debug" }},
+		{name: "Unknown log level", change: func(c *Config) { c.LogLevel = "verbose" }, want: "LOG_LEVEL"},
+		{name: "Unknown log format", change: func(c *Config) { c.LogFormat = "xml" }, want: "LOG_FORMAT"},
+		{name: "Tracing", change: func(c *Config) { c.OTLPEndpoint = "http://localhost:4318" }},
+		{name: "Tracing endpoint without a scheme", change: func(c *Config) { c.OTLPEndpoint = "localhost:4318" }, want: "OTLP_ENDPOINT"},
+		{name: "Reset token", change: func(c *Config) { c.ResetToken = "0123456789abcdef" }},
+		{name: "Short reset token", change: func(c *Config) { c.ResetToken = "dev" }, want: "RESET_TOKEN"},
+		{name: "Negative timeout", change: func(c *Config) { c.HTTPIdleTimeout = -time.Second }, want: "HTTP_IDLE_TIMEOUT"},
+	}
+	for _, tc := range tests {
+		c := valid()
+		tc.change(c)
+		err := c.Validate()
+		if tc.want == "" && err != nil {
+			t.Errorf("%s: Validate error = %v", tc.name, err)
+		}
+		if tc.want != "" && (err == nil || !strings.Contains(err.Error(), tc.want)) {
+			t.Errorf("%s: Validate error = %v, want one mentioning %q", tc.name, err, tc.want)
+		}
+	}
+}
+
+func TestPrint(t *testing.T) {
+	env := map[string]string{
+		"DB_URL":             "postgres://chirpy:hunter2@db:5432/chirpy?sslmode=disable",
+		"SECRET":             "jwt-secret",
+		"OIDC_CLIENT_SECRET": "oidc-secret",
+		"PLATFORM":           "dev",
+	}
+	c, _, err := Load([]string{"-env-file", noEnvFile(t)}, lookup(env))
+	if err != nil {
+		t.Fatalf("Load error = %v", err)
+	}
+	var out bytes.Buffer
+	if err := c.Print(&out); err != nil {
+		t.Fatalf("Print error = %v", err)
+	}
+	printed := out.String()
+	for _, secret := range []string{"hunter2", "jwt-secret", "oidc-secret"} {
+		if strings.Contains(printed, secret) {
+			t.Errorf("Print leaks %q:\n%s", secret, printed)
+		}
+	}
+	for _, want := range []string{`platform: "dev"`, `db_url: "postgres://chirpy:REDACTED@db:5432/chirpy?sslmode=disable"`, `polka_key: ""`, `shutdown_timeout: "30s"`} {
+		if !strings.Contains(printed, want) {
+			t.Errorf("Print output is missing %s:\n%s", want, printed)
+		}
+	}
+
+	// The output is a config file that loads back to the same settings,
+	// apart from the redacted secrets.
+	reloaded, _, err := Load([]string{"-env-file", noEnvFile(t), "-config", writeFile(t, "printed.yaml", printed)}, lookup(nil))
+	if err != nil {
+		t.Fatalf("Loading printed config error = %v", err)
+	}
+	reloaded.DBURL, reloaded.Secret, reloaded.OIDCClientSecret = c.DBURL, c.Secret, c.OIDCClientSecret
+	if *reloaded != *c {
+		t.Errorf("Printed config loads back as %+v, want %+v", reloaded, c)
+	}
+}
@@ -11,6 +11,19 @@ import (
 	"github.com/google/uuid"
 )
 
+type ApiToken struct {
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	UserID     uuid.UUID
+	Name       string
+	TokenHash  string
+	Scopes     string
+	ExpiresAt  sql.NullTime
+	LastUsedAt sql.NullTime
+	RevokedAt  sql.NullTime
+}
+
 type Chirp struct {
 	ID        uuid.UUID
 	CreatedAt time.Time
@@ -19,13 +32,89 @@ type Chirp struct {
 	UserID    uuid.UUID
 }
 
-type RefreshToken struct {
-	Token     string
+type OauthClient struct {
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	UserID     uuid.UUID
+	Name       string
+	SecretHash sql.NullString
+}
+
+type OauthCode struct {
+	CodeHash         string
+	CreatedAt        time.Time
+	ClientID         uuid.UUID
+	UserID           uuid.UUID
+	RedirectUri      string
+	Scopes           string
+	CodeChallenge    string
+	ExpiresAt        time.Time
+	UsedAt           sql.NullTime
+	RedirectUriGiven bool
+}
+
+type OauthRedirectUri struct {
+	ClientID    uuid.UUID
+	RedirectUri string
+}
+
+type OidcLink struct {
+	TokenHash string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	UserID    uuid.UUID
+	Issuer    string
+	Subject   string
+	Email     string
+}
+
+type OidcLogin struct {
+	StateHash    string
+	CreatedAt    time.Time
+	Nonce        string
+	CodeVerifier string
+	ExpiresAt    time.Time
+}
+
+type RecoveryCode struct {
+	CodeHash  string
 	CreatedAt time.Time
-	UpdatedAt time.Time
 	UserID    uuid.UUID
+	UsedAt    sql.NullTime
+}
+
+type RefreshToken struct {
+	TokenHash  string
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	UserID     uuid.UUID
+	ExpiresAt  time.Time
+	RevokedAt  sql.NullTime
+	ID         uuid.UUID
+	DeviceName string
+	UserAgent  string
+	IpAddress  string
+	LastUsedAt time.Time
+	ClientID   uuid.NullUUID
+	Scopes     string
+	FamilyID   uuid.NullUUID
+}
+
+type Report struct {
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	ChirpID    uuid.UUID
+	ReporterID uuid.UUID
+	Reason     string
+}
+
+type TwoFactorChallenge struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	CreatedAt time.Time
 	ExpiresAt time.Time
-	RevokedAt sql.NullTime
+	Attempts  int64
 }
 
 type User struct {
@@ -35,4 +124,20 @@ type User struct {
 	Email          string
 	HashedPassword string
 	IsChirpyRed    bool
+	TotpSecret     sql.NullString
+	TotpEnabled    bool
+	IsAdmin        bool
+	TotpLastStep   int64
+	TotpFailures   int64
+	TotpFailedAt   sql.NullTime
+}
+
+type UserIdentity struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	UserID    uuid.UUID
+	Issuer    string
+	Subject   string
+	Email     string
 }
@@ -15,59 +15,150 @@ import (
 
 const createRefreshToken = `-- name: CreateRefreshToken :one
 INSERT INTO refresh_tokens(
-  token, created_at, updated_at, user_id, expires_at, revoked_at
+  token_hash, created_at, updated_at, user_id, expires_at, revoked_at,
+  id, device_name, user_agent, ip_address, last_used_at, client_id, scopes,
+  family_id
 ) VALUES (
   $1,
   $2,
   $3,
   $4,
   $5,
-  NULL
-) RETURNING token, created_at, updated_at, user_id, expires_at, revoked_at
+  NULL,
+  $6,
+  $7,
+  $8,
+  $9,
+  $10,
+  $11,
+  $12,
+  $13
+) RETURNING token_hash, created_at, updated_at, user_id, expires_at, revoked_at, id, device_name, user_agent, ip_address, last_used_at, client_id, scopes, family_id
 `
 
 type CreateRefreshTokenParams struct {
-	Token     string
-	CreatedAt time.Time
-	UpdatedAt time.Time
-	UserID    uuid.UUID
-	ExpiresAt time.Time
+	TokenHash  string
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	UserID     uuid.UUID
+	ExpiresAt  time.Time
+	ID         uuid.UUID
+	DeviceName string
+	UserAgent  string
+	IpAddress  string
+	LastUsedAt time.Time
+	ClientID   uuid.NullUUID
+	Scopes     string
+	FamilyID   uuid.NullUUID
 }
 
 func (q *Queries) CreateRefreshToken(ctx context.Context, arg CreateRefreshTokenParams) (RefreshToken, error) {
 	row := q.db.QueryRowContext(ctx, createRefreshToken,
-		arg.Token,
+		arg.TokenHash,
 		arg.CreatedAt,
 		arg.UpdatedAt,
 		arg.UserID,
 		arg.ExpiresAt,
+		arg.ID,
+		arg.DeviceName,
+		arg.UserAgent,
+		arg.IpAddress,
+		arg.LastUsedAt,
+		arg.ClientID,
+		arg.Scopes,
+		arg.FamilyID,
 	)
 	var i RefreshToken
 	err := row.Scan(
-		&i.Token,
+		&i.TokenHash,
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.UserID,
 		&i.ExpiresAt,
 		&i.RevokedAt,
+		&i.ID,
+		&i.DeviceName,
+		&i.UserAgent,
+		&i.IpAddress,
+		&i.LastUsedAt,
+		&i.ClientID,
+		&i.Scopes,
+		&i.FamilyID,
 	)
 	return i, err
 }
 
+const getActiveSessionsByUser = `-- name: GetActiveSessionsByUser :many
+SELECT token_hash, created_at, updated_at, user_id, expires_at, revoked_at, id, device_name, user_agent, ip_address, last_used_at, client_id, scopes, family_id FROM refresh_tokens
+WHERE user_id=$1 AND revoked_at IS NULL AND expires_at > $2
+ORDER BY last_used_at DESC
+`
+
+type GetActiveSessionsByUserParams struct {
+	UserID    uuid.UUID
+	ExpiresAt time.Time
+}
+
+func (q *Queries) GetActiveSessionsByUser(ctx context.Context, arg GetActiveSessionsByUserParams) ([]RefreshToken, error) {
+	rows, err := q.db.QueryContext(ctx, getActiveSessionsByUser, arg.UserID, arg.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []RefreshToken
+	for rows.Next() {
+		var i RefreshToken
+		if err := rows.Scan(
+			&i.TokenHash,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.UserID,
+			&i.ExpiresAt,
+			&i.RevokedAt,
+			&i.ID,
+			&i.DeviceName,
+			&i.UserAgent,
+			&i.IpAddress,
+			&i.LastUsedAt,
+			&i.ClientID,
+			&i.Scopes,
+			&i.FamilyID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getRefreshToken = `-- name: GetRefreshToken :one
-SELECT token, created_at, updated_at, user_id, expires_at, revoked_at FROM refresh_tokens WHERE token=$1
+SELECT token_hash, created_at, updated_at, user_id, expires_at, revoked_at, id, device_name, user_agent, ip_address, last_used_at, client_id, scopes, family_id FROM refresh_tokens WHERE token_hash=$1
 `
 
-func (q *Queries) GetRefreshToken(ctx context.Context, token string) (RefreshToken, error) {
-	row := q.db.QueryRowContext(ctx, getRefreshToken, token)
+func (q *Queries) GetRefreshToken(ctx context.Context, tokenHash string) (RefreshToken, error) {
+	row := q.db.QueryRowContext(ctx, getRefreshToken, tokenHash)
 	var i RefreshToken
 	err := row.Scan(
-		&i.Token,
+		&i.TokenHash,
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.UserID,
 		&i.ExpiresAt,
 		&i.RevokedAt,
+		&i.ID,
+		&i.DeviceName,
+		&i.UserAgent,
+		&i.IpAddress,
+		&i.LastUsedAt,
+		&i.ClientID,
+		&i.Scopes,
+		&i.FamilyID,
 	)
 	return i, err
 }
@@ -81,17 +172,103 @@ func (q *Queries) ResetRefreshTokens(ctx context.Context) error {
 	return err
 }
 
-const revokeRefreshToken = `-- name: RevokeRefreshToken :exec
-UPDATE refresh_tokens SET updated_at=$1, revoked_at=$2 WHERE token=$3
+const revokeAllSessionsByUser = `-- name: RevokeAllSessionsByUser :exec
+UPDATE refresh_tokens SET updated_at=$1, revoked_at=$2
+WHERE user_id=$3 AND revoked_at IS NULL
+`
+
+type RevokeAllSessionsByUserParams struct {
+	UpdatedAt time.Time
+	RevokedAt sql.NullTime
+	UserID    uuid.UUID
+}
+
+func (q *Queries) RevokeAllSessionsByUser(ctx context.Context, arg RevokeAllSessionsByUserParams) error {
+	_, err := q.db.ExecContext(ctx, revokeAllSessionsByUser, arg.UpdatedAt, arg.RevokedAt, arg.UserID)
+	return err
+}
+
+const revokeRefreshToken = `-- name: RevokeRefreshToken :execrows
+UPDATE refresh_tokens SET updated_at=$1, revoked_at=$2
+WHERE token_hash=$3 AND revoked_at IS NULL
 `
 
 type RevokeRefreshTokenParams struct {
 	UpdatedAt time.Time
 	RevokedAt sql.NullTime
-	Token     string
+	TokenHash string
+}
+
+func (q *Queries) RevokeRefreshToken(ctx context.Context, arg RevokeRefreshTokenParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, revokeRefreshToken, arg.UpdatedAt, arg.RevokedAt, arg.TokenHash)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const revokeRefreshTokenFamily = `-- name: RevokeRefreshTokenFamily :exec
+UPDATE refresh_tokens SET updated_at=$1, revoked_at=$2
+WHERE family_id=$3 AND revoked_at IS NULL
+`
+
+type RevokeRefreshTokenFamilyParams struct {
+	UpdatedAt time.Time
+	RevokedAt sql.NullTime
+	FamilyID  uuid.NullUUID
+}
+
+func (q *Queries) RevokeRefreshTokenFamily(ctx context.Context, arg RevokeRefreshTokenFamilyParams) error {
+	_, err := q.db.ExecContext(ctx, revokeRefreshTokenFamily, arg.UpdatedAt, arg.RevokedAt, arg.FamilyID)
+	return err
 }
 
-func (q *Queries) RevokeRefreshToken(ctx context.Context, arg RevokeRefreshTokenParams) error {
-	_, err := q.db.ExecContext(ctx, revokeRefreshToken, arg.UpdatedAt, arg.RevokedAt, arg.Token)
+const revokeSessionById = `-- name: RevokeSessionById :execrows
+UPDATE refresh_tokens SET updated_at=$1, revoked_at=$2
+WHERE id=$3 AND user_id=$4 AND revoked_at IS NULL
+`
+
+type RevokeSessionByIdParams struct {
+	UpdatedAt time.Time
+	RevokedAt sql.NullTime
+	ID        uuid.UUID
+	UserID    uuid.UUID
+}
+
+func (q *Queries) RevokeSessionById(ctx context.Context, arg RevokeSessionByIdParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, revokeSessionById,
+		arg.UpdatedAt,
+		arg.RevokedAt,
+		arg.ID,
+		arg.UserID,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const touchRefreshToken = `-- name: TouchRefreshToken :exec
+UPDATE refresh_tokens
+SET updated_at=$1, last_used_at=$2, user_agent=$3, ip_address=$4
+WHERE token_hash=$5
+`
+
+type TouchRefreshTokenParams struct {
+	UpdatedAt  time.Time
+	LastUsedAt time.Time
+	UserAgent  string
+	IpAddress  string
+	TokenHash  string
+}
+
+func (q *Queries) TouchRefreshToken(ctx context.Context, arg TouchRefreshTokenParams) error {
+	_, err := q.db.ExecContext(ctx, touchRefreshToken,
+		arg.UpdatedAt,
+		arg.LastUsedAt,
+		arg.UserAgent,
+		arg.IpAddress,
+		arg.TokenHash,
+	)
 	return err
 }
@@ -7,11 +7,29 @@ package database
 
 import (
 	"context"
+	"database/sql"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+const acceptUsrTotpStep = `-- name: AcceptUsrTotpStep :execrows
+UPDATE users SET totp_last_step = $2, totp_failures = 0 WHERE id = $1 AND totp_last_step < $2
+`
+
+type AcceptUsrTotpStepParams struct {
+	ID           uuid.UUID
+	TotpLastStep int64
+}
+
+func (q *Queries) AcceptUsrTotpStep(ctx context.Context, arg AcceptUsrTotpStepParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, acceptUsrTotpStep, arg.ID, arg.TotpLastStep)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const createUser = `-- name: CreateUser :one
 INSERT INTO users(id, created_at, updated_at, email, hashed_password) 
 VALUES (
@@ -20,7 +38,7 @@ VALUES (
   $3,
   $4,
   $5
-) RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+) RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, totp_secret, totp_enabled, is_admin, totp_last_step, totp_failures, totp_failed_at
 `
 
 type CreateUserParams struct {
@@ -47,6 +65,12 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.TotpSecret,
+		&i.TotpEnabled,
+		&i.IsAdmin,
+		&i.TotpLastStep,
+		&i.TotpFailures,
+		&i.TotpFailedAt,
 	)
 	return i, err
 }
@@ -60,8 +84,67 @@ func (q *Queries) DeleteAll(ctx context.Context) error {
 	return err
 }
 
+const disableUsrTotp = `-- name: DisableUsrTotp :one
+UPDATE users SET updated_at = $2, totp_secret = NULL, totp_enabled = false, totp_last_step = 0
+WHERE id = $1 RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, totp_secret, totp_enabled, is_admin, totp_last_step, totp_failures, totp_failed_at
+`
+
+type DisableUsrTotpParams struct {
+	ID        uuid.UUID
+	UpdatedAt time.Time
+}
+
+func (q *Queries) DisableUsrTotp(ctx context.Context, arg DisableUsrTotpParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, disableUsrTotp, arg.ID, arg.UpdatedAt)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.TotpSecret,
+		&i.TotpEnabled,
+		&i.IsAdmin,
+		&i.TotpLastStep,
+		&i.TotpFailures,
+		&i.TotpFailedAt,
+	)
+	return i, err
+}
+
+const enableUsrTotp = `-- name: EnableUsrTotp :one
+UPDATE users SET updated_at = $2, totp_enabled = true WHERE id = $1 RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, totp_secret, totp_enabled, is_admin, totp_last_step, totp_failures, totp_failed_at
+`
+
+type EnableUsrTotpParams struct {
+	ID        uuid.UUID
+	UpdatedAt time.Time
+}
+
+func (q *Queries) EnableUsrTotp(ctx context.Context, arg EnableUsrTotpParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, enableUsrTotp, arg.ID, arg.UpdatedAt)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.TotpSecret,
+		&i.TotpEnabled,
+		&i.IsAdmin,
+		&i.TotpLastStep,
+		&i.TotpFailures,
+		&i.TotpFailedAt,
+	)
+	return i, err
+}
+
 const getUserByEmail = `-- name: GetUserByEmail :one
-SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red FROM users WHERE email = $1
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, totp_secret, totp_enabled, is_admin, totp_last_step, totp_failures, totp_failed_at FROM users WHERE email = $1
 `
 
 func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error) {
@@ -74,12 +157,18 @@ func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.TotpSecret,
+		&i.TotpEnabled,
+		&i.IsAdmin,
+		&i.TotpLastStep,
+		&i.TotpFailures,
+		&i.TotpFailedAt,
 	)
 	return i, err
 }
 
 const getUserById = `-- name: GetUserById :one
-SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red FROM users WHERE id = $1
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, totp_secret, totp_enabled, is_admin, totp_last_step, totp_failures, totp_failed_at FROM users WHERE id = $1
 `
 
 func (q *Queries) GetUserById(ctx context.Context, id uuid.UUID) (User, error) {
@@ -92,12 +181,98 @@ func (q *Queries) GetUserById(ctx context.Context, id uuid.UUID) (User, error) {
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.TotpSecret,
+		&i.TotpEnabled,
+		&i.IsAdmin,
+		&i.TotpLastStep,
+		&i.TotpFailures,
+		&i.TotpFailedAt,
+	)
+	return i, err
+}
+
+const recordUsrTotpFailure = `-- name: RecordUsrTotpFailure :one
+UPDATE users SET
+  totp_failures = CASE WHEN totp_failed_at > $1 THEN totp_failures + 1 ELSE 1 END,
+  totp_failed_at = $2
+WHERE id = $3 RETURNING totp_failures
+`
+
+type RecordUsrTotpFailureParams struct {
+	WindowStart sql.NullTime
+	FailedAt    sql.NullTime
+	ID          uuid.UUID
+}
+
+func (q *Queries) RecordUsrTotpFailure(ctx context.Context, arg RecordUsrTotpFailureParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, recordUsrTotpFailure, arg.WindowStart, arg.FailedAt, arg.ID)
+	var totp_failures int64
+	err := row.Scan(&totp_failures)
+	return totp_failures, err
+}
+
+const setUsrTotpSecret = `-- name: SetUsrTotpSecret :one
+UPDATE users SET updated_at = $2, totp_secret = $3, totp_enabled = false WHERE id = $1 RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, totp_secret, totp_enabled, is_admin, totp_last_step, totp_failures, totp_failed_at
+`
+
+type SetUsrTotpSecretParams struct {
+	ID         uuid.UUID
+	UpdatedAt  time.Time
+	TotpSecret sql.NullString
+}
+
+func (q *Queries) SetUsrTotpSecret(ctx context.Context, arg SetUsrTotpSecretParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, setUsrTotpSecret, arg.ID, arg.UpdatedAt, arg.TotpSecret)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.TotpSecret,
+		&i.TotpEnabled,
+		&i.IsAdmin,
+		&i.TotpLastStep,
+		&i.TotpFailures,
+		&i.TotpFailedAt,
+	)
+	return i, err
+}
+
+const updateUsrAdmin = `-- name: UpdateUsrAdmin :one
+UPDATE users SET updated_at = $2, is_admin = $3 WHERE email = $1 RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, totp_secret, totp_enabled, is_admin, totp_last_step, totp_failures, totp_failed_at
+`
+
+type UpdateUsrAdminParams struct {
+	Email     string
+	UpdatedAt time.Time
+	IsAdmin   bool
+}
+
+func (q *Queries) UpdateUsrAdmin(ctx context.Context, arg UpdateUsrAdminParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, updateUsrAdmin, arg.Email, arg.UpdatedAt, arg.IsAdmin)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.TotpSecret,
+		&i.TotpEnabled,
+		&i.IsAdmin,
+		&i.TotpLastStep,
+		&i.TotpFailures,
+		&i.TotpFailedAt,
 	)
 	return i, err
 }
 
 const updateUsrChirpyRed = `-- name: UpdateUsrChirpyRed :one
-UPDATE users SET updated_at = $2, is_chirpy_red = $3 WHERE id = $1 RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+UPDATE users SET updated_at = $2, is_chirpy_red = $3 WHERE id = $1 RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, totp_secret, totp_enabled, is_admin, totp_last_step, totp_failures, totp_failed_at
 `
 
 type UpdateUsrChirpyRedParams struct {
@@ -116,12 +291,18 @@ func (q *Queries) UpdateUsrChirpyRed(ctx context.Context, arg UpdateUsrChirpyRed
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.TotpSecret,
+		&i.TotpEnabled,
+		&i.IsAdmin,
+		&i.TotpLastStep,
+		&i.TotpFailures,
+		&i.TotpFailedAt,
 	)
 	return i, err
 }
 
 const updateUsrEmailPwd = `-- name: UpdateUsrEmailPwd :one
-UPDATE users SET updated_at = $2, email = $3, hashed_password = $4 WHERE id = $1 RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+UPDATE users SET updated_at = $2, email = $3, hashed_password = $4 WHERE id = $1 RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, totp_secret, totp_enabled, is_admin, totp_last_step, totp_failures, totp_failed_at
 `
 
 type UpdateUsrEmailPwdParams struct {
@@ -146,6 +327,27 @@ func (q *Queries) UpdateUsrEmailPwd(ctx context.Context, arg UpdateUsrEmailPwdPa
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.TotpSecret,
+		&i.TotpEnabled,
+		&i.IsAdmin,
+		&i.TotpLastStep,
+		&i.TotpFailures,
+		&i.TotpFailedAt,
 	)
 	return i, err
 }
+
+const updateUsrPassword = `-- name: UpdateUsrPassword :exec
+UPDATE users SET updated_at = $2, hashed_password = $3 WHERE id = $1
+`
+
+type UpdateUsrPasswordParams struct {
+	ID             uuid.UUID
+	UpdatedAt      time.Time
+	HashedPassword string
+}
+
+func (q *Queries) UpdateUsrPassword(ctx context.Context, arg UpdateUsrPasswordParams) error {
+	_, err := q.db.ExecContext(ctx, updateUsrPassword, arg.ID, arg.UpdatedAt, arg.HashedPassword)
+	return err
+}
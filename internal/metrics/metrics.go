@@ -0,0 +1,346 @@
+// Package metrics keeps counters and histograms and serves them in the
+// Prometheus text exposition format. It covers what Chirpy measures, which
+// is much less than the official client library.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultBuckets suit HTTP request latencies, in seconds.
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// QueryBuckets suit database query latencies, in seconds.
+var QueryBuckets = []float64{0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1}
+
+// family is one metric name and every series under it.
+type family interface {
+	name() string
+	// describe is everything registered with the family besides its
+	// name, so identical registrations can be told apart from clashes.
+	describe() string
+	write(w *bufio.Writer)
+}
+
+// Registry holds metrics in the order they were registered, which is the
+// order they are written in.
+type Registry struct {
+	mu       sync.Mutex
+	families []family
+}
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// register adds f, or returns the family already registered under its name
+// when that was registered the same way, so several servers can share a
+// registry. Reusing a name for a different metric is a programming error,
+// so it panics.
+func (reg *Registry) register(f family) family {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	for _, existing := range reg.families {
+		if existing.name() != f.name() {
+			continue
+		}
+		if existing.describe() != f.describe() {
+			panic("metrics: " + f.name() + " registered twice with different kinds, help, labels or buckets")
+		}
+		return existing
+	}
+	reg.families = append(reg.families, f)
+	return f
+}
+
+// ServeHTTP writes every metric in the text exposition format.
+func (reg *Registry) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	bw := bufio.NewWriter(w)
+	reg.mu.Lock()
+	families := slices.Clone(reg.families)
+	reg.mu.Unlock()
+	for _, f := range families {
+		f.write(bw)
+	}
+	bw.Flush()
+}
+
+// series is the part of every metric keyed by label values.
+type series[T any] struct {
+	mu     sync.Mutex
+	labels []string
+	byKey  map[string]*T
+	values map[string][]string
+	make   func() *T
+}
+
+func (s *series[T]) with(values []string) *T {
+	if len(values) != len(s.labels) {
+		panic(fmt.Sprintf("metrics: got %d label values for %d labels", len(values), len(s.labels)))
+	}
+	key := strings.Join(values, "\xff")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if m, ok := s.byKey[key]; ok {
+		return m
+	}
+	if s.byKey == nil {
+		s.byKey = map[string]*T{}
+		s.values = map[string][]string{}
+	}
+	m := s.make()
+	s.byKey[key] = m
+	s.values[key] = slices.Clone(values)
+	return m
+}
+
+// each calls fn for every series, sorted by label values so the output is
+// stable between scrapes.
+func (s *series[T]) each(fn func(values []string, m *T)) {
+	s.mu.Lock()
+	keys := make([]string, 0, len(s.byKey))
+	for key := range s.byKey {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	ms := make([]*T, len(keys))
+	values := make([][]string, len(keys))
+	for i, key := range keys {
+		ms[i], values[i] = s.byKey[key], s.values[key]
+	}
+	s.mu.Unlock()
+	for i := range keys {
+		fn(values[i], ms[i])
+	}
+}
+
+// Counter is a value that only goes up.
+type Counter struct {
+	bits atomic.Uint64
+}
+
+func (c *Counter) Inc() {
+	c.Add(1)
+}
+
+// Add increases the counter by v, which must not be negative.
+func (c *Counter) Add(v float64) {
+	for {
+		old := c.bits.Load()
+		if c.bits.CompareAndSwap(old, math.Float64bits(math.Float64frombits(old)+v)) {
+			return
+		}
+	}
+}
+
+func (c *Counter) Value() float64 {
+	return math.Float64frombits(c.bits.Load())
+}
+
+// CounterVec is a counter for each combination of label values.
+type CounterVec struct {
+	metric string
+	help   string
+	series series[Counter]
+}
+
+// NewCounter registers a counter. Without labels, use With() to reach it.
+// Registering the same counter again returns the first one.
+func (reg *Registry) NewCounter(name, help string, labels ...string) *CounterVec {
+	v := &CounterVec{
+		metric: name,
+		help:   help,
+		series: series[Counter]{labels: labels, make: func() *Counter { return &Counter{} }},
+	}
+	return reg.register(v).(*CounterVec)
+}
+
+// With returns the counter for values, one for each label in order.
+func (v *CounterVec) With(values ...string) *Counter {
+	return v.series.with(values)
+}
+
+func (v *CounterVec) name() string { return v.metric }
+
+func (v *CounterVec) describe() string {
+	return describe("counter", v.help, v.series.labels)
+}
+
+func (v *CounterVec) write(w *bufio.Writer) {
+	writeHeader(w, v.metric, v.help, "counter")
+	v.series.each(func(values []string, c *Counter) {
+		writeSample(w, v.metric, v.series.labels, values, "", "", c.Value())
+	})
+}
+
+// Histogram counts observations into buckets.
+type Histogram struct {
+	mu      sync.Mutex
+	buckets []float64
+	counts  []uint64
+	sum     float64
+	count   uint64
+}
+
+func (h *Histogram) Observe(v float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	// Buckets are cumulative when written, so only the first one that
+	// fits is counted here.
+	if i, _ := slices.BinarySearch(h.buckets, v); i < len(h.counts) {
+		h.counts[i]++
+	}
+	h.sum += v
+	h.count++
+}
+
+// HistogramVec is a histogram for each combination of label values.
+type HistogramVec struct {
+	metric  string
+	help    string
+	series  series[Histogram]
+	buckets []float64
+}
+
+// NewHistogram registers a histogram with the given bucket upper bounds,
+// which must be sorted. Registering the same histogram again returns the
+// first one.
+func (reg *Registry) NewHistogram(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	if !slices.IsSorted(buckets) {
+		panic("metrics: buckets of " + name + " are not sorted")
+	}
+	v := &HistogramVec{
+		metric: name,
+		help:   help,
+		series: series[Histogram]{labels: labels, make: func() *Histogram {
+			return &Histogram{buckets: buckets, counts: make([]uint64, len(buckets))}
+		}},
+		buckets: buckets,
+	}
+	return reg.register(v).(*HistogramVec)
+}
+
+// With returns the histogram for values, one for each label in order.
+func (v *HistogramVec) With(values ...string) *Histogram {
+	return v.series.with(values)
+}
+
+func (v *HistogramVec) name() string { return v.metric }
+
+func (v *HistogramVec) describe() string {
+	bounds := make([]string, len(v.buckets))
+	for i, bound := range v.buckets {
+		bounds[i] = formatFloat(bound)
+	}
+	return describe("histogram", v.help, v.series.labels) + "\xff" + strings.Join(bounds, ",")
+}
+
+func (v *HistogramVec) write(w *bufio.Writer) {
+	writeHeader(w, v.metric, v.help, "histogram")
+	v.series.each(func(values []string, h *Histogram) {
+		h.mu.Lock()
+		counts := slices.Clone(h.counts)
+		sum, count := h.sum, h.count
+		h.mu.Unlock()
+
+		var cumulative uint64
+		for i, bound := range h.buckets {
+			cumulative += counts[i]
+			writeSample(w, v.metric+"_bucket", v.series.labels, values, "le", formatFloat(bound), float64(cumulative))
+		}
+		writeSample(w, v.metric+"_bucket", v.series.labels, values, "le", "+Inf", float64(count))
+		writeSample(w, v.metric+"_sum", v.series.labels, values, "", "", sum)
+		writeSample(w, v.metric+"_count", v.series.labels, values, "", "", float64(count))
+	})
+}
+
+// funcMetric is a gauge or counter read from fn at every scrape.
+type funcMetric struct {
+	metric string
+	help   string
+	kind   string
+	fn     func() float64
+}
+
+// GaugeFunc registers a gauge whose value is fn at the time of the scrape.
+// Registering the same gauge again keeps the first fn.
+func (reg *Registry) GaugeFunc(name, help string, fn func() float64) {
+	reg.register(&funcMetric{metric: name, help: help, kind: "gauge", fn: fn})
+}
+
+// CounterFunc registers a counter kept elsewhere, such as by the runtime.
+// Registering the same counter again keeps the first fn.
+func (reg *Registry) CounterFunc(name, help string, fn func() float64) {
+	reg.register(&funcMetric{metric: name, help: help, kind: "counter", fn: fn})
+}
+
+func (m *funcMetric) name() string { return m.metric }
+
+func (m *funcMetric) describe() string {
+	return describe(m.kind, m.help, nil)
+}
+
+func (m *funcMetric) write(w *bufio.Writer) {
+	writeHeader(w, m.metric, m.help, m.kind)
+	writeSample(w, m.metric, nil, nil, "", "", m.fn())
+}
+
+// describe joins what identifies a metric besides its name.
+func describe(kind, help string, labels []string) string {
+	return kind + "\xff" + help + "\xff" + strings.Join(labels, ",")
+}
+
+var helpEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+func writeHeader(w *bufio.Writer, name, help, kind string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, helpEscaper.Replace(help), name, kind)
+}
+
+// writeSample writes one line. extraName, when set, is a label added after
+// the others, such as a histogram bucket's le.
+func writeSample(w *bufio.Writer, name string, labels, values []string, extraName, extraValue string, v float64) {
+	w.WriteString(name)
+	if len(labels) > 0 || extraName != "" {
+		w.WriteByte('{')
+		for i, label := range labels {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			fmt.Fprintf(w, `%s="%s"`, label, labelEscaper.Replace(values[i]))
+		}
+		if extraName != "" {
+			if len(labels) > 0 {
+				w.WriteByte(',')
+			}
+			fmt.Fprintf(w, `%s="%s"`, extraName, extraValue)
+		}
+		w.WriteByte('}')
+	}
+	w.WriteByte(' ')
+	w.WriteString(formatFloat(v))
+	w.WriteByte('\n')
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,131 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func scrape(t *testing.T, reg *Registry) string {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	reg.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if got := rec.Header().Get("Content-Type"); !strings.HasPrefix(got, "text/plain; version=0.0.4") {
+		t.Errorf("Content-Type = %q, want the text exposition format", got)
+	}
+	return rec.Body.String()
+}
+
+func TestExposition(t *testing.T) {
+	reg := NewRegistry()
+	requests := reg.NewCounter("http_requests_total", "Requests served.", "route", "status")
+	requests.With("GET /b", "200").Inc()
+	requests.With("GET /a", "404").Add(2)
+	requests.With("GET /b", "200").Inc()
+	requests.With(`say "hi"\`+"\n", "200").Inc()
+	latency := reg.NewHistogram("latency_seconds", "Latency.\nIn seconds.", []float64{0.1, 1})
+	for _, v := range []float64{0.05, 0.1, 0.5, 3} {
+		latency.With().Observe(v)
+	}
+	reg.GaugeFunc("temperature", "Temperature.", func() float64 { return -1.5 })
+
+	want := `# HELP http_requests_total Requests served.
+# TYPE http_requests_total counter
+http_requests_total{route="GET /a",status="404"} 2
+http_requests_total{route="GET /b",status="200"} 2
+http_requests_total{route="say \"hi\"\\\n",status="200"} 1
+# HELP latency_seconds Latency.\nIn seconds.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{le="0.1"} 2
+latency_seconds_bucket{le="1"} 3
+latency_seconds_bucket{le="+Inf"} 4
+latency_seconds_sum 3.65
+latency_seconds_count 4
+# HELP temperature Temperature.
+# TYPE temperature gauge
+temperature -1.5
+`
+	if got := scrape(t, reg); got != want {
+		t.Errorf("Exposition =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestCounterConcurrentAdd(t *testing.T) {
+	reg := NewRegistry()
+	counter := reg.NewCounter("hits_total", "Hits.")
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 1000 {
+				counter.With().Inc()
+			}
+		}()
+	}
+	wg.Wait()
+	if got := counter.With().Value(); got != 8000 {
+		t.Errorf("Value = %v, want 8000", got)
+	}
+}
+
+func TestRuntime(t *testing.T) {
+	reg := NewRegistry()
+	RegisterRuntime(reg)
+	out := scrape(t, reg)
+	for _, want := range []string{"\ngo_goroutines ", "\ngo_memstats_alloc_bytes ", "\ngo_gc_cycles_total ", `go_info{version="go`, "\nprocess_start_time_seconds "} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Runtime metrics are missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestRegisterAgain(t *testing.T) {
+	reg := NewRegistry()
+	hits := reg.NewCounter("hits_total", "Hits.", "route")
+	if again := reg.NewCounter("hits_total", "Hits.", "route"); again != hits {
+		t.Errorf("Registering hits_total again returned a new counter")
+	}
+	latency := reg.NewHistogram("latency_seconds", "Latency.", []float64{0.1, 1})
+	if again := reg.NewHistogram("latency_seconds", "Latency.", []float64{0.1, 1}); again != latency {
+		t.Errorf("Registering latency_seconds again returned a new histogram")
+	}
+	reg.GaugeFunc("temperature", "Temperature.", func() float64 { return 1 })
+	reg.GaugeFunc("temperature", "Temperature.", func() float64 { return 2 })
+	RegisterRuntime(reg)
+	RegisterRuntime(reg)
+
+	hits.With("/").Inc()
+	out := scrape(t, reg)
+	for _, header := range []string{"# TYPE hits_total", "# TYPE latency_seconds", "# TYPE temperature", "# TYPE go_goroutines"} {
+		if got := strings.Count(out, header); got != 1 {
+			t.Errorf("%q appears %d times, want 1:\n%s", header, got, out)
+		}
+	}
+	if !strings.Contains(out, "\ntemperature 1\n") {
+		t.Errorf("temperature did not keep the first fn:\n%s", out)
+	}
+}
+
+func TestRegisterTwicePanics(t *testing.T) {
+	reg := NewRegistry()
+	reg.NewCounter("hits_total", "Hits.")
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Registering hits_total twice did not panic")
+		}
+	}()
+	reg.NewHistogram("hits_total", "Hits.", DefaultBuckets)
+}
+
+func TestRegisterDifferentLabelsPanics(t *testing.T) {
+	reg := NewRegistry()
+	reg.NewCounter("hits_total", "Hits.", "route")
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Registering hits_total with other labels did not panic")
+		}
+	}()
+	reg.NewCounter("hits_total", "Hits.", "status")
+}
@@ -0,0 +1,50 @@
+package metrics
+
+import (
+	"bufio"
+	"runtime"
+	"time"
+)
+
+// runtimeMetrics reports on the Go runtime, reading its memory statistics
+// once per scrape.
+type runtimeMetrics struct {
+	start time.Time
+}
+
+// RegisterRuntime adds goroutine, memory and garbage collector metrics, and
+// the process start time. Registering them again keeps the first start
+// time.
+func RegisterRuntime(reg *Registry) {
+	reg.register(&runtimeMetrics{start: time.Now()})
+}
+
+func (m *runtimeMetrics) name() string { return "go_" }
+
+func (m *runtimeMetrics) describe() string { return "runtime" }
+
+func (m *runtimeMetrics) write(w *bufio.Writer) {
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+
+	for _, metric := range []struct {
+		name  string
+		help  string
+		kind  string
+		value float64
+	}{
+		{"go_goroutines", "Number of goroutines that currently exist.", "gauge", float64(runtime.NumGoroutine())},
+		{"go_memstats_alloc_bytes", "Bytes of allocated heap objects.", "gauge", float64(stats.HeapAlloc)},
+		{"go_memstats_heap_objects", "Number of allocated heap objects.", "gauge", float64(stats.HeapObjects)},
+		{"go_memstats_sys_bytes", "Bytes of memory obtained from the OS.", "gauge", float64(stats.Sys)},
+		{"go_memstats_mallocs_total", "Heap objects allocated.", "counter", float64(stats.Mallocs)},
+		{"go_gc_cycles_total", "Completed garbage collection cycles.", "counter", float64(stats.NumGC)},
+		{"go_gc_pause_seconds_total", "Time the world was stopped for garbage collection.", "counter", float64(stats.PauseTotalNs) / 1e9},
+		{"process_start_time_seconds", "Start time of the process since the Unix epoch in seconds.", "gauge", float64(m.start.UnixNano()) / 1e9},
+	} {
+		writeHeader(w, metric.name, metric.help, metric.kind)
+		writeSample(w, metric.name, nil, nil, "", "", metric.value)
+	}
+	writeHeader(w, "go_info", "Version of Go the binary was built with.", "gauge")
+	writeSample(w, "go_info", []string{"version"}, []string{runtime.Version()}, "", "", 1)
+}
@@ -0,0 +1,362 @@
+// Package migrate applies the goose annotated migrations in sql/schema and
+// sql/sqlite/schema. It keeps track of them in goose's goose_db_version
+// table, so databases that were migrated with the goose CLI pick up where
+// they left off and the two can be used side by side.
+//
+// A migration file is named <version>_<name>.sql and holds a
+// "-- +goose up" section and an optional "-- +goose down" section, each run
+// as a single batch of statements.
+package migrate
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"modernc.org/sqlite"
+)
+
+// ErrNoMigrations is returned by Down when nothing is applied.
+var ErrNoMigrations = errors.New("migrate: no migrations to roll back")
+
+// Dialect is the flavour of SQL the database speaks.
+type Dialect string
+
+const (
+	Postgres Dialect = "postgres"
+	SQLite   Dialect = "sqlite"
+)
+
+// DialectOf works out the dialect from the driver db was opened with.
+func DialectOf(db *sql.DB) (Dialect, error) {
+	switch db.Driver().(type) {
+	case *pq.Driver:
+		return Postgres, nil
+	case *sqlite.Driver:
+		return SQLite, nil
+	default:
+		return "", fmt.Errorf("migrate: unsupported driver %T", db.Driver())
+	}
+}
+
+// lockKey is the Postgres advisory lock held while migrating, so replicas
+// starting together take turns instead of racing each other.
+const lockKey = 7_261_842_033
+
+// dialectSQL holds the statements that differ between databases. SQLite
+// needs no lock statement as the store opens it with immediate
+// transactions, which take the write lock when they begin.
+var dialectSQL = map[Dialect]struct {
+	createTable string
+	tableExists string
+	lock        string
+}{
+	Postgres: {
+		createTable: `CREATE TABLE IF NOT EXISTS goose_db_version (
+  id integer PRIMARY KEY GENERATED BY DEFAULT AS IDENTITY,
+  version_id bigint NOT NULL,
+  is_applied boolean NOT NULL,
+  tstamp timestamp NOT NULL DEFAULT now()
+)`,
+		tableExists: "SELECT to_regclass('goose_db_version') IS NOT NULL",
+		lock:        fmt.Sprintf("SELECT pg_advisory_xact_lock(%d)", lockKey),
+	},
+	SQLite: {
+		createTable: `CREATE TABLE IF NOT EXISTS goose_db_version (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  version_id INTEGER NOT NULL,
+  is_applied INTEGER NOT NULL,
+  tstamp TIMESTAMP DEFAULT (datetime('now'))
+)`,
+		tableExists: "SELECT EXISTS (SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = 'goose_db_version')",
+	},
+}
+
+// Migration is one migration file.
+type Migration struct {
+	Version int64
+	// Name is the file name, for example 001_users.sql.
+	Name string
+	up   string
+	down string
+}
+
+// Status reports whether a migration has been applied, and when.
+type Status struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// Migrator applies a set of migrations to one database.
+type Migrator struct {
+	db         *sql.DB
+	dialect    Dialect
+	migrations []Migration
+}
+
+var (
+	fileName  = regexp.MustCompile(`^(\d+)_\w+\.sql$`)
+	gooseUp   = regexp.MustCompile(`(?im)^--\s*\+goose\s+up\b.*$`)
+	gooseDown = regexp.MustCompile(`(?im)^--\s*\+goose\s+down\b.*$`)
+	gooseNoTx = regexp.MustCompile(`(?im)^--\s*\+goose\s+no\s*transaction\b`)
+)
+
+// New reads the migrations at the top level of fsys. Files that are not
+// named like migrations are ignored.
+func New(db *sql.DB, fsys fs.FS) (*Migrator, error) {
+	dialect, err := DialectOf(db)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("migrate: reading migrations: %w", err)
+	}
+	m := &Migrator{db: db, dialect: dialect}
+	for _, entry := range entries {
+		match := fileName.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+		migration, err := parse(fsys, entry.Name(), match[1])
+		if err != nil {
+			return nil, err
+		}
+		m.migrations = append(m.migrations, migration)
+	}
+	if len(m.migrations) == 0 {
+		return nil, errors.New("migrate: no migrations found")
+	}
+
+	slices.SortFunc(m.migrations, func(a, b Migration) int { return cmp.Compare(a.Version, b.Version) })
+	for i := 1; i < len(m.migrations); i++ {
+		if m.migrations[i-1].Version == m.migrations[i].Version {
+			return nil, fmt.Errorf("migrate: %s and %s share version %d",
+				m.migrations[i-1].Name, m.migrations[i].Name, m.migrations[i].Version)
+		}
+	}
+	return m, nil
+}
+
+// parse splits a migration file into its up and down halves.
+func parse(fsys fs.FS, name, version string) (Migration, error) {
+	dat, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return Migration{}, fmt.Errorf("migrate: reading %s: %w", name, err)
+	}
+	migration := Migration{Name: path.Base(name)}
+	migration.Version, err = strconv.ParseInt(version, 10, 64)
+	if err != nil {
+		return Migration{}, fmt.Errorf("migrate: %s: bad version: %w", name, err)
+	}
+
+	text := string(dat)
+	if gooseNoTx.MatchString(text) {
+		// Everything is applied in one transaction, so statements that
+		// cannot run in one are not supported.
+		return Migration{}, fmt.Errorf("migrate: %s: +goose NO TRANSACTION is not supported", name)
+	}
+	upAt := gooseUp.FindStringIndex(text)
+	if upAt == nil {
+		return Migration{}, fmt.Errorf("migrate: %s: no +goose up section", name)
+	}
+	migration.up = text[upAt[1]:]
+	if downAt := gooseDown.FindStringIndex(migration.up); downAt != nil {
+		migration.down = strings.TrimSpace(migration.up[downAt[1]:])
+		migration.up = migration.up[:downAt[0]]
+	}
+	migration.up = strings.TrimSpace(migration.up)
+	return migration, nil
+}
+
+// Up applies every pending migration, oldest first, and returns those it
+// applied. They are applied in a single transaction holding the migration
+// lock, so either all of them are applied or none are.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var applied []Migration
+	err := m.inTx(ctx, func(tx *sql.Tx, statuses []Status) error {
+		for _, status := range statuses {
+			if status.Applied {
+				continue
+			}
+			if _, err := tx.ExecContext(ctx, status.up); err != nil {
+				return fmt.Errorf("migrate: applying %s: %w", status.Name, err)
+			}
+			if err := m.record(ctx, tx, status.Version, true); err != nil {
+				return err
+			}
+			applied = append(applied, status.Migration)
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// Down rolls back the most recently applied migration and returns it.
+func (m *Migrator) Down(ctx context.Context) (Migration, error) {
+	var rolledBack Migration
+	err := m.inTx(ctx, func(tx *sql.Tx, statuses []Status) error {
+		i := len(statuses) - 1
+		for i >= 0 && !statuses[i].Applied {
+			i--
+		}
+		if i < 0 {
+			return ErrNoMigrations
+		}
+		rolledBack = statuses[i].Migration
+		if rolledBack.down != "" {
+			if _, err := tx.ExecContext(ctx, rolledBack.down); err != nil {
+				return fmt.Errorf("migrate: rolling back %s: %w", rolledBack.Name, err)
+			}
+		}
+		return m.record(ctx, tx, rolledBack.Version, false)
+	})
+	return rolledBack, err
+}
+
+// Status reports every known migration, oldest first. It only reads, and
+// does not wait for a migration in progress.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	var exists bool
+	err := m.db.QueryRowContext(ctx, dialectSQL[m.dialect].tableExists).Scan(&exists)
+	if err != nil {
+		return nil, fmt.Errorf("migrate: looking for goose_db_version: %w", err)
+	}
+	if !exists {
+		statuses := make([]Status, 0, len(m.migrations))
+		for _, migration := range m.migrations {
+			statuses = append(statuses, Status{Migration: migration})
+		}
+		return statuses, nil
+	}
+	return m.statuses(ctx, m.db)
+}
+
+// Version is the newest applied migration, or 0 when none are.
+func (m *Migrator) Version(ctx context.Context) (int64, error) {
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return 0, err
+	}
+	var version int64
+	for _, status := range statuses {
+		if status.Applied {
+			version = status.Version
+		}
+	}
+	return version, nil
+}
+
+// Latest is the version of the newest known migration.
+func (m *Migrator) Latest() int64 {
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// inTx runs fn in a transaction holding the migration lock, passing it the
+// state of every migration as seen once the lock is held.
+func (m *Migrator) inTx(ctx context.Context, fn func(tx *sql.Tx, statuses []Status) error) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("migrate: %w", err)
+	}
+	defer tx.Rollback()
+
+	dialect := dialectSQL[m.dialect]
+	if dialect.lock != "" {
+		if _, err := tx.ExecContext(ctx, dialect.lock); err != nil {
+			return fmt.Errorf("migrate: taking the migration lock: %w", err)
+		}
+	}
+	if _, err := tx.ExecContext(ctx, dialect.createTable); err != nil {
+		return fmt.Errorf("migrate: creating goose_db_version: %w", err)
+	}
+	// Goose starts the table with a version 0 row and expects it there.
+	_, err = tx.ExecContext(ctx, `INSERT INTO goose_db_version (version_id, is_applied)
+SELECT 0, true WHERE NOT EXISTS (SELECT 1 FROM goose_db_version)`)
+	if err != nil {
+		return fmt.Errorf("migrate: creating goose_db_version: %w", err)
+	}
+	statuses, err := m.statuses(ctx, tx)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx, statuses); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("migrate: %w", err)
+	}
+	return nil
+}
+
+// querier is a *sql.DB or *sql.Tx.
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// statuses reads goose_db_version. Goose records a rollback either by
+// deleting the version's row or, in older releases, by adding one with
+// is_applied false, so the newest row for each version wins.
+func (m *Migrator) statuses(ctx context.Context, q querier) ([]Status, error) {
+	rows, err := q.QueryContext(ctx, "SELECT version_id, is_applied, tstamp FROM goose_db_version ORDER BY id DESC")
+	if err != nil {
+		return nil, fmt.Errorf("migrate: reading goose_db_version: %w", err)
+	}
+	defer rows.Close()
+
+	type row struct {
+		applied bool
+		at      time.Time
+	}
+	seen := map[int64]row{}
+	for rows.Next() {
+		var version int64
+		var r row
+		var at sql.NullTime
+		if err := rows.Scan(&version, &r.applied, &at); err != nil {
+			return nil, fmt.Errorf("migrate: reading goose_db_version: %w", err)
+		}
+		r.at = at.Time
+		if _, ok := seen[version]; !ok {
+			seen[version] = r
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("migrate: reading goose_db_version: %w", err)
+	}
+
+	statuses := make([]Status, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		r := seen[migration.Version]
+		statuses = append(statuses, Status{Migration: migration, Applied: r.applied, AppliedAt: r.at})
+	}
+	return statuses, nil
+}
+
+// record marks version as applied, or forgets it was, the way goose does.
+func (m *Migrator) record(ctx context.Context, tx *sql.Tx, version int64, applied bool) error {
+	var err error
+	if applied {
+		_, err = tx.ExecContext(ctx, fmt.Sprintf(
+			"INSERT INTO goose_db_version (version_id, is_applied) VALUES (%d, true)", version))
+	} else {
+		_, err = tx.ExecContext(ctx, fmt.Sprintf(
+			"DELETE FROM goose_db_version WHERE version_id = %d", version))
+	}
+	if err != nil {
+		return fmt.Errorf("migrate: recording version %d: %w", version, err)
+	}
+	return nil
+}
@@ -0,0 +1,187 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"testing/fstest"
+
+	"github.com/Senaphim/Chirpy/internal/store"
+)
+
+func openSQLite(t *testing.T, path string) *sql.DB {
+	t.Helper()
+	db, err := store.OpenSQLite(path)
+	if err != nil {
+		t.Fatalf("OpenSQLite error = %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func newMigrator(t *testing.T, db *sql.DB, fsys fstest.MapFS) *Migrator {
+	t.Helper()
+	m, err := New(db, fsys)
+	if err != nil {
+		t.Fatalf("New error = %v", err)
+	}
+	return m
+}
+
+func TestUpAndDown(t *testing.T) {
+	ctx := context.Background()
+	db := openSQLite(t, filepath.Join(t.TempDir(), "chirpy.db"))
+	m, err := New(db, os.DirFS("../../sql/sqlite/schema"))
+	if err != nil {
+		t.Fatalf("New error = %v", err)
+	}
+
+	if version, err := m.Version(ctx); err != nil || version != 0 {
+		t.Errorf("Version before migrating = %d, %v, want 0", version, err)
+	}
+	if _, err := db.Exec("SELECT * FROM goose_db_version"); err == nil {
+		t.Errorf("Reading the version created goose_db_version")
+	}
+
+	applied, err := m.Up(ctx)
+	if err != nil {
+		t.Fatalf("Up error = %v", err)
+	}
+	if len(applied) != len(m.migrations) {
+		t.Errorf("Up applied %d migrations, want %d", len(applied), len(m.migrations))
+	}
+	if applied, err := m.Up(ctx); err != nil || len(applied) != 0 {
+		t.Errorf("Second Up = %v, %v, want nothing applied", applied, err)
+	}
+	if version, err := m.Version(ctx); err != nil || version != m.Latest() {
+		t.Errorf("Version = %d, %v, want %d", version, err, m.Latest())
+	}
+
+	// Every down migration must undo its up migration cleanly.
+	for range m.migrations {
+		if _, err := m.Down(ctx); err != nil {
+			t.Fatalf("Down error = %v", err)
+		}
+	}
+	if _, err := m.Down(ctx); !errors.Is(err, ErrNoMigrations) {
+		t.Errorf("Down with nothing applied error = %v, want ErrNoMigrations", err)
+	}
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		t.Fatalf("Status error = %v", err)
+	}
+	for _, status := range statuses {
+		if status.Applied {
+			t.Errorf("%s is still applied after rolling everything back", status.Name)
+		}
+	}
+	if _, err := m.Up(ctx); err != nil {
+		t.Errorf("Up after rolling back error = %v", err)
+	}
+}
+
+func TestUpIsAllOrNothing(t *testing.T) {
+	ctx := context.Background()
+	db := openSQLite(t, filepath.Join(t.TempDir(), "chirpy.db"))
+	m := newMigrator(t, db, fstest.MapFS{
+		"001_good.sql": {Data: []byte("-- +goose Up\nCREATE TABLE good(id INTEGER);\n-- +goose Down\nDROP TABLE good;\n")},
+		"002_bad.sql":  {Data: []byte("-- +goose Up\nCREATE TABLE;\n")},
+	})
+
+	if _, err := m.Up(ctx); err == nil {
+		t.Fatalf("Up with a broken migration succeeded")
+	}
+	if version, err := m.Version(ctx); err != nil || version != 0 {
+		t.Errorf("Version = %d, %v, want 0", version, err)
+	}
+	if _, err := db.Exec("SELECT * FROM good"); err == nil {
+		t.Errorf("The migration before the broken one was kept")
+	}
+}
+
+func TestGooseVersionTable(t *testing.T) {
+	ctx := context.Background()
+	db := openSQLite(t, filepath.Join(t.TempDir(), "chirpy.db"))
+	m := newMigrator(t, db, fstest.MapFS{
+		"001_a.sql": {Data: []byte("-- +goose Up\nCREATE TABLE a(id INTEGER);\n")},
+		"002_b.sql": {Data: []byte("-- +goose Up\nCREATE TABLE b(id INTEGER);\n")},
+		"003_c.sql": {Data: []byte("-- +goose Up\nCREATE TABLE c(id INTEGER);\n")},
+	})
+	// As left by goose: 001 and 002 applied, then 002 rolled back by an
+	// older release that records rollbacks as rows rather than deleting.
+	_, err := db.Exec(dialectSQL[SQLite].createTable + `;
+CREATE TABLE a(id INTEGER);
+INSERT INTO goose_db_version (version_id, is_applied) VALUES (0, 1), (1, 1), (2, 1), (2, 0);`)
+	if err != nil {
+		t.Fatalf("Setting up goose_db_version error = %v", err)
+	}
+
+	applied, err := m.Up(ctx)
+	if err != nil {
+		t.Fatalf("Up error = %v", err)
+	}
+	if len(applied) != 2 || applied[0].Version != 2 || applied[1].Version != 3 {
+		t.Errorf("Up applied %+v, want versions 2 and 3", applied)
+	}
+}
+
+func TestConcurrentUp(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "chirpy.db")
+	fsys := os.DirFS("../../sql/sqlite/schema")
+	files, err := fs.Glob(fsys, "*.sql")
+	if err != nil {
+		t.Fatalf("Glob error = %v", err)
+	}
+
+	// Each replica has its own connection pool, as separate processes would.
+	var wg sync.WaitGroup
+	total := make([]int, 4)
+	for i := range total {
+		m, err := New(openSQLite(t, path), fsys)
+		if err != nil {
+			t.Fatalf("New error = %v", err)
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			applied, err := m.Up(context.Background())
+			if err != nil {
+				t.Errorf("Up error = %v", err)
+			}
+			total[i] = len(applied)
+		}()
+	}
+	wg.Wait()
+
+	sum := 0
+	for _, n := range total {
+		sum += n
+	}
+	if sum != len(files) {
+		t.Errorf("Replicas applied %v migrations between them, want %d in all", total, len(files))
+	}
+}
+
+func TestNewRejectsBadMigrations(t *testing.T) {
+	db := openSQLite(t, filepath.Join(t.TempDir(), "chirpy.db"))
+	tests := map[string]fstest.MapFS{
+		"no migrations": {"README.md": {Data: []byte("hi")}},
+		"no up section": {"001_a.sql": {Data: []byte("CREATE TABLE a(id INTEGER);\n")}},
+		"shared version": {
+			"001_a.sql": {Data: []byte("-- +goose Up\nCREATE TABLE a(id INTEGER);\n")},
+			"002_b.sql": {Data: []byte("-- +goose Up\nCREATE TABLE b(id INTEGER);\n")},
+			"1_c.sql":   {Data: []byte("-- +goose Up\nCREATE TABLE c(id INTEGER);\n")},
+		},
+		"no transaction": {"001_a.sql": {Data: []byte("-- +goose NO TRANSACTION\n-- +goose Up\nCREATE TABLE a(id INTEGER);\n")}},
+	}
+	for name, fsys := range tests {
+		if _, err := New(db, fsys); err == nil {
+			t.Errorf("New with %s succeeded", name)
+		}
+	}
+}
@@ -0,0 +1,318 @@
+package oidc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// jwksRefreshInterval limits how often an unknown kid can trigger a refetch of
+// the provider's keys.
+const jwksRefreshInterval time.Duration = time.Minute
+
+// Config identifies Chirpy to an external OpenID Connect provider.
+type Config struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+}
+
+type discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JwksURI               string `json:"jwks_uri"`
+}
+
+// Provider is a discovered OpenID Connect provider.
+type Provider struct {
+	config   Config
+	client   *http.Client
+	metadata discovery
+
+	mu          sync.Mutex
+	keys        map[string]any
+	keysFetched time.Time
+}
+
+// IDClaims are the ID token claims Chirpy relies on.
+type IDClaims struct {
+	jwt.RegisteredClaims
+	Nonce         string `json:"nonce"`
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+}
+
+// Discover fetches the provider metadata from the issuer's well-known
+// configuration document.
+func Discover(ctx context.Context, config Config, client *http.Client) (*Provider, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	wellKnown := strings.TrimSuffix(config.Issuer, "/") + "/.well-known/openid-configuration"
+	metadata := discovery{}
+	if err := getJSON(ctx, client, wellKnown, &metadata); err != nil {
+		fmtErr := fmt.Errorf("Error fetching provider metadata: %w", err)
+		return nil, fmtErr
+	}
+	if metadata.Issuer != config.Issuer {
+		return nil, fmt.Errorf("Provider issuer %q does not match %q", metadata.Issuer, config.Issuer)
+	}
+	if metadata.AuthorizationEndpoint == "" || metadata.TokenEndpoint == "" || metadata.JwksURI == "" {
+		return nil, errors.New("Provider metadata is missing required endpoints")
+	}
+
+	return &Provider{
+		config:   config,
+		client:   client,
+		metadata: metadata,
+		keys:     map[string]any{},
+	}, nil
+}
+
+func (p *Provider) Issuer() string {
+	return p.config.Issuer
+}
+
+// AuthCodeURL returns the provider URL the browser is sent to. The state,
+// nonce and PKCE challenge must be remembered for the callback.
+func (p *Provider) AuthCodeURL(state, nonce, codeChallenge string) string {
+	query := url.Values{}
+	query.Set("response_type", "code")
+	query.Set("client_id", p.config.ClientID)
+	query.Set("redirect_uri", p.config.RedirectURL)
+	query.Set("scope", "openid email")
+	query.Set("state", state)
+	query.Set("nonce", nonce)
+	query.Set("code_challenge", codeChallenge)
+	query.Set("code_challenge_method", "S256")
+
+	separator := "?"
+	if strings.Contains(p.metadata.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return p.metadata.AuthorizationEndpoint + separator + query.Encode()
+}
+
+// Exchange trades an authorization code for the provider's tokens and
+// returns the raw ID token.
+func (p *Provider) Exchange(ctx context.Context, code, codeVerifier string) (string, error) {
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.config.RedirectURL)
+	form.Set("code_verifier", codeVerifier)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.metadata.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(p.config.ClientID), url.QueryEscape(p.config.ClientSecret))
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		fmtErr := fmt.Errorf("Error calling token endpoint: %w", err)
+		return "", fmtErr
+	}
+	defer resp.Body.Close()
+
+	tokens := struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&tokens); err != nil {
+		fmtErr := fmt.Errorf("Error decoding token response: %w", err)
+		return "", fmtErr
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("Token endpoint returned %d: %s %s", resp.StatusCode, tokens.Error, tokens.ErrorDescription)
+	}
+	if tokens.IDToken == "" {
+		return "", errors.New("Token response has no id_token")
+	}
+
+	return tokens.IDToken, nil
+}
+
+// VerifyIDToken checks the signature of an ID token against the provider's
+// JWKS along with its issuer, audience, expiry and nonce.
+func (p *Provider) VerifyIDToken(ctx context.Context, rawIDToken, nonce string) (IDClaims, error) {
+	claims := IDClaims{}
+	_, err := jwt.ParseWithClaims(
+		rawIDToken,
+		&claims,
+		func(t *jwt.Token) (any, error) { return p.key(ctx, t) },
+		jwt.WithValidMethods([]string{"RS256", "ES256", "EdDSA"}),
+		jwt.WithIssuer(p.config.Issuer),
+		jwt.WithAudience(p.config.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(time.Minute),
+	)
+	if err != nil {
+		fmtErr := fmt.Errorf("Error validating ID token: %w", err)
+		return IDClaims{}, fmtErr
+	}
+
+	if claims.Nonce != nonce {
+		return IDClaims{}, errors.New("ID token nonce mismatch")
+	}
+	if claims.Subject == "" {
+		return IDClaims{}, errors.New("ID token has no subject")
+	}
+
+	return claims, nil
+}
+
+func (p *Provider) key(ctx context.Context, t *jwt.Token) (any, error) {
+	kid, _ := t.Header["kid"].(string)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+	// Unknown kids usually mean the provider rotated its keys.
+	if time.Since(p.keysFetched) < jwksRefreshInterval {
+		return nil, fmt.Errorf("Unknown key id %q", kid)
+	}
+
+	keys, err := p.fetchKeys(ctx)
+	p.keysFetched = time.Now()
+	if err != nil {
+		return nil, err
+	}
+	p.keys = keys
+
+	key, ok := p.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("Unknown key id %q", kid)
+	}
+	return key, nil
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (p *Provider) fetchKeys(ctx context.Context) (map[string]any, error) {
+	set := struct {
+		Keys []jwk `json:"keys"`
+	}{}
+	if err := getJSON(ctx, p.client, p.metadata.JwksURI, &set); err != nil {
+		fmtErr := fmt.Errorf("Error fetching provider keys: %w", err)
+		return nil, fmtErr
+	}
+
+	keys := map[string]any{}
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := parseJWK(k)
+		if err != nil {
+			// Skip key types we do not understand rather than failing
+			// every login.
+			continue
+		}
+		keys[k.Kid] = key
+	}
+
+	return keys, nil
+}
+
+func parseJWK(k jwk) (any, error) {
+	decode := base64.RawURLEncoding.DecodeString
+
+	switch k.Kty {
+	case "RSA":
+		n, err := decode(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decode(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}, nil
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, fmt.Errorf("Unsupported curve %q", k.Crv)
+		}
+		x, err := decode(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decode(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("Unsupported curve %q", k.Crv)
+		}
+		x, err := decode(k.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("Bad Ed25519 key length")
+		}
+		return ed25519.PublicKey(x), nil
+	}
+
+	return nil, fmt.Errorf("Unsupported key type %q", k.Kty)
+}
+
+func getJSON(ctx context.Context, client *http.Client, target string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %d", target, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
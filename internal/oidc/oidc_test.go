@@ -0,0 +1,128 @@
+package oidc_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/Senaphim/Chirpy/internal/oidc"
+	"github.com/Senaphim/Chirpy/internal/oidc/oidctest"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const verifier = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+
+func setup(t *testing.T) (*oidctest.Provider, *oidc.Provider) {
+	t.Helper()
+	stub, err := oidctest.NewProvider("chirpy", "hunter2")
+	if err != nil {
+		t.Fatalf("NewProvider error = %v", err)
+	}
+	t.Cleanup(stub.Close)
+
+	config := oidc.Config{
+		Issuer:       stub.Issuer(),
+		ClientID:     "chirpy",
+		ClientSecret: "hunter2",
+		RedirectURL:  "http://localhost:8080/api/oidc/callback",
+	}
+	provider, err := oidc.Discover(context.Background(), config, nil)
+	if err != nil {
+		t.Fatalf("Discover error = %v", err)
+	}
+	return stub, provider
+}
+
+func authorize(t *testing.T, provider *oidc.Provider, state, nonce string) string {
+	t.Helper()
+	sum := sha256.Sum256([]byte(verifier))
+	challenge := base64.RawURLEncoding.EncodeToString(sum[:])
+
+	client := &http.Client{
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+	resp, err := client.Get(provider.AuthCodeURL(state, nonce, challenge))
+	if err != nil {
+		t.Fatalf("authorize request error = %v", err)
+	}
+	resp.Body.Close()
+
+	location, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatalf("bad redirect %v", err)
+	}
+	if location.Query().Get("state") != state {
+		t.Errorf("state = %v, want %v", location.Query().Get("state"), state)
+	}
+	return location.Query().Get("code")
+}
+
+func TestLoginFlow(t *testing.T) {
+	stub, provider := setup(t)
+	stub.SetUser(oidctest.User{Subject: "42", Email: "sso@example.com", EmailVerified: true})
+	ctx := context.Background()
+
+	code := authorize(t, provider, "the-state", "the-nonce")
+	idToken, err := provider.Exchange(ctx, code, verifier)
+	if err != nil {
+		t.Fatalf("Exchange error = %v", err)
+	}
+
+	claims, err := provider.VerifyIDToken(ctx, idToken, "the-nonce")
+	if err != nil {
+		t.Fatalf("VerifyIDToken error = %v", err)
+	}
+	if claims.Subject != "42" || claims.Email != "sso@example.com" || !claims.EmailVerified {
+		t.Errorf("VerifyIDToken claims = %+v", claims)
+	}
+
+	if _, err := provider.VerifyIDToken(ctx, idToken, "other-nonce"); err == nil {
+		t.Errorf("VerifyIDToken accepted the wrong nonce")
+	}
+	if _, err := provider.Exchange(ctx, code, verifier); err == nil {
+		t.Errorf("Exchange accepted a code twice")
+	}
+}
+
+func TestVerifyIDTokenRejectsBadClaims(t *testing.T) {
+	stub, provider := setup(t)
+	ctx := context.Background()
+
+	base := func() jwt.MapClaims {
+		return jwt.MapClaims{
+			"iss":   stub.Issuer(),
+			"aud":   "chirpy",
+			"sub":   "42",
+			"nonce": "n",
+			"exp":   time.Now().Add(time.Minute).Unix(),
+		}
+	}
+
+	tests := []struct {
+		name   string
+		modify func(jwt.MapClaims)
+	}{
+		{name: "Wrong audience", modify: func(c jwt.MapClaims) { c["aud"] = "someone-else" }},
+		{name: "Wrong issuer", modify: func(c jwt.MapClaims) { c["iss"] = "https://evil.example" }},
+		{name: "Expired", modify: func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Hour).Unix() }},
+		{name: "No expiry", modify: func(c jwt.MapClaims) { delete(c, "exp") }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := base()
+			tt.modify(claims)
+			token, err := stub.SignIDToken(claims)
+			if err != nil {
+				t.Fatalf("SignIDToken error = %v", err)
+			}
+			if _, err := provider.VerifyIDToken(ctx, token, "n"); err == nil {
+				t.Errorf("VerifyIDToken accepted a token with bad claims")
+			}
+		})
+	}
+}
@@ -0,0 +1,309 @@
+// Package openapi reads the subset of OpenAPI 3 that Chirpy's specification
+// uses, and checks request and response bodies against its schemas. It is
+// not a general purpose validator: only the keywords used by the spec are
+// understood, and anything else is ignored.
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Document struct {
+	OpenAPI    string                           `json:"openapi"`
+	Paths      map[string]map[string]*Operation `json:"paths"`
+	Components struct {
+		Schemas map[string]*Schema `json:"schemas"`
+	} `json:"components"`
+}
+
+type Operation struct {
+	OperationID string               `json:"operationId"`
+	Deprecated  bool                 `json:"deprecated"`
+	RequestBody *Body                `json:"requestBody"`
+	Responses   map[string]*Response `json:"responses"`
+}
+
+type Body struct {
+	Required bool                  `json:"required"`
+	Content  map[string]*MediaType `json:"content"`
+}
+
+type Response struct {
+	Content map[string]*MediaType `json:"content"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+type Schema struct {
+	Ref                  string             `json:"$ref"`
+	Type                 string             `json:"type"`
+	Format               string             `json:"format"`
+	Nullable             bool               `json:"nullable"`
+	Enum                 []any              `json:"enum"`
+	Properties           map[string]*Schema `json:"properties"`
+	Required             []string           `json:"required"`
+	AdditionalProperties *bool              `json:"additionalProperties"`
+	Items                *Schema            `json:"items"`
+	OneOf                []*Schema          `json:"oneOf"`
+	MinLength            *int               `json:"minLength"`
+	MaxLength            *int               `json:"maxLength"`
+}
+
+// Parse reads a JSON encoded specification.
+func Parse(data []byte) (*Document, error) {
+	doc := &Document{}
+	if err := json.Unmarshal(data, doc); err != nil {
+		fmtErr := fmt.Errorf("Error parsing OpenAPI document: %w", err)
+		return nil, fmtErr
+	}
+	if !strings.HasPrefix(doc.OpenAPI, "3.") {
+		return nil, fmt.Errorf("Unsupported OpenAPI version %q", doc.OpenAPI)
+	}
+	return doc, nil
+}
+
+// Operation finds the operation for a method and a templated path as written
+// in the specification, such as /api/v1/chirps/{chirpID}.
+func (d *Document) Operation(method, path string) (*Operation, error) {
+	item, ok := d.Paths[path]
+	if !ok {
+		return nil, fmt.Errorf("Path %s is not documented", path)
+	}
+	op, ok := item[strings.ToLower(method)]
+	if !ok {
+		return nil, fmt.Errorf("%s %s is not documented", method, path)
+	}
+	return op, nil
+}
+
+// Routes lists every documented operation as "METHOD /path".
+func (d *Document) Routes() []string {
+	routes := []string{}
+	for path, item := range d.Paths {
+		for method := range item {
+			routes = append(routes, strings.ToUpper(method)+" "+path)
+		}
+	}
+	sort.Strings(routes)
+	return routes
+}
+
+// ValidateRequest checks a request body against the operation.
+func (d *Document) ValidateRequest(method, path, contentType string, body []byte) error {
+	op, err := d.Operation(method, path)
+	if err != nil {
+		return err
+	}
+	if op.RequestBody == nil {
+		if len(body) > 0 {
+			return fmt.Errorf("%s %s takes no request body", method, path)
+		}
+		return nil
+	}
+	if len(body) == 0 {
+		if op.RequestBody.Required {
+			return fmt.Errorf("%s %s requires a request body", method, path)
+		}
+		return nil
+	}
+
+	return d.validateContent(op.RequestBody.Content, contentType, body)
+}
+
+// ValidateResponse checks that status is documented for the operation and
+// that the body matches its schema.
+func (d *Document) ValidateResponse(method, path string, status int, contentType string, body []byte) error {
+	op, err := d.Operation(method, path)
+	if err != nil {
+		return err
+	}
+	resp, ok := op.Responses[strconv.Itoa(status)]
+	if !ok {
+		resp, ok = op.Responses["default"]
+	}
+	if !ok {
+		return fmt.Errorf("%s %s does not document status %d", method, path, status)
+	}
+	if len(resp.Content) == 0 {
+		if len(body) > 0 {
+			return fmt.Errorf("%s %s status %d documents no body", method, path, status)
+		}
+		return nil
+	}
+
+	return d.validateContent(resp.Content, contentType, body)
+}
+
+func (d *Document) validateContent(content map[string]*MediaType, contentType string, body []byte) error {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return fmt.Errorf("Bad content type %q", contentType)
+	}
+	media, ok := content[mediaType]
+	if !ok {
+		return fmt.Errorf("Content type %s is not documented", mediaType)
+	}
+	if media.Schema == nil || mediaType != "application/json" {
+		return nil
+	}
+
+	var value any
+	if err := json.Unmarshal(body, &value); err != nil {
+		fmtErr := fmt.Errorf("Body is not JSON: %w", err)
+		return fmtErr
+	}
+	return d.Validate(media.Schema, value)
+}
+
+// Validate checks a decoded JSON value against a schema.
+func (d *Document) Validate(schema *Schema, value any) error {
+	return d.validate(schema, value, "$")
+}
+
+func (d *Document) resolve(schema *Schema) (*Schema, error) {
+	for schema.Ref != "" {
+		name, ok := strings.CutPrefix(schema.Ref, "#/components/schemas/")
+		if !ok {
+			return nil, fmt.Errorf("Unsupported reference %s", schema.Ref)
+		}
+		next, ok := d.Components.Schemas[name]
+		if !ok {
+			return nil, fmt.Errorf("Unknown schema %s", name)
+		}
+		schema = next
+	}
+	return schema, nil
+}
+
+func (d *Document) validate(schema *Schema, value any, at string) error {
+	schema, err := d.resolve(schema)
+	if err != nil {
+		return err
+	}
+
+	if value == nil {
+		if schema.Nullable {
+			return nil
+		}
+		return fmt.Errorf("%s: null is not allowed", at)
+	}
+
+	if len(schema.OneOf) > 0 {
+		matches := 0
+		for _, option := range schema.OneOf {
+			if d.validate(option, value, at) == nil {
+				matches++
+			}
+		}
+		if matches != 1 {
+			return fmt.Errorf("%s: matches %d of the oneOf schemas, want 1", at, matches)
+		}
+		return nil
+	}
+
+	if len(schema.Enum) > 0 && !slices.Contains(schema.Enum, value) {
+		return fmt.Errorf("%s: %v is not one of %v", at, value, schema.Enum)
+	}
+
+	switch schema.Type {
+	case "object":
+		obj, ok := value.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s: want object, got %T", at, value)
+		}
+		for _, name := range schema.Required {
+			if _, ok := obj[name]; !ok {
+				return fmt.Errorf("%s: missing required property %s", at, name)
+			}
+		}
+		for name, propValue := range obj {
+			prop, ok := schema.Properties[name]
+			if !ok {
+				if schema.AdditionalProperties != nil && !*schema.AdditionalProperties {
+					return fmt.Errorf("%s: unexpected property %s", at, name)
+				}
+				continue
+			}
+			if err := d.validate(prop, propValue, at+"."+name); err != nil {
+				return err
+			}
+		}
+	case "array":
+		arr, ok := value.([]any)
+		if !ok {
+			return fmt.Errorf("%s: want array, got %T", at, value)
+		}
+		if schema.Items != nil {
+			for i, item := range arr {
+				if err := d.validate(schema.Items, item, fmt.Sprintf("%s[%d]", at, i)); err != nil {
+					return err
+				}
+			}
+		}
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("%s: want string, got %T", at, value)
+		}
+		if err := checkFormat(schema.Format, str); err != nil {
+			return fmt.Errorf("%s: %w", at, err)
+		}
+		if schema.MinLength != nil && len([]rune(str)) < *schema.MinLength {
+			return fmt.Errorf("%s: shorter than %d", at, *schema.MinLength)
+		}
+		if schema.MaxLength != nil && len([]rune(str)) > *schema.MaxLength {
+			return fmt.Errorf("%s: longer than %d", at, *schema.MaxLength)
+		}
+	case "integer":
+		num, ok := value.(float64)
+		if !ok || num != float64(int64(num)) {
+			return fmt.Errorf("%s: want integer, got %v", at, value)
+		}
+	case "number":
+		if _, ok := value.(float64); !ok {
+			return fmt.Errorf("%s: want number, got %T", at, value)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("%s: want boolean, got %T", at, value)
+		}
+	case "":
+		// Any value is allowed.
+	default:
+		return fmt.Errorf("%s: unsupported schema type %s", at, schema.Type)
+	}
+
+	return nil
+}
+
+var emailPattern = regexp.MustCompile(`^[^@\s]+@[^@\s]+$`)
+
+func checkFormat(format, value string) error {
+	switch format {
+	case "uuid":
+		if _, err := uuid.Parse(value); err != nil {
+			return fmt.Errorf("%q is not a uuid", value)
+		}
+	case "date-time":
+		if _, err := time.Parse(time.RFC3339Nano, value); err != nil {
+			return fmt.Errorf("%q is not a date-time", value)
+		}
+	case "email":
+		if !emailPattern.MatchString(value) {
+			return fmt.Errorf("%q is not an email", value)
+		}
+	}
+	return nil
+}
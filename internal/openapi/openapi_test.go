@@ -0,0 +1,177 @@
+package openapi
+
+import (
+	"reflect"
+	"testing"
+)
+
+const spec = `{
+  "openapi": "3.0.3",
+  "paths": {
+    "/things/{id}": {
+      "get": {
+        "responses": {
+          "200": {
+            "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Thing"}}}
+          },
+          "204": {}
+        }
+      },
+      "put": {
+        "requestBody": {
+          "required": true,
+          "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Thing"}}}
+        },
+        "responses": {"204": {}}
+      }
+    }
+  },
+  "components": {
+    "schemas": {
+      "Thing": {
+        "type": "object",
+        "additionalProperties": false,
+        "required": ["id", "name"],
+        "properties": {
+          "id": {"type": "string", "format": "uuid"},
+          "name": {"type": "string", "maxLength": 5},
+          "kind": {"type": "string", "enum": ["a", "b"]},
+          "count": {"type": "integer"},
+          "seen_at": {"type": "string", "format": "date-time", "nullable": true},
+          "tags": {"type": "array", "items": {"type": "string"}}
+        }
+      }
+    }
+  }
+}`
+
+func TestValidateResponse(t *testing.T) {
+	doc, err := Parse([]byte(spec))
+	if err != nil {
+		t.Fatalf("Parse error = %v", err)
+	}
+
+	const id = `"7c6a1d4c-5b8e-4b8e-9f5e-2f1d8c3b6a90"`
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		wantErr bool
+	}{
+		{name: "Valid", status: 200, body: `{"id":` + id + `,"name":"bob","seen_at":null,"tags":["x"]}`},
+		{name: "Date time", status: 200, body: `{"id":` + id + `,"name":"bob","seen_at":"2025-01-02T03:04:05.123456+01:00"}`},
+		{name: "Missing required", status: 200, body: `{"id":` + id + `}`, wantErr: true},
+		{name: "Unexpected property", status: 200, body: `{"id":` + id + `,"name":"bob","extra":1}`, wantErr: true},
+		{name: "Bad format", status: 200, body: `{"id":"nope","name":"bob"}`, wantErr: true},
+		{name: "Too long", status: 200, body: `{"id":` + id + `,"name":"roberta"}`, wantErr: true},
+		{name: "Not in enum", status: 200, body: `{"id":` + id + `,"name":"bob","kind":"c"}`, wantErr: true},
+		{name: "Not an integer", status: 200, body: `{"id":` + id + `,"name":"bob","count":1.5}`, wantErr: true},
+		{name: "Wrong item type", status: 200, body: `{"id":` + id + `,"name":"bob","tags":[1]}`, wantErr: true},
+		{name: "No content", status: 204, body: ``},
+		{name: "Undocumented status", status: 500, body: ``, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := doc.ValidateResponse("GET", "/things/{id}", tt.status, "application/json", []byte(tt.body))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateResponse error = %v, want err %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateRequest(t *testing.T) {
+	doc, err := Parse([]byte(spec))
+	if err != nil {
+		t.Fatalf("Parse error = %v", err)
+	}
+
+	if err := doc.ValidateRequest("PUT", "/things/{id}", "application/json", nil); err == nil {
+		t.Errorf("ValidateRequest accepted a missing required body")
+	}
+	if err := doc.ValidateRequest("PUT", "/things/{id}", "text/plain", []byte("hi")); err == nil {
+		t.Errorf("ValidateRequest accepted an undocumented content type")
+	}
+	if err := doc.ValidateRequest("DELETE", "/things/{id}", "", nil); err == nil {
+		t.Errorf("ValidateRequest accepted an undocumented method")
+	}
+	if got := doc.Routes(); len(got) != 2 || got[0] != "GET /things/{id}" {
+		t.Errorf("Routes = %v", got)
+	}
+}
+
+func TestValidateRequestType(t *testing.T) {
+	doc, err := Parse([]byte(spec))
+	if err != nil {
+		t.Fatalf("Parse error = %v", err)
+	}
+
+	type thing struct {
+		Id     string   `json:"id" validate:"required"`
+		Name   string   `json:"name" validate:"required,max=5"`
+		Kind   string   `json:"kind"`
+		Count  int      `json:"count"`
+		SeenAt *string  `json:"seen_at"`
+		Tags   []string `json:"tags"`
+	}
+	type missingField struct {
+		Id   string `json:"id" validate:"required"`
+		Name string `json:"name" validate:"required,max=5"`
+	}
+	type extraField struct {
+		Id     string   `json:"id" validate:"required"`
+		Name   string   `json:"name" validate:"required,max=5"`
+		Kind   string   `json:"kind"`
+		Count  int      `json:"count"`
+		SeenAt *string  `json:"seen_at"`
+		Tags   []string `json:"tags"`
+		Colour string   `json:"colour"`
+	}
+	type optionalName struct {
+		Id     string   `json:"id" validate:"required"`
+		Name   string   `json:"name" validate:"max=5"`
+		Kind   string   `json:"kind"`
+		Count  int      `json:"count"`
+		SeenAt *string  `json:"seen_at"`
+		Tags   []string `json:"tags"`
+	}
+	type wrongLength struct {
+		Id     string   `json:"id" validate:"required"`
+		Name   string   `json:"name" validate:"required,max=6"`
+		Kind   string   `json:"kind"`
+		Count  int      `json:"count"`
+		SeenAt *string  `json:"seen_at"`
+		Tags   []string `json:"tags"`
+	}
+	type wrongType struct {
+		Id     string   `json:"id" validate:"required"`
+		Name   string   `json:"name" validate:"required,max=5"`
+		Kind   string   `json:"kind"`
+		Count  string   `json:"count"`
+		SeenAt *string  `json:"seen_at"`
+		Tags   []string `json:"tags"`
+	}
+
+	tests := []struct {
+		name    string
+		value   any
+		wantErr bool
+	}{
+		{name: "Matches", value: thing{}},
+		{name: "Undecoded property", value: missingField{}, wantErr: true},
+		{name: "Undocumented field", value: extraField{}, wantErr: true},
+		{name: "Required differs", value: optionalName{}, wantErr: true},
+		{name: "Max length differs", value: wrongLength{}, wantErr: true},
+		{name: "Type differs", value: wrongType{}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := doc.ValidateRequestType("PUT", "/things/{id}", reflect.TypeOf(tt.value), true)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateRequestType error = %v, want err %v", err, tt.wantErr)
+			}
+		})
+	}
+}
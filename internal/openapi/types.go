@@ -0,0 +1,143 @@
+package openapi
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ValidateRequestType checks that the operation's JSON request body
+// documents exactly what a handler decodes into typ: the same properties,
+// types and required fields, and the formats and lengths from its validate
+// tags. strict says the handler rejects unknown fields, which the schema
+// must forbid too.
+func (d *Document) ValidateRequestType(method, path string, typ reflect.Type, strict bool) error {
+	op, err := d.Operation(method, path)
+	if err != nil {
+		return err
+	}
+	if op.RequestBody == nil {
+		return fmt.Errorf("%s %s documents no request body", method, path)
+	}
+	media, ok := op.RequestBody.Content["application/json"]
+	if !ok || media.Schema == nil {
+		return fmt.Errorf("%s %s documents no JSON request body", method, path)
+	}
+	return d.checkType(media.Schema, typ, strict, "$")
+}
+
+func (d *Document) checkType(schema *Schema, typ reflect.Type, strict bool, at string) error {
+	schema, err := d.resolve(schema)
+	if err != nil {
+		return err
+	}
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+
+	want := ""
+	switch typ.Kind() {
+	case reflect.Struct:
+		want = "object"
+	case reflect.Slice, reflect.Array:
+		want = "array"
+	case reflect.String:
+		want = "string"
+	case reflect.Bool:
+		want = "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		want = "integer"
+	case reflect.Float32, reflect.Float64:
+		want = "number"
+	default:
+		return fmt.Errorf("%s: unsupported Go type %s", at, typ)
+	}
+	if schema.Type != want {
+		return fmt.Errorf("%s: documented as %q, decoded as %s", at, schema.Type, typ)
+	}
+
+	switch typ.Kind() {
+	case reflect.Slice, reflect.Array:
+		if schema.Items == nil {
+			return fmt.Errorf("%s: array has no items schema", at)
+		}
+		return d.checkType(schema.Items, typ.Elem(), strict, at+"[]")
+	case reflect.Struct:
+		return d.checkStruct(schema, typ, strict, at)
+	}
+	return nil
+}
+
+func (d *Document) checkStruct(schema *Schema, typ reflect.Type, strict bool, at string) error {
+	if strict && (schema.AdditionalProperties == nil || *schema.AdditionalProperties) {
+		return fmt.Errorf("%s: unknown fields are rejected, but additionalProperties is not false", at)
+	}
+
+	fields := map[string]bool{}
+	required := []string{}
+	for i := range typ.NumField() {
+		field := typ.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if !field.IsExported() || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fields[name] = true
+		fieldAt := at + "." + name
+
+		prop, ok := schema.Properties[name]
+		if !ok {
+			return fmt.Errorf("%s: decoded but not documented", fieldAt)
+		}
+		if err := d.checkType(prop, field.Type, strict, fieldAt); err != nil {
+			return err
+		}
+		prop, err := d.resolve(prop)
+		if err != nil {
+			return err
+		}
+
+		rules := strings.Split(field.Tag.Get("validate"), ",")
+		if slices.Contains(rules, "required") {
+			required = append(required, name)
+		}
+		if field.Type.Kind() != reflect.String {
+			continue
+		}
+		if email := slices.Contains(rules, "email"); email != (prop.Format == "email") {
+			return fmt.Errorf("%s: validate email is %t, but format is %q", fieldAt, email, prop.Format)
+		}
+		maxLength := -1
+		for _, rule := range rules {
+			if limit, ok := strings.CutPrefix(rule, "max="); ok {
+				if maxLength, err = strconv.Atoi(limit); err != nil {
+					return fmt.Errorf("%s: bad validate rule %q", fieldAt, rule)
+				}
+			}
+		}
+		documented := -1
+		if prop.MaxLength != nil {
+			documented = *prop.MaxLength
+		}
+		if maxLength != documented {
+			return fmt.Errorf("%s: validate max is %d, but maxLength is %d", fieldAt, maxLength, documented)
+		}
+	}
+
+	for name := range schema.Properties {
+		if !fields[name] {
+			return fmt.Errorf("%s.%s: documented but not decoded", at, name)
+		}
+	}
+	documentedRequired := slices.Sorted(slices.Values(schema.Required))
+	slices.Sort(required)
+	if !slices.Equal(required, documentedRequired) {
+		return fmt.Errorf("%s: required fields are %v, but the schema requires %v", at, required, documentedRequired)
+	}
+	return nil
+}
@@ -0,0 +1,345 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/Senaphim/Chirpy/internal/auth"
+	"github.com/Senaphim/Chirpy/internal/database"
+)
+
+const (
+	adminSessionCookie    string        = "chirpy_admin"
+	adminSessionExpiresIn time.Duration = time.Hour
+	// dashboardDays is how far back the per day tables go.
+	dashboardDays int = 30
+	// dashboardTopPosters is how many of the most prolific users are listed.
+	dashboardTopPosters int32 = 10
+	// dashboardReports is how many of the latest reports are listed.
+	dashboardReports int32 = 20
+)
+
+var (
+	errAdminSession = errors.New("Missing or invalid admin session")
+	errNotAdmin     = errors.New("User is not an admin")
+)
+
+var adminLoginTemplate = template.Must(template.New("admin_login").Parse(`<html>
+  <body>
+    <h1>Chirpy Admin</h1>
+    {{if .Error}}<p><strong>{{.Error}}</strong></p>{{end}}
+    <form method="POST" action="/admin/login">
+      <p><label>Email <input type="email" name="email" value="{{.Email}}"></label></p>
+      <p><label>Password <input type="password" name="password"></label></p>
+      <p><label>Authenticator code (if enabled) <input type="text" name="totp_code" autocomplete="one-time-code"></label></p>
+      <button type="submit">Log in</button>
+    </form>
+  </body>
+</html>
+`))
+
+var adminDashboardTemplate = template.Must(template.New("admin_dashboard").Parse(`<html>
+  <body>
+    <h1>Welcome, Chirpy Admin</h1>
+    <form method="POST" action="/admin/logout">
+      <p>Logged in as {{.Admin}} <button type="submit">Log out</button></p>
+    </form>
+    <p>Chirpy has been visited {{.Hits}} times!</p>
+    <h2>Totals</h2>
+    <table>
+      <tr><th>Users</th><td>{{.Users}}</td></tr>
+      <tr><th>Chirpy Red subscribers</th><td>{{.ChirpyRed}}</td></tr>
+      <tr><th>Chirps</th><td>{{.Chirps}}</td></tr>
+    </table>
+    <h2>Signups per day since {{.Since}}</h2>
+    <table>
+      {{range .Signups}}<tr><td>{{.Day}}</td><td>{{.Count}}</td></tr>
+      {{else}}<tr><td>None</td></tr>
+      {{end}}
+    </table>
+    <h2>Chirps per day since {{.Since}}</h2>
+    <table>
+      {{range .ChirpsPerDay}}<tr><td>{{.Day}}</td><td>{{.Count}}</td></tr>
+      {{else}}<tr><td>None</td></tr>
+      {{end}}
+    </table>
+    <h2>Top posters</h2>
+    <table>
+      {{range .TopPosters}}<tr><td>{{.Email}}</td><td>{{.ChirpCount}}</td></tr>
+      {{else}}<tr><td>None</td></tr>
+      {{end}}
+    </table>
+    <h2>Recent reports</h2>
+    <table>
+      {{range .Reports}}<tr><td>{{.CreatedAt.Format "2006-01-02 15:04:05"}}</td><td>{{.ReporterEmail}}</td><td>{{.Body}}</td><td>{{.Reason}}</td></tr>
+      {{else}}<tr><td>None</td></tr>
+      {{end}}
+    </table>
+    <h2>Recent errors</h2>
+    <table>
+      {{range .Errors}}<tr><td>{{.Time.Format "2006-01-02 15:04:05"}}</td><td>{{.Route}}</td><td>{{.Message}}</td><td>{{.Error}}</td><td>{{.RequestID}}</td></tr>
+      {{else}}<tr><td>None</td></tr>
+      {{end}}
+    </table>
+    <h2>Deprecated API usage</h2>
+    <ul>
+      {{range .Legacy}}<li>{{.Pattern}}: {{.Hits}}</li>
+      {{end}}
+    </ul>
+  </body>
+</html>
+`))
+
+var adminErrorTemplate = template.Must(template.New("admin_error").Parse(`<html>
+  <body>
+    <h1>Chirpy Admin</h1>
+    <p>{{.}}</p>
+  </body>
+</html>
+`))
+
+// helperRenderAdmin writes one of the admin pages, which must never be
+// cached or framed.
+func helperRenderAdmin(w http.ResponseWriter, r *http.Request, status int, tmpl *template.Template, data any) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.Header().Set("Content-Security-Policy", "frame-ancestors 'none'")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	err := tmpl.Execute(w, data)
+	if err != nil {
+		requestLogger(r).Error("Error rendering admin page", "page", tmpl.Name(), "error", err)
+	}
+}
+
+func helperRenderAdminLogin(w http.ResponseWriter, r *http.Request, status int, email string, message string) {
+	data := struct {
+		Email string
+		Error string
+	}{
+		Email: email,
+		Error: message,
+	}
+	helperRenderAdmin(w, r, status, adminLoginTemplate, data)
+}
+
+// helperAdminUser resolves the admin behind the session cookie. Anything
+// short of a valid session for a current admin wraps errAdminSession or
+// errNotAdmin.
+func (cfg *apiConfig) helperAdminUser(r *http.Request) (database.User, error) {
+	cookie, err := r.Cookie(adminSessionCookie)
+	if err != nil {
+		return database.User{}, fmt.Errorf("%w: %w", errAdminSession, err)
+	}
+	// Only sessions from /admin/login are accepted, not API access tokens.
+	userId, err := auth.ValidateAdminJWT(cookie.Value, cfg.keys)
+	if err != nil {
+		return database.User{}, fmt.Errorf("%w: %w", errAdminSession, err)
+	}
+	setRequestUser(r, userId)
+
+	dbUsr, err := cfg.store.GetUserById(r.Context(), userId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.User{}, fmt.Errorf("%w: user no longer exists", errAdminSession)
+	}
+	if err != nil {
+		return database.User{}, err
+	}
+	if !dbUsr.IsAdmin {
+		return database.User{}, errNotAdmin
+	}
+	return dbUsr, nil
+}
+
+func (cfg *apiConfig) handleAdminLoginPage(w http.ResponseWriter, r *http.Request) {
+	helperRenderAdminLogin(w, r, http.StatusOK, "", "")
+}
+
+func (cfg *apiConfig) handleAdminLogin(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		requestLogger(r).Info("Error parsing admin login form", "error", err)
+		helperRenderAdmin(w, r, http.StatusBadRequest, adminErrorTemplate, "The form could not be read.")
+		return
+	}
+
+	email := r.PostForm.Get("email")
+	dbUsr, err := cfg.store.GetUserByEmail(r.Context(), email)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		requestLogger(r).Error("Error fetching user from email", "error", err)
+		helperRenderAdmin(w, r, http.StatusInternalServerError, adminErrorTemplate, "Something went wrong.")
+		return
+	}
+	if err == nil {
+		err = cfg.checkPasswordHash(r.Context(), r.PostForm.Get("password"), dbUsr.HashedPassword)
+	}
+	if err != nil {
+		requestLogger(r).Info("Bad credentials on admin login", "error", err)
+		cfg.metrics.observeLogin(loginAdmin, loginFailure)
+		helperRenderAdminLogin(w, r, http.StatusUnauthorized, email, "Incorrect email or password")
+		return
+	}
+	setRequestUser(r, dbUsr.ID)
+	if dbUsr.TotpEnabled {
+		ok, err := cfg.helperCheckTOTP(r.Context(), dbUsr, r.PostForm.Get("totp_code"))
+		if errors.Is(err, errTotpLocked) {
+			requestLogger(r).Info("Authenticator codes locked out on admin login")
+			cfg.metrics.observeLogin(loginAdmin, loginFailure)
+			helperRenderAdminLogin(w, r, http.StatusTooManyRequests, email, "Too many incorrect authenticator codes, try again later")
+			return
+		}
+		if err != nil {
+			requestLogger(r).Error("Error checking authenticator code", "error", err)
+			helperRenderAdmin(w, r, http.StatusInternalServerError, adminErrorTemplate, "Something went wrong.")
+			return
+		}
+		if !ok {
+			requestLogger(r).Info("Bad TOTP code on admin login")
+			cfg.metrics.observeLogin(loginAdmin, loginFailure)
+			helperRenderAdminLogin(w, r, http.StatusUnauthorized, email, "Incorrect authenticator code")
+			return
+		}
+	}
+	if !dbUsr.IsAdmin {
+		requestLogger(r).Info("Admin login by a user who is not an admin")
+		cfg.metrics.observeLogin(loginAdmin, loginFailure)
+		helperRenderAdminLogin(w, r, http.StatusForbidden, email, "This account is not an admin")
+		return
+	}
+
+	token, err := auth.MakeAdminJWT(dbUsr.ID, cfg.keys, adminSessionExpiresIn)
+	if err != nil {
+		requestLogger(r).Error("Error making admin session token", "error", err)
+		helperRenderAdmin(w, r, http.StatusInternalServerError, adminErrorTemplate, "Something went wrong.")
+		return
+	}
+	cfg.metrics.observeLogin(loginAdmin, loginSuccess)
+
+	// SameSite=Strict keeps other sites from posting to the admin pages with
+	// the session attached.
+	http.SetCookie(w, &http.Cookie{
+		Name:     adminSessionCookie,
+		Value:    token,
+		Path:     "/admin",
+		MaxAge:   int(adminSessionExpiresIn.Seconds()),
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteStrictMode,
+	})
+	http.Redirect(w, r, "/admin", http.StatusSeeOther)
+}
+
+func (cfg *apiConfig) handleAdminLogout(w http.ResponseWriter, r *http.Request) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     adminSessionCookie,
+		Path:     "/admin",
+		MaxAge:   -1,
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+	http.Redirect(w, r, "/admin/login", http.StatusSeeOther)
+}
+
+// legacyUsage is how often a deprecated unversioned route was used.
+type legacyUsage struct {
+	Pattern string
+	Hits    float64
+}
+
+type adminDashboard struct {
+	Admin        string
+	Hits         uint64
+	Users        int64
+	ChirpyRed    int64
+	Chirps       int64
+	Since        string
+	Signups      []database.SignupsPerDayRow
+	ChirpsPerDay []database.ChirpsPerDayRow
+	TopPosters   []database.TopPostersRow
+	Reports      []database.RecentReportsRow
+	Errors       []loggedError
+	Legacy       []legacyUsage
+}
+
+// helperDashboard gathers everything the dashboard shows apart from who is
+// looking at it.
+func (cfg *apiConfig) helperDashboard(ctx context.Context) (adminDashboard, error) {
+	data := adminDashboard{
+		Hits:   uint64(cfg.metrics.fileserverHits.Value()) - cfg.hitsAtReset.Load(),
+		Errors: cfg.recentErrors.list(),
+	}
+	for _, pattern := range slices.Sorted(slices.Values(cfg.legacyRoutes)) {
+		data.Legacy = append(data.Legacy, legacyUsage{Pattern: pattern, Hits: cfg.metrics.legacyRequests.With(pattern).Value()})
+	}
+
+	var err error
+	data.Users, err = cfg.store.CountUsers(ctx)
+	if err != nil {
+		return data, fmt.Errorf("Error counting users: %w", err)
+	}
+	data.ChirpyRed, err = cfg.store.CountChirpyRed(ctx)
+	if err != nil {
+		return data, fmt.Errorf("Error counting Chirpy Red subscribers: %w", err)
+	}
+	data.Chirps, err = cfg.store.CountChirps(ctx)
+	if err != nil {
+		return data, fmt.Errorf("Error counting chirps: %w", err)
+	}
+
+	since := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, 1-dashboardDays)
+	data.Since = since.Format(time.DateOnly)
+	data.Signups, err = cfg.store.SignupsPerDay(ctx, since)
+	if err != nil {
+		return data, fmt.Errorf("Error counting signups per day: %w", err)
+	}
+	data.ChirpsPerDay, err = cfg.store.ChirpsPerDay(ctx, since)
+	if err != nil {
+		return data, fmt.Errorf("Error counting chirps per day: %w", err)
+	}
+	data.TopPosters, err = cfg.store.TopPosters(ctx, dashboardTopPosters)
+	if err != nil {
+		return data, fmt.Errorf("Error fetching top posters: %w", err)
+	}
+	data.Reports, err = cfg.store.RecentReports(ctx, dashboardReports)
+	if err != nil {
+		return data, fmt.Errorf("Error fetching recent reports: %w", err)
+	}
+
+	return data, nil
+}
+
+// handleAdminDashboard shows admins how Chirpy is being used. Anyone else
+// is sent to log in.
+func (cfg *apiConfig) handleAdminDashboard(w http.ResponseWriter, r *http.Request) {
+	admin, err := cfg.helperAdminUser(r)
+	if errors.Is(err, errAdminSession) {
+		requestLogger(r).Info("Admin dashboard without a session", "error", err)
+		http.Redirect(w, r, "/admin/login", http.StatusSeeOther)
+		return
+	}
+	if errors.Is(err, errNotAdmin) {
+		requestLogger(r).Info("Forbidden", "error", err)
+		helperRenderAdmin(w, r, http.StatusForbidden, adminErrorTemplate, "This account is not an admin.")
+		return
+	}
+	if err != nil {
+		requestLogger(r).Error("Error fetching admin user", "error", err)
+		helperRenderAdmin(w, r, http.StatusInternalServerError, adminErrorTemplate, "Something went wrong.")
+		return
+	}
+
+	data, err := cfg.helperDashboard(r.Context())
+	if err != nil {
+		requestLogger(r).Error("Error building admin dashboard", "error", err)
+		helperRenderAdmin(w, r, http.StatusInternalServerError, adminErrorTemplate, "Something went wrong.")
+		return
+	}
+	data.Admin = admin.Email
+
+	helperRenderAdmin(w, r, http.StatusOK, adminDashboardTemplate, data)
+}
@@ -0,0 +1,158 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/Senaphim/Chirpy/internal/database"
+	"github.com/Senaphim/Chirpy/internal/store"
+)
+
+// adminBrowser grants the user with email admin and returns a client logged
+// in to the admin pages as them.
+func adminBrowser(t *testing.T, serverURL string, st store.Store, email, password string) *http.Client {
+	t.Helper()
+	_, err := st.UpdateUsrAdmin(context.Background(), database.UpdateUsrAdminParams{Email: email, IsAdmin: true})
+	if err != nil {
+		t.Fatalf("UpdateUsrAdmin error = %v", err)
+	}
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatalf("cookiejar.New error = %v", err)
+	}
+	browser := &http.Client{Jar: jar}
+	resp, err := browser.PostForm(serverURL+"/admin/login", url.Values{"email": {email}, "password": {password}})
+	if err != nil {
+		t.Fatalf("POST /admin/login error = %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Request.URL.Path != "/admin" {
+		t.Fatalf("POST /admin/login ended at %s with status %d, want the dashboard", resp.Request.URL.Path, resp.StatusCode)
+	}
+	return browser
+}
+
+// browse fetches target with browser, returning the final status and body.
+func browse(t *testing.T, browser *http.Client, target string) (int, string) {
+	t.Helper()
+	resp, err := browser.Get(target)
+	if err != nil {
+		t.Fatalf("GET %s error = %v", target, err)
+	}
+	defer resp.Body.Close()
+	dat, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(dat)
+}
+
+// brokenChirps fails to list chirps, so the server has an error to report.
+type brokenChirps struct {
+	store.Store
+}
+
+func (brokenChirps) AllChirps(ctx context.Context) ([]database.Chirp, error) {
+	return nil, errors.New("disk on fire")
+}
+
+func TestAdminDashboard(t *testing.T) {
+	st := brokenChirps{store.NewMemory()}
+	srv := httptest.NewServer(NewServer(testServerConfig(t), st))
+	t.Cleanup(srv.Close)
+	c := &testClient{t: t, url: srv.URL}
+
+	const password = "correct horse battery staple"
+	walt := c.signUp(password)
+	jesse := c.signUp(password)
+	for _, body := range []string{"Say my name", "I am the one who knocks"} {
+		c.do("POST", "/api/v1/chirps", bearer(walt.Token), map[string]string{"body": body}, http.StatusCreated, nil)
+	}
+	c.do("POST", "/api/v1/chirps", bearer(jesse.Token), map[string]string{"body": "Yeah, science!"}, http.StatusCreated, nil)
+	_, err := st.UpdateUsrChirpyRed(context.Background(), database.UpdateUsrChirpyRedParams{ID: jesse.ID, IsChirpyRed: true})
+	if err != nil {
+		t.Fatalf("UpdateUsrChirpyRed error = %v", err)
+	}
+	c.do("GET", "/api/v1/chirps", "", nil, http.StatusInternalServerError, nil)
+	waltChirps := []testChirp{}
+	c.do("GET", "/api/v1/chirps?author_id="+walt.ID.String(), "", nil, http.StatusOK, &waltChirps)
+	c.do("POST", "/api/v1/chirps/"+waltChirps[0].ID.String()+"/reports", bearer(jesse.Token), map[string]string{"reason": "Too menacing"}, http.StatusCreated, nil)
+
+	// Without a session the dashboard sends browsers to log in.
+	anonymous := &http.Client{}
+	if status, page := browse(t, anonymous, srv.URL+"/admin"); status != http.StatusOK || !strings.Contains(page, `action="/admin/login"`) {
+		t.Errorf("GET /admin without a session = %d %s, want the login page", status, page)
+	}
+
+	// Other users can't log in.
+	resp, err := anonymous.PostForm(srv.URL+"/admin/login", url.Values{"email": {jesse.Email}, "password": {password}})
+	if err != nil {
+		t.Fatalf("POST /admin/login error = %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("Admin login by a user who is not an admin status = %d, want 403", resp.StatusCode)
+	}
+	resp, err = anonymous.PostForm(srv.URL+"/admin/login", url.Values{"email": {walt.Email}, "password": {"wrong"}})
+	if err != nil {
+		t.Fatalf("POST /admin/login error = %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Admin login with the wrong password status = %d, want 401", resp.StatusCode)
+	}
+
+	browser := adminBrowser(t, srv.URL, st, walt.Email, password)
+	status, page := browse(t, browser, srv.URL+"/admin")
+	if status != http.StatusOK {
+		t.Fatalf("GET /admin status = %d: %s", status, page)
+	}
+	for _, want := range []string{
+		"Logged in as " + walt.Email,
+		"<tr><th>Users</th><td>2</td></tr>",
+		"<tr><th>Chirpy Red subscribers</th><td>1</td></tr>",
+		"<tr><th>Chirps</th><td>3</td></tr>",
+		"<tr><td>" + walt.Email + "</td><td>2</td></tr>",
+		"<td>GET /api/v1/chirps</td><td>Error fetching chirps</td><td>disk on fire</td>",
+		"<td>" + jesse.Email + "</td><td>" + waltChirps[0].Body + "</td><td>Too menacing</td>",
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("Dashboard is missing %q:\n%s", want, page)
+		}
+	}
+
+	// The session is not an API token, and API tokens are not sessions.
+	adminURL, _ := url.Parse(srv.URL + "/admin")
+	session := browser.Jar.Cookies(adminURL)[0]
+	c.do("GET", "/api/v1/sessions", bearer(session.Value), nil, http.StatusUnauthorized, nil)
+	forged, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatalf("cookiejar.New error = %v", err)
+	}
+	forged.SetCookies(adminURL, []*http.Cookie{{Name: adminSessionCookie, Value: walt.Token}})
+	if status, page := browse(t, &http.Client{Jar: forged}, srv.URL+"/admin"); status != http.StatusOK || !strings.Contains(page, `action="/admin/login"`) {
+		t.Errorf("GET /admin with an access token as the session = %d %s, want the login page", status, page)
+	}
+
+	// Losing admin ends the session's access.
+	_, err = st.UpdateUsrAdmin(context.Background(), database.UpdateUsrAdminParams{Email: walt.Email, IsAdmin: false})
+	if err != nil {
+		t.Fatalf("UpdateUsrAdmin error = %v", err)
+	}
+	if status, _ := browse(t, browser, srv.URL+"/admin"); status != http.StatusForbidden {
+		t.Errorf("GET /admin after losing admin status = %d, want 403", status)
+	}
+
+	resp, err = browser.PostForm(srv.URL+"/admin/logout", nil)
+	if err != nil {
+		t.Fatalf("POST /admin/logout error = %v", err)
+	}
+	resp.Body.Close()
+	if len(browser.Jar.Cookies(adminURL)) != 0 {
+		t.Errorf("Session cookie survived logging out")
+	}
+}
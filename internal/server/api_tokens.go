@@ -0,0 +1,250 @@
+package server
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Senaphim/Chirpy/internal/auth"
+	"github.com/Senaphim/Chirpy/internal/database"
+	"github.com/Senaphim/Chirpy/internal/validate"
+	"github.com/google/uuid"
+)
+
+var errInsufficientScope = errors.New("Token lacks required scope")
+
+// helperAuthenticate resolves the user behind a bearer token. Access JWTs
+// from an interactive login may do anything. Personal access tokens and JWTs
+// issued to OAuth clients are only accepted when scope is non-empty and they
+// were granted it.
+func (cfg *apiConfig) helperAuthenticate(r *http.Request, scope string) (uuid.UUID, error) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	if !auth.IsAPIToken(token) {
+		userId, claims, err := auth.ValidateAccessJWT(token, cfg.keys)
+		if err != nil {
+			return uuid.Nil, err
+		}
+		setRequestUser(r, userId)
+		if claims.Delegated() && (scope == "" || !auth.HasScope(claims.Scopes(), scope)) {
+			return uuid.Nil, errInsufficientScope
+		}
+		return userId, nil
+	}
+
+	dbToken, err := cfg.store.GetAPITokenByHash(r.Context(), auth.HashAPIToken(token))
+	if err != nil {
+		fmtErr := fmt.Errorf("API token not found: %w", err)
+		return uuid.Nil, fmtErr
+	}
+	setRequestUser(r, dbToken.UserID)
+	if dbToken.RevokedAt.Valid {
+		return uuid.Nil, errors.New("API token revoked")
+	}
+	if dbToken.ExpiresAt.Valid && time.Now().After(dbToken.ExpiresAt.Time) {
+		return uuid.Nil, errors.New("API token expired")
+	}
+	if scope == "" || !auth.HasScope(auth.SplitScopes(dbToken.Scopes), scope) {
+		return uuid.Nil, errInsufficientScope
+	}
+
+	touchParams := database.TouchAPITokenParams{
+		LastUsedAt: sql.NullTime{
+			Time:  time.Now().Local(),
+			Valid: true,
+		},
+		ID: dbToken.ID,
+	}
+	err = cfg.store.TouchAPIToken(r.Context(), touchParams)
+	if err != nil {
+		requestLogger(r).Error("Error updating API token usage", "error", err)
+	}
+
+	return dbToken.UserID, nil
+}
+
+// helperAuthError writes the status for an error from helperAuthenticate.
+func helperAuthError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, errInsufficientScope) {
+		requestLogger(r).Info("Forbidden", "error", err)
+		helperError(w, http.StatusForbidden, errCodeInsufficientScope, "Token lacks the required scope", nil)
+		return
+	}
+
+	requestLogger(r).Info("Invalid token", "error", err)
+	helperError(w, http.StatusUnauthorized, errCodeUnauthorized, "Missing or invalid access token", nil)
+}
+
+type returnAPIToken struct {
+	ID         uuid.UUID  `json:"id"`
+	Name       string     `json:"name"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	Token      string     `json:"token,omitempty"`
+}
+
+func helperReturnAPIToken(dbToken database.ApiToken) returnAPIToken {
+	rToken := returnAPIToken{
+		ID:        dbToken.ID,
+		Name:      dbToken.Name,
+		Scopes:    auth.SplitScopes(dbToken.Scopes),
+		CreatedAt: dbToken.CreatedAt,
+	}
+	if dbToken.ExpiresAt.Valid {
+		rToken.ExpiresAt = &dbToken.ExpiresAt.Time
+	}
+	if dbToken.LastUsedAt.Valid {
+		rToken.LastUsedAt = &dbToken.LastUsedAt.Time
+	}
+
+	return rToken
+}
+
+func (cfg *apiConfig) handlerCreateAPIToken(w http.ResponseWriter, r *http.Request) {
+	userId, err := cfg.helperAuthenticate(r, "")
+	if err != nil {
+		helperAuthError(w, r, err)
+		return
+	}
+
+	type parameters struct {
+		Name          string   `json:"name" validate:"required,max=100"`
+		Scopes        []string `json:"scopes" validate:"required"`
+		ExpiresInDays int      `json:"expires_in_days" validate:"min=0"`
+	}
+
+	params := parameters{}
+	if !helperDecode(w, r, &params) {
+		return
+	}
+
+	scopes, err := auth.ValidateScopes(params.Scopes)
+	if err != nil {
+		requestLogger(r).Info("Invalid scopes", "error", err)
+		helperValidationError(w, "Invalid scopes", []validate.FieldError{
+			{Field: "scopes", Message: err.Error()},
+		})
+		return
+	}
+
+	token, err := auth.MakeAPIToken()
+	if err != nil {
+		requestLogger(r).Error("Error making API token", "error", err)
+		helperInternalError(w)
+		return
+	}
+
+	// Zero days means the token lives until it is revoked.
+	expiresAt := sql.NullTime{}
+	if params.ExpiresInDays > 0 {
+		expiresAt = sql.NullTime{
+			Time:  time.Now().Local().AddDate(0, 0, params.ExpiresInDays),
+			Valid: true,
+		}
+	}
+
+	createParams := database.CreateAPITokenParams{
+		ID:        uuid.New(),
+		CreatedAt: time.Now().Local(),
+		UpdatedAt: time.Now().Local(),
+		UserID:    userId,
+		Name:      params.Name,
+		TokenHash: auth.HashAPIToken(token),
+		Scopes:    auth.JoinScopes(scopes),
+		ExpiresAt: expiresAt,
+	}
+	dbToken, err := cfg.store.CreateAPIToken(r.Context(), createParams)
+	if err != nil {
+		requestLogger(r).Error("Error storing API token", "error", err)
+		helperInternalError(w)
+		return
+	}
+
+	// The raw token is only ever shown in this response.
+	rToken := helperReturnAPIToken(dbToken)
+	rToken.Token = token
+	dat, err := json.Marshal(rToken)
+	if err != nil {
+		helperJsonError(w, r, "Error marshalling response", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	w.Write(dat)
+}
+
+func (cfg *apiConfig) handlerListAPITokens(w http.ResponseWriter, r *http.Request) {
+	userId, err := cfg.helperAuthenticate(r, "")
+	if err != nil {
+		helperAuthError(w, r, err)
+		return
+	}
+
+	tokens, err := cfg.store.GetAPITokensByUser(r.Context(), userId)
+	if err != nil {
+		requestLogger(r).Error("Error fetching API tokens", "error", err)
+		helperInternalError(w)
+		return
+	}
+
+	returnArray := []returnAPIToken{}
+	for _, token := range tokens {
+		returnArray = append(returnArray, helperReturnAPIToken(token))
+	}
+
+	dat, err := json.Marshal(returnArray)
+	if err != nil {
+		helperJsonError(w, r, "Error marshalling response", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(dat)
+}
+
+func (cfg *apiConfig) handlerRevokeAPIToken(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("tokenID")
+	tokenId, err := uuid.Parse(id)
+	if err != nil {
+		requestLogger(r).Info("Error parsing tokenID", "error", err)
+		helperNotFound(w, "API token not found")
+		return
+	}
+
+	userId, err := cfg.helperAuthenticate(r, "")
+	if err != nil {
+		helperAuthError(w, r, err)
+		return
+	}
+
+	revokeParams := database.RevokeAPITokenParams{
+		UpdatedAt: time.Now().Local(),
+		RevokedAt: sql.NullTime{
+			Time:  time.Now().Local(),
+			Valid: true,
+		},
+		ID:     tokenId,
+		UserID: userId,
+	}
+	revoked, err := cfg.store.RevokeAPIToken(r.Context(), revokeParams)
+	if err != nil {
+		requestLogger(r).Error("Error revoking API token", "error", err)
+		helperInternalError(w)
+		return
+	}
+	if revoked == 0 {
+		requestLogger(r).Info("API token not found for user")
+		helperNotFound(w, "API token not found")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,106 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+)
+
+// recentErrorsKept is how many errors the admin dashboard shows.
+const recentErrorsKept int = 20
+
+// loggedError is an error logged while serving a request.
+type loggedError struct {
+	Time      time.Time
+	Message   string
+	RequestID string
+	Route     string
+	Error     string
+}
+
+// recentErrors keeps the last errors logged, newest last, so the admin
+// dashboard can show them without anyone reading the logs.
+type recentErrors struct {
+	mu      sync.Mutex
+	entries []loggedError
+	size    int
+}
+
+func newRecentErrors(size int) *recentErrors {
+	return &recentErrors{size: size}
+}
+
+func (e *recentErrors) add(entry loggedError) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.entries = append(e.entries, entry)
+	if len(e.entries) > e.size {
+		e.entries = slices.Delete(e.entries, 0, len(e.entries)-e.size)
+	}
+}
+
+// list returns the errors kept, newest first.
+func (e *recentErrors) list() []loggedError {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	entries := slices.Clone(e.entries)
+	slices.Reverse(entries)
+	return entries
+}
+
+// handler wraps next so that error records are kept as well as logged.
+func (e *recentErrors) handler(next slog.Handler) slog.Handler {
+	return &errorLogHandler{next: next, errors: e}
+}
+
+// errorLogHandler passes every record on to next, keeping those at error
+// level in errors. It remembers the attributes loggers were given with With,
+// as that is how request IDs and routes reach the handler.
+type errorLogHandler struct {
+	next   slog.Handler
+	errors *recentErrors
+	attrs  []slog.Attr
+}
+
+func (h *errorLogHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *errorLogHandler) Handle(ctx context.Context, record slog.Record) error {
+	if record.Level >= slog.LevelError {
+		entry := loggedError{Time: record.Time, Message: record.Message}
+		note := func(a slog.Attr) bool {
+			switch a.Key {
+			case "request_id":
+				entry.RequestID = a.Value.String()
+			case "route":
+				entry.Route = a.Value.String()
+			case "error":
+				entry.Error = a.Value.String()
+			}
+			return true
+		}
+		for _, a := range h.attrs {
+			note(a)
+		}
+		record.Attrs(note)
+		h.errors.add(entry)
+	}
+	return h.next.Handle(ctx, record)
+}
+
+func (h *errorLogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &errorLogHandler{
+		next:   h.next.WithAttrs(attrs),
+		errors: h.errors,
+		attrs:  append(slices.Clip(h.attrs), attrs...),
+	}
+}
+
+func (h *errorLogHandler) WithGroup(name string) slog.Handler {
+	return &errorLogHandler{next: h.next.WithGroup(name), errors: h.errors, attrs: h.attrs}
+}
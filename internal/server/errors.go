@@ -0,0 +1,97 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/Senaphim/Chirpy/internal/store"
+	"github.com/Senaphim/Chirpy/internal/validate"
+	"github.com/lib/pq"
+)
+
+// Machine readable error codes returned in the error envelope. Clients
+// should branch on these rather than on the message.
+const (
+	errCodeInvalidJSON        string = "invalid_json"
+	errCodeTooLarge           string = "request_too_large"
+	errCodeBadRequest         string = "bad_request"
+	errCodeValidation         string = "validation_failed"
+	errCodeUnauthorized       string = "unauthorized"
+	errCodeInvalidCredentials string = "invalid_credentials"
+	errCodeForbidden          string = "forbidden"
+	errCodeInsufficientScope  string = "insufficient_scope"
+	errCodeNotFound           string = "not_found"
+	errCodeConflict           string = "conflict"
+	errCodeEmailTaken         string = "email_taken"
+	errCodeTooManyAttempts    string = "too_many_attempts"
+	errCodeInternal           string = "internal_error"
+)
+
+// errEmailTaken is returned when a user is created or renamed to an email
+// address that already belongs to someone else.
+var errEmailTaken = errors.New("Email address already in use")
+
+// apiError is the body of every error response from the JSON API.
+type apiError struct {
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	Details   any    `json:"details,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// helperError writes an error envelope. The request ID is read back from the
+// response header set by middlewareRequestID.
+func helperError(w http.ResponseWriter, status int, code string, message string, details any) {
+	type responseJson struct {
+		Error apiError `json:"error"`
+	}
+
+	resp := responseJson{
+		Error: apiError{
+			Code:      code,
+			Message:   message,
+			Details:   details,
+			RequestID: w.Header().Get(requestIDHeader),
+		},
+	}
+	dat, err := json.Marshal(resp)
+	if err != nil {
+		slog.Error("Error marshalling error response", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(dat)
+}
+
+// helperInternalError hides the cause of a server side failure from the
+// client. Callers log the cause themselves.
+func helperInternalError(w http.ResponseWriter) {
+	helperError(w, http.StatusInternalServerError, errCodeInternal, "Something went wrong", nil)
+}
+
+// helperJsonError logs a failure to produce a response body.
+func helperJsonError(w http.ResponseWriter, r *http.Request, message string, err error) {
+	requestLogger(r).Error(message, "error", err)
+	helperInternalError(w)
+}
+
+func helperValidationError(w http.ResponseWriter, message string, details []validate.FieldError) {
+	helperError(w, http.StatusUnprocessableEntity, errCodeValidation, message, details)
+}
+
+func helperNotFound(w http.ResponseWriter, message string) {
+	helperError(w, http.StatusNotFound, errCodeNotFound, message, nil)
+}
+
+// isUniqueViolation reports whether err came from a unique constraint.
+func isUniqueViolation(err error) bool {
+	if errors.Is(err, store.ErrDuplicate) {
+		return true
+	}
+	pqErr := &pq.Error{}
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
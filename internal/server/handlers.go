@@ -0,0 +1,763 @@
+package server
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Senaphim/Chirpy/internal/auth"
+	"github.com/Senaphim/Chirpy/internal/database"
+	"github.com/Senaphim/Chirpy/internal/validate"
+	"github.com/google/uuid"
+)
+
+func (cfg *apiConfig) handleChirp(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Body string `json:"body" validate:"required,max=140"`
+	}
+
+	userId, err := cfg.helperAuthenticate(r, auth.ScopeChirpsWrite)
+	if err != nil {
+		helperAuthError(w, r, err)
+		return
+	}
+
+	params := parameters{}
+	if !helperDecode(w, r, &params) {
+		return
+	}
+
+	cleanString := helperCleanString(params.Body)
+	chirp, err := cfg.helperCreateChirp(cleanString, userId, r)
+	if err != nil {
+		requestLogger(r).Error("Error creating chirp", "error", err)
+		helperInternalError(w)
+		return
+	}
+	cfg.metrics.chirpsCreated.Inc()
+
+	type returnVals struct {
+		Id        uuid.UUID `json:"id"`
+		CreatedAt time.Time `json:"created_at"`
+		UpdatedAt time.Time `json:"updated_at"`
+		Body      string    `json:"body"`
+		UserId    uuid.UUID `json:"user_id"`
+	}
+
+	resp := returnVals{
+		Id:        chirp.ID,
+		CreatedAt: chirp.CreatedAt,
+		UpdatedAt: chirp.UpdatedAt,
+		Body:      chirp.Body,
+		UserId:    chirp.UserID,
+	}
+	dat, err := json.Marshal(resp)
+	if err != nil {
+		helperJsonError(w, r, "Error marshalling response", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(201)
+	w.Write(dat)
+}
+
+// helperCheckPassword applies the password policy, writing a response
+// listing every violated rule when the password is rejected.
+func (cfg *apiConfig) helperCheckPassword(w http.ResponseWriter, r *http.Request, password string) bool {
+	err := cfg.passwordPolicy.Check(password)
+	if err == nil {
+		return true
+	}
+
+	policyErr := &auth.PasswordPolicyError{}
+	if !errors.As(err, &policyErr) {
+		requestLogger(r).Error("Error checking password policy", "error", err)
+		helperInternalError(w)
+		return false
+	}
+
+	helperError(
+		w,
+		http.StatusUnprocessableEntity,
+		errCodeValidation,
+		"Password does not meet the password policy",
+		policyErr.Violations,
+	)
+	return false
+}
+
+func helperCleanString(post string) string {
+	profanity := []string{"kerfuffle", "sharbert", "fornax", "Kerfuffle", "Sharbert", "Fornax"}
+	cleanPost := post
+
+	for _, profane := range profanity {
+		cleanPost = strings.ReplaceAll(cleanPost, profane, "****")
+	}
+
+	return cleanPost
+}
+
+func (cfg *apiConfig) handlerCreateUser(w http.ResponseWriter, r *http.Request) {
+	type email struct {
+		Email    string `json:"email" validate:"required,email,max=254"`
+		Password string `json:"password" validate:"required"`
+	}
+
+	em := email{}
+	if !helperDecode(w, r, &em) {
+		return
+	}
+
+	if !cfg.helperCheckPassword(w, r, em.Password) {
+		return
+	}
+
+	user, err := cfg.helperCreateUser(em.Email, em.Password, r)
+	if errors.Is(err, errEmailTaken) {
+		requestLogger(r).Info("Error creating user", "error", err)
+		helperError(w, http.StatusConflict, errCodeEmailTaken, "Email address already in use", nil)
+		return
+	}
+	if err != nil {
+		requestLogger(r).Error("Error creating user", "error", err)
+		helperInternalError(w)
+		return
+	}
+
+	type retUser struct {
+		ID          uuid.UUID `json:"id"`
+		CreatedAt   time.Time `json:"created_at"`
+		UpdatedAt   time.Time `json:"updated_at"`
+		Email       string    `json:"email"`
+		IsChirpyRed bool      `json:"is_chirpy_red"`
+	}
+	rUser := retUser{
+		ID:          user.ID,
+		CreatedAt:   user.CreatedAt,
+		UpdatedAt:   user.UpdatedAt,
+		Email:       user.Email,
+		IsChirpyRed: user.IsChirpyRed,
+	}
+	dat, err := json.Marshal(rUser)
+	if err != nil {
+		helperJsonError(w, r, "Error marshalling response", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(201)
+	w.Write(dat)
+}
+
+func (cfg *apiConfig) helperCreateUser(
+	email string,
+	password string,
+	r *http.Request,
+) (database.User, error) {
+
+	hashedPassword, err := cfg.hashPassword(r.Context(), password)
+	if err != nil {
+		fmtErr := fmt.Errorf("Error with password: %w", err)
+		return database.User{}, fmtErr
+	}
+	userDetails := database.CreateUserParams{
+		ID:             uuid.New(),
+		CreatedAt:      time.Now().Local(),
+		UpdatedAt:      time.Now().Local(),
+		Email:          email,
+		HashedPassword: hashedPassword,
+	}
+
+	user, err := cfg.store.CreateUser(r.Context(), userDetails)
+	if isUniqueViolation(err) {
+		return database.User{}, errEmailTaken
+	}
+	if err != nil {
+		fmtErr := fmt.Errorf("Error adding user to database: %w", err)
+		return database.User{}, fmtErr
+	}
+
+	return user, nil
+}
+
+func (cfg *apiConfig) helperCreateChirp(
+	body string,
+	user uuid.UUID,
+	r *http.Request,
+) (database.Chirp, error) {
+
+	chirpDetails := database.CreateChirpParams{
+		ID:        uuid.New(),
+		CreatedAt: time.Now().Local(),
+		UpdatedAt: time.Now().Local(),
+		Body:      body,
+		UserID:    user,
+	}
+
+	chirp, err := cfg.store.CreateChirp(r.Context(), chirpDetails)
+	if err != nil {
+		fmtErr := fmt.Errorf("Error adding chirp to database: %w", err)
+		return database.Chirp{}, fmtErr
+	}
+
+	return chirp, nil
+}
+
+func (cfg *apiConfig) handlerAllChirps(w http.ResponseWriter, r *http.Request) {
+	author := r.URL.Query().Get("author_id")
+
+	chirps := []database.Chirp{}
+	err := errors.New("")
+	if author == "" {
+		chirps, err = cfg.store.AllChirps(r.Context())
+	} else {
+		var author_uuid uuid.UUID
+		if author == "me" {
+			// Listing your own chirps is the one read that needs a token,
+			// and so the one the chirps:read scope grants.
+			author_uuid, err = cfg.helperAuthenticate(r, auth.ScopeChirpsRead)
+			if err != nil {
+				helperAuthError(w, r, err)
+				return
+			}
+		} else if author_uuid, err = uuid.Parse(author); err != nil {
+			requestLogger(r).Info("Error parsing query parameter", "error", err)
+			helperError(w, http.StatusBadRequest, errCodeBadRequest, "Invalid author_id", []validate.FieldError{
+				{Field: "author_id", Message: "must be a UUID or me"},
+			})
+			return
+		}
+		chirps, err = cfg.store.GetChirpsByAuthor(r.Context(), author_uuid)
+		if err != nil {
+			requestLogger(r).Error("Error fetching chirps", "error", err)
+			helperInternalError(w)
+			return
+		}
+	}
+	if err != nil && err.Error() != "" {
+		requestLogger(r).Error("Error fetching chirps", "error", err)
+		helperInternalError(w)
+		return
+	}
+
+	type returnChirp struct {
+		Id        uuid.UUID `json:"id"`
+		CreatedAt time.Time `json:"created_at"`
+		UpdatedAt time.Time `json:"updated_at"`
+		Body      string    `json:"body"`
+		UserId    uuid.UUID `json:"user_id"`
+	}
+
+	returnArray := []returnChirp{}
+
+	for _, chirp := range chirps {
+		rChirp := returnChirp{
+			Id:        chirp.ID,
+			CreatedAt: chirp.CreatedAt,
+			UpdatedAt: chirp.UpdatedAt,
+			Body:      chirp.Body,
+			UserId:    chirp.UserID,
+		}
+
+		returnArray = append(returnArray, rChirp)
+	}
+
+	sort := r.URL.Query().Get("sort")
+	if sort != "" {
+		if sort == "asc" {
+			slices.SortFunc(returnArray, func(a, b returnChirp) int {
+				return a.CreatedAt.Compare(b.CreatedAt)
+			})
+		} else if sort == "desc" {
+			slices.SortFunc(returnArray, func(a, b returnChirp) int {
+				return -(a.CreatedAt.Compare(b.CreatedAt))
+			})
+		} else {
+			requestLogger(r).Info("Unexpected query parameter value", "sort", sort)
+		}
+	}
+
+	dat, err := json.Marshal(returnArray)
+	if err != nil {
+		helperJsonError(w, r, "Error marshalling response", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	w.Write(dat)
+}
+
+func (cfg *apiConfig) handlerChirpById(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("chirpID")
+	chirp_uuid, err := uuid.Parse(id)
+	if err != nil {
+		requestLogger(r).Info("Error parsing chirpID", "error", err)
+		helperNotFound(w, "Chirp not found")
+		return
+	}
+
+	chirp, err := cfg.store.GetChirpById(r.Context(), chirp_uuid)
+	if errors.Is(err, sql.ErrNoRows) {
+		requestLogger(r).Info("Error fetching chirps", "error", err)
+		helperNotFound(w, "Chirp not found")
+		return
+	}
+	if err != nil {
+		requestLogger(r).Error("Error fetching chirps", "error", err)
+		helperInternalError(w)
+		return
+	}
+
+	type returnChirp struct {
+		Id        uuid.UUID `json:"id"`
+		CreatedAt time.Time `json:"created_at"`
+		UpdatedAt time.Time `json:"updated_at"`
+		Body      string    `json:"body"`
+		UserId    uuid.UUID `json:"user_id"`
+	}
+	rChirp := returnChirp{
+		Id:        chirp.ID,
+		CreatedAt: chirp.CreatedAt,
+		UpdatedAt: chirp.UpdatedAt,
+		Body:      chirp.Body,
+		UserId:    chirp.UserID,
+	}
+
+	dat, err := json.Marshal(rChirp)
+	if err != nil {
+		helperJsonError(w, r, "Error marshalling response", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	w.Write(dat)
+}
+
+func (cfg *apiConfig) handlerLogin(w http.ResponseWriter, r *http.Request) {
+	type user struct {
+		Email      string `json:"email" validate:"required"`
+		Password   string `json:"password" validate:"required"`
+		DeviceName string `json:"device_name" validate:"max=100"`
+	}
+
+	usr := user{
+		Email:      "",
+		Password:   "",
+		DeviceName: "",
+	}
+	if !helperDecode(w, r, &usr) {
+		return
+	}
+
+	dbUsr, err := cfg.store.GetUserByEmail(r.Context(), usr.Email)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		requestLogger(r).Error("Error fetching user from email", "error", err)
+		helperInternalError(w)
+		return
+	}
+	if err == nil {
+		err = cfg.checkPasswordHash(r.Context(), usr.Password, dbUsr.HashedPassword)
+	}
+	if err != nil {
+		requestLogger(r).Info("Error bad email or password", "error", err)
+		cfg.metrics.observeLogin(loginPassword, loginFailure)
+		helperError(w, http.StatusUnauthorized, errCodeInvalidCredentials, "Incorrect email or password", nil)
+		return
+	}
+	cfg.metrics.observeLogin(loginPassword, loginSuccess)
+
+	// Upgrade bcrypt hashes, or Argon2id hashes made with older parameters,
+	// while the plain password is at hand. Failing to do so is not fatal.
+	if cfg.hasher.NeedsRehash(dbUsr.HashedPassword) {
+		cfg.helperRehashPassword(r, dbUsr, usr.Password)
+	}
+
+	if dbUsr.TotpEnabled {
+		cfg.helperTwoFactorChallenge(w, r, dbUsr)
+		return
+	}
+
+	cfg.helperIssueSession(w, r, dbUsr, usr.DeviceName)
+}
+
+func (cfg *apiConfig) helperRehashPassword(r *http.Request, dbUsr database.User, password string) {
+	hash, err := cfg.hashPassword(r.Context(), password)
+	if err != nil {
+		requestLogger(r).Error("Error rehashing password", "error", err)
+		return
+	}
+
+	update := database.UpdateUsrPasswordParams{
+		ID:             dbUsr.ID,
+		UpdatedAt:      time.Now().Local(),
+		HashedPassword: hash,
+	}
+	err = cfg.store.UpdateUsrPassword(r.Context(), update)
+	if err != nil {
+		requestLogger(r).Error("Error storing rehashed password", "error", err)
+	}
+}
+
+// helperIssueSession writes the access and refresh token pair for a user
+// that has completed every login step.
+func (cfg *apiConfig) helperIssueSession(
+	w http.ResponseWriter,
+	r *http.Request,
+	dbUsr database.User,
+	deviceName string,
+) {
+	setRequestUser(r, dbUsr.ID)
+	refreshToken, err := auth.MakeRefreshToken()
+	if err != nil {
+		requestLogger(r).Error("Error making refresh token", "error", err)
+		helperInternalError(w)
+		return
+	}
+
+	jwtExpiration, err := time.ParseDuration("3600s")
+	if err != nil {
+		requestLogger(r).Error("Error parsing duration string", "error", err)
+		helperInternalError(w)
+		return
+	}
+	jwt, err := auth.MakeJWT(dbUsr.ID, cfg.keys, jwtExpiration)
+	if err != nil {
+		requestLogger(r).Error("Error making JWT", "error", err)
+		helperInternalError(w)
+		return
+	}
+
+	refreshTokenExpiration, err := time.ParseDuration("1440h")
+	if err != nil {
+		requestLogger(r).Error("Error parsing duration", "error", err)
+		helperInternalError(w)
+		return
+	}
+	expiryTime := time.Now().Add(refreshTokenExpiration)
+	refreshParams := database.CreateRefreshTokenParams{
+		TokenHash:  auth.HashRefreshToken(refreshToken),
+		CreatedAt:  time.Now().Local(),
+		UpdatedAt:  time.Now().Local(),
+		UserID:     dbUsr.ID,
+		ExpiresAt:  expiryTime,
+		ID:         uuid.New(),
+		DeviceName: deviceName,
+		UserAgent:  r.UserAgent(),
+		IpAddress:  helperClientIP(r),
+		LastUsedAt: time.Now().Local(),
+	}
+	_, err = cfg.store.CreateRefreshToken(r.Context(), refreshParams)
+	if err != nil {
+		requestLogger(r).Error("Error storing refresh token", "error", err)
+		helperInternalError(w)
+		return
+	}
+
+	type retUser struct {
+		ID           uuid.UUID `json:"id"`
+		CreatedAt    time.Time `json:"created_at"`
+		UpdatedAt    time.Time `json:"updated_at"`
+		Email        string    `json:"email"`
+		IsChirpyRed  bool      `json:"is_chirpy_red"`
+		Token        string    `json:"token"`
+		RefreshToken string    `json:"refresh_token"`
+	}
+	rUser := retUser{
+		ID:           dbUsr.ID,
+		CreatedAt:    dbUsr.CreatedAt,
+		UpdatedAt:    dbUsr.UpdatedAt,
+		Email:        dbUsr.Email,
+		IsChirpyRed:  dbUsr.IsChirpyRed,
+		Token:        jwt,
+		RefreshToken: refreshToken,
+	}
+	dat, err := json.Marshal(rUser)
+	if err != nil {
+		helperJsonError(w, r, "Error marshalling response", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(dat)
+}
+
+func (cfg *apiConfig) handlerRefresh(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		requestLogger(r).Info("Error getting bearer token", "error", err)
+		helperError(w, http.StatusUnauthorized, errCodeUnauthorized, "Missing refresh token", nil)
+		return
+	}
+
+	dbToken, err := cfg.store.GetRefreshToken(r.Context(), auth.HashRefreshToken(token))
+	if err != nil {
+		requestLogger(r).Info("Refresh token not found", "error", err)
+		helperError(w, http.StatusUnauthorized, errCodeUnauthorized, "Invalid refresh token", nil)
+		return
+	}
+	setRequestUser(r, dbToken.UserID)
+	if dbToken.RevokedAt.Valid || time.Now().After(dbToken.ExpiresAt) {
+		requestLogger(r).Info("Refresh token revoked or expired")
+		helperError(w, http.StatusUnauthorized, errCodeUnauthorized, "Invalid refresh token", nil)
+		return
+	}
+	// Refresh tokens held by OAuth clients must go through /oauth/token so
+	// that the new access token keeps the granted scopes.
+	if dbToken.ClientID.Valid {
+		requestLogger(r).Info("OAuth client refresh token used on first party endpoint")
+		helperError(w, http.StatusUnauthorized, errCodeUnauthorized, "Invalid refresh token", nil)
+		return
+	}
+
+	touchParams := database.TouchRefreshTokenParams{
+		UpdatedAt:  time.Now().Local(),
+		LastUsedAt: time.Now().Local(),
+		UserAgent:  r.UserAgent(),
+		IpAddress:  helperClientIP(r),
+		TokenHash:  dbToken.TokenHash,
+	}
+	err = cfg.store.TouchRefreshToken(r.Context(), touchParams)
+	if err != nil {
+		requestLogger(r).Error("Error updating session activity", "error", err)
+		helperInternalError(w)
+		return
+	}
+
+	tokenExpiration, err := time.ParseDuration("1h")
+	if err != nil {
+		requestLogger(r).Error("Error parsing duration", "error", err)
+		helperInternalError(w)
+		return
+	}
+	oneHrToken, err := auth.MakeJWT(dbToken.UserID, cfg.keys, tokenExpiration)
+	if err != nil {
+		requestLogger(r).Error("Error creating JWT", "error", err)
+		helperInternalError(w)
+		return
+	}
+
+	type retStruct struct {
+		Token string `json:"token"`
+	}
+	rStruct := retStruct{
+		Token: oneHrToken,
+	}
+	dat, err := json.Marshal(rStruct)
+	if err != nil {
+		helperJsonError(w, r, "Error marshalling response", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(dat)
+}
+
+func (cfg *apiConfig) handlerRevoke(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		requestLogger(r).Info("Error getting token from header", "error", err)
+		helperError(w, http.StatusUnauthorized, errCodeUnauthorized, "Missing refresh token", nil)
+		return
+	}
+
+	dbToken, err := cfg.store.GetRefreshToken(r.Context(), auth.HashRefreshToken(token))
+	if err != nil {
+		requestLogger(r).Info("Refresh token not found", "error", err)
+		helperError(w, http.StatusUnauthorized, errCodeUnauthorized, "Invalid refresh token", nil)
+		return
+	}
+	setRequestUser(r, dbToken.UserID)
+	if dbToken.RevokedAt.Valid || time.Now().After(dbToken.ExpiresAt) {
+		requestLogger(r).Info("Refresh token revoked or expired")
+		helperError(w, http.StatusUnauthorized, errCodeUnauthorized, "Invalid refresh token", nil)
+		return
+	}
+	// As with refreshing, tokens held by OAuth clients are theirs to manage
+	// through the OAuth endpoints.
+	if dbToken.ClientID.Valid {
+		requestLogger(r).Info("OAuth client refresh token used on first party endpoint")
+		helperError(w, http.StatusUnauthorized, errCodeUnauthorized, "Invalid refresh token", nil)
+		return
+	}
+
+	revokeParams := database.RevokeRefreshTokenParams{
+		TokenHash: dbToken.TokenHash,
+		UpdatedAt: time.Now().Local(),
+		RevokedAt: sql.NullTime{
+			Time:  time.Now().Local(),
+			Valid: true,
+		},
+	}
+	_, err = cfg.store.RevokeRefreshToken(r.Context(), revokeParams)
+	if err != nil {
+		requestLogger(r).Error("Error revoking refresh token", "error", err)
+		helperInternalError(w)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handleChangePwd(w http.ResponseWriter, r *http.Request) {
+	userId, err := cfg.helperAuthenticate(r, "")
+	if err != nil {
+		helperAuthError(w, r, err)
+		return
+	}
+
+	type newData struct {
+		Email    string `json:"email" validate:"required,email,max=254"`
+		Password string `json:"password" validate:"required"`
+	}
+
+	data := newData{}
+	if !helperDecode(w, r, &data) {
+		return
+	}
+
+	if !cfg.helperCheckPassword(w, r, data.Password) {
+		return
+	}
+
+	hash, err := cfg.hashPassword(r.Context(), data.Password)
+	if err != nil {
+		requestLogger(r).Error("Failed to hash password", "error", err)
+		helperInternalError(w)
+		return
+	}
+
+	update := database.UpdateUsrEmailPwdParams{
+		ID:             userId,
+		UpdatedAt:      time.Now().Local(),
+		Email:          data.Email,
+		HashedPassword: hash,
+	}
+	user, err := cfg.store.UpdateUsrEmailPwd(r.Context(), update)
+	if isUniqueViolation(err) {
+		requestLogger(r).Info("Failed to update user information", "error", err)
+		helperError(w, http.StatusConflict, errCodeEmailTaken, "Email address already in use", nil)
+		return
+	}
+	if err != nil {
+		requestLogger(r).Error("Failed to update user information", "error", err)
+		helperInternalError(w)
+		return
+	}
+
+	type retUser struct {
+		ID        uuid.UUID `json:"id"`
+		CreatedAt time.Time `json:"created_at"`
+		UpdatedAt time.Time `json:"updated_at"`
+		Email     string    `json:"email"`
+	}
+	rUser := retUser{
+		ID:        user.ID,
+		CreatedAt: user.CreatedAt,
+		UpdatedAt: user.UpdatedAt,
+		Email:     user.Email,
+	}
+	dat, err := json.Marshal(rUser)
+	if err != nil {
+		helperJsonError(w, r, "Error marshalling response", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(dat)
+}
+
+func (cfg *apiConfig) handlerDeleteChirp(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("chirpID")
+	chirp_uuid, err := uuid.Parse(id)
+	if err != nil {
+		requestLogger(r).Info("Error parsing chirpID", "error", err)
+		helperNotFound(w, "Chirp not found")
+		return
+	}
+
+	userId, err := cfg.helperAuthenticate(r, auth.ScopeChirpsWrite)
+	if err != nil {
+		helperAuthError(w, r, err)
+		return
+	}
+
+	chirp, err := cfg.store.GetChirpById(r.Context(), chirp_uuid)
+	if err != nil {
+		requestLogger(r).Info("Error fetching chirp", "error", err)
+		helperNotFound(w, "Chirp not found")
+		return
+	}
+
+	if chirp.UserID != userId {
+		requestLogger(r).Info("User ids do not match - failed to delete")
+		helperError(w, http.StatusForbidden, errCodeForbidden, "Chirp belongs to another user", nil)
+		return
+	}
+
+	err = cfg.store.DeleteChirpById(r.Context(), chirp_uuid)
+	if err != nil {
+		requestLogger(r).Error("Error deleting chirp", "error", err)
+		helperInternalError(w)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlePolkaWebhook(w http.ResponseWriter, r *http.Request) {
+	apiKey, err := auth.GetAPIKey(r.Header)
+	if err != nil {
+		requestLogger(r).Info("Bad header in webhook access", "error", err)
+		helperError(w, http.StatusUnauthorized, errCodeUnauthorized, "Missing API key", nil)
+		return
+	}
+	if apiKey != cfg.polkaKey {
+		requestLogger(r).Info("Unauthorised access to webhook")
+		helperError(w, http.StatusUnauthorized, errCodeUnauthorized, "Invalid API key", nil)
+		return
+	}
+
+	type req struct {
+		Event string `json:"event" validate:"required"`
+		Data  struct {
+			UserId string `json:"user_id"`
+		} `json:"data"`
+	}
+
+	data := req{}
+	if !helperDecodeLenient(w, r, &data) {
+		return
+	}
+
+	if data.Event != "user.upgraded" {
+		requestLogger(r).Info("Ignoring webhook event", "event", data.Event)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	userId, err := uuid.Parse(data.Data.UserId)
+	if err != nil {
+		requestLogger(r).Info("Error parsing uuid", "error", err)
+		helperValidationError(w, "Invalid user_id", []validate.FieldError{
+			{Field: "data.user_id", Message: "must be a UUID"},
+		})
+		return
+	}
+
+	params := database.UpdateUsrChirpyRedParams{
+		ID:          userId,
+		UpdatedAt:   time.Now().Local(),
+		IsChirpyRed: true,
+	}
+	_, err = cfg.store.UpdateUsrChirpyRed(r.Context(), params)
+	if err != nil {
+		requestLogger(r).Info("Error updating user", "error", err)
+		helperNotFound(w, "User not found")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
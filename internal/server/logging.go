@@ -0,0 +1,113 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type contextKey int
+
+const (
+	requestIDKey contextKey = iota
+	requestLogKey
+)
+
+// requestLog is what the logs know about a request in flight. Handlers fill
+// in the user once they have authenticated them.
+type requestLog struct {
+	logger *slog.Logger
+	start  time.Time
+	userID uuid.UUID
+}
+
+// middlewareInstrument gives each request a span and a logger tagged with
+// its request and trace IDs. Once it has been served it writes an access
+// log line, counts it and ends the span.
+func (cfg *apiConfig) middlewareInstrument(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logger := cfg.logger
+		if id, ok := r.Context().Value(requestIDKey).(string); ok {
+			logger = logger.With("request_id", id)
+		}
+		ctx, span := cfg.startRequestSpan(r)
+		if sc := span.SpanContext(); sc.IsValid() {
+			logger = logger.With("trace_id", sc.TraceID().String(), "span_id", sc.SpanID().String())
+		}
+		rl := &requestLog{logger: logger, start: time.Now()}
+		r = r.WithContext(context.WithValue(ctx, requestLogKey, rl))
+
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+
+		// The mux has recorded the matched pattern on r by now.
+		cfg.metrics.observeRequest(r.Pattern, rec.status, time.Since(rl.start))
+		endRequestSpan(span, r, rec.status)
+		requestLogger(r).Info("Request served",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", rec.status,
+			"bytes", rec.bytes,
+			"remote_addr", r.RemoteAddr,
+			"user_agent", r.UserAgent(),
+		)
+	})
+}
+
+// requestLogger returns the logger for r, carrying its request ID, route,
+// user and how long it has been running. Outside middlewareInstrument it is
+// the default logger.
+func requestLogger(r *http.Request) *slog.Logger {
+	rl, ok := r.Context().Value(requestLogKey).(*requestLog)
+	if !ok {
+		return slog.Default()
+	}
+	logger := rl.logger.With("route", r.Pattern)
+	if rl.userID != uuid.Nil {
+		logger = logger.With("user_id", rl.userID)
+	}
+	return logger.With("latency_ms", float64(time.Since(rl.start).Microseconds())/1000)
+}
+
+// setRequestUser records who r is from, for everything logged about it from
+// then on.
+func setRequestUser(r *http.Request, userID uuid.UUID) {
+	if rl, ok := r.Context().Value(requestLogKey).(*requestLog); ok {
+		rl.userID = userID
+	}
+}
+
+// statusRecorder notes the status and size of a response for the access
+// log.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (rec *statusRecorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *statusRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	n, err := rec.ResponseWriter.Write(b)
+	rec.bytes += n
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (rec *statusRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
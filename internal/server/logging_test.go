@@ -0,0 +1,95 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Senaphim/Chirpy/internal/auth"
+	"github.com/Senaphim/Chirpy/internal/store"
+	"github.com/google/uuid"
+)
+
+func TestRequestLogging(t *testing.T) {
+	var logs bytes.Buffer
+	config := testServerConfig(t)
+	config.Logger = slog.New(slog.NewJSONHandler(&logs, nil))
+	handler := NewServer(config, store.NewMemory())
+
+	userId := uuid.New()
+	token, err := auth.MakeJWT(userId, config.Keys, time.Hour)
+	if err != nil {
+		t.Fatalf("MakeJWT error = %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		method    string
+		target    string
+		body      string
+		token     string
+		wantRoute string
+		wantUser  string
+		// wantLog is a line logged by the handler before the access log.
+		wantLog    string
+		wantStatus float64
+	}{
+		{name: "Authenticated", method: "GET", target: "/api/v1/tokens", token: token, wantRoute: "GET /api/v1/tokens", wantUser: userId.String(), wantStatus: 200},
+		{name: "Handler log", method: "POST", target: "/api/v1/chirps", body: "{", token: token, wantRoute: "POST /api/v1/chirps", wantUser: userId.String(), wantLog: "Error decoding request body", wantStatus: 400},
+		{name: "Anonymous", method: "GET", target: "/api/healthz", wantRoute: "GET /api/healthz", wantStatus: 200},
+		{name: "Unmatched", method: "GET", target: "/nowhere", wantStatus: 404},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			logs.Reset()
+			req := httptest.NewRequest(tc.method, tc.target, strings.NewReader(tc.body))
+			req.Header.Set(requestIDHeader, "req-"+strings.ReplaceAll(tc.name, " ", "-"))
+			if tc.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tc.token)
+			}
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			var lines []map[string]any
+			for line := range strings.Lines(logs.String()) {
+				entry := map[string]any{}
+				if err := json.Unmarshal([]byte(line), &entry); err != nil {
+					t.Fatalf("Log line %q is not JSON: %v", line, err)
+				}
+				lines = append(lines, entry)
+			}
+			if len(lines) == 0 {
+				t.Fatalf("Nothing logged")
+			}
+			access := lines[len(lines)-1]
+			if access["msg"] != "Request served" {
+				t.Fatalf("Last log line = %v, want the access log", access)
+			}
+			if access["status"] != tc.wantStatus || access["method"] != tc.method || access["path"] != req.URL.Path {
+				t.Errorf("Access log = %v, want %s %s with status %v", access, tc.method, req.URL.Path, tc.wantStatus)
+			}
+			if _, ok := access["latency_ms"].(float64); !ok {
+				t.Errorf("Access log latency_ms = %v, want a number", access["latency_ms"])
+			}
+			if tc.wantLog != "" && (len(lines) < 2 || lines[0]["msg"] != tc.wantLog) {
+				t.Errorf("Handler logged %v, want %q", lines[:len(lines)-1], tc.wantLog)
+			}
+
+			// Every line about the request carries its ID, route and user.
+			for _, entry := range lines {
+				if entry["request_id"] != req.Header.Get(requestIDHeader) {
+					t.Errorf("Log request_id = %v, want %s", entry["request_id"], req.Header.Get(requestIDHeader))
+				}
+				if entry["route"] != tc.wantRoute {
+					t.Errorf("Log route = %v, want %q", entry["route"], tc.wantRoute)
+				}
+				if user, _ := entry["user_id"].(string); user != tc.wantUser {
+					t.Errorf("Log user_id = %q, want %q", user, tc.wantUser)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Senaphim/Chirpy/internal/metrics"
+)
+
+// serverMetrics are the metrics the server keeps itself. The store's
+// queries are measured by store.QueryMetrics.
+type serverMetrics struct {
+	requests        *metrics.CounterVec
+	requestDuration *metrics.HistogramVec
+	logins          *metrics.CounterVec
+	chirpsCreated   *metrics.Counter
+	fileserverHits  *metrics.Counter
+	legacyRequests  *metrics.CounterVec
+}
+
+func newServerMetrics(reg *metrics.Registry) *serverMetrics {
+	return &serverMetrics{
+		requests:        reg.NewCounter("chirpy_http_requests_total", "HTTP requests served.", "route", "status"),
+		requestDuration: reg.NewHistogram("chirpy_http_request_duration_seconds", "Time taken to serve HTTP requests.", metrics.DefaultBuckets, "route", "status"),
+		logins:          reg.NewCounter("chirpy_logins_total", "Login attempts, by method and result.", "method", "result"),
+		chirpsCreated:   reg.NewCounter("chirpy_chirps_created_total", "Chirps posted.").With(),
+		fileserverHits:  reg.NewCounter("chirpy_fileserver_hits_total", "Requests for the web app's files.").With(),
+		legacyRequests:  reg.NewCounter("chirpy_legacy_requests_total", "Requests to deprecated unversioned API routes.", "route"),
+	}
+}
+
+// observeRequest counts a request served by route, the pattern it matched.
+// Requests matching no route share one label, so clients can't add series
+// by making up paths.
+func (m *serverMetrics) observeRequest(route string, status int, took time.Duration) {
+	if route == "" {
+		route = "unmatched"
+	}
+	m.requests.With(route, strconv.Itoa(status)).Inc()
+	m.requestDuration.With(route, strconv.Itoa(status)).Observe(took.Seconds())
+}
+
+// Login methods and results for chirpy_logins_total.
+const (
+	loginPassword  string = "password"
+	loginTwoFactor string = "two_factor"
+	loginOIDC      string = "oidc"
+	loginAdmin     string = "admin"
+	loginSuccess   string = "success"
+	loginFailure   string = "failure"
+)
+
+func (m *serverMetrics) observeLogin(method, result string) {
+	m.logins.With(method, result).Inc()
+}
+
+func (cfg *apiConfig) middlewareMetricInc(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cfg.metrics.fileserverHits.Inc()
+		next.ServeHTTP(w, r)
+	})
+}
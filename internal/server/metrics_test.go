@@ -0,0 +1,96 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Senaphim/Chirpy/internal/metrics"
+	"github.com/Senaphim/Chirpy/internal/store"
+)
+
+func TestMetrics(t *testing.T) {
+	config := testServerConfig(t)
+	config.Platform = "dev"
+	config.ResetToken = testResetToken
+	config.Metrics = metrics.NewRegistry()
+	st := store.NewMemory()
+	srv := httptest.NewServer(NewServer(config, st))
+	t.Cleanup(srv.Close)
+	c := &testClient{t: t, url: srv.URL}
+
+	get := func(path string) string {
+		t.Helper()
+		resp, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatalf("GET %s error = %v", path, err)
+		}
+		defer resp.Body.Close()
+		dat, _ := io.ReadAll(resp.Body)
+		return string(dat)
+	}
+
+	session := c.signUp("correct horse battery staple")
+	c.do("POST", "/api/v1/login", "", map[string]string{"email": session.Email, "password": "wrong"}, http.StatusUnauthorized, nil)
+	c.do("POST", "/api/v1/chirps", bearer(session.Token), map[string]string{"body": "Hello"}, http.StatusCreated, nil)
+	c.do("POST", "/api/v1/chirps", bearer(session.Token), map[string]string{"body": "Again"}, http.StatusCreated, nil)
+	get("/app/")
+	get("/nowhere")
+	get("/also/nowhere")
+
+	out := get("/metrics")
+	for _, line := range []string{
+		`chirpy_http_requests_total{route="POST /api/v1/chirps",status="201"} 2`,
+		`chirpy_http_requests_total{route="POST /api/v1/login",status="401"} 1`,
+		`chirpy_http_requests_total{route="unmatched",status="404"} 2`,
+		`chirpy_http_request_duration_seconds_count{route="POST /api/v1/chirps",status="201"} 2`,
+		`chirpy_logins_total{method="password",result="failure"} 1`,
+		`chirpy_logins_total{method="password",result="success"} 1`,
+		`chirpy_chirps_created_total 2`,
+		`chirpy_fileserver_hits_total 1`,
+	} {
+		if !strings.Contains(out, line+"\n") {
+			t.Errorf("Metrics are missing %s:\n%s", line, out)
+		}
+	}
+
+	// Resetting clears the dashboard's count, but the Prometheus counter
+	// keeps going up. Logging in again is needed as resetting deletes the
+	// admin too.
+	browser := adminBrowser(t, srv.URL, st, session.Email, "correct horse battery staple")
+	if _, page := browse(t, browser, srv.URL+"/admin"); !strings.Contains(page, "visited 1 times") {
+		t.Errorf("Dashboard before reset = %s", page)
+	}
+	if status, body := postReset(t, srv.URL, testResetToken, ""); status != http.StatusOK {
+		t.Fatalf("Reset = %d %s", status, body)
+	}
+	get("/app/")
+	session = c.signUp("correct horse battery staple")
+	browser = adminBrowser(t, srv.URL, st, session.Email, "correct horse battery staple")
+	if _, page := browse(t, browser, srv.URL+"/admin"); !strings.Contains(page, "visited 1 times") {
+		t.Errorf("Dashboard after reset = %s", page)
+	}
+	if out := get("/metrics"); !strings.Contains(out, "chirpy_fileserver_hits_total 2\n") {
+		t.Errorf("Hits counter after reset:\n%s", out)
+	}
+}
+
+func TestMetricsSharedRegistry(t *testing.T) {
+	config := testServerConfig(t)
+	config.Metrics = metrics.NewRegistry()
+	first := httptest.NewServer(NewServer(config, store.NewMemory()))
+	t.Cleanup(first.Close)
+	second := httptest.NewServer(NewServer(config, store.NewMemory()))
+	t.Cleanup(second.Close)
+
+	for _, srv := range []*httptest.Server{first, second} {
+		(&testClient{t: t, url: srv.URL}).do("GET", "/api/healthz", "", nil, http.StatusOK, nil)
+	}
+	rec := httptest.NewRecorder()
+	config.Metrics.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if line := `chirpy_http_requests_total{route="GET /api/healthz",status="200"} 2`; !strings.Contains(rec.Body.String(), line+"\n") {
+		t.Errorf("Shared metrics are missing %s:\n%s", line, rec.Body.String())
+	}
+}
@@ -0,0 +1,671 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/Senaphim/Chirpy/internal/auth"
+	"github.com/Senaphim/Chirpy/internal/database"
+	"github.com/Senaphim/Chirpy/internal/store"
+	"github.com/Senaphim/Chirpy/internal/validate"
+	"github.com/google/uuid"
+)
+
+const (
+	oauthCodeExpiresIn    time.Duration = 10 * time.Minute
+	oauthAccessExpiresIn  time.Duration = time.Hour
+	oauthRefreshExpiresIn time.Duration = 1440 * time.Hour
+)
+
+var consentTemplate = template.Must(template.New("consent").Parse(`<html>
+  <body>
+    <h1>Authorise {{.ClientName}}</h1>
+    <p>{{.ClientName}} would like to act on your Chirpy account with these permissions:</p>
+    <ul>
+      {{range .Scopes}}<li>{{.}}</li>{{end}}
+    </ul>
+    {{if .Error}}<p><strong>{{.Error}}</strong></p>{{end}}
+    <form method="POST" action="/oauth/authorize">
+      {{range $name, $values := .Params}}{{range $values}}<input type="hidden" name="{{$name}}" value="{{.}}">
+      {{end}}{{end}}
+      <p><label>Email <input type="email" name="email" value="{{.Email}}"></label></p>
+      <p><label>Password <input type="password" name="password"></label></p>
+      <p><label>Authenticator code (if enabled) <input type="text" name="totp_code" autocomplete="one-time-code"></label></p>
+      <button type="submit" name="action" value="approve">Allow</button>
+      <button type="submit" name="action" value="deny">Deny</button>
+    </form>
+  </body>
+</html>
+`))
+
+var oauthErrorTemplate = template.Must(template.New("oauth_error").Parse(`<html>
+  <body>
+    <h1>Authorisation failed</h1>
+    <p>{{.}}</p>
+  </body>
+</html>
+`))
+
+// authorizeRequest is a validated /oauth/authorize request.
+type authorizeRequest struct {
+	client        database.OauthClient
+	redirectURI   string
+	scopes        []string
+	state         string
+	codeChallenge string
+
+	// redirectURIGiven is false when the client left redirect_uri out
+	// and its only registered URI was used.
+	redirectURIGiven bool
+}
+
+// errInvalidClient marks authorize requests that must not be redirected back
+// to the client because the client or redirect URI could not be trusted.
+var errInvalidClient = errors.New("Invalid client or redirect URI")
+
+// helperParseAuthorize validates the parameters of an authorization request.
+// A non-empty error code is reported to the client by redirect, anything
+// wrapping errInvalidClient is shown to the user instead.
+func (cfg *apiConfig) helperParseAuthorize(
+	ctx context.Context,
+	params url.Values,
+) (authorizeRequest, string, error) {
+	req := authorizeRequest{state: params.Get("state")}
+
+	clientId, err := uuid.Parse(params.Get("client_id"))
+	if err != nil {
+		return req, "", fmt.Errorf("%w: bad client_id", errInvalidClient)
+	}
+	req.client, err = cfg.store.GetOAuthClient(ctx, clientId)
+	if err != nil {
+		return req, "", fmt.Errorf("%w: unknown client", errInvalidClient)
+	}
+
+	registered, err := cfg.store.GetOAuthRedirectURIs(ctx, clientId)
+	if err != nil {
+		return req, "", fmt.Errorf("%w: %w", errInvalidClient, err)
+	}
+	req.redirectURI = params.Get("redirect_uri")
+	req.redirectURIGiven = req.redirectURI != ""
+	if req.redirectURI == "" && len(registered) == 1 {
+		req.redirectURI = registered[0]
+	}
+	if !slices.Contains(registered, req.redirectURI) {
+		return req, "", fmt.Errorf("%w: unregistered redirect_uri", errInvalidClient)
+	}
+
+	if params.Get("response_type") != "code" {
+		return req, "unsupported_response_type", errors.New("Only the code response type is supported")
+	}
+
+	req.scopes, err = auth.ValidateScopes(auth.SplitScopes(params.Get("scope")))
+	if err != nil {
+		return req, "invalid_scope", err
+	}
+
+	req.codeChallenge = params.Get("code_challenge")
+	if params.Get("code_challenge_method") != auth.PKCEMethod || !auth.ValidPKCEChallenge(req.codeChallenge) {
+		return req, "invalid_request", errors.New("PKCE with S256 is required")
+	}
+
+	return req, "", nil
+}
+
+// helperOAuthRedirect sends the browser back to the client with the given
+// query parameters and the original state.
+func helperOAuthRedirect(
+	w http.ResponseWriter,
+	r *http.Request,
+	req authorizeRequest,
+	values url.Values,
+) {
+	target, err := url.Parse(req.redirectURI)
+	if err != nil {
+		requestLogger(r).Error("Error parsing redirect URI", "error", err)
+		helperRenderOAuthError(w, r, http.StatusInternalServerError, "Something went wrong.")
+		return
+	}
+
+	query := target.Query()
+	for key, vals := range values {
+		query[key] = vals
+	}
+	if req.state != "" {
+		query.Set("state", req.state)
+	}
+	target.RawQuery = query.Encode()
+
+	http.Redirect(w, r, target.String(), http.StatusFound)
+}
+
+func helperRenderOAuthError(w http.ResponseWriter, r *http.Request, status int, message string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	err := oauthErrorTemplate.Execute(w, message)
+	if err != nil {
+		requestLogger(r).Error("Error rendering OAuth error page", "error", err)
+	}
+}
+
+func helperRenderConsent(
+	w http.ResponseWriter,
+	r *http.Request,
+	status int,
+	req authorizeRequest,
+	params url.Values,
+	email string,
+	message string,
+) {
+	// Only the authorization parameters are carried through the form.
+	hidden := url.Values{}
+	for _, key := range []string{
+		"response_type", "client_id", "redirect_uri", "scope", "state",
+		"code_challenge", "code_challenge_method",
+	} {
+		if params.Has(key) {
+			hidden[key] = params[key]
+		}
+	}
+
+	data := struct {
+		ClientName string
+		Scopes     []string
+		Params     url.Values
+		Email      string
+		Error      string
+	}{
+		ClientName: req.client.Name,
+		Scopes:     req.scopes,
+		Params:     hidden,
+		Email:      email,
+		Error:      message,
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.Header().Set("Content-Security-Policy", "frame-ancestors 'none'")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	err := consentTemplate.Execute(w, data)
+	if err != nil {
+		requestLogger(r).Error("Error rendering consent page", "error", err)
+	}
+}
+
+func (cfg *apiConfig) handleOAuthAuthorize(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	req, errCode, err := cfg.helperParseAuthorize(r.Context(), params)
+	if errors.Is(err, errInvalidClient) {
+		requestLogger(r).Info("Rejected authorization request", "error", err)
+		helperRenderOAuthError(w, r, http.StatusBadRequest, "The application's client ID or redirect URI is not valid.")
+		return
+	}
+	if err != nil {
+		requestLogger(r).Info("Invalid authorization request", "error", err)
+		helperOAuthRedirect(w, r, req, url.Values{
+			"error":             {errCode},
+			"error_description": {err.Error()},
+		})
+		return
+	}
+
+	helperRenderConsent(w, r, http.StatusOK, req, params, "", "")
+}
+
+func (cfg *apiConfig) handleOAuthDecision(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		requestLogger(r).Info("Error parsing consent form", "error", err)
+		helperRenderOAuthError(w, r, http.StatusBadRequest, "The form could not be read.")
+		return
+	}
+	params := r.PostForm
+
+	req, errCode, err := cfg.helperParseAuthorize(r.Context(), params)
+	if errors.Is(err, errInvalidClient) {
+		requestLogger(r).Info("Rejected authorization request", "error", err)
+		helperRenderOAuthError(w, r, http.StatusBadRequest, "The application's client ID or redirect URI is not valid.")
+		return
+	}
+	if err != nil {
+		requestLogger(r).Info("Invalid authorization request", "error", err)
+		helperOAuthRedirect(w, r, req, url.Values{
+			"error":             {errCode},
+			"error_description": {err.Error()},
+		})
+		return
+	}
+
+	if params.Get("action") != "approve" {
+		helperOAuthRedirect(w, r, req, url.Values{"error": {"access_denied"}})
+		return
+	}
+
+	email := params.Get("email")
+	dbUsr, err := cfg.store.GetUserByEmail(r.Context(), email)
+	if err == nil {
+		err = cfg.checkPasswordHash(r.Context(), params.Get("password"), dbUsr.HashedPassword)
+	}
+	if err != nil {
+		requestLogger(r).Info("Bad credentials on consent page", "error", err)
+		helperRenderConsent(w, r, http.StatusUnauthorized, req, params, email, "Incorrect email or password")
+		return
+	}
+	if dbUsr.TotpEnabled {
+		ok, err := cfg.helperCheckTOTP(r.Context(), dbUsr, params.Get("totp_code"))
+		if errors.Is(err, errTotpLocked) {
+			requestLogger(r).Info("Authenticator codes locked out on consent page")
+			helperRenderConsent(w, r, http.StatusTooManyRequests, req, params, email, "Too many incorrect authenticator codes, try again later")
+			return
+		}
+		if err != nil {
+			requestLogger(r).Error("Error checking authenticator code", "error", err)
+			helperRenderOAuthError(w, r, http.StatusInternalServerError, "Something went wrong.")
+			return
+		}
+		if !ok {
+			requestLogger(r).Info("Bad TOTP code on consent page")
+			helperRenderConsent(w, r, http.StatusUnauthorized, req, params, email, "Incorrect authenticator code")
+			return
+		}
+	}
+
+	code, err := auth.MakeAuthorizationCode()
+	if err != nil {
+		requestLogger(r).Error("Error making authorization code", "error", err)
+		helperRenderOAuthError(w, r, http.StatusInternalServerError, "Something went wrong.")
+		return
+	}
+	codeParams := database.CreateOAuthCodeParams{
+		CodeHash:         auth.HashAuthorizationCode(code),
+		CreatedAt:        time.Now().Local(),
+		ClientID:         req.client.ID,
+		UserID:           dbUsr.ID,
+		RedirectUri:      req.redirectURI,
+		Scopes:           auth.JoinScopes(req.scopes),
+		CodeChallenge:    req.codeChallenge,
+		ExpiresAt:        time.Now().Local().Add(oauthCodeExpiresIn),
+		RedirectUriGiven: req.redirectURIGiven,
+	}
+	err = cfg.store.CreateOAuthCode(r.Context(), codeParams)
+	if err != nil {
+		requestLogger(r).Error("Error storing authorization code", "error", err)
+		helperRenderOAuthError(w, r, http.StatusInternalServerError, "Something went wrong.")
+		return
+	}
+
+	helperOAuthRedirect(w, r, req, url.Values{"code": {code}})
+}
+
+// helperOAuthError writes an RFC 6749 section 5.2 error response.
+func helperOAuthError(w http.ResponseWriter, r *http.Request, status int, code, description string) {
+	type responseJson struct {
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description,omitempty"`
+	}
+
+	requestLogger(r).Info("OAuth token error", "code", code, "description", description)
+
+	resp := responseJson{
+		Error:            code,
+		ErrorDescription: description,
+	}
+	dat, err := json.Marshal(resp)
+	if err != nil {
+		requestLogger(r).Error("Error marshalling error response", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	w.Write(dat)
+}
+
+func (cfg *apiConfig) handleOAuthToken(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		helperOAuthError(w, r, http.StatusBadRequest, "invalid_request", "Malformed form body")
+		return
+	}
+	params := r.PostForm
+
+	// Clients may authenticate with HTTP Basic or with form parameters.
+	clientIdStr, clientSecret, hasBasic := r.BasicAuth()
+	if !hasBasic {
+		clientIdStr = params.Get("client_id")
+		clientSecret = params.Get("client_secret")
+	}
+	clientId, err := uuid.Parse(clientIdStr)
+	if err != nil {
+		helperOAuthError(w, r, http.StatusUnauthorized, "invalid_client", "Unknown client")
+		return
+	}
+	client, err := cfg.store.GetOAuthClient(r.Context(), clientId)
+	if err != nil {
+		helperOAuthError(w, r, http.StatusUnauthorized, "invalid_client", "Unknown client")
+		return
+	}
+	if client.SecretHash.Valid && !auth.CheckClientSecret(clientSecret, client.SecretHash.String) {
+		helperOAuthError(w, r, http.StatusUnauthorized, "invalid_client", "Client authentication failed")
+		return
+	}
+
+	switch params.Get("grant_type") {
+	case "authorization_code":
+		cfg.helperAuthorizationCodeGrant(w, r, client, params)
+	case "refresh_token":
+		cfg.helperRefreshTokenGrant(w, r, client, params)
+	default:
+		helperOAuthError(w, r, http.StatusBadRequest, "unsupported_grant_type", "")
+	}
+}
+
+func (cfg *apiConfig) helperAuthorizationCodeGrant(
+	w http.ResponseWriter,
+	r *http.Request,
+	client database.OauthClient,
+	params url.Values,
+) {
+	consumeParams := database.ConsumeOAuthCodeParams{
+		UsedAt: sql.NullTime{
+			Time:  time.Now().Local(),
+			Valid: true,
+		},
+		CodeHash: auth.HashAuthorizationCode(params.Get("code")),
+		// Only the client the code was issued to can use it up, so
+		// another client presenting it leaves it intact.
+		ClientID: client.ID,
+	}
+	code, err := cfg.store.ConsumeOAuthCode(r.Context(), consumeParams)
+	if err != nil {
+		helperOAuthError(w, r, http.StatusBadRequest, "invalid_grant", "Unknown or used authorization code, or issued to another client")
+		return
+	}
+	// RFC 6749 section 4.1.3 only requires redirect_uri when the
+	// authorization request included it. Clients that send it anyway must
+	// still send the right one.
+	given := code.RedirectUriGiven || params.Has("redirect_uri")
+	if given && code.RedirectUri != params.Get("redirect_uri") {
+		helperOAuthError(w, r, http.StatusBadRequest, "invalid_grant", "Code was issued to another redirect URI")
+		return
+	}
+	if time.Now().After(code.ExpiresAt) {
+		helperOAuthError(w, r, http.StatusBadRequest, "invalid_grant", "Authorization code expired")
+		return
+	}
+	if !auth.VerifyPKCE(params.Get("code_verifier"), code.CodeChallenge) {
+		helperOAuthError(w, r, http.StatusBadRequest, "invalid_grant", "PKCE verification failed")
+		return
+	}
+
+	cfg.helperIssueOAuthTokens(w, r, client, code.UserID, auth.SplitScopes(code.Scopes), uuid.New())
+}
+
+func (cfg *apiConfig) helperRefreshTokenGrant(
+	w http.ResponseWriter,
+	r *http.Request,
+	client database.OauthClient,
+	params url.Values,
+) {
+	dbToken, err := cfg.store.GetRefreshToken(r.Context(), auth.HashRefreshToken(params.Get("refresh_token")))
+	if err != nil {
+		helperOAuthError(w, r, http.StatusBadRequest, "invalid_grant", "Unknown refresh token")
+		return
+	}
+	if !dbToken.ClientID.Valid || dbToken.ClientID.UUID != client.ID {
+		helperOAuthError(w, r, http.StatusBadRequest, "invalid_grant", "Refresh token was issued to another client")
+		return
+	}
+	if time.Now().After(dbToken.ExpiresAt) {
+		helperOAuthError(w, r, http.StatusBadRequest, "invalid_grant", "Refresh token expired")
+		return
+	}
+
+	// Refresh tokens are rotated on every use. Only one request can revoke
+	// the token, and one that finds it already revoked is a replay, so the
+	// grant is treated as stolen and every token rotated from it revoked.
+	revokedAt := sql.NullTime{
+		Time:  time.Now().Local(),
+		Valid: true,
+	}
+	revoked := int64(0)
+	if !dbToken.RevokedAt.Valid {
+		revokeParams := database.RevokeRefreshTokenParams{
+			UpdatedAt: time.Now().Local(),
+			RevokedAt: revokedAt,
+			TokenHash: dbToken.TokenHash,
+		}
+		revoked, err = cfg.store.RevokeRefreshToken(r.Context(), revokeParams)
+		if err != nil {
+			requestLogger(r).Error("Error revoking refresh token", "error", err)
+			helperOAuthError(w, r, http.StatusInternalServerError, "server_error", "")
+			return
+		}
+	}
+	if revoked == 0 {
+		requestLogger(r).Info("Revoked refresh token replayed", "session_id", dbToken.ID)
+		familyParams := database.RevokeRefreshTokenFamilyParams{
+			UpdatedAt: time.Now().Local(),
+			RevokedAt: revokedAt,
+			FamilyID:  dbToken.FamilyID,
+		}
+		err = cfg.store.RevokeRefreshTokenFamily(r.Context(), familyParams)
+		if err != nil {
+			requestLogger(r).Error("Error revoking refresh token family", "error", err)
+			helperOAuthError(w, r, http.StatusInternalServerError, "server_error", "")
+			return
+		}
+		helperOAuthError(w, r, http.StatusBadRequest, "invalid_grant", "Refresh token revoked")
+		return
+	}
+
+	cfg.helperIssueOAuthTokens(w, r, client, dbToken.UserID, auth.SplitScopes(dbToken.Scopes), dbToken.FamilyID.UUID)
+}
+
+// helperIssueOAuthTokens responds with a new access and refresh token. The
+// refresh token joins familyId, the grant it was rotated from.
+func (cfg *apiConfig) helperIssueOAuthTokens(
+	w http.ResponseWriter,
+	r *http.Request,
+	client database.OauthClient,
+	userId uuid.UUID,
+	scopes []string,
+	familyId uuid.UUID,
+) {
+	accessToken, err := auth.MakeDelegatedJWT(userId, cfg.keys, oauthAccessExpiresIn, client.ID.String(), scopes)
+	if err != nil {
+		requestLogger(r).Error("Error making access token", "error", err)
+		helperOAuthError(w, r, http.StatusInternalServerError, "server_error", "")
+		return
+	}
+
+	refreshToken, err := auth.MakeRefreshToken()
+	if err != nil {
+		requestLogger(r).Error("Error making refresh token", "error", err)
+		helperOAuthError(w, r, http.StatusInternalServerError, "server_error", "")
+		return
+	}
+	refreshParams := database.CreateRefreshTokenParams{
+		TokenHash:  auth.HashRefreshToken(refreshToken),
+		CreatedAt:  time.Now().Local(),
+		UpdatedAt:  time.Now().Local(),
+		UserID:     userId,
+		ExpiresAt:  time.Now().Local().Add(oauthRefreshExpiresIn),
+		ID:         uuid.New(),
+		DeviceName: client.Name,
+		UserAgent:  r.UserAgent(),
+		IpAddress:  helperClientIP(r),
+		LastUsedAt: time.Now().Local(),
+		ClientID: uuid.NullUUID{
+			UUID:  client.ID,
+			Valid: true,
+		},
+		Scopes: auth.JoinScopes(scopes),
+		FamilyID: uuid.NullUUID{
+			UUID:  familyId,
+			Valid: true,
+		},
+	}
+	_, err = cfg.store.CreateRefreshToken(r.Context(), refreshParams)
+	if err != nil {
+		requestLogger(r).Error("Error storing refresh token", "error", err)
+		helperOAuthError(w, r, http.StatusInternalServerError, "server_error", "")
+		return
+	}
+
+	type retStruct struct {
+		AccessToken  string `json:"access_token"`
+		TokenType    string `json:"token_type"`
+		ExpiresIn    int    `json:"expires_in"`
+		RefreshToken string `json:"refresh_token"`
+		Scope        string `json:"scope"`
+	}
+	rStruct := retStruct{
+		AccessToken:  accessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(oauthAccessExpiresIn.Seconds()),
+		RefreshToken: refreshToken,
+		Scope:        auth.JoinScopes(scopes),
+	}
+	dat, err := json.Marshal(rStruct)
+	if err != nil {
+		requestLogger(r).Error("Error marshalling response", "error", err)
+		helperOAuthError(w, r, http.StatusInternalServerError, "server_error", "")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	w.Write(dat)
+}
+
+// helperValidRedirectURI accepts absolute https URIs, or http for loopback
+// addresses used by native apps.
+func helperValidRedirectURI(raw string) bool {
+	u, err := url.Parse(raw)
+	if err != nil || !u.IsAbs() || u.Fragment != "" {
+		return false
+	}
+	if u.Scheme == "https" {
+		return true
+	}
+	host := u.Hostname()
+	return u.Scheme == "http" && (host == "localhost" || host == "127.0.0.1" || host == "::1")
+}
+
+func (cfg *apiConfig) handlerCreateOAuthClient(w http.ResponseWriter, r *http.Request) {
+	userId, err := cfg.helperAuthenticate(r, "")
+	if err != nil {
+		helperAuthError(w, r, err)
+		return
+	}
+
+	type parameters struct {
+		Name         string   `json:"name" validate:"required,max=100"`
+		RedirectURIs []string `json:"redirect_uris" validate:"required,max=10"`
+		Confidential bool     `json:"confidential"`
+	}
+
+	params := parameters{}
+	if !helperDecode(w, r, &params) {
+		return
+	}
+
+	fieldErrors := []validate.FieldError{}
+	for i, uri := range params.RedirectURIs {
+		if !helperValidRedirectURI(uri) {
+			fieldErrors = append(fieldErrors, validate.FieldError{
+				Field:   "redirect_uris",
+				Message: fmt.Sprintf("%q must be https, or http on a loopback address", uri),
+			})
+		}
+		if slices.Contains(params.RedirectURIs[:i], uri) {
+			fieldErrors = append(fieldErrors, validate.FieldError{
+				Field:   "redirect_uris",
+				Message: fmt.Sprintf("%q is listed more than once", uri),
+			})
+		}
+	}
+	if len(fieldErrors) > 0 {
+		requestLogger(r).Info("Invalid OAuth client registration", "errors", fieldErrors)
+		helperValidationError(w, "Invalid OAuth client", fieldErrors)
+		return
+	}
+
+	// Public clients such as mobile apps cannot keep a secret and rely on
+	// PKCE alone.
+	secret := ""
+	secretHash := sql.NullString{}
+	if params.Confidential {
+		secret, err = auth.MakeRefreshToken()
+		if err != nil {
+			requestLogger(r).Error("Error making client secret", "error", err)
+			helperInternalError(w)
+			return
+		}
+		secretHash = sql.NullString{
+			String: auth.HashClientSecret(secret),
+			Valid:  true,
+		}
+	}
+
+	clientParams := database.CreateOAuthClientParams{
+		ID:         uuid.New(),
+		CreatedAt:  time.Now().Local(),
+		UpdatedAt:  time.Now().Local(),
+		UserID:     userId,
+		Name:       params.Name,
+		SecretHash: secretHash,
+	}
+	var client database.OauthClient
+	err = cfg.store.InTx(r.Context(), func(tx store.Store) error {
+		var err error
+		client, err = tx.CreateOAuthClient(r.Context(), clientParams)
+		if err != nil {
+			return err
+		}
+		for _, uri := range params.RedirectURIs {
+			err = tx.CreateOAuthRedirectURI(r.Context(), database.CreateOAuthRedirectURIParams{
+				ClientID:    client.ID,
+				RedirectUri: uri,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		requestLogger(r).Error("Error storing OAuth client", "error", err)
+		helperInternalError(w)
+		return
+	}
+
+	type retClient struct {
+		ClientID     uuid.UUID `json:"client_id"`
+		Name         string    `json:"name"`
+		RedirectURIs []string  `json:"redirect_uris"`
+		ClientSecret string    `json:"client_secret,omitempty"`
+	}
+	rClient := retClient{
+		ClientID:     client.ID,
+		Name:         client.Name,
+		RedirectURIs: params.RedirectURIs,
+		ClientSecret: secret,
+	}
+	dat, err := json.Marshal(rClient)
+	if err != nil {
+		helperJsonError(w, r, "Error marshalling response", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	w.Write(dat)
+}
@@ -0,0 +1,30 @@
+package server
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+// The specification is maintained by hand, not generated from the handlers.
+// openapi_test.go keeps it honest: it checks the spec against the registered
+// routes and real responses, and each JSON request body schema against the
+// struct its handler decodes into, validate tags included.
+//
+//go:embed api/openapi.json
+var openAPISpec []byte
+
+//go:embed api/docs.html
+var apiDocsPage []byte
+
+func handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	w.WriteHeader(http.StatusOK)
+	w.Write(openAPISpec)
+}
+
+func handleAPIDocs(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write(apiDocsPage)
+}
@@ -0,0 +1,539 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/Senaphim/Chirpy/internal/auth"
+	"github.com/Senaphim/Chirpy/internal/database"
+	"github.com/Senaphim/Chirpy/internal/oidc"
+	"github.com/Senaphim/Chirpy/internal/oidc/oidctest"
+	"github.com/Senaphim/Chirpy/internal/openapi"
+	"github.com/Senaphim/Chirpy/internal/store"
+	"github.com/google/uuid"
+)
+
+// unreachableDB points at a socket that does not exist, so every query fails
+// straight away and handlers take their error paths.
+const unreachableDB = "host=/nonexistent/chirpy-test dbname=chirpy sslmode=disable"
+
+// testOIDCProvider starts a stub OpenID Connect provider and discovers it.
+func testOIDCProvider(t *testing.T) (*oidctest.Provider, *oidc.Provider) {
+	t.Helper()
+	stub, err := oidctest.NewProvider("chirpy", "hunter2")
+	if err != nil {
+		t.Fatalf("NewProvider error = %v", err)
+	}
+	t.Cleanup(stub.Close)
+	oidcConfig := oidc.Config{
+		Issuer:       stub.Issuer(),
+		ClientID:     "chirpy",
+		ClientSecret: "hunter2",
+		RedirectURL:  "http://localhost:8080/api/v1/oidc/callback",
+	}
+	provider, err := oidc.Discover(context.Background(), oidcConfig, nil)
+	if err != nil {
+		t.Fatalf("Discover error = %v", err)
+	}
+	return stub, provider
+}
+
+// testServerConfig returns a Config with cheap password hashing and single
+// sign on against a stub provider.
+func testServerConfig(t *testing.T) Config {
+	t.Helper()
+	_, provider := testOIDCProvider(t)
+
+	return Config{
+		Platform: "test",
+		Keys:     auth.NewHMACKeySet("test-secret"),
+		OIDC:     provider,
+		Hasher: auth.NewPasswordHasher(auth.Argon2Params{
+			Memory:      64,
+			Iterations:  1,
+			Parallelism: 1,
+			SaltLength:  16,
+			KeyLength:   32,
+		}),
+		PolkaKey: "polka-key",
+	}
+}
+
+func testConfig(t *testing.T) *apiConfig {
+	t.Helper()
+	db, err := sql.Open("postgres", unreachableDB)
+	if err != nil {
+		t.Fatalf("sql.Open error = %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	config := testServerConfig(t)
+	config.DB = db
+	return newAPIConfig(config, store.NewPostgres(db))
+}
+
+// specRoute maps a ServeMux pattern to the operation documenting it.
+func specRoute(pattern string) string {
+	method, path, found := strings.Cut(pattern, " ")
+	if !found {
+		method, path = "GET", pattern
+	}
+	if strings.HasSuffix(path, "/") {
+		path += "{path}"
+	}
+	return method + " " + path
+}
+
+func loadSpec(t *testing.T) *openapi.Document {
+	t.Helper()
+	doc, err := openapi.Parse(openAPISpec)
+	if err != nil {
+		t.Fatalf("Parse error = %v", err)
+	}
+	return doc
+}
+
+func TestOpenAPIDocumentsEveryRoute(t *testing.T) {
+	doc := loadSpec(t)
+	documented := doc.Routes()
+
+	cfg := testConfig(t)
+	registered := []string{}
+	for _, pattern := range cfg.routes().patterns {
+		// Legacy aliases are documented once, under /api/v1.
+		if slices.Contains(cfg.legacyRoutes, pattern) {
+			continue
+		}
+		registered = append(registered, specRoute(pattern))
+	}
+	slices.Sort(registered)
+
+	for _, route := range registered {
+		if !slices.Contains(documented, route) {
+			t.Errorf("Route %s is not in the specification", route)
+		}
+	}
+	for _, route := range documented {
+		if !slices.Contains(registered, route) {
+			t.Errorf("Specification documents %s, which is not registered", route)
+		}
+	}
+}
+
+type specCase struct {
+	name        string
+	method      string
+	target      string
+	route       string
+	token       string
+	header      http.Header
+	contentType string
+	body        string
+	// invalid marks bodies that deliberately break the request schema.
+	invalid    bool
+	wantStatus int
+}
+
+func TestOpenAPIResponses(t *testing.T) {
+	doc := loadSpec(t)
+	cfg := testConfig(t)
+	cfg.platform = "dev"
+	cfg.resetToken = testResetToken
+	handler := middlewareRequestID(cfg.routes())
+
+	userId := uuid.New()
+	token, err := auth.MakeJWT(userId, cfg.keys, time.Hour)
+	if err != nil {
+		t.Fatalf("MakeJWT error = %v", err)
+	}
+	readOnly, err := auth.MakeDelegatedJWT(userId, cfg.keys, time.Hour, uuid.NewString(), []string{auth.ScopeChirpsRead})
+	if err != nil {
+		t.Fatalf("MakeDelegatedJWT error = %v", err)
+	}
+	adminSession, err := auth.MakeAdminJWT(userId, cfg.keys, time.Hour)
+	if err != nil {
+		t.Fatalf("MakeAdminJWT error = %v", err)
+	}
+	polka := http.Header{"Authorization": {"ApiKey " + cfg.polkaKey}}
+	refresh := http.Header{"Authorization": {"Bearer not-a-refresh-token"}}
+	adminCookie := http.Header{"Cookie": {adminSessionCookie + "=" + adminSession}}
+	chirpPath := "/api/v1/chirps/" + uuid.NewString()
+	form := "application/x-www-form-urlencoded"
+
+	cases := []specCase{
+		{name: "App", method: "GET", target: "/app/", route: "/app/{path}", wantStatus: 200},
+		{name: "App missing file", method: "GET", target: "/app/missing.txt", route: "/app/{path}", wantStatus: 404},
+		{name: "Health", method: "GET", target: "/api/healthz", wantStatus: 200},
+		{name: "Readiness database down", method: "GET", target: "/api/readyz", wantStatus: 503},
+		{name: "Specification", method: "GET", target: "/api/v1/openapi.json", wantStatus: 200},
+		{name: "Docs", method: "GET", target: "/api/v1/docs", wantStatus: 200},
+		{name: "Old hit counter", method: "GET", target: "/admin/metrics", wantStatus: 301},
+		{name: "Dashboard without session", method: "GET", target: "/admin", wantStatus: 303},
+		{name: "Dashboard database down", method: "GET", target: "/admin", header: adminCookie, wantStatus: 500},
+		{name: "Admin login page", method: "GET", target: "/admin/login", wantStatus: 200},
+		{name: "Admin login database down", method: "POST", target: "/admin/login", contentType: form, body: "email=walt%40breakingbad.com&password=x", wantStatus: 500},
+		{name: "Admin logout", method: "POST", target: "/admin/logout", wantStatus: 303},
+		{name: "Prometheus metrics", method: "GET", target: "/metrics", wantStatus: 200},
+		{name: "Reset without token", method: "POST", target: "/admin/reset", wantStatus: 403},
+		{name: "Reset database down", method: "POST", target: "/admin/reset", header: http.Header{resetTokenHeader: {testResetToken}}, body: `{"tables":["chirps"]}`, wantStatus: 500},
+		{name: "JWKS", method: "GET", target: "/.well-known/jwks.json", wantStatus: 200},
+
+		{name: "List chirps bad author", method: "GET", target: "/api/v1/chirps?author_id=nope", route: "/api/v1/chirps", wantStatus: 400},
+		{name: "Own chirps without token", method: "GET", target: "/api/v1/chirps?author_id=me", route: "/api/v1/chirps", wantStatus: 401},
+		{name: "List chirps database down", method: "GET", target: "/api/v1/chirps?sort=asc", route: "/api/v1/chirps", wantStatus: 500},
+		{name: "Chirp without token", method: "POST", target: "/api/v1/chirps", body: `{"body":"hi"}`, wantStatus: 401},
+		{name: "Chirp without scope", method: "POST", target: "/api/v1/chirps", token: readOnly, body: `{"body":"hi"}`, wantStatus: 403},
+		{name: "Chirp not JSON", method: "POST", target: "/api/v1/chirps", token: token, body: `{"body":`, invalid: true, wantStatus: 400},
+		{name: "Chirp unknown field", method: "POST", target: "/api/v1/chirps", token: token, body: `{"body":"hi","mood":"happy"}`, invalid: true, wantStatus: 400},
+		{name: "Chirp too long", method: "POST", target: "/api/v1/chirps", token: token, body: `{"body":"` + strings.Repeat("a", 141) + `"}`, invalid: true, wantStatus: 422},
+		{name: "Chirp too large", method: "POST", target: "/api/v1/chirps", token: token, body: `{"body":"` + strings.Repeat("a", 1<<20) + `"}`, invalid: true, wantStatus: 413},
+		{name: "Chirp database down", method: "POST", target: "/api/v1/chirps", token: token, body: `{"body":"hi"}`, wantStatus: 500},
+		{name: "Chirp bad ID", method: "GET", target: "/api/v1/chirps/nope", route: "/api/v1/chirps/{chirpID}", wantStatus: 404},
+		{name: "Chirp database down", method: "GET", target: chirpPath, route: "/api/v1/chirps/{chirpID}", wantStatus: 500},
+		{name: "Delete chirp bad ID", method: "DELETE", target: "/api/v1/chirps/nope", route: "/api/v1/chirps/{chirpID}", wantStatus: 404},
+		{name: "Delete chirp without token", method: "DELETE", target: chirpPath, route: "/api/v1/chirps/{chirpID}", wantStatus: 401},
+		{name: "Delete missing chirp", method: "DELETE", target: chirpPath, route: "/api/v1/chirps/{chirpID}", token: token, wantStatus: 404},
+		{name: "Report chirp bad ID", method: "POST", target: "/api/v1/chirps/nope/reports", route: "/api/v1/chirps/{chirpID}/reports", token: token, body: `{"reason":"Spam"}`, wantStatus: 404},
+		{name: "Report chirp without token", method: "POST", target: chirpPath + "/reports", route: "/api/v1/chirps/{chirpID}/reports", body: `{"reason":"Spam"}`, wantStatus: 401},
+		{name: "Report chirp without scope", method: "POST", target: chirpPath + "/reports", route: "/api/v1/chirps/{chirpID}/reports", token: readOnly, body: `{"reason":"Spam"}`, wantStatus: 403},
+		{name: "Report chirp missing reason", method: "POST", target: chirpPath + "/reports", route: "/api/v1/chirps/{chirpID}/reports", token: token, body: `{}`, invalid: true, wantStatus: 422},
+		{name: "Report missing chirp", method: "POST", target: chirpPath + "/reports", route: "/api/v1/chirps/{chirpID}/reports", token: token, body: `{"reason":"Spam"}`, wantStatus: 404},
+
+		{name: "Sign up bad email", method: "POST", target: "/api/v1/users", body: `{"email":"walt","password":"correct horse battery"}`, invalid: true, wantStatus: 422},
+		{name: "Sign up weak password", method: "POST", target: "/api/v1/users", body: `{"email":"walt@breakingbad.com","password":"password"}`, wantStatus: 422},
+		{name: "Sign up database down", method: "POST", target: "/api/v1/users", body: `{"email":"walt@breakingbad.com","password":"correct horse battery"}`, wantStatus: 500},
+		{name: "Update user without token", method: "PUT", target: "/api/v1/users", body: `{"email":"walt@breakingbad.com","password":"correct horse battery"}`, wantStatus: 401},
+		{name: "Update user with OAuth token", method: "PUT", target: "/api/v1/users", token: readOnly, body: `{"email":"walt@breakingbad.com","password":"correct horse battery"}`, wantStatus: 403},
+		{name: "Update user missing email", method: "PUT", target: "/api/v1/users", token: token, body: `{"password":"correct horse battery"}`, invalid: true, wantStatus: 422},
+
+		{name: "Login missing fields", method: "POST", target: "/api/v1/login", body: `{}`, invalid: true, wantStatus: 422},
+		{name: "Login database down", method: "POST", target: "/api/v1/login", body: `{"email":"walt@breakingbad.com","password":"x"}`, wantStatus: 500},
+		{name: "Two factor bad challenge", method: "POST", target: "/api/v1/login/2fa", body: `{"challenge_token":"nope","code":"123456"}`, wantStatus: 401},
+		{name: "Two factor missing challenge", method: "POST", target: "/api/v1/login/2fa", body: `{"code":"123456"}`, invalid: true, wantStatus: 422},
+		{name: "Refresh without token", method: "POST", target: "/api/v1/refresh", wantStatus: 401},
+		{name: "Refresh unknown token", method: "POST", target: "/api/v1/refresh", header: refresh, wantStatus: 401},
+		{name: "Revoke without token", method: "POST", target: "/api/v1/revoke", wantStatus: 401},
+
+		{name: "Sessions without token", method: "GET", target: "/api/v1/sessions", wantStatus: 401},
+		{name: "Sessions database down", method: "GET", target: "/api/v1/sessions", token: token, wantStatus: 500},
+		{name: "Revoke all sessions without token", method: "DELETE", target: "/api/v1/sessions", wantStatus: 401},
+		{name: "Revoke session bad ID", method: "DELETE", target: "/api/v1/sessions/nope", route: "/api/v1/sessions/{sessionID}", wantStatus: 404},
+
+		{name: "Enroll without token", method: "POST", target: "/api/v1/2fa/enroll", wantStatus: 401},
+		{name: "Enroll with OAuth token", method: "POST", target: "/api/v1/2fa/enroll", token: readOnly, wantStatus: 403},
+		{name: "Confirm missing code", method: "POST", target: "/api/v1/2fa/confirm", token: token, body: `{}`, invalid: true, wantStatus: 422},
+		{name: "Disable 2FA without token", method: "DELETE", target: "/api/v1/2fa", body: `{"password":"x","code":"123456"}`, wantStatus: 401},
+		{name: "Disable 2FA missing password", method: "DELETE", target: "/api/v1/2fa", token: token, body: `{"code":"123456"}`, invalid: true, wantStatus: 422},
+		{name: "Regenerate recovery codes with OAuth token", method: "POST", target: "/api/v1/2fa/recovery-codes", token: readOnly, body: `{"password":"x","code":"123456"}`, wantStatus: 403},
+		{name: "Regenerate recovery codes missing code", method: "POST", target: "/api/v1/2fa/recovery-codes", token: token, body: `{"password":"x"}`, invalid: true, wantStatus: 422},
+
+		{name: "Create API token bad scope", method: "POST", target: "/api/v1/tokens", token: token, body: `{"name":"ci","scopes":["admin"]}`, invalid: true, wantStatus: 422},
+		{name: "List API tokens without token", method: "GET", target: "/api/v1/tokens", wantStatus: 401},
+		{name: "Revoke API token bad ID", method: "DELETE", target: "/api/v1/tokens/nope", route: "/api/v1/tokens/{tokenID}", wantStatus: 404},
+		{name: "OAuth client bad redirect", method: "POST", target: "/api/v1/oauth/clients", token: token, body: `{"name":"app","redirect_uris":["http://evil.example/cb"]}`, wantStatus: 422},
+		{name: "OAuth client repeated redirect", method: "POST", target: "/api/v1/oauth/clients", token: token, body: `{"name":"app","redirect_uris":["https://app.example/cb","https://app.example/cb"]}`, wantStatus: 422},
+		{name: "Authorize bad client", method: "GET", target: "/oauth/authorize?client_id=nope", route: "/oauth/authorize", wantStatus: 400},
+		{name: "Decide bad client", method: "POST", target: "/oauth/authorize", contentType: form, body: "client_id=nope", wantStatus: 400},
+		{name: "Token bad client", method: "POST", target: "/oauth/token", contentType: form, body: "grant_type=authorization_code&client_id=nope", wantStatus: 401},
+
+		{name: "Webhook without key", method: "POST", target: "/api/v1/polka/webhooks", body: `{"event":"user.upgraded"}`, wantStatus: 401},
+		{name: "Webhook other event", method: "POST", target: "/api/v1/polka/webhooks", header: polka, body: `{"event":"user.created","data":{"user_id":"` + userId.String() + `"},"attempt":1}`, wantStatus: 204},
+		{name: "Webhook bad user", method: "POST", target: "/api/v1/polka/webhooks", header: polka, body: `{"event":"user.upgraded","data":{"user_id":"nope"}}`, invalid: true, wantStatus: 422},
+
+		{name: "OIDC login database down", method: "GET", target: "/api/v1/oidc/login", wantStatus: 500},
+		{name: "OIDC provider refused", method: "GET", target: "/api/v1/oidc/callback?error=access_denied", route: "/api/v1/oidc/callback", wantStatus: 401},
+		{name: "OIDC link missing password", method: "POST", target: "/api/v1/oidc/link", body: `{"link_token":"x"}`, invalid: true, wantStatus: 422},
+		{name: "OIDC link database down", method: "POST", target: "/api/v1/oidc/link", body: `{"link_token":"x","password":"x"}`, wantStatus: 500},
+	}
+
+	exercised := map[string]bool{}
+	decoded := map[string]bool{}
+	for _, tc := range cases {
+		exercised[tc.method+" "+tc.specPath()] = true
+		t.Run(tc.method+" "+tc.name, func(t *testing.T) {
+			runSpecCase(t, doc, handler, tc, decoded)
+		})
+	}
+
+	for _, route := range doc.Routes() {
+		if !exercised[route] {
+			t.Errorf("No test case exercises %s", route)
+		}
+		method, path, _ := strings.Cut(route, " ")
+		op, err := doc.Operation(method, path)
+		if err != nil {
+			t.Fatalf("Operation error = %v", err)
+		}
+		if op.RequestBody != nil && op.RequestBody.Content["application/json"] != nil && !decoded[route] {
+			t.Errorf("No test case checks the JSON body of %s against what its handler decodes", route)
+		}
+	}
+}
+
+// specPath is the documented path the case exercises.
+func (tc specCase) specPath() string {
+	if tc.route != "" {
+		return tc.route
+	}
+	return tc.target
+}
+
+// runSpecCase sends the request, checks the status and that both the request
+// and the response match the specification, and returns the response. As
+// the specification is written by hand, when the handler decodes a JSON
+// body the documented schema is also checked against the Go type it decodes
+// into, and the route is added to decoded.
+func runSpecCase(t *testing.T, doc *openapi.Document, handler http.Handler, tc specCase, decoded map[string]bool) (*http.Response, []byte) {
+	t.Helper()
+	route := tc.specPath()
+	contentType := tc.contentType
+	if contentType == "" && tc.body != "" {
+		contentType = "application/json"
+	}
+
+	if !tc.invalid {
+		if err := doc.ValidateRequest(tc.method, route, contentType, []byte(tc.body)); err != nil {
+			t.Errorf("Request does not match the specification: %v", err)
+		}
+	}
+
+	req := httptest.NewRequest(tc.method, tc.target, strings.NewReader(tc.body))
+	for key, values := range tc.header {
+		req.Header[key] = values
+	}
+	if tc.token != "" {
+		req.Header.Set("Authorization", "Bearer "+tc.token)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	into := &decodedBody{}
+	req = req.WithContext(context.WithValue(req.Context(), decodedBodyKey{}, into))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if into.typ != nil {
+		if err := doc.ValidateRequestType(tc.method, route, into.typ, into.strict); err != nil {
+			t.Errorf("Request schema does not match what the handler decodes: %v", err)
+		}
+		decoded[tc.method+" "+route] = true
+	}
+
+	resp := rec.Result()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != tc.wantStatus {
+		t.Fatalf("%s %s status = %d, want %d: %s", tc.method, tc.target, resp.StatusCode, tc.wantStatus, body)
+	}
+	if resp.Header.Get(requestIDHeader) == "" {
+		t.Errorf("Response has no %s header", requestIDHeader)
+	}
+	err := doc.ValidateResponse(tc.method, route, resp.StatusCode, resp.Header.Get("Content-Type"), body)
+	if err != nil {
+		t.Errorf("Response does not match the specification: %v\n%s", err, body)
+	}
+	return resp, body
+}
+
+// TestOpenAPISuccessResponses walks through the API against the in-memory
+// store, checking the successful responses against the specification.
+func TestOpenAPISuccessResponses(t *testing.T) {
+	doc := loadSpec(t)
+	config := testServerConfig(t)
+	config.Platform = "dev"
+	config.ResetToken = testResetToken
+	clock := newTestClock()
+	config.Now = clock.Now
+	config.Fixtures = fstest.MapFS{"demo.json": {Data: []byte(`{"users": [{"email": "jesse@breakingbad.com", "password": "correct horse battery"}]}`)}}
+	st := store.NewMemory()
+	handler := NewServer(config, st)
+
+	// call runs one step, decoding a JSON response into out when given.
+	call := func(tc specCase, out any) *http.Response {
+		t.Helper()
+		resp, body := runSpecCase(t, doc, handler, tc, map[string]bool{})
+		if out != nil {
+			if err := json.Unmarshal(body, out); err != nil {
+				t.Fatalf("%s %s response %s: %v", tc.method, tc.target, body, err)
+			}
+		}
+		return resp
+	}
+	creds := `{"email":"walt@breakingbad.com","password":"correct horse battery"}`
+
+	call(specCase{method: "GET", target: "/api/readyz", wantStatus: 200}, nil)
+	call(specCase{method: "GET", target: "/metrics", wantStatus: 200}, nil)
+	call(specCase{method: "POST", target: "/api/v1/users", body: creds, wantStatus: 201}, nil)
+	session := struct {
+		ID           uuid.UUID `json:"id"`
+		Token        string    `json:"token"`
+		RefreshToken string    `json:"refresh_token"`
+	}{}
+	call(specCase{method: "POST", target: "/api/v1/login", body: creds, wantStatus: 200}, &session)
+	refresh := http.Header{"Authorization": {"Bearer " + session.RefreshToken}}
+
+	chirp := struct {
+		ID uuid.UUID `json:"id"`
+	}{}
+	call(specCase{method: "POST", target: "/api/v1/chirps", token: session.Token, body: `{"body":"Say my name"}`, wantStatus: 201}, &chirp)
+	chirpPath := "/api/v1/chirps/" + chirp.ID.String()
+	call(specCase{method: "GET", target: "/api/v1/chirps?author_id=" + session.ID.String(), route: "/api/v1/chirps", wantStatus: 200}, nil)
+	call(specCase{method: "GET", target: chirpPath, route: "/api/v1/chirps/{chirpID}", wantStatus: 200}, nil)
+	call(specCase{method: "POST", target: chirpPath + "/reports", route: "/api/v1/chirps/{chirpID}/reports", token: session.Token, body: `{"reason":"Spam"}`, wantStatus: 201}, nil)
+	call(specCase{method: "DELETE", target: chirpPath, route: "/api/v1/chirps/{chirpID}", token: session.Token, wantStatus: 204}, nil)
+
+	call(specCase{method: "PUT", target: "/api/v1/users", token: session.Token, body: creds, wantStatus: 200}, nil)
+	call(specCase{method: "POST", target: "/api/v1/refresh", header: refresh, wantStatus: 200}, nil)
+
+	apiToken := struct {
+		ID uuid.UUID `json:"id"`
+	}{}
+	call(specCase{method: "POST", target: "/api/v1/tokens", token: session.Token, body: `{"name":"ci","scopes":["chirps:read"],"expires_in_days":30}`, wantStatus: 201}, &apiToken)
+	call(specCase{method: "GET", target: "/api/v1/tokens", token: session.Token, wantStatus: 200}, nil)
+	call(specCase{method: "DELETE", target: "/api/v1/tokens/" + apiToken.ID.String(), route: "/api/v1/tokens/{tokenID}", token: session.Token, wantStatus: 204}, nil)
+
+	polka := http.Header{"Authorization": {"ApiKey " + config.PolkaKey}}
+	upgrade := `{"event":"user.upgraded","data":{"user_id":"` + session.ID.String() + `"}}`
+	call(specCase{method: "POST", target: "/api/v1/polka/webhooks", header: polka, body: upgrade, wantStatus: 204}, nil)
+
+	// Walt is granted admin from the command line and views the dashboard.
+	_, err := st.UpdateUsrAdmin(context.Background(), database.UpdateUsrAdminParams{Email: "walt@breakingbad.com", IsAdmin: true})
+	if err != nil {
+		t.Fatalf("UpdateUsrAdmin error = %v", err)
+	}
+	form := "application/x-www-form-urlencoded"
+	call(specCase{method: "GET", target: "/admin/login", wantStatus: 200}, nil)
+	adminLogin := url.Values{"email": {"walt@breakingbad.com"}, "password": {"correct horse battery"}}
+	resp := call(specCase{method: "POST", target: "/admin/login", contentType: form, body: adminLogin.Encode(), wantStatus: 303}, nil)
+	adminCookie := http.Header{"Cookie": {}}
+	for _, cookie := range resp.Cookies() {
+		adminCookie.Add("Cookie", cookie.Name+"="+cookie.Value)
+	}
+	call(specCase{method: "GET", target: "/admin", header: adminCookie, wantStatus: 200}, nil)
+	call(specCase{method: "POST", target: "/admin/logout", header: adminCookie, wantStatus: 303}, nil)
+
+	// An OAuth client going through the authorization code flow with PKCE.
+	client := struct {
+		ClientID string `json:"client_id"`
+	}{}
+	call(specCase{method: "POST", target: "/api/v1/oauth/clients", token: session.Token, body: `{"name":"app","redirect_uris":["http://localhost:3000/cb"]}`, wantStatus: 201}, &client)
+	verifier := strings.Repeat("v", 43)
+	authorize := url.Values{
+		"client_id":             {client.ClientID},
+		"response_type":         {"code"},
+		"scope":                 {"chirps:read"},
+		"code_challenge":        {auth.PKCEChallenge(verifier)},
+		"code_challenge_method": {auth.PKCEMethod},
+	}
+	call(specCase{method: "GET", target: "/oauth/authorize?" + authorize.Encode(), route: "/oauth/authorize", wantStatus: 200}, nil)
+	decision := url.Values{"action": {"approve"}, "email": {"walt@breakingbad.com"}, "password": {"correct horse battery"}}
+	for key, values := range authorize {
+		decision[key] = values
+	}
+	resp = call(specCase{method: "POST", target: "/oauth/authorize", contentType: form, body: decision.Encode(), wantStatus: 302}, nil)
+	location, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatalf("Bad redirect %q: %v", resp.Header.Get("Location"), err)
+	}
+	exchange := url.Values{
+		"grant_type":    {"authorization_code"},
+		"client_id":     {client.ClientID},
+		"code":          {location.Query().Get("code")},
+		"redirect_uri":  {"http://localhost:3000/cb"},
+		"code_verifier": {verifier},
+	}
+	grant := struct {
+		RefreshToken string `json:"refresh_token"`
+	}{}
+	call(specCase{method: "POST", target: "/oauth/token", contentType: form, body: exchange.Encode(), wantStatus: 200}, &grant)
+	rotate := func(refreshToken string, wantStatus int) string {
+		t.Helper()
+		values := url.Values{"grant_type": {"refresh_token"}, "client_id": {client.ClientID}, "refresh_token": {refreshToken}}
+		rotated := grant
+		call(specCase{method: "POST", target: "/oauth/token", contentType: form, body: values.Encode(), wantStatus: wantStatus}, &rotated)
+		return rotated.RefreshToken
+	}
+	rotated := rotate(grant.RefreshToken, 200)
+	// Replaying a rotated token revokes every token from the grant.
+	rotate(grant.RefreshToken, 400)
+	rotate(rotated, 400)
+
+	call(specCase{method: "GET", target: "/api/v1/oidc/login", wantStatus: 302}, nil)
+
+	enrollment := struct {
+		Secret string `json:"secret"`
+	}{}
+	call(specCase{method: "POST", target: "/api/v1/2fa/enroll", token: session.Token, wantStatus: 200}, &enrollment)
+	code, err := auth.TOTPCode(enrollment.Secret, clock.Now())
+	if err != nil {
+		t.Fatalf("TOTPCode error = %v", err)
+	}
+	call(specCase{method: "POST", target: "/api/v1/2fa/confirm", token: session.Token, body: `{"code":"` + code + `"}`, wantStatus: 200}, nil)
+	// Each code is only accepted once.
+	clock.Advance(auth.TOTPPeriod)
+	code, err = auth.TOTPCode(enrollment.Secret, clock.Now())
+	if err != nil {
+		t.Fatalf("TOTPCode error = %v", err)
+	}
+	challenge := struct {
+		ChallengeToken string `json:"challenge_token"`
+	}{}
+	call(specCase{method: "POST", target: "/api/v1/login", body: creds, wantStatus: 200}, &challenge)
+	secondFactor := `{"challenge_token":"` + challenge.ChallengeToken + `","code":"` + code + `","device_name":"laptop"}`
+	call(specCase{method: "POST", target: "/api/v1/login/2fa", body: secondFactor, wantStatus: 200}, nil)
+
+	call(specCase{method: "POST", target: "/api/v1/revoke", header: refresh, wantStatus: 204}, nil)
+	sessions := []struct {
+		ID uuid.UUID `json:"id"`
+	}{}
+	call(specCase{method: "GET", target: "/api/v1/sessions", token: session.Token, wantStatus: 200}, &sessions)
+	call(specCase{method: "DELETE", target: "/api/v1/sessions/" + sessions[0].ID.String(), route: "/api/v1/sessions/{sessionID}", token: session.Token, wantStatus: 204}, nil)
+	call(specCase{method: "DELETE", target: "/api/v1/sessions", token: session.Token, wantStatus: 204}, nil)
+	resetToken := http.Header{resetTokenHeader: {testResetToken}}
+	call(specCase{method: "POST", target: "/admin/reset", header: resetToken, body: `{"tables":["chirps"],"fixture":"demo.json"}`, wantStatus: 200}, nil)
+	call(specCase{method: "POST", target: "/admin/reset", header: resetToken, wantStatus: 200}, nil)
+}
+
+func TestRequestIDIsPropagated(t *testing.T) {
+	handler := middlewareRequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		helperNotFound(w, "Nothing here")
+	}))
+
+	tests := []struct {
+		name     string
+		incoming string
+		keep     bool
+	}{
+		{name: "Generated", incoming: "", keep: false},
+		{name: "Propagated", incoming: "abc-123.def_4", keep: true},
+		{name: "Unsafe replaced", incoming: "abc\n123", keep: false},
+		{name: "Too long replaced", incoming: strings.Repeat("a", 129), keep: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			if tt.incoming != "" {
+				req.Header.Set(requestIDHeader, tt.incoming)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			got := rec.Header().Get(requestIDHeader)
+			if (got == tt.incoming) != tt.keep || got == "" {
+				t.Errorf("%s = %q for incoming %q", requestIDHeader, got, tt.incoming)
+			}
+			if !strings.Contains(rec.Body.String(), `"request_id":"`+got+`"`) {
+				t.Errorf("Error body %s lacks the request ID", rec.Body.String())
+			}
+		})
+	}
+}
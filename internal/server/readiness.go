@@ -0,0 +1,78 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// readyTimeout bounds the checks behind /api/readyz, so a hung database
+// fails the probe rather than stalling it.
+const readyTimeout = 2 * time.Second
+
+type readyCheck struct {
+	Status  string `json:"status"`
+	Error   string `json:"error,omitempty"`
+	Version *int64 `json:"version,omitempty"`
+	Latest  *int64 `json:"latest,omitempty"`
+}
+
+// handleReady reports whether the server can take traffic: the database
+// answers and every migration is applied. Unlike /api/healthz, a failing
+// check here means "route traffic elsewhere", not "restart me".
+func (cfg *apiConfig) handleReady(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), readyTimeout)
+	defer cancel()
+
+	type retStruct struct {
+		Status string                `json:"status"`
+		Checks map[string]readyCheck `json:"checks"`
+	}
+	ret := retStruct{Status: "ready", Checks: map[string]readyCheck{}}
+
+	if cfg.db != nil {
+		check := readyCheck{Status: "ok"}
+		if err := cfg.db.PingContext(ctx); err != nil {
+			requestLogger(r).Error("Error pinging database", "error", err)
+			check = readyCheck{Status: "failing", Error: "Database is unreachable"}
+		}
+		ret.Checks["database"] = check
+	}
+
+	if cfg.migrator != nil {
+		latest := cfg.migrator.Latest()
+		check := readyCheck{Status: "ok", Latest: &latest}
+		version, err := cfg.migrator.Version(ctx)
+		if err != nil {
+			requestLogger(r).Error("Error reading migration version", "error", err)
+			check.Status = "failing"
+			check.Error = "Migration version is unknown"
+		} else {
+			check.Version = &version
+			if version < latest {
+				check.Status = "failing"
+				check.Error = "Migrations are pending"
+			}
+		}
+		ret.Checks["migrations"] = check
+	}
+
+	status := http.StatusOK
+	for _, check := range ret.Checks {
+		if check.Status != "ok" {
+			ret.Status = "unavailable"
+			status = http.StatusServiceUnavailable
+		}
+	}
+
+	dat, err := json.Marshal(ret)
+	if err != nil {
+		helperJsonError(w, r, "Error marshalling response", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	w.Write(dat)
+}
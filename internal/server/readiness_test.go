@@ -0,0 +1,90 @@
+package server
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/Senaphim/Chirpy/internal/migrate"
+	"github.com/Senaphim/Chirpy/internal/store"
+)
+
+func TestReadiness(t *testing.T) {
+	db, err := store.OpenSQLite(filepath.Join(t.TempDir(), "chirpy.db"))
+	if err != nil {
+		t.Fatalf("OpenSQLite error = %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	migrator, err := migrate.New(db, os.DirFS("../../sql/sqlite/schema"))
+	if err != nil {
+		t.Fatalf("migrate.New error = %v", err)
+	}
+	// A build that knows one more migration than has been applied.
+	newer, err := migrate.New(db, fstest.MapFS{
+		"001_users.sql":  {Data: []byte("-- +goose Up\nSELECT 1;\n")},
+		"999_future.sql": {Data: []byte("-- +goose Up\nSELECT 1;\n")},
+	})
+	if err != nil {
+		t.Fatalf("migrate.New error = %v", err)
+	}
+	unreachable, err := sql.Open("postgres", unreachableDB)
+	if err != nil {
+		t.Fatalf("sql.Open error = %v", err)
+	}
+	t.Cleanup(func() { unreachable.Close() })
+
+	type check struct {
+		Status string `json:"status"`
+	}
+	tests := []struct {
+		name           string
+		db             *sql.DB
+		migrator       *migrate.Migrator
+		migrate        bool
+		wantStatus     int
+		wantDatabase   string
+		wantMigrations string
+	}{
+		{name: "In-memory store", wantStatus: http.StatusOK},
+		{name: "Not migrated", db: db, migrator: migrator, wantStatus: http.StatusServiceUnavailable, wantDatabase: "ok", wantMigrations: "failing"},
+		{name: "Migrated", db: db, migrator: migrator, migrate: true, wantStatus: http.StatusOK, wantDatabase: "ok", wantMigrations: "ok"},
+		{name: "Migrations pending", db: db, migrator: newer, wantStatus: http.StatusServiceUnavailable, wantDatabase: "ok", wantMigrations: "failing"},
+		{name: "Database down", db: unreachable, wantStatus: http.StatusServiceUnavailable, wantDatabase: "failing"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.migrate {
+				if _, err := tc.migrator.Up(t.Context()); err != nil {
+					t.Fatalf("Up error = %v", err)
+				}
+			}
+			config := testServerConfig(t)
+			config.DB = tc.db
+			config.Migrator = tc.migrator
+			handler := NewServer(config, store.NewMemory())
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest("GET", "/api/readyz", nil))
+			if rec.Code != tc.wantStatus {
+				t.Errorf("Status = %d, want %d", rec.Code, tc.wantStatus)
+			}
+			var ret struct {
+				Checks map[string]check `json:"checks"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &ret); err != nil {
+				t.Fatalf("Unmarshal error = %v", err)
+			}
+			if got := ret.Checks["database"].Status; got != tc.wantDatabase {
+				t.Errorf("Database check = %q, want %q", got, tc.wantDatabase)
+			}
+			if got := ret.Checks["migrations"].Status; got != tc.wantMigrations {
+				t.Errorf("Migrations check = %q, want %q", got, tc.wantMigrations)
+			}
+		})
+	}
+}
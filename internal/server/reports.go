@@ -0,0 +1,82 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/Senaphim/Chirpy/internal/auth"
+	"github.com/Senaphim/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// handlerReportChirp flags a chirp for the admins, who see the latest
+// reports on the dashboard. Each user can report a chirp once.
+func (cfg *apiConfig) handlerReportChirp(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Reason string `json:"reason" validate:"required,max=280"`
+	}
+
+	chirpId, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		requestLogger(r).Info("Error parsing chirpID", "error", err)
+		helperNotFound(w, "Chirp not found")
+		return
+	}
+
+	userId, err := cfg.helperAuthenticate(r, auth.ScopeChirpsWrite)
+	if err != nil {
+		helperAuthError(w, r, err)
+		return
+	}
+
+	params := parameters{}
+	if !helperDecode(w, r, &params) {
+		return
+	}
+
+	if _, err := cfg.store.GetChirpById(r.Context(), chirpId); err != nil {
+		requestLogger(r).Info("Error fetching chirp", "error", err)
+		helperNotFound(w, "Chirp not found")
+		return
+	}
+
+	report, err := cfg.store.CreateReport(r.Context(), database.CreateReportParams{
+		ID:         uuid.New(),
+		CreatedAt:  cfg.now().Local(),
+		ChirpID:    chirpId,
+		ReporterID: userId,
+		Reason:     params.Reason,
+	})
+	if isUniqueViolation(err) {
+		requestLogger(r).Info("Chirp already reported by user", "error", err)
+		helperError(w, http.StatusConflict, errCodeConflict, "You have already reported this chirp", nil)
+		return
+	}
+	if err != nil {
+		requestLogger(r).Error("Error creating report", "error", err)
+		helperInternalError(w)
+		return
+	}
+
+	type returnVals struct {
+		Id        uuid.UUID `json:"id"`
+		CreatedAt time.Time `json:"created_at"`
+		ChirpId   uuid.UUID `json:"chirp_id"`
+		Reason    string    `json:"reason"`
+	}
+
+	dat, err := json.Marshal(returnVals{
+		Id:        report.ID,
+		CreatedAt: report.CreatedAt,
+		ChirpId:   report.ChirpID,
+		Reason:    report.Reason,
+	})
+	if err != nil {
+		helperJsonError(w, r, "Error marshalling response", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	w.Write(dat)
+}
@@ -0,0 +1,93 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/Senaphim/Chirpy/internal/validate"
+)
+
+// maxBodyBytes caps JSON request bodies. Chirps are at most 140 characters,
+// so nothing legitimate comes close.
+const maxBodyBytes int64 = 1 << 20
+
+// helperDecode reads a single JSON object from the request body into dst and
+// applies the validate tags on dst. Unknown fields are rejected. On failure
+// the error response has been written and false is returned.
+func helperDecode(w http.ResponseWriter, r *http.Request, dst any) bool {
+	return helperDecodeBody(w, r, dst, true)
+}
+
+// helperDecodeLenient is helperDecode for bodies sent by third parties, such
+// as webhooks, which may gain fields we do not know about.
+func helperDecodeLenient(w http.ResponseWriter, r *http.Request, dst any) bool {
+	return helperDecodeBody(w, r, dst, false)
+}
+
+// decodedBody records what a handler decoded its body into. Tests put one in
+// the request's context to check the specification documents exactly that.
+type decodedBody struct {
+	typ    reflect.Type
+	strict bool
+}
+
+type decodedBodyKey struct{}
+
+func helperDecodeBody(w http.ResponseWriter, r *http.Request, dst any, strict bool) bool {
+	if decoded, ok := r.Context().Value(decodedBodyKey{}).(*decodedBody); ok {
+		decoded.typ, decoded.strict = reflect.TypeOf(dst), strict
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxBodyBytes)
+	decoder := json.NewDecoder(r.Body)
+	if strict {
+		decoder.DisallowUnknownFields()
+	}
+
+	err := decoder.Decode(dst)
+	if err == nil && decoder.Decode(&struct{}{}) != io.EOF {
+		err = errors.New("Body must contain a single JSON object")
+	}
+	if err != nil {
+		requestLogger(r).Info("Error decoding request body", "error", err)
+		helperBodyError(w, err)
+		return false
+	}
+
+	if fieldErrors := validate.Struct(dst); len(fieldErrors) > 0 {
+		requestLogger(r).Info("Request failed validation", "errors", fieldErrors)
+		helperValidationError(w, "Request validation failed", fieldErrors)
+		return false
+	}
+
+	return true
+}
+
+// helperBodyError maps a decoding error to the most helpful response.
+func helperBodyError(w http.ResponseWriter, err error) {
+	maxBytesErr := &http.MaxBytesError{}
+	typeErr := &json.UnmarshalTypeError{}
+
+	switch {
+	case errors.As(err, &maxBytesErr):
+		message := fmt.Sprintf("Request body must not be larger than %d bytes", maxBytesErr.Limit)
+		helperError(w, http.StatusRequestEntityTooLarge, errCodeTooLarge, message, nil)
+	case errors.Is(err, io.EOF):
+		helperError(w, http.StatusBadRequest, errCodeInvalidJSON, "Request body must not be empty", nil)
+	case errors.As(err, &typeErr):
+		helperError(w, http.StatusBadRequest, errCodeInvalidJSON, "Request body has a field of the wrong type", []validate.FieldError{
+			{Field: typeErr.Field, Message: fmt.Sprintf("must be of type %s", typeErr.Type)},
+		})
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+		helperError(w, http.StatusBadRequest, errCodeInvalidJSON, "Request body has an unknown field", []validate.FieldError{
+			{Field: field, Message: "is not a known field"},
+		})
+	default:
+		helperError(w, http.StatusBadRequest, errCodeInvalidJSON, "Request body is not valid JSON", nil)
+	}
+}
@@ -0,0 +1,279 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/Senaphim/Chirpy/internal/auth"
+	"github.com/Senaphim/Chirpy/internal/database"
+	"github.com/Senaphim/Chirpy/internal/store"
+	"github.com/Senaphim/Chirpy/internal/validate"
+	"github.com/google/uuid"
+)
+
+// resetTokenHeader carries the confirmation POST /admin/reset needs.
+const resetTokenHeader string = "X-Reset-Token"
+
+// fixture is what a fixture file seeds the database with. Chirps name their
+// author by email, who is either one of the fixture's users or already in
+// the database.
+type fixture struct {
+	Users []struct {
+		Email       string `json:"email" validate:"required,email,max=254"`
+		Password    string `json:"password" validate:"required"`
+		IsChirpyRed bool   `json:"is_chirpy_red"`
+		IsAdmin     bool   `json:"is_admin"`
+	} `json:"users"`
+	Chirps []struct {
+		Author string `json:"author" validate:"required,email"`
+		Body   string `json:"body" validate:"required,max=140"`
+	} `json:"chirps"`
+}
+
+// errUnknownAuthor means a fixture chirp's author doesn't exist.
+var errUnknownAuthor = errors.New("no user with the author's email")
+
+type resetResult struct {
+	Tables []string `json:"tables"`
+	Users  int      `json:"users"`
+	Chirps int      `json:"chirps"`
+}
+
+// handleReset empties the chosen tables, or all of them, and seeds the
+// database from a fixture, all in one transaction. As it can wipe the
+// database it needs PLATFORM=dev and the configured reset token.
+func (cfg *apiConfig) handleReset(w http.ResponseWriter, r *http.Request) {
+	if cfg.platform != "dev" {
+		helperError(w, http.StatusForbidden, errCodeForbidden, "Reset is only available in development", nil)
+		return
+	}
+	if cfg.resetToken == "" {
+		helperError(w, http.StatusForbidden, errCodeForbidden, "Reset needs RESET_TOKEN to be configured", nil)
+		return
+	}
+	token := r.Header.Get(resetTokenHeader)
+	if subtle.ConstantTimeCompare([]byte(token), []byte(cfg.resetToken)) != 1 {
+		requestLogger(r).Info("Reset with a missing or wrong token")
+		helperError(w, http.StatusForbidden, errCodeForbidden, "Missing or wrong "+resetTokenHeader, nil)
+		return
+	}
+
+	params := struct {
+		Tables  []string `json:"tables"`
+		Fixture string   `json:"fixture"`
+	}{}
+	// Without a body everything is reset and nothing seeded.
+	if r.ContentLength != 0 && !helperDecode(w, r, &params) {
+		return
+	}
+	for _, table := range params.Tables {
+		if !slices.Contains(store.Tables, table) {
+			helperValidationError(w, "Invalid tables", []validate.FieldError{
+				{Field: "tables", Message: fmt.Sprintf("%q is not one of %v", table, store.Tables)},
+			})
+			return
+		}
+	}
+
+	var seed fixture
+	if params.Fixture != "" {
+		var ok bool
+		if seed, ok = cfg.helperLoadFixture(w, r, params.Fixture); !ok {
+			return
+		}
+	}
+
+	// Passwords are hashed before the transaction so it isn't held open
+	// while they are.
+	hashes := make([]string, len(seed.Users))
+	for i, user := range seed.Users {
+		hash, err := cfg.hashPassword(r.Context(), user.Password)
+		if err != nil {
+			requestLogger(r).Error("Error hashing fixture password", "error", err)
+			helperInternalError(w)
+			return
+		}
+		hashes[i] = hash
+	}
+
+	err := cfg.store.InTx(r.Context(), func(tx store.Store) error {
+		if err := store.Reset(r.Context(), tx, params.Tables); err != nil {
+			return err
+		}
+		return helperSeed(r.Context(), tx, seed, hashes)
+	})
+	if errors.Is(err, errUnknownAuthor) {
+		requestLogger(r).Info("Fixture chirp has an unknown author", "error", err)
+		helperValidationError(w, "Invalid fixture", []validate.FieldError{
+			{Field: "fixture", Message: err.Error()},
+		})
+		return
+	}
+	if isUniqueViolation(err) {
+		requestLogger(r).Info("Fixture clashes with rows kept", "error", err)
+		helperError(w, http.StatusConflict, errCodeConflict, "Fixture clashes with rows that were not reset", nil)
+		return
+	}
+	if err != nil {
+		requestLogger(r).Error("Error resetting the database", "error", err)
+		helperInternalError(w)
+		return
+	}
+
+	tables := params.Tables
+	if len(tables) == 0 {
+		tables = store.Tables
+		cfg.hitsAtReset.Store(uint64(cfg.metrics.fileserverHits.Value()))
+	}
+	requestLogger(r).Info("Reset the database", "tables", tables, "fixture", params.Fixture)
+
+	dat, err := json.Marshal(resetResult{Tables: tables, Users: len(seed.Users), Chirps: len(seed.Chirps)})
+	if err != nil {
+		helperJsonError(w, r, "Error marshalling response", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(dat)
+}
+
+// helperLoadFixture reads and checks the named fixture, writing the error
+// response when it can't.
+func (cfg *apiConfig) helperLoadFixture(w http.ResponseWriter, r *http.Request, name string) (fixture, bool) {
+	invalid := func(message string) (fixture, bool) {
+		helperValidationError(w, "Invalid fixture", []validate.FieldError{
+			{Field: "fixture", Message: message},
+		})
+		return fixture{}, false
+	}
+	if cfg.fixtures == nil {
+		return invalid("no FIXTURES_DIR is configured")
+	}
+	if !fs.ValidPath(name) {
+		return invalid("must be a path within FIXTURES_DIR")
+	}
+
+	dat, err := fs.ReadFile(cfg.fixtures, name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return invalid(fmt.Sprintf("no fixture %q", name))
+	}
+	if err != nil {
+		requestLogger(r).Error("Error reading fixture", "error", err)
+		helperInternalError(w)
+		return fixture{}, false
+	}
+
+	var seed fixture
+	decoder := json.NewDecoder(bytes.NewReader(dat))
+	decoder.DisallowUnknownFields()
+	err = decoder.Decode(&seed)
+	if err == nil && decoder.Decode(&struct{}{}) != io.EOF {
+		err = errors.New("must contain a single JSON object")
+	}
+	if err != nil {
+		requestLogger(r).Info("Error decoding fixture", "error", err)
+		return invalid(fmt.Sprintf("%s: %v", name, err))
+	}
+
+	// validate doesn't look inside slices, so each entry is checked here.
+	var fieldErrors []validate.FieldError
+	for i, user := range seed.Users {
+		for _, fe := range validate.Struct(user) {
+			fe.Field = fmt.Sprintf("users[%d].%s", i, fe.Field)
+			fieldErrors = append(fieldErrors, fe)
+		}
+	}
+	for i, chirp := range seed.Chirps {
+		for _, fe := range validate.Struct(chirp) {
+			fe.Field = fmt.Sprintf("chirps[%d].%s", i, fe.Field)
+			fieldErrors = append(fieldErrors, fe)
+		}
+	}
+	// Seeded users and chirps must be ones the API itself would accept.
+	for i, user := range seed.Users {
+		err := cfg.passwordPolicy.Check(user.Password)
+		policyErr := &auth.PasswordPolicyError{}
+		if err != nil && !errors.As(err, &policyErr) {
+			requestLogger(r).Error("Error checking password policy", "error", err)
+			helperInternalError(w)
+			return fixture{}, false
+		}
+		for _, v := range policyErr.Violations {
+			fieldErrors = append(fieldErrors, validate.FieldError{
+				Field:   fmt.Sprintf("users[%d].password", i),
+				Message: v.Message,
+			})
+		}
+	}
+	for i := range seed.Chirps {
+		seed.Chirps[i].Body = helperCleanString(seed.Chirps[i].Body)
+	}
+	if len(fieldErrors) > 0 {
+		requestLogger(r).Info("Fixture failed validation", "errors", fieldErrors)
+		helperValidationError(w, "Invalid fixture", fieldErrors)
+		return fixture{}, false
+	}
+
+	return seed, true
+}
+
+// helperSeed adds the fixture's users, with the given password hashes, and
+// then its chirps.
+func helperSeed(ctx context.Context, st store.Store, seed fixture, hashes []string) error {
+	now := time.Now().Local()
+	for i, user := range seed.Users {
+		dbUsr, err := st.CreateUser(ctx, database.CreateUserParams{
+			ID:             uuid.New(),
+			CreatedAt:      now,
+			UpdatedAt:      now,
+			Email:          user.Email,
+			HashedPassword: hashes[i],
+		})
+		if err != nil {
+			return fmt.Errorf("seeding user %s: %w", user.Email, err)
+		}
+		if user.IsChirpyRed {
+			_, err = st.UpdateUsrChirpyRed(ctx, database.UpdateUsrChirpyRedParams{ID: dbUsr.ID, UpdatedAt: now, IsChirpyRed: true})
+			if err != nil {
+				return fmt.Errorf("upgrading user %s: %w", user.Email, err)
+			}
+		}
+		if user.IsAdmin {
+			_, err = st.UpdateUsrAdmin(ctx, database.UpdateUsrAdminParams{Email: user.Email, UpdatedAt: now, IsAdmin: true})
+			if err != nil {
+				return fmt.Errorf("granting admin to %s: %w", user.Email, err)
+			}
+		}
+	}
+
+	for _, chirp := range seed.Chirps {
+		author, err := st.GetUserByEmail(ctx, chirp.Author)
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("chirp by %s: %w", chirp.Author, errUnknownAuthor)
+		}
+		if err != nil {
+			return fmt.Errorf("finding author %s: %w", chirp.Author, err)
+		}
+		_, err = st.CreateChirp(ctx, database.CreateChirpParams{
+			ID:        uuid.New(),
+			CreatedAt: now,
+			UpdatedAt: now,
+			Body:      chirp.Body,
+			UserID:    author.ID,
+		})
+		if err != nil {
+			return fmt.Errorf("seeding chirp by %s: %w", chirp.Author, err)
+		}
+	}
+	return nil
+}
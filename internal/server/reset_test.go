@@ -0,0 +1,166 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"testing/fstest"
+
+	"github.com/Senaphim/Chirpy/internal/store"
+)
+
+const testResetToken = "reset-token-for-tests"
+
+// postReset calls POST /admin/reset with token and body, returning the
+// status and the body of the response.
+func postReset(t *testing.T, serverURL, token, body string) (int, []byte) {
+	t.Helper()
+	req, err := http.NewRequest("POST", serverURL+"/admin/reset", bytes.NewBufferString(body))
+	if err != nil {
+		t.Fatalf("NewRequest error = %v", err)
+	}
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set(resetTokenHeader, token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("POST /admin/reset error = %v", err)
+	}
+	defer resp.Body.Close()
+	dat, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, dat
+}
+
+func TestReset(t *testing.T) {
+	demo, err := os.ReadFile("../../fixtures/demo.json")
+	if err != nil {
+		t.Fatalf("ReadFile error = %v", err)
+	}
+	config := testServerConfig(t)
+	config.Platform = "dev"
+	config.ResetToken = testResetToken
+	config.Fixtures = fstest.MapFS{
+		"demo.json":        {Data: demo},
+		"broken.json":      {Data: []byte(`{"users": [{"email": "not an email"}]}`)},
+		"ghostwriter.json": {Data: []byte(`{"chirps": [{"author": "nobody@example.com", "body": "Boo"}]}`)},
+		"weak.json":        {Data: []byte(`{"users": [{"email": "skyler@breakingbad.com", "password": "short"}]}`)},
+		"sweary.json":      {Data: []byte(`{"chirps": [{"author": "walt@breakingbad.com", "body": "What a kerfuffle"}]}`)},
+	}
+	st := store.NewMemory()
+	srv := httptest.NewServer(NewServer(config, st))
+	t.Cleanup(srv.Close)
+	c := &testClient{t: t, url: srv.URL}
+
+	const password = "correct horse battery staple"
+	// Walt shares his email with the demo fixture's Walt.
+	creds := map[string]string{"email": "walt@breakingbad.com", "password": password}
+	c.do("POST", "/api/v1/users", "", creds, http.StatusCreated, nil)
+	walt := testSession{}
+	c.do("POST", "/api/v1/login", "", creds, http.StatusOK, &walt)
+	c.do("POST", "/api/v1/chirps", bearer(walt.Token), map[string]string{"body": "Say my name"}, http.StatusCreated, nil)
+	count := func() (users, chirps int) {
+		t.Helper()
+		all, err := st.AllChirps(context.Background())
+		if err != nil {
+			t.Fatalf("AllChirps error = %v", err)
+		}
+		n, err := st.CountUsers(context.Background())
+		if err != nil {
+			t.Fatalf("CountUsers error = %v", err)
+		}
+		return int(n), len(all)
+	}
+
+	// Every way of getting the token wrong leaves the data alone.
+	for _, token := range []string{"", "wrong"} {
+		if status, body := postReset(t, srv.URL, token, ""); status != http.StatusForbidden {
+			t.Errorf("Reset with token %q = %d %s, want 403", token, status, body)
+		}
+	}
+	srvNoToken := httptest.NewServer(NewServer(Config{Platform: "dev", Keys: config.Keys}, st))
+	t.Cleanup(srvNoToken.Close)
+	if status, body := postReset(t, srvNoToken.URL, "", ""); status != http.StatusForbidden {
+		t.Errorf("Reset without RESET_TOKEN configured = %d %s, want 403", status, body)
+	}
+	for _, tc := range []struct {
+		name       string
+		body       string
+		wantStatus int
+	}{
+		{name: "Unknown table", body: `{"tables": ["secrets"]}`, wantStatus: http.StatusUnprocessableEntity},
+		{name: "Missing fixture", body: `{"fixture": "nope.json"}`, wantStatus: http.StatusUnprocessableEntity},
+		{name: "Fixture outside the directory", body: `{"fixture": "../demo.json"}`, wantStatus: http.StatusUnprocessableEntity},
+		{name: "Invalid fixture", body: `{"fixture": "broken.json"}`, wantStatus: http.StatusUnprocessableEntity},
+		{name: "Fixture breaking the password policy", body: `{"fixture": "weak.json"}`, wantStatus: http.StatusUnprocessableEntity},
+		// The chirps are only found to be orphans after the reset has run,
+		// so this checks it is rolled back.
+		{name: "Fixture with an unknown author", body: `{"fixture": "ghostwriter.json"}`, wantStatus: http.StatusUnprocessableEntity},
+		// Users aren't reset, so the fixture can't add Walt again.
+		{name: "Fixture clashing with kept rows", body: `{"tables": ["chirps"], "fixture": "demo.json"}`, wantStatus: http.StatusConflict},
+	} {
+		if status, body := postReset(t, srv.URL, testResetToken, tc.body); status != tc.wantStatus {
+			t.Errorf("%s: reset = %d %s, want %d", tc.name, status, body, tc.wantStatus)
+		}
+	}
+	if users, chirps := count(); users != 1 || chirps != 1 {
+		t.Fatalf("After failed resets there are %d users and %d chirps, want 1 and 1", users, chirps)
+	}
+
+	// Resetting only chirps keeps users.
+	status, body := postReset(t, srv.URL, testResetToken, `{"tables": ["chirps"]}`)
+	if status != http.StatusOK {
+		t.Fatalf("Reset chirps = %d %s", status, body)
+	}
+	if users, chirps := count(); users != 1 || chirps != 0 {
+		t.Errorf("After resetting chirps there are %d users and %d chirps, want 1 and 0", users, chirps)
+	}
+
+	// Resetting everything and seeding the demo fixture.
+	status, body = postReset(t, srv.URL, testResetToken, `{"fixture": "demo.json"}`)
+	if status != http.StatusOK {
+		t.Fatalf("Reset with fixture = %d %s", status, body)
+	}
+	result := resetResult{}
+	if err := json.Unmarshal(body, &result); err != nil {
+		t.Fatalf("Unmarshal %s: %v", body, err)
+	}
+	if len(result.Tables) != len(store.Tables) || result.Users != 2 || result.Chirps != 3 {
+		t.Errorf("Reset result = %+v", result)
+	}
+	if users, chirps := count(); users != 2 || chirps != 3 {
+		t.Errorf("After seeding there are %d users and %d chirps, want 2 and 3", users, chirps)
+	}
+	seeded, err := st.GetUserByEmail(context.Background(), "walt@breakingbad.com")
+	if err != nil {
+		t.Fatalf("GetUserByEmail error = %v", err)
+	}
+	if seeded.ID == walt.ID || !seeded.IsChirpyRed || !seeded.IsAdmin {
+		t.Errorf("Seeded user = %+v, want a new Chirpy Red admin", seeded)
+	}
+	c.do("POST", "/api/v1/login", "", map[string]string{"email": seeded.Email, "password": password}, http.StatusOK, nil)
+
+	// Fixture chirps are cleaned like posted ones.
+	if status, body := postReset(t, srv.URL, testResetToken, `{"tables": ["chirps"], "fixture": "sweary.json"}`); status != http.StatusOK {
+		t.Fatalf("Reset with sweary fixture = %d %s", status, body)
+	}
+	all, err := st.AllChirps(context.Background())
+	if err != nil || len(all) != 1 || all[0].Body != "What a ****" {
+		t.Errorf("Seeded chirps = %+v, %v, want the body cleaned", all, err)
+	}
+
+	// Without a body everything is reset.
+	if status, body := postReset(t, srv.URL, testResetToken, ""); status != http.StatusOK {
+		t.Fatalf("Reset without a body = %d %s", status, body)
+	}
+	if users, chirps := count(); users != 0 || chirps != 0 {
+		t.Errorf("After resetting everything there are %d users and %d chirps, want none", users, chirps)
+	}
+}
@@ -0,0 +1,24 @@
+package server
+
+import (
+	"net/http"
+)
+
+// router is a ServeMux that remembers the patterns registered on it.
+type router struct {
+	mux      *http.ServeMux
+	patterns []string
+}
+
+func newRouter() *router {
+	return &router{mux: http.NewServeMux()}
+}
+
+func (rt *router) Handle(pattern string, handler http.Handler) {
+	rt.mux.Handle(pattern, handler)
+	rt.patterns = append(rt.patterns, pattern)
+}
+
+func (rt *router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rt.mux.ServeHTTP(w, r)
+}
@@ -0,0 +1,291 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/Senaphim/Chirpy/internal/auth"
+	"github.com/Senaphim/Chirpy/internal/metrics"
+	"github.com/Senaphim/Chirpy/internal/migrate"
+	"github.com/Senaphim/Chirpy/internal/oidc"
+	"github.com/Senaphim/Chirpy/internal/store"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const requestIDHeader string = "X-Request-ID"
+
+// Config holds everything the server needs besides its store.
+type Config struct {
+	// Platform is "dev" to allow resetting the database through the admin
+	// API.
+	Platform string
+	// ResetToken must also be set, and sent with every reset, for resetting
+	// to be allowed.
+	ResetToken string
+	// Fixtures holds the JSON fixtures a reset can seed the database from.
+	Fixtures fs.FS
+	// Keys signs and verifies access tokens. It is required.
+	Keys *auth.KeySet
+	// OIDC enables single sign on when set.
+	OIDC *oidc.Provider
+	// OIDCAutoLink links a new single sign on identity to the user with
+	// the same verified email straight away. Otherwise the user has to
+	// confirm the link with their password, as anyone controlling an
+	// account at the provider could otherwise take over theirs.
+	OIDCAutoLink bool
+	// PasswordPolicy defaults to auth.DefaultPasswordPolicy.
+	PasswordPolicy *auth.PasswordPolicy
+	// Hasher defaults to Argon2id with auth.DefaultArgon2Params.
+	Hasher *auth.PasswordHasher
+	// PolkaKey authenticates the payment provider's webhooks.
+	PolkaKey string
+	// FileDir is served under /app/. It defaults to the working directory.
+	FileDir string
+	// DB is pinged by /api/readyz. It is nil for the in-memory store.
+	DB *sql.DB
+	// Migrator lets /api/readyz check every migration is applied.
+	Migrator *migrate.Migrator
+	// Logger receives the access log and errors. It defaults to
+	// slog.Default.
+	Logger *slog.Logger
+	// Metrics is served at /metrics, with the server's own metrics added.
+	// It defaults to a registry holding only those. Servers sharing a
+	// registry count into the same metrics.
+	Metrics *metrics.Registry
+	// TracerProvider receives a span for every request, and for password
+	// hashing within them. It defaults to otel.GetTracerProvider.
+	TracerProvider trace.TracerProvider
+	// Now tells the time for checking authenticator codes and their limits.
+	// It defaults to time.Now; tests set it to step through time.
+	Now func() time.Time
+}
+
+type apiConfig struct {
+	// hitsAtReset is the file server hit count when the admin last reset
+	// it, as Prometheus counters can't go down.
+	hitsAtReset atomic.Uint64
+	// legacyRoutes are the deprecated unversioned aliases, whose use is
+	// counted by chirpy_legacy_requests_total.
+	legacyRoutes   []string
+	store          store.Store
+	platform       string
+	resetToken     string
+	fixtures       fs.FS
+	keys           *auth.KeySet
+	oidc           *oidc.Provider
+	oidcAutoLink   bool
+	passwordPolicy *auth.PasswordPolicy
+	hasher         *auth.PasswordHasher
+	polkaKey       string
+	fileDir        string
+	db             *sql.DB
+	migrator       *migrate.Migrator
+	logger         *slog.Logger
+	registry       *metrics.Registry
+	metrics        *serverMetrics
+	tracer         trace.Tracer
+	recentErrors   *recentErrors
+	now            func() time.Time
+}
+
+// NewServer returns the handler serving the whole of Chirpy: the static
+// files, the API and the admin endpoints.
+func NewServer(config Config, st store.Store) http.Handler {
+	cfg := newAPIConfig(config, st)
+	return middlewareRequestID(cfg.middlewareInstrument(cfg.routes()))
+}
+
+func newAPIConfig(config Config, st store.Store) *apiConfig {
+	cfg := &apiConfig{
+		store:          st,
+		platform:       config.Platform,
+		resetToken:     config.ResetToken,
+		fixtures:       config.Fixtures,
+		keys:           config.Keys,
+		oidc:           config.OIDC,
+		oidcAutoLink:   config.OIDCAutoLink,
+		passwordPolicy: config.PasswordPolicy,
+		hasher:         config.Hasher,
+		polkaKey:       config.PolkaKey,
+		fileDir:        config.FileDir,
+		db:             config.DB,
+		migrator:       config.Migrator,
+		logger:         config.Logger,
+		registry:       config.Metrics,
+		now:            config.Now,
+	}
+	if cfg.passwordPolicy == nil {
+		cfg.passwordPolicy = auth.DefaultPasswordPolicy()
+	}
+	if cfg.hasher == nil {
+		cfg.hasher = auth.NewPasswordHasher(auth.DefaultArgon2Params)
+	}
+	if cfg.logger == nil {
+		cfg.logger = slog.Default()
+	}
+	if cfg.now == nil {
+		cfg.now = time.Now
+	}
+	cfg.recentErrors = newRecentErrors(recentErrorsKept)
+	cfg.logger = slog.New(cfg.recentErrors.handler(cfg.logger.Handler()))
+	if cfg.registry == nil {
+		cfg.registry = metrics.NewRegistry()
+	}
+	cfg.metrics = newServerMetrics(cfg.registry)
+	tracerProvider := config.TracerProvider
+	if tracerProvider == nil {
+		tracerProvider = otel.GetTracerProvider()
+	}
+	cfg.tracer = tracerProvider.Tracer(tracerName)
+	if cfg.fileDir == "" {
+		cfg.fileDir = "."
+	}
+	return cfg
+}
+
+// middlewareRequestID tags every request with an ID, reusing the caller's
+// X-Request-ID when it looks sane, so errors can be matched with logs.
+func middlewareRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if !validRequestID(id) {
+			id = uuid.NewString()
+		}
+		w.Header().Set(requestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey, id)))
+	})
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, c := range id {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.ContainsRune("-_.", c)) {
+			return false
+		}
+	}
+	return true
+}
+
+func (cfg *apiConfig) routes() *router {
+	serveMux := newRouter()
+
+	// File server handler
+	fsHandler := http.StripPrefix("/app", http.FileServer(http.Dir(cfg.fileDir)))
+	serveMux.Handle("/app/", cfg.middlewareMetricInc(fsHandler))
+
+	// Other handlers
+	hapi := http.HandlerFunc(handleOpenAPI)
+	cfg.handleVersioned(serveMux, "GET /api/v1/openapi.json", hapi)
+	hdoc := http.HandlerFunc(handleAPIDocs)
+	cfg.handleVersioned(serveMux, "GET /api/v1/docs", hdoc)
+	hhe := http.HandlerFunc(handleHealth)
+	serveMux.Handle("GET /api/healthz", hhe)
+	hrd := http.HandlerFunc(cfg.handleReady)
+	serveMux.Handle("GET /api/readyz", hrd)
+	serveMux.Handle("GET /metrics", cfg.registry)
+	had := http.HandlerFunc(cfg.handleAdminDashboard)
+	serveMux.Handle("GET /admin", had)
+	// The dashboard replaced the old hit counter page.
+	serveMux.Handle("GET /admin/metrics", http.RedirectHandler("/admin", http.StatusMovedPermanently))
+	halp := http.HandlerFunc(cfg.handleAdminLoginPage)
+	serveMux.Handle("GET /admin/login", halp)
+	hali := http.HandlerFunc(cfg.handleAdminLogin)
+	serveMux.Handle("POST /admin/login", hali)
+	halo := http.HandlerFunc(cfg.handleAdminLogout)
+	serveMux.Handle("POST /admin/logout", halo)
+	hr := http.HandlerFunc(cfg.handleReset)
+	serveMux.Handle("POST /admin/reset", hr)
+	hc := http.HandlerFunc(cfg.handleChirp)
+	cfg.handleVersioned(serveMux, "POST /api/v1/chirps", hc)
+	hcr := http.HandlerFunc(cfg.handlerCreateUser)
+	cfg.handleVersioned(serveMux, "POST /api/v1/users", hcr)
+	hac := http.HandlerFunc(cfg.handlerAllChirps)
+	cfg.handleVersioned(serveMux, "GET /api/v1/chirps", hac)
+	hci := http.HandlerFunc(cfg.handlerChirpById)
+	cfg.handleVersioned(serveMux, "GET /api/v1/chirps/{chirpID}", hci)
+	hl := http.HandlerFunc(cfg.handlerLogin)
+	cfg.handleVersioned(serveMux, "POST /api/v1/login", hl)
+	hre := http.HandlerFunc(cfg.handlerRefresh)
+	cfg.handleVersioned(serveMux, "POST /api/v1/refresh", hre)
+	hrev := http.HandlerFunc(cfg.handlerRevoke)
+	cfg.handleVersioned(serveMux, "POST /api/v1/revoke", hrev)
+	hcpe := http.HandlerFunc(cfg.handleChangePwd)
+	cfg.handleVersioned(serveMux, "PUT /api/v1/users", hcpe)
+	hdc := http.HandlerFunc(cfg.handlerDeleteChirp)
+	cfg.handleVersioned(serveMux, "DELETE /api/v1/chirps/{chirpID}", hdc)
+	hrc := http.HandlerFunc(cfg.handlerReportChirp)
+	cfg.handleVersioned(serveMux, "POST /api/v1/chirps/{chirpID}/reports", hrc)
+	hpw := http.HandlerFunc(cfg.handlePolkaWebhook)
+	cfg.handleVersioned(serveMux, "POST /api/v1/polka/webhooks", hpw)
+	hls := http.HandlerFunc(cfg.handlerListSessions)
+	cfg.handleVersioned(serveMux, "GET /api/v1/sessions", hls)
+	hrs := http.HandlerFunc(cfg.handlerRevokeSession)
+	cfg.handleVersioned(serveMux, "DELETE /api/v1/sessions/{sessionID}", hrs)
+	hras := http.HandlerFunc(cfg.handlerRevokeAllSessions)
+	cfg.handleVersioned(serveMux, "DELETE /api/v1/sessions", hras)
+	hte := http.HandlerFunc(cfg.handlerEnrollTotp)
+	cfg.handleVersioned(serveMux, "POST /api/v1/2fa/enroll", hte)
+	htc := http.HandlerFunc(cfg.handlerConfirmTotp)
+	cfg.handleVersioned(serveMux, "POST /api/v1/2fa/confirm", htc)
+	htd := http.HandlerFunc(cfg.handlerDisableTotp)
+	cfg.handleVersioned(serveMux, "DELETE /api/v1/2fa", htd)
+	hrrc := http.HandlerFunc(cfg.handlerRegenerateRecoveryCodes)
+	cfg.handleVersioned(serveMux, "POST /api/v1/2fa/recovery-codes", hrrc)
+	hlt := http.HandlerFunc(cfg.handlerLoginTwoFactor)
+	cfg.handleVersioned(serveMux, "POST /api/v1/login/2fa", hlt)
+	hjw := http.HandlerFunc(cfg.handleJWKS)
+	serveMux.Handle("GET /.well-known/jwks.json", hjw)
+	hcat := http.HandlerFunc(cfg.handlerCreateAPIToken)
+	cfg.handleVersioned(serveMux, "POST /api/v1/tokens", hcat)
+	hlat := http.HandlerFunc(cfg.handlerListAPITokens)
+	cfg.handleVersioned(serveMux, "GET /api/v1/tokens", hlat)
+	hrat := http.HandlerFunc(cfg.handlerRevokeAPIToken)
+	cfg.handleVersioned(serveMux, "DELETE /api/v1/tokens/{tokenID}", hrat)
+	hoc := http.HandlerFunc(cfg.handlerCreateOAuthClient)
+	cfg.handleVersioned(serveMux, "POST /api/v1/oauth/clients", hoc)
+	hoa := http.HandlerFunc(cfg.handleOAuthAuthorize)
+	serveMux.Handle("GET /oauth/authorize", hoa)
+	hoad := http.HandlerFunc(cfg.handleOAuthDecision)
+	serveMux.Handle("POST /oauth/authorize", hoad)
+	hot := http.HandlerFunc(cfg.handleOAuthToken)
+	serveMux.Handle("POST /oauth/token", hot)
+	if cfg.oidc != nil {
+		hol := http.HandlerFunc(cfg.handleOIDCLogin)
+		cfg.handleVersioned(serveMux, "GET /api/v1/oidc/login", hol)
+		hocb := http.HandlerFunc(cfg.handleOIDCCallback)
+		cfg.handleVersioned(serveMux, "GET /api/v1/oidc/callback", hocb)
+		holk := http.HandlerFunc(cfg.handlerOIDCLink)
+		cfg.handleVersioned(serveMux, "POST /api/v1/oidc/link", holk)
+	}
+
+	return serveMux
+}
+
+func handleHealth(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(http.StatusText(http.StatusOK)))
+}
+
+func (cfg *apiConfig) handleJWKS(w http.ResponseWriter, r *http.Request) {
+	dat, err := json.Marshal(cfg.keys.JWKS())
+	if err != nil {
+		helperJsonError(w, r, "Error marshalling response", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	w.WriteHeader(http.StatusOK)
+	w.Write(dat)
+}
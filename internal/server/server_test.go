@@ -0,0 +1,599 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Senaphim/Chirpy/internal/auth"
+	"github.com/Senaphim/Chirpy/internal/database"
+	"github.com/Senaphim/Chirpy/internal/store"
+	"github.com/Senaphim/Chirpy/internal/store/storetest"
+	"github.com/google/uuid"
+)
+
+// testStores returns the stores the end-to-end suite runs against: memory, a
+// fresh SQLite database and Postgres. Postgres is only used when
+// CHIRPY_TEST_DB_URL points at a migrated database that the tests may write
+// to.
+func testStores(t *testing.T) map[string]store.Store {
+	t.Helper()
+	stores := map[string]store.Store{
+		"memory": store.NewMemory(),
+		"sqlite": storetest.SQLite(t),
+	}
+
+	if dbUrl := os.Getenv("CHIRPY_TEST_DB_URL"); dbUrl != "" {
+		db, err := sql.Open("postgres", dbUrl)
+		if err != nil {
+			t.Fatalf("sql.Open error = %v", err)
+		}
+		t.Cleanup(func() { db.Close() })
+		stores["postgres"] = store.NewPostgres(db)
+	}
+	return stores
+}
+
+// testClock is a clock tests move forward by hand, so that authenticator
+// codes and their limits can be checked without waiting.
+type testClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func newTestClock() *testClock {
+	return &testClock{now: time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)}
+}
+
+func (c *testClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *testClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// testClient talks to a running test server.
+type testClient struct {
+	t   *testing.T
+	url string
+	// clock is the server's clock, when the test set one.
+	clock *testClock
+}
+
+func newTestClient(t *testing.T, st store.Store) *testClient {
+	t.Helper()
+	config := testServerConfig(t)
+	clock := newTestClock()
+	config.Now = clock.Now
+	srv := httptest.NewServer(NewServer(config, st))
+	t.Cleanup(srv.Close)
+	return &testClient{t: t, url: srv.URL, clock: clock}
+}
+
+// do sends body as JSON, checks the status and decodes the response into
+// out when it is not nil.
+func (c *testClient) do(method, path, token string, body any, wantStatus int, out any) {
+	c.t.Helper()
+
+	var reqBody io.Reader
+	if body != nil {
+		dat, err := json.Marshal(body)
+		if err != nil {
+			c.t.Fatalf("json.Marshal error = %v", err)
+		}
+		reqBody = bytes.NewReader(dat)
+	}
+	req, err := http.NewRequest(method, c.url+path, reqBody)
+	if err != nil {
+		c.t.Fatalf("NewRequest error = %v", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", token)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		c.t.Fatalf("%s %s error = %v", method, path, err)
+	}
+	defer resp.Body.Close()
+	dat, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != wantStatus {
+		c.t.Fatalf("%s %s status = %d, want %d: %s", method, path, resp.StatusCode, wantStatus, dat)
+	}
+	if out != nil {
+		if err := json.Unmarshal(dat, out); err != nil {
+			c.t.Fatalf("%s %s response %s: %v", method, path, dat, err)
+		}
+	}
+}
+
+func bearer(token string) string {
+	return "Bearer " + token
+}
+
+type testSession struct {
+	ID           uuid.UUID `json:"id"`
+	Email        string    `json:"email"`
+	IsChirpyRed  bool      `json:"is_chirpy_red"`
+	Token        string    `json:"token"`
+	RefreshToken string    `json:"refresh_token"`
+}
+
+type testChirp struct {
+	ID     uuid.UUID `json:"id"`
+	Body   string    `json:"body"`
+	UserID uuid.UUID `json:"user_id"`
+}
+
+type testError struct {
+	Error struct {
+		Code string `json:"code"`
+	} `json:"error"`
+}
+
+// signUp creates a user with a unique email, so the suite can share a
+// database with earlier runs, and logs them in.
+func (c *testClient) signUp(password string) testSession {
+	c.t.Helper()
+	creds := map[string]string{
+		"email":    uuid.NewString() + "@example.com",
+		"password": password,
+	}
+	c.do("POST", "/api/v1/users", "", creds, http.StatusCreated, nil)
+	session := testSession{}
+	c.do("POST", "/api/v1/login", "", creds, http.StatusOK, &session)
+	return session
+}
+
+func TestEndToEnd(t *testing.T) {
+	for name, st := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			t.Run("Chirps", func(t *testing.T) { testChirps(t, newTestClient(t, st)) })
+			t.Run("Reports", func(t *testing.T) { testReports(t, newTestClient(t, st)) })
+			t.Run("Accounts", func(t *testing.T) { testAccounts(t, newTestClient(t, st)) })
+			t.Run("Sessions", func(t *testing.T) { testSessions(t, newTestClient(t, st)) })
+			t.Run("Webhooks", func(t *testing.T) { testWebhooks(t, newTestClient(t, st)) })
+			t.Run("API tokens", func(t *testing.T) { testAPITokens(t, newTestClient(t, st)) })
+			t.Run("Two factor", func(t *testing.T) { testTwoFactor(t, newTestClient(t, st)) })
+			t.Run("OAuth", func(t *testing.T) { testOAuth(t, newTestClient(t, st)) })
+		})
+	}
+}
+
+func testChirps(t *testing.T, c *testClient) {
+	alice := c.signUp("correct horse battery")
+	bob := c.signUp("correct horse battery")
+	// Chirp bodies are unique, so make them differ between runs.
+	run := uuid.NewString()[:8]
+
+	first := testChirp{}
+	c.do("POST", "/api/v1/chirps", bearer(alice.Token), map[string]string{"body": "What a kerfuffle " + run}, http.StatusCreated, &first)
+	if first.Body != "What a **** "+run || first.UserID != alice.ID {
+		t.Errorf("Created chirp = %+v", first)
+	}
+	second := testChirp{}
+	c.do("POST", "/api/v1/chirps", bearer(alice.Token), map[string]string{"body": "Second " + run}, http.StatusCreated, &second)
+	c.do("POST", "/api/v1/chirps", bearer(bob.Token), map[string]string{"body": "Bob's " + run}, http.StatusCreated, nil)
+	// Chirps are always posted as the authenticated user.
+	impostor := map[string]string{"body": "Not Bob " + run, "user_id": bob.ID.String()}
+	c.do("POST", "/api/v1/chirps", bearer(alice.Token), impostor, http.StatusBadRequest, nil)
+
+	got := testChirp{}
+	c.do("GET", "/api/v1/chirps/"+first.ID.String(), "", nil, http.StatusOK, &got)
+	if got != first {
+		t.Errorf("Fetched chirp = %+v, want %+v", got, first)
+	}
+
+	list := []testChirp{}
+	c.do("GET", "/api/v1/chirps?sort=desc&author_id="+alice.ID.String(), "", nil, http.StatusOK, &list)
+	if len(list) != 2 || list[0].ID != second.ID || list[1].ID != first.ID {
+		t.Errorf("Alice's chirps newest first = %+v", list)
+	}
+
+	c.do("DELETE", "/api/v1/chirps/"+first.ID.String(), bearer(bob.Token), nil, http.StatusForbidden, nil)
+	c.do("DELETE", "/api/v1/chirps/"+first.ID.String(), bearer(alice.Token), nil, http.StatusNoContent, nil)
+	c.do("GET", "/api/v1/chirps/"+first.ID.String(), "", nil, http.StatusNotFound, nil)
+}
+
+func testReports(t *testing.T, c *testClient) {
+	alice := c.signUp("correct horse battery")
+	bob := c.signUp("correct horse battery")
+	chirp := testChirp{}
+	c.do("POST", "/api/v1/chirps", bearer(alice.Token), map[string]string{"body": "Report me " + uuid.NewString()[:8]}, http.StatusCreated, &chirp)
+
+	path := "/api/v1/chirps/" + chirp.ID.String() + "/reports"
+	reason := map[string]string{"reason": "Spam"}
+	c.do("POST", path, "", reason, http.StatusUnauthorized, nil)
+	c.do("POST", path, bearer(bob.Token), map[string]string{}, http.StatusUnprocessableEntity, nil)
+	c.do("POST", "/api/v1/chirps/"+uuid.NewString()+"/reports", bearer(bob.Token), reason, http.StatusNotFound, nil)
+	report := struct {
+		ChirpID uuid.UUID `json:"chirp_id"`
+		Reason  string    `json:"reason"`
+	}{}
+	c.do("POST", path, bearer(bob.Token), reason, http.StatusCreated, &report)
+	if report.ChirpID != chirp.ID || report.Reason != "Spam" {
+		t.Errorf("Created report = %+v", report)
+	}
+	c.do("POST", path, bearer(bob.Token), reason, http.StatusConflict, nil)
+}
+
+func testAccounts(t *testing.T, c *testClient) {
+	user := c.signUp("correct horse battery")
+
+	apiErr := testError{}
+	taken := map[string]string{"email": user.Email, "password": "correct horse battery"}
+	c.do("POST", "/api/v1/users", "", taken, http.StatusConflict, &apiErr)
+	if apiErr.Error.Code != errCodeEmailTaken {
+		t.Errorf("Duplicate sign up code = %q", apiErr.Error.Code)
+	}
+
+	wrong := map[string]string{"email": user.Email, "password": "battery staple horse"}
+	c.do("POST", "/api/v1/login", "", wrong, http.StatusUnauthorized, nil)
+
+	changed := map[string]string{"email": uuid.NewString() + "@example.com", "password": "battery staple horse"}
+	c.do("PUT", "/api/v1/users", bearer(user.Token), changed, http.StatusOK, nil)
+	c.do("POST", "/api/v1/login", "", changed, http.StatusOK, nil)
+	c.do("POST", "/api/v1/login", "", taken, http.StatusUnauthorized, nil)
+}
+
+func testSessions(t *testing.T, c *testClient) {
+	user := c.signUp("correct horse battery")
+	creds := map[string]string{"email": user.Email, "password": "correct horse battery", "device_name": "phone"}
+	phone := testSession{}
+	c.do("POST", "/api/v1/login", "", creds, http.StatusOK, &phone)
+
+	type sessionInfo struct {
+		ID         uuid.UUID `json:"id"`
+		DeviceName string    `json:"device_name"`
+	}
+	sessions := []sessionInfo{}
+	c.do("GET", "/api/v1/sessions", bearer(user.Token), nil, http.StatusOK, &sessions)
+	if len(sessions) != 2 {
+		t.Fatalf("Sessions = %+v, want 2", sessions)
+	}
+
+	refreshed := struct {
+		Token string `json:"token"`
+	}{}
+	c.do("POST", "/api/v1/refresh", bearer(phone.RefreshToken), nil, http.StatusOK, &refreshed)
+	if refreshed.Token == "" {
+		t.Errorf("Refresh returned no access token")
+	}
+
+	c.do("POST", "/api/v1/revoke", bearer(user.RefreshToken), nil, http.StatusNoContent, nil)
+	c.do("POST", "/api/v1/refresh", bearer(user.RefreshToken), nil, http.StatusUnauthorized, nil)
+
+	for _, session := range sessions {
+		if session.DeviceName == "phone" {
+			c.do("DELETE", "/api/v1/sessions/"+session.ID.String(), bearer(user.Token), nil, http.StatusNoContent, nil)
+		}
+	}
+	c.do("POST", "/api/v1/refresh", bearer(phone.RefreshToken), nil, http.StatusUnauthorized, nil)
+	c.do("GET", "/api/v1/sessions", bearer(user.Token), nil, http.StatusOK, &sessions)
+	if len(sessions) != 0 {
+		t.Errorf("Sessions after revoking = %+v, want none", sessions)
+	}
+}
+
+func TestRefreshExpired(t *testing.T) {
+	st := store.NewMemory()
+	c := newTestClient(t, st)
+	user := c.signUp("correct horse battery")
+
+	// An expired session no longer lists, so it mustn't work either.
+	refreshToken, err := auth.MakeRefreshToken()
+	if err != nil {
+		t.Fatalf("MakeRefreshToken error = %v", err)
+	}
+	expired := time.Now().Add(-time.Minute)
+	_, err = st.CreateRefreshToken(context.Background(), database.CreateRefreshTokenParams{
+		TokenHash:  auth.HashRefreshToken(refreshToken),
+		CreatedAt:  expired.Add(-time.Hour),
+		UpdatedAt:  expired.Add(-time.Hour),
+		UserID:     user.ID,
+		ExpiresAt:  expired,
+		ID:         uuid.New(),
+		LastUsedAt: expired.Add(-time.Hour),
+	})
+	if err != nil {
+		t.Fatalf("CreateRefreshToken error = %v", err)
+	}
+	c.do("POST", "/api/v1/refresh", bearer(refreshToken), nil, http.StatusUnauthorized, nil)
+	c.do("POST", "/api/v1/revoke", bearer(refreshToken), nil, http.StatusUnauthorized, nil)
+	c.do("POST", "/api/v1/refresh", bearer(user.RefreshToken), nil, http.StatusOK, nil)
+}
+
+func testWebhooks(t *testing.T, c *testClient) {
+	user := c.signUp("correct horse battery")
+	polka := "ApiKey polka-key"
+
+	upgrade := map[string]any{"event": "user.upgraded", "data": map[string]string{"user_id": user.ID.String()}}
+	c.do("POST", "/api/v1/polka/webhooks", "ApiKey wrong", upgrade, http.StatusUnauthorized, nil)
+	c.do("POST", "/api/v1/polka/webhooks", polka, upgrade, http.StatusNoContent, nil)
+
+	creds := map[string]string{"email": user.Email, "password": "correct horse battery"}
+	session := testSession{}
+	c.do("POST", "/api/v1/login", "", creds, http.StatusOK, &session)
+	if !session.IsChirpyRed {
+		t.Errorf("User is not Chirpy Red after upgrading")
+	}
+
+	unknown := map[string]any{"event": "user.upgraded", "data": map[string]string{"user_id": uuid.NewString()}}
+	c.do("POST", "/api/v1/polka/webhooks", polka, unknown, http.StatusNotFound, nil)
+}
+
+func testAPITokens(t *testing.T, c *testClient) {
+	user := c.signUp("correct horse battery")
+
+	type apiToken struct {
+		ID    uuid.UUID `json:"id"`
+		Token string    `json:"token"`
+	}
+	readOnly := apiToken{}
+	c.do("POST", "/api/v1/tokens", bearer(user.Token), map[string]any{"name": "reader", "scopes": []string{auth.ScopeChirpsRead}}, http.StatusCreated, &readOnly)
+	writer := apiToken{}
+	c.do("POST", "/api/v1/tokens", bearer(user.Token), map[string]any{"name": "writer", "scopes": []string{auth.ScopeChirpsWrite}}, http.StatusCreated, &writer)
+
+	chirp := map[string]string{"body": "Posted by a robot " + uuid.NewString()[:8]}
+	apiErr := testError{}
+	c.do("POST", "/api/v1/chirps", bearer(readOnly.Token), chirp, http.StatusForbidden, &apiErr)
+	if apiErr.Error.Code != errCodeInsufficientScope {
+		t.Errorf("Read only token code = %q", apiErr.Error.Code)
+	}
+	c.do("POST", "/api/v1/chirps", bearer(writer.Token), chirp, http.StatusCreated, nil)
+
+	// Only the read scope lists the user's own chirps.
+	mine := []testChirp{}
+	c.do("GET", "/api/v1/chirps?author_id=me", bearer(readOnly.Token), nil, http.StatusOK, &mine)
+	if len(mine) != 1 || mine[0].UserID != user.ID {
+		t.Errorf("Own chirps listed with the read only token = %+v", mine)
+	}
+	c.do("GET", "/api/v1/chirps?author_id=me", bearer(writer.Token), nil, http.StatusForbidden, nil)
+	c.do("GET", "/api/v1/chirps?author_id=me", bearer(user.Token), nil, http.StatusOK, nil)
+	c.do("GET", "/api/v1/chirps?author_id=me", "", nil, http.StatusUnauthorized, nil)
+
+	tokens := []apiToken{}
+	c.do("GET", "/api/v1/tokens", bearer(user.Token), nil, http.StatusOK, &tokens)
+	if len(tokens) != 2 || tokens[0].Token != "" {
+		t.Errorf("Listed tokens = %+v", tokens)
+	}
+
+	c.do("DELETE", "/api/v1/tokens/"+writer.ID.String(), bearer(user.Token), nil, http.StatusNoContent, nil)
+	c.do("POST", "/api/v1/chirps", bearer(writer.Token), chirp, http.StatusUnauthorized, nil)
+}
+
+func testTwoFactor(t *testing.T, c *testClient) {
+	user := c.signUp("correct horse battery")
+
+	enrollment := struct {
+		Secret string `json:"secret"`
+	}{}
+	c.do("POST", "/api/v1/2fa/enroll", bearer(user.Token), nil, http.StatusOK, &enrollment)
+	// code is the authenticator's code at the server's time.
+	code := func() string {
+		t.Helper()
+		code, err := auth.TOTPCode(enrollment.Secret, c.clock.Now())
+		if err != nil {
+			t.Fatalf("TOTPCode error = %v", err)
+		}
+		return code
+	}
+	recovery := struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}{}
+	confirmed := code()
+	c.do("POST", "/api/v1/2fa/confirm", bearer(user.Token), map[string]string{"code": confirmed}, http.StatusOK, &recovery)
+	if len(recovery.RecoveryCodes) == 0 {
+		t.Fatalf("Confirming returned no recovery codes")
+	}
+
+	login := func() string {
+		t.Helper()
+		challenge := struct {
+			TwoFactorRequired bool   `json:"two_factor_required"`
+			ChallengeToken    string `json:"challenge_token"`
+		}{}
+		creds := map[string]string{"email": user.Email, "password": "correct horse battery"}
+		c.do("POST", "/api/v1/login", "", creds, http.StatusOK, &challenge)
+		if !challenge.TwoFactorRequired {
+			t.Fatalf("Login did not ask for a second factor")
+		}
+		return challenge.ChallengeToken
+	}
+	secondFactor := func(challenge, code string, wantStatus int) {
+		t.Helper()
+		c.do("POST", "/api/v1/login/2fa", "", map[string]string{"challenge_token": challenge, "code": code}, wantStatus, nil)
+	}
+
+	// The code used to confirm can't be used again, even in its own period.
+	challenge := login()
+	secondFactor(challenge, confirmed, http.StatusUnauthorized)
+	c.clock.Advance(auth.TOTPPeriod)
+	session := testSession{}
+	c.do("POST", "/api/v1/login/2fa", "", map[string]string{"challenge_token": challenge, "code": code()}, http.StatusOK, &session)
+	if session.Token == "" {
+		t.Errorf("Two factor login returned no access token")
+	}
+	// Nor can the challenge.
+	c.clock.Advance(auth.TOTPPeriod)
+	secondFactor(challenge, code(), http.StatusUnauthorized)
+
+	// A challenge is thrown away after maxChallengeAttempts wrong codes.
+	wrong := "000000"
+	if wrong == code() {
+		wrong = "000001"
+	}
+	challenge = login()
+	for range maxChallengeAttempts {
+		secondFactor(challenge, wrong, http.StatusUnauthorized)
+	}
+	secondFactor(challenge, code(), http.StatusUnauthorized)
+
+	// Those were also maxTotpFailures wrong codes, so even the right code is
+	// refused until totpFailureWindow has passed, at every page taking one.
+	challenge = login()
+	secondFactor(challenge, code(), http.StatusTooManyRequests)
+	resp, err := http.PostForm(c.url+"/admin/login", url.Values{
+		"email":     {user.Email},
+		"password":  {"correct horse battery"},
+		"totp_code": {code()},
+	})
+	if err != nil {
+		t.Fatalf("POST /admin/login error = %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("Admin login while locked out status = %d, want 429", resp.StatusCode)
+	}
+	// Recovery codes still work.
+	useRecovery := map[string]string{"challenge_token": challenge, "recovery_code": recovery.RecoveryCodes[0]}
+	c.do("POST", "/api/v1/login/2fa", "", useRecovery, http.StatusOK, nil)
+	useRecovery["challenge_token"] = login()
+	c.do("POST", "/api/v1/login/2fa", "", useRecovery, http.StatusUnauthorized, nil)
+
+	c.clock.Advance(totpFailureWindow)
+	secondFactor(login(), code(), http.StatusOK)
+
+	// Recovery codes are replaced with the password and an authenticator
+	// code, and the old ones stop working.
+	c.clock.Advance(auth.TOTPPeriod)
+	const password = "correct horse battery"
+	c.do("POST", "/api/v1/2fa/recovery-codes", bearer(user.Token), map[string]string{"password": "wrong", "code": code()}, http.StatusUnauthorized, nil)
+	regenerated := struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}{}
+	c.do("POST", "/api/v1/2fa/recovery-codes", bearer(user.Token), map[string]string{"password": password, "code": code()}, http.StatusOK, &regenerated)
+	if len(regenerated.RecoveryCodes) != recoveryCodeCount {
+		t.Fatalf("Regenerated %d recovery codes, want %d", len(regenerated.RecoveryCodes), recoveryCodeCount)
+	}
+
+	// A user who lost their authenticator turns 2FA off with a recovery
+	// code, after which logging in takes only the password.
+	disable := map[string]string{"password": password, "recovery_code": recovery.RecoveryCodes[1]}
+	c.do("DELETE", "/api/v1/2fa", bearer(user.Token), disable, http.StatusUnauthorized, nil)
+	disable["recovery_code"] = regenerated.RecoveryCodes[0]
+	c.do("DELETE", "/api/v1/2fa", bearer(user.Token), disable, http.StatusNoContent, nil)
+	loggedIn := testSession{}
+	c.do("POST", "/api/v1/login", "", map[string]string{"email": user.Email, "password": password}, http.StatusOK, &loggedIn)
+	if loggedIn.Token == "" {
+		t.Errorf("Login after disabling 2FA returned no access token")
+	}
+	disable["recovery_code"] = regenerated.RecoveryCodes[1]
+	c.do("DELETE", "/api/v1/2fa", bearer(user.Token), disable, http.StatusBadRequest, nil)
+}
+
+func testOAuth(t *testing.T, c *testClient) {
+	user := c.signUp("correct horse battery")
+	noRedirects := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	post := func(path string, form url.Values) *http.Response {
+		t.Helper()
+		resp, err := noRedirects.PostForm(c.url+path, form)
+		if err != nil {
+			t.Fatalf("POST %s error = %v", path, err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+	newClient := func() string {
+		t.Helper()
+		client := struct {
+			ClientID string `json:"client_id"`
+		}{}
+		body := map[string]any{"name": "app", "redirect_uris": []string{"https://app.example/cb"}}
+		c.do("POST", "/api/v1/oauth/clients", bearer(user.Token), body, http.StatusCreated, &client)
+		return client.ClientID
+	}
+	verifier := strings.Repeat("v", 43)
+	// authorize approves clientId, sending redirectURI unless it is empty,
+	// and returns the code.
+	authorize := func(clientId, redirectURI string) string {
+		t.Helper()
+		form := url.Values{
+			"action":                {"approve"},
+			"email":                 {user.Email},
+			"password":              {"correct horse battery"},
+			"client_id":             {clientId},
+			"response_type":         {"code"},
+			"scope":                 {"chirps:read"},
+			"code_challenge":        {auth.PKCEChallenge(verifier)},
+			"code_challenge_method": {auth.PKCEMethod},
+		}
+		if redirectURI != "" {
+			form.Set("redirect_uri", redirectURI)
+		}
+		resp := post("/oauth/authorize", form)
+		location, err := url.Parse(resp.Header.Get("Location"))
+		if resp.StatusCode != http.StatusFound || err != nil {
+			t.Fatalf("POST /oauth/authorize = %d %q", resp.StatusCode, resp.Header.Get("Location"))
+		}
+		return location.Query().Get("code")
+	}
+	// exchange trades code for tokens, returning the refresh token.
+	exchange := func(clientId, code, redirectURI string, wantStatus int) string {
+		t.Helper()
+		form := url.Values{
+			"grant_type":    {"authorization_code"},
+			"client_id":     {clientId},
+			"code":          {code},
+			"code_verifier": {verifier},
+		}
+		if redirectURI != "" {
+			form.Set("redirect_uri", redirectURI)
+		}
+		resp, err := http.PostForm(c.url+"/oauth/token", form)
+		if err != nil {
+			t.Fatalf("POST /oauth/token error = %v", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != wantStatus {
+			t.Errorf("Exchanging code = %d, want %d", resp.StatusCode, wantStatus)
+		}
+		grant := struct {
+			RefreshToken string `json:"refresh_token"`
+		}{}
+		json.NewDecoder(resp.Body).Decode(&grant)
+		return grant.RefreshToken
+	}
+
+	app, other := newClient(), newClient()
+
+	// Leaving redirect_uri out of the authorization request means it can
+	// be left out of the token request too.
+	exchange(app, authorize(app, ""), "", http.StatusOK)
+	exchange(app, authorize(app, ""), "https://app.example/cb", http.StatusOK)
+	exchange(app, authorize(app, ""), "https://app.example/other", http.StatusBadRequest)
+	// Once given, it must be given again.
+	exchange(app, authorize(app, "https://app.example/cb"), "", http.StatusBadRequest)
+	exchange(app, authorize(app, "https://app.example/cb"), "https://app.example/cb", http.StatusOK)
+
+	// Another client can't use a code up before the client it was issued to.
+	code := authorize(app, "")
+	exchange(other, code, "", http.StatusBadRequest)
+	refreshToken := exchange(app, code, "", http.StatusOK)
+	exchange(app, code, "", http.StatusBadRequest)
+
+	// The client's refresh token can't be revoked as a first party session.
+	c.do("POST", "/api/v1/revoke", bearer(refreshToken), nil, http.StatusUnauthorized, nil)
+	refresh := url.Values{"grant_type": {"refresh_token"}, "client_id": {app}, "refresh_token": {refreshToken}}
+	if resp := post("/oauth/token", refresh); resp.StatusCode != http.StatusOK {
+		t.Errorf("Refreshing after a first party revoke = %d, want 200", resp.StatusCode)
+	}
+}
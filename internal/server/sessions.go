@@ -0,0 +1,141 @@
+package server
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/Senaphim/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+func (cfg *apiConfig) handlerListSessions(w http.ResponseWriter, r *http.Request) {
+	userId, err := cfg.helperAuthenticate(r, "")
+	if err != nil {
+		helperAuthError(w, r, err)
+		return
+	}
+
+	params := database.GetActiveSessionsByUserParams{
+		UserID:    userId,
+		ExpiresAt: time.Now().Local(),
+	}
+	sessions, err := cfg.store.GetActiveSessionsByUser(r.Context(), params)
+	if err != nil {
+		requestLogger(r).Error("Error fetching sessions", "error", err)
+		helperInternalError(w)
+		return
+	}
+
+	type returnSession struct {
+		ID         uuid.UUID `json:"id"`
+		DeviceName string    `json:"device_name"`
+		UserAgent  string    `json:"user_agent"`
+		IpAddress  string    `json:"ip_address"`
+		CreatedAt  time.Time `json:"created_at"`
+		LastUsedAt time.Time `json:"last_used_at"`
+		ExpiresAt  time.Time `json:"expires_at"`
+	}
+
+	returnArray := []returnSession{}
+	for _, session := range sessions {
+		rSession := returnSession{
+			ID:         session.ID,
+			DeviceName: session.DeviceName,
+			UserAgent:  session.UserAgent,
+			IpAddress:  session.IpAddress,
+			CreatedAt:  session.CreatedAt,
+			LastUsedAt: session.LastUsedAt,
+			ExpiresAt:  session.ExpiresAt,
+		}
+
+		returnArray = append(returnArray, rSession)
+	}
+
+	dat, err := json.Marshal(returnArray)
+	if err != nil {
+		helperJsonError(w, r, "Error marshalling response", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(dat)
+}
+
+func (cfg *apiConfig) handlerRevokeSession(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("sessionID")
+	sessionId, err := uuid.Parse(id)
+	if err != nil {
+		requestLogger(r).Info("Error parsing sessionID", "error", err)
+		helperNotFound(w, "Session not found")
+		return
+	}
+
+	userId, err := cfg.helperAuthenticate(r, "")
+	if err != nil {
+		helperAuthError(w, r, err)
+		return
+	}
+
+	revokeParams := database.RevokeSessionByIdParams{
+		UpdatedAt: time.Now().Local(),
+		RevokedAt: sql.NullTime{
+			Time:  time.Now().Local(),
+			Valid: true,
+		},
+		ID:     sessionId,
+		UserID: userId,
+	}
+	revoked, err := cfg.store.RevokeSessionById(r.Context(), revokeParams)
+	if err != nil {
+		requestLogger(r).Error("Error revoking session", "error", err)
+		helperInternalError(w)
+		return
+	}
+	// Sessions belonging to other users are reported as missing so that
+	// session ids cannot be probed.
+	if revoked == 0 {
+		requestLogger(r).Info("Session not found for user")
+		helperNotFound(w, "Session not found")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerRevokeAllSessions(w http.ResponseWriter, r *http.Request) {
+	userId, err := cfg.helperAuthenticate(r, "")
+	if err != nil {
+		helperAuthError(w, r, err)
+		return
+	}
+
+	revokeParams := database.RevokeAllSessionsByUserParams{
+		UpdatedAt: time.Now().Local(),
+		RevokedAt: sql.NullTime{
+			Time:  time.Now().Local(),
+			Valid: true,
+		},
+		UserID: userId,
+	}
+	err = cfg.store.RevokeAllSessionsByUser(r.Context(), revokeParams)
+	if err != nil {
+		requestLogger(r).Error("Error revoking sessions", "error", err)
+		helperInternalError(w)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// helperClientIP returns the address of the connecting client without the
+// port. Forwarding headers are ignored as they can be set by the client.
+func helperClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
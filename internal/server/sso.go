@@ -0,0 +1,343 @@
+package server
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/Senaphim/Chirpy/internal/auth"
+	"github.com/Senaphim/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	oidcStateCookie    string        = "chirpy_oidc_state"
+	oidcLoginExpiresIn time.Duration = 10 * time.Minute
+	oidcLinkExpiresIn  time.Duration = 10 * time.Minute
+)
+
+// errLinkRequired means a new identity's email belongs to an existing user,
+// who has to confirm the link before it can be used to sign in.
+var errLinkRequired = errors.New("Email belongs to an existing account")
+
+// handleOIDCLogin starts a login with the configured OpenID Connect provider.
+// The state is kept both in a cookie, binding the login to this browser, and
+// in the database alongside the nonce and PKCE verifier.
+func (cfg *apiConfig) handleOIDCLogin(w http.ResponseWriter, r *http.Request) {
+	state, err := auth.MakeRefreshToken()
+	if err != nil {
+		requestLogger(r).Error("Error making OIDC state", "error", err)
+		helperInternalError(w)
+		return
+	}
+	nonce, err := auth.MakeRefreshToken()
+	if err != nil {
+		requestLogger(r).Error("Error making OIDC nonce", "error", err)
+		helperInternalError(w)
+		return
+	}
+	verifier, err := auth.MakeRefreshToken()
+	if err != nil {
+		requestLogger(r).Error("Error making PKCE verifier", "error", err)
+		helperInternalError(w)
+		return
+	}
+
+	loginParams := database.CreateOIDCLoginParams{
+		StateHash:    auth.HashRefreshToken(state),
+		CreatedAt:    time.Now().Local(),
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		ExpiresAt:    time.Now().Local().Add(oidcLoginExpiresIn),
+	}
+	err = cfg.store.CreateOIDCLogin(r.Context(), loginParams)
+	if err != nil {
+		requestLogger(r).Error("Error storing OIDC login", "error", err)
+		helperInternalError(w)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    state,
+		Path:     "/api",
+		MaxAge:   int(oidcLoginExpiresIn.Seconds()),
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, cfg.oidc.AuthCodeURL(state, nonce, auth.PKCEChallenge(verifier)), http.StatusFound)
+}
+
+func (cfg *apiConfig) handleOIDCCallback(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if query.Get("error") != "" {
+		requestLogger(r).Info("OIDC provider returned error", "error", query.Get("error"))
+		helperError(w, http.StatusUnauthorized, errCodeUnauthorized, "Login was cancelled or refused by the provider", nil)
+		return
+	}
+
+	cookie, err := r.Cookie(oidcStateCookie)
+	if err != nil {
+		requestLogger(r).Info("OIDC callback without state cookie", "error", err)
+		helperError(w, http.StatusUnauthorized, errCodeUnauthorized, "Login session missing or expired", nil)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookie,
+		Path:     "/api",
+		MaxAge:   -1,
+		HttpOnly: true,
+	})
+	state := query.Get("state")
+	if subtle.ConstantTimeCompare([]byte(state), []byte(cookie.Value)) != 1 {
+		requestLogger(r).Info("OIDC state mismatch")
+		helperError(w, http.StatusUnauthorized, errCodeUnauthorized, "Login session missing or expired", nil)
+		return
+	}
+
+	login, err := cfg.store.ConsumeOIDCLogin(r.Context(), auth.HashRefreshToken(state))
+	if err != nil {
+		requestLogger(r).Info("Unknown OIDC state", "error", err)
+		helperError(w, http.StatusUnauthorized, errCodeUnauthorized, "Login session missing or expired", nil)
+		return
+	}
+	if time.Now().After(login.ExpiresAt) {
+		requestLogger(r).Info("OIDC login expired")
+		helperError(w, http.StatusUnauthorized, errCodeUnauthorized, "Login session missing or expired", nil)
+		return
+	}
+
+	idToken, err := cfg.oidc.Exchange(r.Context(), query.Get("code"), login.CodeVerifier)
+	if err != nil {
+		requestLogger(r).Info("Error exchanging OIDC code", "error", err)
+		cfg.metrics.observeLogin(loginOIDC, loginFailure)
+		helperError(w, http.StatusUnauthorized, errCodeUnauthorized, "Login with the provider failed", nil)
+		return
+	}
+	claims, err := cfg.oidc.VerifyIDToken(r.Context(), idToken, login.Nonce)
+	if err != nil {
+		requestLogger(r).Info("Invalid ID token", "error", err)
+		cfg.metrics.observeLogin(loginOIDC, loginFailure)
+		helperError(w, http.StatusUnauthorized, errCodeUnauthorized, "Login with the provider failed", nil)
+		return
+	}
+
+	dbUsr, err := cfg.helperUserForIdentity(r, claims.Subject, claims.Email, claims.EmailVerified)
+	if errors.Is(err, errLinkRequired) {
+		requestLogger(r).Info("External identity needs linking", "user_id", dbUsr.ID)
+		cfg.helperOIDCLinkRequired(w, r, dbUsr, claims.Subject, claims.Email)
+		return
+	}
+	if err != nil {
+		requestLogger(r).Info("Error resolving external identity", "error", err)
+		cfg.metrics.observeLogin(loginOIDC, loginFailure)
+		helperError(w, http.StatusForbidden, errCodeForbidden, "The provider account cannot be used to sign in", nil)
+		return
+	}
+	cfg.metrics.observeLogin(loginOIDC, loginSuccess)
+
+	if dbUsr.TotpEnabled {
+		cfg.helperTwoFactorChallenge(w, r, dbUsr)
+		return
+	}
+
+	cfg.helperIssueSession(w, r, dbUsr, "SSO")
+}
+
+// helperUserForIdentity finds the user linked to an external identity. New
+// identities are linked to a newly created user without a usable password.
+// When the verified email already belongs to a user, they are returned with
+// errLinkRequired, unless OIDCAutoLink links the identity to them at once.
+func (cfg *apiConfig) helperUserForIdentity(
+	r *http.Request,
+	subject string,
+	email string,
+	emailVerified bool,
+) (database.User, error) {
+	identityParams := database.GetUserIdentityParams{
+		Issuer:  cfg.oidc.Issuer(),
+		Subject: subject,
+	}
+	identity, err := cfg.store.GetUserIdentity(r.Context(), identityParams)
+	if err == nil {
+		return cfg.store.GetUserById(r.Context(), identity.UserID)
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return database.User{}, err
+	}
+
+	if email == "" || !emailVerified {
+		return database.User{}, errors.New("Provider did not supply a verified email")
+	}
+
+	user, err := cfg.store.GetUserByEmail(r.Context(), email)
+	if err == nil && !cfg.oidcAutoLink {
+		return user, errLinkRequired
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		password, err := auth.MakeRefreshToken()
+		if err != nil {
+			return database.User{}, err
+		}
+		user, err = cfg.helperCreateUser(email, password, r)
+		if err != nil {
+			return database.User{}, err
+		}
+	} else if err != nil {
+		return database.User{}, err
+	}
+
+	createParams := database.CreateUserIdentityParams{
+		ID:        uuid.New(),
+		CreatedAt: time.Now().Local(),
+		UpdatedAt: time.Now().Local(),
+		UserID:    user.ID,
+		Issuer:    cfg.oidc.Issuer(),
+		Subject:   subject,
+		Email:     email,
+	}
+	_, err = cfg.store.CreateUserIdentity(r.Context(), createParams)
+	if err != nil {
+		return database.User{}, err
+	}
+
+	return user, nil
+}
+
+// helperOIDCLinkRequired responds with a token that links the identity to
+// dbUsr once exchanged at /api/v1/oidc/link along with their password.
+func (cfg *apiConfig) helperOIDCLinkRequired(
+	w http.ResponseWriter,
+	r *http.Request,
+	dbUsr database.User,
+	subject string,
+	email string,
+) {
+	token, err := auth.MakeRefreshToken()
+	if err != nil {
+		requestLogger(r).Error("Error making link token", "error", err)
+		helperInternalError(w)
+		return
+	}
+	linkParams := database.CreateOIDCLinkParams{
+		TokenHash: auth.HashRefreshToken(token),
+		CreatedAt: time.Now().Local(),
+		ExpiresAt: time.Now().Local().Add(oidcLinkExpiresIn),
+		UserID:    dbUsr.ID,
+		Issuer:    cfg.oidc.Issuer(),
+		Subject:   subject,
+		Email:     email,
+	}
+	err = cfg.store.CreateOIDCLink(r.Context(), linkParams)
+	if err != nil {
+		requestLogger(r).Error("Error storing OIDC link", "error", err)
+		helperInternalError(w)
+		return
+	}
+
+	type retStruct struct {
+		LinkRequired bool   `json:"link_required"`
+		LinkToken    string `json:"link_token"`
+		Email        string `json:"email"`
+	}
+	dat, err := json.Marshal(retStruct{
+		LinkRequired: true,
+		LinkToken:    token,
+		Email:        dbUsr.Email,
+	})
+	if err != nil {
+		helperJsonError(w, r, "Error marshalling response", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(dat)
+}
+
+// handlerOIDCLink links a provider account to the existing user with its
+// email, once their password confirms they own it, and signs them in.
+func (cfg *apiConfig) handlerOIDCLink(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		LinkToken string `json:"link_token" validate:"required"`
+		Password  string `json:"password" validate:"required"`
+	}
+
+	params := parameters{}
+	if !helperDecode(w, r, &params) {
+		return
+	}
+
+	tokenHash := auth.HashRefreshToken(params.LinkToken)
+	link, err := cfg.store.GetOIDCLink(r.Context(), tokenHash)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && time.Now().After(link.ExpiresAt)) {
+		requestLogger(r).Info("Unknown or expired link token", "error", err)
+		helperError(w, http.StatusUnauthorized, errCodeUnauthorized, "Invalid or expired link token", nil)
+		return
+	}
+	if err != nil {
+		requestLogger(r).Error("Error fetching OIDC link", "error", err)
+		helperInternalError(w)
+		return
+	}
+	setRequestUser(r, link.UserID)
+
+	dbUsr, err := cfg.store.GetUserById(r.Context(), link.UserID)
+	if err != nil {
+		requestLogger(r).Error("Error fetching user", "error", err)
+		helperInternalError(w)
+		return
+	}
+	err = cfg.checkPasswordHash(r.Context(), params.Password, dbUsr.HashedPassword)
+	if err != nil {
+		requestLogger(r).Info("Bad password confirming OIDC link", "error", err)
+		cfg.metrics.observeLogin(loginOIDC, loginFailure)
+		helperError(w, http.StatusUnauthorized, errCodeInvalidCredentials, "Incorrect password", nil)
+		return
+	}
+
+	// Deleting the link first means each token links at most once.
+	deleted, err := cfg.store.DeleteOIDCLink(r.Context(), tokenHash)
+	if err != nil {
+		requestLogger(r).Error("Error deleting OIDC link", "error", err)
+		helperInternalError(w)
+		return
+	}
+	if deleted == 0 {
+		requestLogger(r).Info("Link token already used")
+		helperError(w, http.StatusUnauthorized, errCodeUnauthorized, "Invalid or expired link token", nil)
+		return
+	}
+
+	createParams := database.CreateUserIdentityParams{
+		ID:        uuid.New(),
+		CreatedAt: time.Now().Local(),
+		UpdatedAt: time.Now().Local(),
+		UserID:    dbUsr.ID,
+		Issuer:    link.Issuer,
+		Subject:   link.Subject,
+		Email:     link.Email,
+	}
+	_, err = cfg.store.CreateUserIdentity(r.Context(), createParams)
+	if isUniqueViolation(err) {
+		requestLogger(r).Info("Provider account already linked", "error", err)
+		helperError(w, http.StatusConflict, errCodeConflict, "The provider account is already linked", nil)
+		return
+	}
+	if err != nil {
+		requestLogger(r).Error("Error linking identity", "error", err)
+		helperInternalError(w)
+		return
+	}
+	cfg.metrics.observeLogin(loginOIDC, loginSuccess)
+
+	if dbUsr.TotpEnabled {
+		cfg.helperTwoFactorChallenge(w, r, dbUsr)
+		return
+	}
+
+	cfg.helperIssueSession(w, r, dbUsr, "SSO")
+}
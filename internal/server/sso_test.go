@@ -0,0 +1,117 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/Senaphim/Chirpy/internal/oidc/oidctest"
+	"github.com/Senaphim/Chirpy/internal/store"
+	"github.com/google/uuid"
+)
+
+// ssoResult is what the OIDC callback responds with: a session, or a token
+// to confirm linking an existing account.
+type ssoResult struct {
+	testSession
+	LinkRequired bool   `json:"link_required"`
+	LinkToken    string `json:"link_token"`
+}
+
+// ssoLogin signs in through the stub provider as user, following the
+// redirects by hand as the provider sends the browser to the configured
+// redirect URL rather than to the test server.
+func ssoLogin(t *testing.T, serverURL string, stub *oidctest.Provider, user oidctest.User) ssoResult {
+	t.Helper()
+	stub.SetUser(user)
+	noRedirects := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+
+	resp, err := noRedirects.Get(serverURL + "/api/v1/oidc/login")
+	if err != nil {
+		t.Fatalf("GET /api/v1/oidc/login error = %v", err)
+	}
+	resp.Body.Close()
+	cookies := resp.Cookies()
+	resp, err = noRedirects.Get(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatalf("GET provider authorize error = %v", err)
+	}
+	resp.Body.Close()
+	callback, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatalf("Bad callback %q: %v", resp.Header.Get("Location"), err)
+	}
+
+	req, err := http.NewRequest("GET", serverURL+"/api/v1/oidc/callback?"+callback.RawQuery, nil)
+	if err != nil {
+		t.Fatalf("NewRequest error = %v", err)
+	}
+	for _, cookie := range cookies {
+		req.AddCookie(cookie)
+	}
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET /api/v1/oidc/callback error = %v", err)
+	}
+	defer resp.Body.Close()
+	dat, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET /api/v1/oidc/callback status = %d: %s", resp.StatusCode, dat)
+	}
+	result := ssoResult{}
+	if err := json.Unmarshal(dat, &result); err != nil {
+		t.Fatalf("Unmarshal %s: %v", dat, err)
+	}
+	return result
+}
+
+func TestSSOLinking(t *testing.T) {
+	stub, provider := testOIDCProvider(t)
+	config := testServerConfig(t)
+	config.OIDC = provider
+	srv := httptest.NewServer(NewServer(config, store.NewMemory()))
+	t.Cleanup(srv.Close)
+	c := &testClient{t: t, url: srv.URL}
+
+	const password = "correct horse battery staple"
+	walt := c.signUp(password)
+	identity := oidctest.User{Subject: uuid.NewString(), Email: walt.Email, EmailVerified: true}
+
+	// A provider account with Walt's email can't sign in as him until his
+	// password confirms the link.
+	pending := ssoLogin(t, srv.URL, stub, identity)
+	if !pending.LinkRequired || pending.LinkToken == "" || pending.Token != "" {
+		t.Fatalf("Login with an existing user's email = %+v, want a link token", pending)
+	}
+	c.do("POST", "/api/v1/oidc/link", "", map[string]string{"link_token": pending.LinkToken, "password": "wrong"}, http.StatusUnauthorized, nil)
+	linked := testSession{}
+	c.do("POST", "/api/v1/oidc/link", "", map[string]string{"link_token": pending.LinkToken, "password": password}, http.StatusOK, &linked)
+	if linked.ID != walt.ID || linked.Token == "" {
+		t.Errorf("Linked session = %+v, want one for Walt", linked)
+	}
+	c.do("POST", "/api/v1/oidc/link", "", map[string]string{"link_token": pending.LinkToken, "password": password}, http.StatusUnauthorized, nil)
+
+	// Once linked the provider account signs in directly.
+	if again := ssoLogin(t, srv.URL, stub, identity); again.LinkRequired || again.ID != walt.ID {
+		t.Errorf("Login with a linked identity = %+v, want a session for Walt", again)
+	}
+
+	// Provider accounts with new emails get new users.
+	fresh := ssoLogin(t, srv.URL, stub, oidctest.User{Subject: uuid.NewString(), Email: "skyler@breakingbad.com", EmailVerified: true})
+	if fresh.LinkRequired || fresh.Token == "" || fresh.ID == walt.ID {
+		t.Errorf("Login with a new email = %+v, want a session for a new user", fresh)
+	}
+
+	// OIDCAutoLink trusts the provider's verified email.
+	config.OIDCAutoLink = true
+	autoSrv := httptest.NewServer(NewServer(config, store.NewMemory()))
+	t.Cleanup(autoSrv.Close)
+	jesse := (&testClient{t: t, url: autoSrv.URL}).signUp(password)
+	auto := ssoLogin(t, autoSrv.URL, stub, oidctest.User{Subject: uuid.NewString(), Email: jesse.Email, EmailVerified: true})
+	if auto.LinkRequired || auto.ID != jesse.ID {
+		t.Errorf("Login with OIDCAutoLink = %+v, want a session for Jesse", auto)
+	}
+}
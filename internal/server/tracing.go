@@ -0,0 +1,74 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/Senaphim/Chirpy/internal/auth"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName string = "github.com/Senaphim/Chirpy/internal/server"
+
+// traceContext reads and writes W3C traceparent and tracestate headers, so
+// a request joins the trace of whoever sent it.
+var traceContext = propagation.TraceContext{}
+
+// startRequestSpan starts the server span for r, continuing the caller's
+// trace when it sent one. It is named after the route once that is known.
+func (cfg *apiConfig) startRequestSpan(r *http.Request) (context.Context, trace.Span) {
+	ctx := traceContext.Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+	return cfg.tracer.Start(ctx, r.Method,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			attribute.String("http.request.method", r.Method),
+			attribute.String("url.path", r.URL.Path),
+			attribute.String("user_agent.original", r.UserAgent()),
+		),
+	)
+}
+
+// endRequestSpan records how r was answered on its span and ends it.
+func endRequestSpan(span trace.Span, r *http.Request, status int) {
+	if r.Pattern != "" {
+		span.SetName(r.Pattern)
+		route := r.Pattern
+		if _, path, found := strings.Cut(r.Pattern, " "); found {
+			route = path
+		}
+		span.SetAttributes(attribute.String("http.route", route))
+	}
+	span.SetAttributes(attribute.Int("http.response.status_code", status))
+	// Client errors are the client's problem, not a failure of the server.
+	if status >= http.StatusInternalServerError {
+		span.SetStatus(codes.Error, http.StatusText(status))
+	}
+	span.End()
+}
+
+// hashPassword is cfg.hasher.Hash in a span, as hashing is deliberately
+// slow.
+func (cfg *apiConfig) hashPassword(ctx context.Context, password string) (string, error) {
+	_, span := cfg.tracer.Start(ctx, "auth.HashPassword")
+	defer span.End()
+	hash, err := cfg.hasher.Hash(password)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "hashing failed")
+	}
+	return hash, err
+}
+
+// checkPasswordHash is auth.CheckPasswordHash in a span. A wrong password
+// is not an error of the span, as that is the check doing its job.
+func (cfg *apiConfig) checkPasswordHash(ctx context.Context, password, hash string) error {
+	_, span := cfg.tracer.Start(ctx, "auth.CheckPasswordHash")
+	defer span.End()
+	err := auth.CheckPasswordHash(password, hash)
+	span.SetAttributes(attribute.Bool("auth.password.match", err == nil))
+	return err
+}
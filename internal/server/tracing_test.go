@@ -0,0 +1,68 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Senaphim/Chirpy/internal/store"
+	"github.com/Senaphim/Chirpy/internal/store/storetest"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	config := testServerConfig(t)
+	config.TracerProvider = provider
+	st := storetest.SQLite(t, store.QueryTracing(provider))
+	srv := httptest.NewServer(NewServer(config, st))
+	t.Cleanup(srv.Close)
+	c := &testClient{t: t, url: srv.URL}
+	session := c.signUp("correct horse battery staple")
+
+	// Log in again as part of a trace started by the caller.
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	body := `{"email":"` + session.Email + `","password":"correct horse battery staple"}`
+	req, err := http.NewRequest("POST", srv.URL+"/api/v1/login", strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("NewRequest error = %v", err)
+	}
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	recorder.Reset()
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("POST /api/v1/login error = %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("POST /api/v1/login status = %d", resp.StatusCode)
+	}
+
+	spans := map[string]sdktrace.ReadOnlySpan{}
+	for _, span := range recorder.Ended() {
+		spans[span.Name()] = span
+	}
+	root, ok := spans["POST /api/v1/login"]
+	if !ok {
+		t.Fatalf("No span for the request among %v", spans)
+	}
+	if got := root.SpanContext().TraceID().String(); got != traceID {
+		t.Errorf("Request span trace ID = %s, want the caller's %s", got, traceID)
+	}
+	if got := root.Parent().SpanID().String(); got != "00f067aa0ba902b7" {
+		t.Errorf("Request span parent = %s, want the caller's span", got)
+	}
+	for _, name := range []string{"GetUserByEmail", "auth.CheckPasswordHash", "CreateRefreshToken"} {
+		span, ok := spans[name]
+		if !ok {
+			t.Errorf("No %s span among %v", name, spans)
+			continue
+		}
+		if span.Parent().SpanID() != root.SpanContext().SpanID() {
+			t.Errorf("%s span parent = %s, want the request span", name, span.Parent().SpanID())
+		}
+	}
+}
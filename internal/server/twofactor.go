@@ -0,0 +1,571 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Senaphim/Chirpy/internal/auth"
+	"github.com/Senaphim/Chirpy/internal/database"
+	"github.com/Senaphim/Chirpy/internal/store"
+	"github.com/Senaphim/Chirpy/internal/validate"
+	"github.com/google/uuid"
+)
+
+const (
+	totpIssuer         string        = "Chirpy"
+	recoveryCodeCount  int           = 10
+	challengeExpiresIn time.Duration = 5 * time.Minute
+	// maxChallengeAttempts is how many second factors can be tried with one
+	// challenge token before it is thrown away.
+	maxChallengeAttempts int64 = 5
+	// maxTotpFailures wrong authenticator codes within totpFailureWindow
+	// stop any more being checked for the user until the window has passed.
+	maxTotpFailures   int64         = 5
+	totpFailureWindow time.Duration = 15 * time.Minute
+)
+
+// errTotpLocked means too many wrong authenticator codes were tried for the
+// user lately.
+var errTotpLocked = errors.New("too many wrong authenticator codes")
+
+// helperCheckTOTP checks code against the user's authenticator. Every page
+// taking a TOTP code goes through it, so wrong codes count towards the same
+// per user limit wherever they are tried, and a code is refused once it, or a
+// later one, has been accepted.
+func (cfg *apiConfig) helperCheckTOTP(ctx context.Context, user database.User, code string) (bool, error) {
+	now := cfg.now()
+	if user.TotpFailures >= maxTotpFailures && user.TotpFailedAt.Valid && now.Sub(user.TotpFailedAt.Time) < totpFailureWindow {
+		return false, errTotpLocked
+	}
+
+	if step, ok := auth.MatchTOTP(code, user.TotpSecret.String, now); ok {
+		acceptParams := database.AcceptUsrTotpStepParams{
+			ID:           user.ID,
+			TotpLastStep: step,
+		}
+		accepted, err := cfg.store.AcceptUsrTotpStep(ctx, acceptParams)
+		if err != nil {
+			return false, err
+		}
+		if accepted == 1 {
+			return true, nil
+		}
+	}
+
+	failureParams := database.RecordUsrTotpFailureParams{
+		WindowStart: sql.NullTime{
+			Time:  now.Add(-totpFailureWindow).Local(),
+			Valid: true,
+		},
+		FailedAt: sql.NullTime{
+			Time:  now.Local(),
+			Valid: true,
+		},
+		ID: user.ID,
+	}
+	_, err := cfg.store.RecordUsrTotpFailure(ctx, failureParams)
+	return false, err
+}
+
+// helperTotpError writes the response for a code helperCheckTOTP did not
+// accept, returning false if it wrote one.
+func helperTotpError(w http.ResponseWriter, r *http.Request, err error) bool {
+	if errors.Is(err, errTotpLocked) {
+		requestLogger(r).Info("Authenticator codes locked out")
+		w.Header().Set("Retry-After", fmt.Sprint(int(totpFailureWindow.Seconds())))
+		helperError(w, http.StatusTooManyRequests, errCodeTooManyAttempts, "Too many incorrect authenticator codes, try again later", nil)
+		return false
+	}
+	if err != nil {
+		requestLogger(r).Error("Error checking authenticator code", "error", err)
+		helperInternalError(w)
+		return false
+	}
+	return true
+}
+
+func (cfg *apiConfig) handlerEnrollTotp(w http.ResponseWriter, r *http.Request) {
+	userId, err := cfg.helperAuthenticate(r, "")
+	if err != nil {
+		helperAuthError(w, r, err)
+		return
+	}
+
+	user, err := cfg.store.GetUserById(r.Context(), userId)
+	if err != nil {
+		requestLogger(r).Info("Error fetching user", "error", err)
+		helperError(w, http.StatusUnauthorized, errCodeUnauthorized, "User not found", nil)
+		return
+	}
+	if user.TotpEnabled {
+		requestLogger(r).Info("Two factor authentication already enabled")
+		helperError(w, http.StatusConflict, errCodeConflict, "Two factor authentication is already enabled", nil)
+		return
+	}
+
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		requestLogger(r).Error("Error generating TOTP secret", "error", err)
+		helperInternalError(w)
+		return
+	}
+
+	params := database.SetUsrTotpSecretParams{
+		ID:        userId,
+		UpdatedAt: cfg.now().Local(),
+		TotpSecret: sql.NullString{
+			String: secret,
+			Valid:  true,
+		},
+	}
+	_, err = cfg.store.SetUsrTotpSecret(r.Context(), params)
+	if err != nil {
+		requestLogger(r).Error("Error storing TOTP secret", "error", err)
+		helperInternalError(w)
+		return
+	}
+
+	type retStruct struct {
+		Secret     string `json:"secret"`
+		OtpauthURI string `json:"otpauth_uri"`
+	}
+	rStruct := retStruct{
+		Secret:     secret,
+		OtpauthURI: auth.TOTPURI(secret, totpIssuer, user.Email),
+	}
+	dat, err := json.Marshal(rStruct)
+	if err != nil {
+		helperJsonError(w, r, "Error marshalling response", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(dat)
+}
+
+func (cfg *apiConfig) handlerConfirmTotp(w http.ResponseWriter, r *http.Request) {
+	userId, err := cfg.helperAuthenticate(r, "")
+	if err != nil {
+		helperAuthError(w, r, err)
+		return
+	}
+
+	type confirmation struct {
+		Code string `json:"code" validate:"required"`
+	}
+
+	data := confirmation{}
+	if !helperDecode(w, r, &data) {
+		return
+	}
+
+	user, err := cfg.store.GetUserById(r.Context(), userId)
+	if err != nil {
+		requestLogger(r).Info("Error fetching user", "error", err)
+		helperError(w, http.StatusUnauthorized, errCodeUnauthorized, "User not found", nil)
+		return
+	}
+	if user.TotpEnabled {
+		requestLogger(r).Info("Two factor authentication already enabled")
+		helperError(w, http.StatusConflict, errCodeConflict, "Two factor authentication is already enabled", nil)
+		return
+	}
+	if !user.TotpSecret.Valid {
+		requestLogger(r).Info("Two factor confirmation without enrollment")
+		helperError(w, http.StatusBadRequest, errCodeBadRequest, "Two factor authentication has not been enrolled", nil)
+		return
+	}
+
+	ok, err := cfg.helperCheckTOTP(r.Context(), user, data.Code)
+	if !helperTotpError(w, r, err) {
+		return
+	}
+	if !ok {
+		requestLogger(r).Info("Invalid TOTP code during confirmation")
+		helperValidationError(w, "Invalid authenticator code", []validate.FieldError{
+			{Field: "code", Message: "does not match"},
+		})
+		return
+	}
+
+	codes, err := cfg.helperReplaceRecoveryCodes(r.Context(), cfg.store, userId)
+	if err != nil {
+		requestLogger(r).Error("Error replacing recovery codes", "error", err)
+		helperInternalError(w)
+		return
+	}
+
+	// Only enable 2FA once the recovery codes are safely stored, so that a
+	// failure part way through cannot lock the user out.
+	enableParams := database.EnableUsrTotpParams{
+		ID:        userId,
+		UpdatedAt: cfg.now().Local(),
+	}
+	_, err = cfg.store.EnableUsrTotp(r.Context(), enableParams)
+	if err != nil {
+		requestLogger(r).Error("Error enabling two factor authentication", "error", err)
+		helperInternalError(w)
+		return
+	}
+
+	helperRecoveryCodesResponse(w, r, codes)
+}
+
+// helperReplaceRecoveryCodes throws away the user's recovery codes, used or
+// not, and stores recoveryCodeCount new ones, which are returned.
+func (cfg *apiConfig) helperReplaceRecoveryCodes(ctx context.Context, st store.Store, userId uuid.UUID) ([]string, error) {
+	codes, err := auth.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := st.DeleteRecoveryCodesByUser(ctx, userId); err != nil {
+		return nil, err
+	}
+	for _, code := range codes {
+		codeParams := database.CreateRecoveryCodeParams{
+			CodeHash:  auth.HashRecoveryCode(code),
+			CreatedAt: cfg.now().Local(),
+			UserID:    userId,
+		}
+		if err := st.CreateRecoveryCode(ctx, codeParams); err != nil {
+			return nil, err
+		}
+	}
+	return codes, nil
+}
+
+func helperRecoveryCodesResponse(w http.ResponseWriter, r *http.Request, codes []string) {
+	type retStruct struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}
+	rStruct := retStruct{
+		RecoveryCodes: codes,
+	}
+	dat, err := json.Marshal(rStruct)
+	if err != nil {
+		helperJsonError(w, r, "Error marshalling response", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(dat)
+}
+
+// helperReauthenticateTwoFactor loads the user of a request changing their
+// two factor authentication and checks they have it enabled, their password
+// and a second factor. A recovery code is only accepted when allowRecovery
+// is set. On failure the error response has been written and false is
+// returned.
+func (cfg *apiConfig) helperReauthenticateTwoFactor(
+	w http.ResponseWriter,
+	r *http.Request,
+	userId uuid.UUID,
+	password string,
+	code string,
+	recoveryCode string,
+	allowRecovery bool,
+) bool {
+	user, err := cfg.store.GetUserById(r.Context(), userId)
+	if err != nil {
+		requestLogger(r).Info("Error fetching user", "error", err)
+		helperError(w, http.StatusUnauthorized, errCodeUnauthorized, "User not found", nil)
+		return false
+	}
+	if !user.TotpEnabled || !user.TotpSecret.Valid {
+		requestLogger(r).Info("Two factor authentication not enabled")
+		helperError(w, http.StatusBadRequest, errCodeBadRequest, "Two factor authentication is not enabled", nil)
+		return false
+	}
+
+	if err := cfg.checkPasswordHash(r.Context(), password, user.HashedPassword); err != nil {
+		requestLogger(r).Info("Incorrect password changing two factor authentication", "error", err)
+		helperError(w, http.StatusUnauthorized, errCodeInvalidCredentials, "Incorrect password", nil)
+		return false
+	}
+
+	switch {
+	case code != "":
+		ok, err := cfg.helperCheckTOTP(r.Context(), user, code)
+		if !helperTotpError(w, r, err) {
+			return false
+		}
+		if !ok {
+			requestLogger(r).Info("Invalid TOTP code changing two factor authentication")
+			helperError(w, http.StatusUnauthorized, errCodeInvalidCredentials, "Incorrect authenticator code", nil)
+			return false
+		}
+	case recoveryCode != "" && allowRecovery:
+		useParams := database.UseRecoveryCodeParams{
+			UsedAt: sql.NullTime{
+				Time:  cfg.now().Local(),
+				Valid: true,
+			},
+			CodeHash: auth.HashRecoveryCode(recoveryCode),
+			UserID:   userId,
+		}
+		used, err := cfg.store.UseRecoveryCode(r.Context(), useParams)
+		if err != nil {
+			requestLogger(r).Error("Error using recovery code", "error", err)
+			helperInternalError(w)
+			return false
+		}
+		if used == 0 {
+			requestLogger(r).Info("Invalid recovery code changing two factor authentication")
+			helperError(w, http.StatusUnauthorized, errCodeInvalidCredentials, "Incorrect recovery code", nil)
+			return false
+		}
+	default:
+		requestLogger(r).Info("No second factor supplied")
+		helperValidationError(w, "An authenticator code is required", []validate.FieldError{
+			{Field: "code", Message: "is required"},
+		})
+		return false
+	}
+	return true
+}
+
+// handlerDisableTotp turns two factor authentication off. It takes the
+// password and an authenticator code, or a recovery code for users who have
+// lost their authenticator.
+func (cfg *apiConfig) handlerDisableTotp(w http.ResponseWriter, r *http.Request) {
+	userId, err := cfg.helperAuthenticate(r, "")
+	if err != nil {
+		helperAuthError(w, r, err)
+		return
+	}
+
+	type parameters struct {
+		Password     string `json:"password" validate:"required"`
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recovery_code"`
+	}
+
+	params := parameters{}
+	if !helperDecode(w, r, &params) {
+		return
+	}
+	if !cfg.helperReauthenticateTwoFactor(w, r, userId, params.Password, params.Code, params.RecoveryCode, true) {
+		return
+	}
+
+	err = cfg.store.InTx(r.Context(), func(tx store.Store) error {
+		disableParams := database.DisableUsrTotpParams{
+			ID:        userId,
+			UpdatedAt: cfg.now().Local(),
+		}
+		if _, err := tx.DisableUsrTotp(r.Context(), disableParams); err != nil {
+			return err
+		}
+		return tx.DeleteRecoveryCodesByUser(r.Context(), userId)
+	})
+	if err != nil {
+		requestLogger(r).Error("Error disabling two factor authentication", "error", err)
+		helperInternalError(w)
+		return
+	}
+
+	requestLogger(r).Info("Two factor authentication disabled")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handlerRegenerateRecoveryCodes replaces the user's recovery codes, for
+// when they have used them up or lost them. It takes the password and an
+// authenticator code.
+func (cfg *apiConfig) handlerRegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	userId, err := cfg.helperAuthenticate(r, "")
+	if err != nil {
+		helperAuthError(w, r, err)
+		return
+	}
+
+	type parameters struct {
+		Password string `json:"password" validate:"required"`
+		Code     string `json:"code" validate:"required"`
+	}
+
+	params := parameters{}
+	if !helperDecode(w, r, &params) {
+		return
+	}
+	if !cfg.helperReauthenticateTwoFactor(w, r, userId, params.Password, params.Code, "", false) {
+		return
+	}
+
+	var codes []string
+	err = cfg.store.InTx(r.Context(), func(tx store.Store) error {
+		var err error
+		codes, err = cfg.helperReplaceRecoveryCodes(r.Context(), tx, userId)
+		return err
+	})
+	if err != nil {
+		requestLogger(r).Error("Error replacing recovery codes", "error", err)
+		helperInternalError(w)
+		return
+	}
+
+	helperRecoveryCodesResponse(w, r, codes)
+}
+
+// helperTwoFactorChallenge answers a correct password for a user with 2FA
+// enabled. The challenge token is exchanged at /api/v1/login/2fa together with a
+// TOTP or recovery code for the real access and refresh tokens. It names a
+// stored challenge, which is used up by a successful login or by
+// maxChallengeAttempts failed ones.
+func (cfg *apiConfig) helperTwoFactorChallenge(w http.ResponseWriter, r *http.Request, dbUsr database.User) {
+	challengeParams := database.CreateTwoFactorChallengeParams{
+		ID:        uuid.New(),
+		UserID:    dbUsr.ID,
+		CreatedAt: cfg.now().Local(),
+		ExpiresAt: cfg.now().Local().Add(challengeExpiresIn),
+	}
+	err := cfg.store.CreateTwoFactorChallenge(r.Context(), challengeParams)
+	if err != nil {
+		requestLogger(r).Error("Error storing challenge", "error", err)
+		helperInternalError(w)
+		return
+	}
+	challenge, err := auth.MakeChallengeJWT(dbUsr.ID, challengeParams.ID, cfg.keys, challengeExpiresIn)
+	if err != nil {
+		requestLogger(r).Error("Error making challenge token", "error", err)
+		helperInternalError(w)
+		return
+	}
+
+	type retStruct struct {
+		TwoFactorRequired bool   `json:"two_factor_required"`
+		ChallengeToken    string `json:"challenge_token"`
+	}
+	rStruct := retStruct{
+		TwoFactorRequired: true,
+		ChallengeToken:    challenge,
+	}
+	dat, err := json.Marshal(rStruct)
+	if err != nil {
+		helperJsonError(w, r, "Error marshalling response", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(dat)
+}
+
+func (cfg *apiConfig) handlerLoginTwoFactor(w http.ResponseWriter, r *http.Request) {
+	type secondFactor struct {
+		ChallengeToken string `json:"challenge_token" validate:"required"`
+		Code           string `json:"code"`
+		RecoveryCode   string `json:"recovery_code"`
+		DeviceName     string `json:"device_name" validate:"max=100"`
+	}
+
+	data := secondFactor{}
+	if !helperDecode(w, r, &data) {
+		return
+	}
+
+	userId, challengeId, err := auth.ValidateChallengeJWT(data.ChallengeToken, cfg.keys)
+	if err != nil {
+		requestLogger(r).Info("Invalid challenge token", "error", err)
+		helperError(w, http.StatusUnauthorized, errCodeUnauthorized, "Invalid or expired challenge token", nil)
+		return
+	}
+
+	attemptParams := database.AttemptTwoFactorChallengeParams{
+		ID:  challengeId,
+		Now: cfg.now().Local(),
+	}
+	challenge, err := cfg.store.AttemptTwoFactorChallenge(r.Context(), attemptParams)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		requestLogger(r).Error("Error fetching challenge", "error", err)
+		helperInternalError(w)
+		return
+	}
+	if err != nil || challenge.UserID != userId || challenge.Attempts > maxChallengeAttempts {
+		requestLogger(r).Info("Challenge used up or expired", "error", err)
+		helperError(w, http.StatusUnauthorized, errCodeUnauthorized, "Invalid or expired challenge token", nil)
+		return
+	}
+	setRequestUser(r, userId)
+
+	// rejected answers a wrong second factor, throwing the challenge away
+	// once it has had all its attempts.
+	rejected := func(reason string) {
+		requestLogger(r).Info(reason, "attempt", challenge.Attempts)
+		if challenge.Attempts >= maxChallengeAttempts {
+			if _, err := cfg.store.DeleteTwoFactorChallenge(r.Context(), challengeId); err != nil {
+				requestLogger(r).Error("Error deleting challenge", "error", err)
+			}
+		}
+		cfg.metrics.observeLogin(loginTwoFactor, loginFailure)
+		helperError(w, http.StatusUnauthorized, errCodeInvalidCredentials, "Incorrect authenticator or recovery code", nil)
+	}
+
+	dbUsr, err := cfg.store.GetUserById(r.Context(), userId)
+	if err != nil {
+		requestLogger(r).Info("Error fetching user", "error", err)
+		helperError(w, http.StatusUnauthorized, errCodeUnauthorized, "User not found", nil)
+		return
+	}
+	if !dbUsr.TotpEnabled || !dbUsr.TotpSecret.Valid {
+		requestLogger(r).Info("Two factor login for user without 2FA")
+		helperError(w, http.StatusUnauthorized, errCodeUnauthorized, "Invalid or expired challenge token", nil)
+		return
+	}
+
+	if data.Code != "" {
+		ok, err := cfg.helperCheckTOTP(r.Context(), dbUsr, data.Code)
+		if !helperTotpError(w, r, err) {
+			return
+		}
+		if !ok {
+			rejected("Invalid TOTP code")
+			return
+		}
+	} else if data.RecoveryCode != "" {
+		useParams := database.UseRecoveryCodeParams{
+			UsedAt: sql.NullTime{
+				Time:  cfg.now().Local(),
+				Valid: true,
+			},
+			CodeHash: auth.HashRecoveryCode(data.RecoveryCode),
+			UserID:   userId,
+		}
+		used, err := cfg.store.UseRecoveryCode(r.Context(), useParams)
+		if err != nil {
+			requestLogger(r).Error("Error using recovery code", "error", err)
+			helperInternalError(w)
+			return
+		}
+		if used == 0 {
+			rejected("Invalid recovery code")
+			return
+		}
+	} else {
+		requestLogger(r).Info("No second factor supplied")
+		helperValidationError(w, "A code or recovery code is required", []validate.FieldError{
+			{Field: "code", Message: "is required"},
+		})
+		return
+	}
+
+	// Only the request that deletes the challenge logs in with it.
+	deleted, err := cfg.store.DeleteTwoFactorChallenge(r.Context(), challengeId)
+	if err != nil {
+		requestLogger(r).Error("Error deleting challenge", "error", err)
+		helperInternalError(w)
+		return
+	}
+	if deleted == 0 {
+		requestLogger(r).Info("Challenge used by another request")
+		helperError(w, http.StatusUnauthorized, errCodeUnauthorized, "Invalid or expired challenge token", nil)
+		return
+	}
+
+	cfg.metrics.observeLogin(loginTwoFactor, loginSuccess)
+	cfg.helperIssueSession(w, r, dbUsr, data.DeviceName)
+}
@@ -0,0 +1,51 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	apiPrefix   string = "/api/"
+	apiV1Prefix string = "/api/v1/"
+)
+
+// The unversioned /api/ paths were deprecated when /api/v1 was introduced
+// and are removed at the sunset date.
+var (
+	legacyDeprecatedAt = time.Date(2026, time.October, 18, 0, 0, 0, 0, time.UTC)
+	legacySunsetAt     = time.Date(2027, time.April, 18, 0, 0, 0, 0, time.UTC)
+)
+
+// handleVersioned registers handler under a /api/v1/ pattern, and under the
+// same pattern without the version as a deprecated alias for older clients.
+func (cfg *apiConfig) handleVersioned(mux *router, pattern string, handler http.Handler) {
+	mux.Handle(pattern, handler)
+
+	legacyPattern := strings.Replace(pattern, apiV1Prefix, apiPrefix, 1)
+	if legacyPattern == pattern {
+		return
+	}
+	cfg.legacyRoutes = append(cfg.legacyRoutes, legacyPattern)
+	mux.Handle(legacyPattern, cfg.middlewareDeprecated(legacyPattern, handler))
+}
+
+// middlewareDeprecated marks responses with the Deprecation (RFC 9745) and
+// Sunset (RFC 8594) headers, points clients at the versioned path and counts
+// how often the legacy pattern is still used.
+func (cfg *apiConfig) middlewareDeprecated(pattern string, next http.Handler) http.Handler {
+	// Every legacy route is exported from the start, so unused ones show
+	// as zero rather than missing.
+	hits := cfg.metrics.legacyRequests.With(pattern)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Inc()
+
+		successor := apiV1Prefix + strings.TrimPrefix(r.URL.Path, apiPrefix)
+		w.Header().Set("Deprecation", "@"+strconv.FormatInt(legacyDeprecatedAt.Unix(), 10))
+		w.Header().Set("Sunset", legacySunsetAt.Format(http.TimeFormat))
+		w.Header().Add("Link", "<"+successor+`>; rel="successor-version"`)
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,63 @@
+package server
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLegacyRoutesAreDeprecated(t *testing.T) {
+	cfg := testConfig(t)
+	handler := cfg.routes()
+
+	tests := []struct {
+		name           string
+		target         string
+		wantDeprecated bool
+		wantSuccessor  string
+	}{
+		{name: "Versioned", target: "/api/v1/openapi.json"},
+		{name: "Legacy", target: "/api/openapi.json", wantDeprecated: true, wantSuccessor: `</api/v1/openapi.json>; rel="successor-version"`},
+		{name: "Legacy with wildcard", target: "/api/chirps/nope", wantDeprecated: true, wantSuccessor: `</api/v1/chirps/nope>; rel="successor-version"`},
+		{name: "Unversioned", target: "/api/healthz"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest("GET", tt.target, nil))
+
+			if rec.Body.String() == "404 page not found\n" {
+				t.Fatalf("%s was not routed", tt.target)
+			}
+			deprecation := rec.Header().Get("Deprecation")
+			if (deprecation != "") != tt.wantDeprecated {
+				t.Errorf("Deprecation = %q, want deprecated %v", deprecation, tt.wantDeprecated)
+			}
+			if !tt.wantDeprecated {
+				return
+			}
+			if deprecation != "@1792281600" {
+				t.Errorf("Deprecation = %q", deprecation)
+			}
+			if got := rec.Header().Get("Sunset"); got != "Sun, 18 Apr 2027 00:00:00 GMT" {
+				t.Errorf("Sunset = %q", got)
+			}
+			if got := rec.Header().Get("Link"); got != tt.wantSuccessor {
+				t.Errorf("Link = %q, want %q", got, tt.wantSuccessor)
+			}
+		})
+	}
+
+	rec := httptest.NewRecorder()
+	cfg.registry.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	for _, line := range []string{
+		`chirpy_legacy_requests_total{route="GET /api/openapi.json"} 1`,
+		`chirpy_legacy_requests_total{route="GET /api/chirps/{chirpID}"} 1`,
+		`chirpy_legacy_requests_total{route="POST /api/chirps"} 0`,
+	} {
+		if !strings.Contains(rec.Body.String(), line+"\n") {
+			t.Errorf("Metrics are missing %s:\n%s", line, rec.Body.String())
+		}
+	}
+}
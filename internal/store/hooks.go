@@ -0,0 +1,127 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/Senaphim/Chirpy/internal/metrics"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// QueryHook is called before every query a store makes, with the query's
+// sqlc name. The context it returns is used for the query, and the function
+// it returns is called with the query's error once it has run.
+type QueryHook func(ctx context.Context, name string) (context.Context, func(error))
+
+// dbtx is the DBTX interface sqlc generates for both databases.
+type dbtx interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	PrepareContext(ctx context.Context, query string) (*sql.Stmt, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// hookedDB runs hooks around every query on db.
+type hookedDB struct {
+	db    dbtx
+	hooks []QueryHook
+}
+
+func withHooks(db dbtx, hooks []QueryHook) dbtx {
+	if len(hooks) == 0 {
+		return db
+	}
+	return hookedDB{db: db, hooks: hooks}
+}
+
+// start runs the hooks for query and returns the function to finish them.
+func (db hookedDB) start(ctx context.Context, query string) (context.Context, func(error)) {
+	name := queryName(query)
+	dones := make([]func(error), len(db.hooks))
+	for i, hook := range db.hooks {
+		ctx, dones[i] = hook(ctx, name)
+	}
+	return ctx, func(err error) {
+		for i := len(dones) - 1; i >= 0; i-- {
+			dones[i](err)
+		}
+	}
+}
+
+func (db hookedDB) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	ctx, done := db.start(ctx, query)
+	result, err := db.db.ExecContext(ctx, query, args...)
+	done(err)
+	return result, err
+}
+
+func (db hookedDB) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	return db.db.PrepareContext(ctx, query)
+}
+
+// QueryContext only times the query until the first rows are ready, not
+// reading them all.
+func (db hookedDB) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	ctx, done := db.start(ctx, query)
+	rows, err := db.db.QueryContext(ctx, query, args...)
+	done(err)
+	return rows, err
+}
+
+func (db hookedDB) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	ctx, done := db.start(ctx, query)
+	row := db.db.QueryRowContext(ctx, query, args...)
+	done(row.Err())
+	return row
+}
+
+// queryName reads the name sqlc keeps in a comment at the top of each
+// query, such as GetUserByEmail.
+func queryName(query string) string {
+	rest, ok := strings.CutPrefix(query, "-- name: ")
+	if !ok {
+		return "unknown"
+	}
+	name, _, _ := strings.Cut(rest, " ")
+	return name
+}
+
+// QueryMetrics returns a hook timing every query, labelled by name, and
+// counting those that fail.
+func QueryMetrics(reg *metrics.Registry) QueryHook {
+	duration := reg.NewHistogram("chirpy_db_query_duration_seconds", "Time taken by database queries.", metrics.QueryBuckets, "query")
+	failures := reg.NewCounter("chirpy_db_query_errors_total", "Database queries that failed.", "query")
+	return func(ctx context.Context, name string) (context.Context, func(error)) {
+		start := time.Now()
+		return ctx, func(err error) {
+			duration.With(name).Observe(time.Since(start).Seconds())
+			if err != nil && !errors.Is(err, sql.ErrNoRows) {
+				failures.With(name).Inc()
+			}
+		}
+	}
+}
+
+// QueryTracing returns a hook giving every query a client span, named after
+// the query, under the span of whatever made it.
+func QueryTracing(tp trace.TracerProvider) QueryHook {
+	tracer := tp.Tracer("github.com/Senaphim/Chirpy/internal/store")
+	return func(ctx context.Context, name string) (context.Context, func(error)) {
+		ctx, span := tracer.Start(ctx, name,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(attribute.String("db.operation.name", name)),
+		)
+		return ctx, func(err error) {
+			if err != nil && !errors.Is(err, sql.ErrNoRows) {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, "query failed")
+			}
+			span.End()
+		}
+	}
+}
@@ -0,0 +1,1109 @@
+package store
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Senaphim/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// Memory is a Store that keeps everything in memory. It mirrors the
+// behaviour of the Postgres schema, including unique constraints, foreign
+// keys and cascading deletes, so handlers cannot tell the two apart. It is
+// safe for concurrent use.
+type Memory struct {
+	mu            sync.Mutex
+	users         []database.User
+	chirps        []database.Chirp
+	reports       []database.Report
+	refreshTokens []database.RefreshToken
+	recoveryCodes []database.RecoveryCode
+	apiTokens     []database.ApiToken
+	oauthClients  []database.OauthClient
+	redirectURIs  []database.OauthRedirectUri
+	oauthCodes    []database.OauthCode
+	identities    []database.UserIdentity
+	oidcLogins    []database.OidcLogin
+	oidcLinks     []database.OidcLink
+	challenges    []database.TwoFactorChallenge
+}
+
+var _ Store = (*Memory)(nil)
+
+func NewMemory() *Memory {
+	return &Memory{}
+}
+
+// InTx runs fn against a copy of the store, which replaces what the store
+// holds if fn succeeds. The store stays locked until then, so nothing
+// written by others is lost; fn must only use the store it is given.
+func (m *Memory) InTx(ctx context.Context, fn func(Store) error) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tx := &Memory{}
+	tx.copyFrom(m)
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	m.copyFrom(tx)
+	return nil
+}
+
+// copyFrom makes m hold a copy of everything in from. The caller must hold
+// from's lock, and m's too unless no one else can see m yet.
+func (m *Memory) copyFrom(from *Memory) {
+	m.users = slices.Clone(from.users)
+	m.chirps = slices.Clone(from.chirps)
+	m.reports = slices.Clone(from.reports)
+	m.refreshTokens = slices.Clone(from.refreshTokens)
+	m.recoveryCodes = slices.Clone(from.recoveryCodes)
+	m.apiTokens = slices.Clone(from.apiTokens)
+	m.oauthClients = slices.Clone(from.oauthClients)
+	m.redirectURIs = slices.Clone(from.redirectURIs)
+	m.oauthCodes = slices.Clone(from.oauthCodes)
+	m.identities = slices.Clone(from.identities)
+	m.oidcLogins = slices.Clone(from.oidcLogins)
+	m.oidcLinks = slices.Clone(from.oidcLinks)
+	m.challenges = slices.Clone(from.challenges)
+}
+
+func duplicate(table, column string) error {
+	return fmt.Errorf("%w: %s.%s", ErrDuplicate, table, column)
+}
+
+// userExists stands in for the foreign keys onto users. The caller must hold
+// the lock.
+func (m *Memory) userExists(id uuid.UUID) error {
+	if !slices.ContainsFunc(m.users, func(u database.User) bool { return u.ID == id }) {
+		return fmt.Errorf("store: user %s does not exist", id)
+	}
+	return nil
+}
+
+// updateUser applies update to the user with id and returns the result. The
+// caller must hold the lock.
+func (m *Memory) updateUser(id uuid.UUID, update func(*database.User)) (database.User, error) {
+	i := slices.IndexFunc(m.users, func(u database.User) bool { return u.ID == id })
+	if i < 0 {
+		return database.User{}, sql.ErrNoRows
+	}
+	update(&m.users[i])
+	return m.users[i], nil
+}
+
+func (m *Memory) CreateUser(ctx context.Context, arg database.CreateUserParams) (database.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, u := range m.users {
+		if u.ID == arg.ID {
+			return database.User{}, duplicate("users", "id")
+		}
+		if u.Email == arg.Email {
+			return database.User{}, duplicate("users", "email")
+		}
+	}
+	user := database.User{
+		ID:             arg.ID,
+		CreatedAt:      arg.CreatedAt,
+		UpdatedAt:      arg.UpdatedAt,
+		Email:          arg.Email,
+		HashedPassword: arg.HashedPassword,
+	}
+	m.users = append(m.users, user)
+	return user, nil
+}
+
+func (m *Memory) GetUserByEmail(ctx context.Context, email string) (database.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	i := slices.IndexFunc(m.users, func(u database.User) bool { return u.Email == email })
+	if i < 0 {
+		return database.User{}, sql.ErrNoRows
+	}
+	return m.users[i], nil
+}
+
+func (m *Memory) GetUserById(ctx context.Context, id uuid.UUID) (database.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	i := slices.IndexFunc(m.users, func(u database.User) bool { return u.ID == id })
+	if i < 0 {
+		return database.User{}, sql.ErrNoRows
+	}
+	return m.users[i], nil
+}
+
+func (m *Memory) UpdateUsrEmailPwd(ctx context.Context, arg database.UpdateUsrEmailPwdParams) (database.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if slices.ContainsFunc(m.users, func(u database.User) bool { return u.Email == arg.Email && u.ID != arg.ID }) {
+		return database.User{}, duplicate("users", "email")
+	}
+	return m.updateUser(arg.ID, func(u *database.User) {
+		u.UpdatedAt = arg.UpdatedAt
+		u.Email = arg.Email
+		u.HashedPassword = arg.HashedPassword
+	})
+}
+
+func (m *Memory) UpdateUsrPassword(ctx context.Context, arg database.UpdateUsrPasswordParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, err := m.updateUser(arg.ID, func(u *database.User) {
+		u.UpdatedAt = arg.UpdatedAt
+		u.HashedPassword = arg.HashedPassword
+	})
+	if err == sql.ErrNoRows {
+		// An UPDATE matching nothing is not an error.
+		return nil
+	}
+	return err
+}
+
+func (m *Memory) UpdateUsrChirpyRed(ctx context.Context, arg database.UpdateUsrChirpyRedParams) (database.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.updateUser(arg.ID, func(u *database.User) {
+		u.UpdatedAt = arg.UpdatedAt
+		u.IsChirpyRed = arg.IsChirpyRed
+	})
+}
+
+func (m *Memory) SetUsrTotpSecret(ctx context.Context, arg database.SetUsrTotpSecretParams) (database.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.updateUser(arg.ID, func(u *database.User) {
+		u.UpdatedAt = arg.UpdatedAt
+		u.TotpSecret = arg.TotpSecret
+		u.TotpEnabled = false
+	})
+}
+
+func (m *Memory) EnableUsrTotp(ctx context.Context, arg database.EnableUsrTotpParams) (database.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.updateUser(arg.ID, func(u *database.User) {
+		u.UpdatedAt = arg.UpdatedAt
+		u.TotpEnabled = true
+	})
+}
+
+func (m *Memory) DisableUsrTotp(ctx context.Context, arg database.DisableUsrTotpParams) (database.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.updateUser(arg.ID, func(u *database.User) {
+		u.UpdatedAt = arg.UpdatedAt
+		u.TotpSecret = sql.NullString{}
+		u.TotpEnabled = false
+		u.TotpLastStep = 0
+	})
+}
+
+func (m *Memory) UpdateUsrAdmin(ctx context.Context, arg database.UpdateUsrAdminParams) (database.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	i := slices.IndexFunc(m.users, func(u database.User) bool { return u.Email == arg.Email })
+	if i < 0 {
+		return database.User{}, sql.ErrNoRows
+	}
+	return m.updateUser(m.users[i].ID, func(u *database.User) {
+		u.UpdatedAt = arg.UpdatedAt
+		u.IsAdmin = arg.IsAdmin
+	})
+}
+
+func (m *Memory) AcceptUsrTotpStep(ctx context.Context, arg database.AcceptUsrTotpStepParams) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	i := slices.IndexFunc(m.users, func(u database.User) bool { return u.ID == arg.ID })
+	if i < 0 || m.users[i].TotpLastStep >= arg.TotpLastStep {
+		return 0, nil
+	}
+	m.users[i].TotpLastStep = arg.TotpLastStep
+	m.users[i].TotpFailures = 0
+	return 1, nil
+}
+
+func (m *Memory) RecordUsrTotpFailure(ctx context.Context, arg database.RecordUsrTotpFailureParams) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	user, err := m.updateUser(arg.ID, func(u *database.User) {
+		if u.TotpFailedAt.Valid && u.TotpFailedAt.Time.After(arg.WindowStart.Time) {
+			u.TotpFailures++
+		} else {
+			u.TotpFailures = 1
+		}
+		u.TotpFailedAt = arg.FailedAt
+	})
+	return user.TotpFailures, err
+}
+
+func (m *Memory) DeleteAll(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Everything except pending single sign on logins references a user.
+	m.users = nil
+	m.chirps = nil
+	m.reports = nil
+	m.refreshTokens = nil
+	m.recoveryCodes = nil
+	m.apiTokens = nil
+	m.oauthClients = nil
+	m.redirectURIs = nil
+	m.oauthCodes = nil
+	m.identities = nil
+	m.oidcLinks = nil
+	m.challenges = nil
+	return nil
+}
+
+func (m *Memory) CreateChirp(ctx context.Context, arg database.CreateChirpParams) (database.Chirp, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.userExists(arg.UserID); err != nil {
+		return database.Chirp{}, err
+	}
+	for _, c := range m.chirps {
+		if c.ID == arg.ID {
+			return database.Chirp{}, duplicate("chirps", "id")
+		}
+		if c.Body == arg.Body {
+			return database.Chirp{}, duplicate("chirps", "body")
+		}
+	}
+	chirp := database.Chirp{
+		ID:        arg.ID,
+		CreatedAt: arg.CreatedAt,
+		UpdatedAt: arg.UpdatedAt,
+		Body:      arg.Body,
+		UserID:    arg.UserID,
+	}
+	m.chirps = append(m.chirps, chirp)
+	return chirp, nil
+}
+
+func (m *Memory) AllChirps(ctx context.Context) ([]database.Chirp, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	chirps := slices.Clone(m.chirps)
+	slices.SortStableFunc(chirps, func(a, b database.Chirp) int {
+		return a.UpdatedAt.Compare(b.UpdatedAt)
+	})
+	return chirps, nil
+}
+
+func (m *Memory) GetChirpsByAuthor(ctx context.Context, userID uuid.UUID) ([]database.Chirp, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	chirps := []database.Chirp{}
+	for _, c := range m.chirps {
+		if c.UserID == userID {
+			chirps = append(chirps, c)
+		}
+	}
+	return chirps, nil
+}
+
+func (m *Memory) GetChirpById(ctx context.Context, id uuid.UUID) (database.Chirp, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	i := slices.IndexFunc(m.chirps, func(c database.Chirp) bool { return c.ID == id })
+	if i < 0 {
+		return database.Chirp{}, sql.ErrNoRows
+	}
+	return m.chirps[i], nil
+}
+
+func (m *Memory) DeleteChirpById(ctx context.Context, id uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.chirps = slices.DeleteFunc(m.chirps, func(c database.Chirp) bool { return c.ID == id })
+	m.reports = slices.DeleteFunc(m.reports, func(r database.Report) bool { return r.ChirpID == id })
+	return nil
+}
+
+func (m *Memory) ResetChirps(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.chirps = nil
+	m.reports = nil
+	return nil
+}
+
+func (m *Memory) CreateReport(ctx context.Context, arg database.CreateReportParams) (database.Report, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.userExists(arg.ReporterID); err != nil {
+		return database.Report{}, err
+	}
+	if !slices.ContainsFunc(m.chirps, func(c database.Chirp) bool { return c.ID == arg.ChirpID }) {
+		return database.Report{}, fmt.Errorf("store: chirp %s does not exist", arg.ChirpID)
+	}
+	for _, r := range m.reports {
+		if r.ID == arg.ID {
+			return database.Report{}, duplicate("reports", "id")
+		}
+		if r.ChirpID == arg.ChirpID && r.ReporterID == arg.ReporterID {
+			return database.Report{}, duplicate("reports", "chirp_id, reporter_id")
+		}
+	}
+	report := database.Report(arg)
+	m.reports = append(m.reports, report)
+	return report, nil
+}
+
+func (m *Memory) RecentReports(ctx context.Context, limit int32) ([]database.RecentReportsRow, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	rows := []database.RecentReportsRow{}
+	for _, r := range m.reports {
+		row := database.RecentReportsRow{ID: r.ID, CreatedAt: r.CreatedAt, Reason: r.Reason, ChirpID: r.ChirpID}
+		if i := slices.IndexFunc(m.chirps, func(c database.Chirp) bool { return c.ID == r.ChirpID }); i >= 0 {
+			row.Body = m.chirps[i].Body
+		}
+		if i := slices.IndexFunc(m.users, func(u database.User) bool { return u.ID == r.ReporterID }); i >= 0 {
+			row.ReporterEmail = m.users[i].Email
+		}
+		rows = append(rows, row)
+	}
+	slices.SortFunc(rows, func(a, b database.RecentReportsRow) int {
+		if c := b.CreatedAt.Compare(a.CreatedAt); c != 0 {
+			return c
+		}
+		return strings.Compare(a.ID.String(), b.ID.String())
+	})
+	if len(rows) > int(limit) {
+		rows = rows[:limit]
+	}
+	return rows, nil
+}
+
+func (m *Memory) ResetReports(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.reports = nil
+	return nil
+}
+
+func (m *Memory) CreateRefreshToken(ctx context.Context, arg database.CreateRefreshTokenParams) (database.RefreshToken, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.userExists(arg.UserID); err != nil {
+		return database.RefreshToken{}, err
+	}
+	if arg.ClientID.Valid && !slices.ContainsFunc(m.oauthClients, func(c database.OauthClient) bool { return c.ID == arg.ClientID.UUID }) {
+		return database.RefreshToken{}, fmt.Errorf("store: OAuth client %s does not exist", arg.ClientID.UUID)
+	}
+	for _, t := range m.refreshTokens {
+		if t.TokenHash == arg.TokenHash {
+			return database.RefreshToken{}, duplicate("refresh_tokens", "token_hash")
+		}
+		if t.ID == arg.ID {
+			return database.RefreshToken{}, duplicate("refresh_tokens", "id")
+		}
+	}
+	token := database.RefreshToken{
+		TokenHash:  arg.TokenHash,
+		CreatedAt:  arg.CreatedAt,
+		UpdatedAt:  arg.UpdatedAt,
+		UserID:     arg.UserID,
+		ExpiresAt:  arg.ExpiresAt,
+		ID:         arg.ID,
+		DeviceName: arg.DeviceName,
+		UserAgent:  arg.UserAgent,
+		IpAddress:  arg.IpAddress,
+		LastUsedAt: arg.LastUsedAt,
+		ClientID:   arg.ClientID,
+		Scopes:     arg.Scopes,
+		FamilyID:   arg.FamilyID,
+	}
+	m.refreshTokens = append(m.refreshTokens, token)
+	return token, nil
+}
+
+func (m *Memory) GetRefreshToken(ctx context.Context, tokenHash string) (database.RefreshToken, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	i := slices.IndexFunc(m.refreshTokens, func(t database.RefreshToken) bool { return t.TokenHash == tokenHash })
+	if i < 0 {
+		return database.RefreshToken{}, sql.ErrNoRows
+	}
+	return m.refreshTokens[i], nil
+}
+
+func (m *Memory) TouchRefreshToken(ctx context.Context, arg database.TouchRefreshTokenParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i := range m.refreshTokens {
+		t := &m.refreshTokens[i]
+		if t.TokenHash == arg.TokenHash {
+			t.UpdatedAt = arg.UpdatedAt
+			t.LastUsedAt = arg.LastUsedAt
+			t.UserAgent = arg.UserAgent
+			t.IpAddress = arg.IpAddress
+		}
+	}
+	return nil
+}
+
+func (m *Memory) RevokeRefreshToken(ctx context.Context, arg database.RevokeRefreshTokenParams) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var revoked int64
+	for i := range m.refreshTokens {
+		t := &m.refreshTokens[i]
+		if t.TokenHash == arg.TokenHash && !t.RevokedAt.Valid {
+			t.UpdatedAt = arg.UpdatedAt
+			t.RevokedAt = arg.RevokedAt
+			revoked++
+		}
+	}
+	return revoked, nil
+}
+
+func (m *Memory) RevokeRefreshTokenFamily(ctx context.Context, arg database.RevokeRefreshTokenFamilyParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i := range m.refreshTokens {
+		t := &m.refreshTokens[i]
+		if arg.FamilyID.Valid && t.FamilyID == arg.FamilyID && !t.RevokedAt.Valid {
+			t.UpdatedAt = arg.UpdatedAt
+			t.RevokedAt = arg.RevokedAt
+		}
+	}
+	return nil
+}
+
+func (m *Memory) GetActiveSessionsByUser(ctx context.Context, arg database.GetActiveSessionsByUserParams) ([]database.RefreshToken, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	sessions := []database.RefreshToken{}
+	for _, t := range m.refreshTokens {
+		if t.UserID == arg.UserID && !t.RevokedAt.Valid && t.ExpiresAt.After(arg.ExpiresAt) {
+			sessions = append(sessions, t)
+		}
+	}
+	slices.SortStableFunc(sessions, func(a, b database.RefreshToken) int {
+		return b.LastUsedAt.Compare(a.LastUsedAt)
+	})
+	return sessions, nil
+}
+
+func (m *Memory) RevokeSessionById(ctx context.Context, arg database.RevokeSessionByIdParams) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var revoked int64
+	for i := range m.refreshTokens {
+		t := &m.refreshTokens[i]
+		if t.ID == arg.ID && t.UserID == arg.UserID && !t.RevokedAt.Valid {
+			t.UpdatedAt = arg.UpdatedAt
+			t.RevokedAt = arg.RevokedAt
+			revoked++
+		}
+	}
+	return revoked, nil
+}
+
+func (m *Memory) RevokeAllSessionsByUser(ctx context.Context, arg database.RevokeAllSessionsByUserParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i := range m.refreshTokens {
+		t := &m.refreshTokens[i]
+		if t.UserID == arg.UserID && !t.RevokedAt.Valid {
+			t.UpdatedAt = arg.UpdatedAt
+			t.RevokedAt = arg.RevokedAt
+		}
+	}
+	return nil
+}
+
+func (m *Memory) ResetRefreshTokens(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.refreshTokens = nil
+	return nil
+}
+
+func (m *Memory) CreateRecoveryCode(ctx context.Context, arg database.CreateRecoveryCodeParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.userExists(arg.UserID); err != nil {
+		return err
+	}
+	if slices.ContainsFunc(m.recoveryCodes, func(c database.RecoveryCode) bool { return c.CodeHash == arg.CodeHash }) {
+		return duplicate("recovery_codes", "code_hash")
+	}
+	m.recoveryCodes = append(m.recoveryCodes, database.RecoveryCode{
+		CodeHash:  arg.CodeHash,
+		CreatedAt: arg.CreatedAt,
+		UserID:    arg.UserID,
+	})
+	return nil
+}
+
+func (m *Memory) UseRecoveryCode(ctx context.Context, arg database.UseRecoveryCodeParams) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var used int64
+	for i := range m.recoveryCodes {
+		c := &m.recoveryCodes[i]
+		if c.CodeHash == arg.CodeHash && c.UserID == arg.UserID && !c.UsedAt.Valid {
+			c.UsedAt = arg.UsedAt
+			used++
+		}
+	}
+	return used, nil
+}
+
+func (m *Memory) DeleteRecoveryCodesByUser(ctx context.Context, userID uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.recoveryCodes = slices.DeleteFunc(m.recoveryCodes, func(c database.RecoveryCode) bool { return c.UserID == userID })
+	return nil
+}
+
+func (m *Memory) ResetRecoveryCodes(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.recoveryCodes = nil
+	return nil
+}
+
+func (m *Memory) CreateTwoFactorChallenge(ctx context.Context, arg database.CreateTwoFactorChallengeParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.userExists(arg.UserID); err != nil {
+		return err
+	}
+	if slices.ContainsFunc(m.challenges, func(c database.TwoFactorChallenge) bool { return c.ID == arg.ID }) {
+		return duplicate("two_factor_challenges", "id")
+	}
+	m.challenges = append(m.challenges, database.TwoFactorChallenge{
+		ID:        arg.ID,
+		UserID:    arg.UserID,
+		CreatedAt: arg.CreatedAt,
+		ExpiresAt: arg.ExpiresAt,
+	})
+	return nil
+}
+
+func (m *Memory) AttemptTwoFactorChallenge(ctx context.Context, arg database.AttemptTwoFactorChallengeParams) (database.TwoFactorChallenge, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	i := slices.IndexFunc(m.challenges, func(c database.TwoFactorChallenge) bool {
+		return c.ID == arg.ID && c.ExpiresAt.After(arg.Now)
+	})
+	if i < 0 {
+		return database.TwoFactorChallenge{}, sql.ErrNoRows
+	}
+	m.challenges[i].Attempts++
+	return m.challenges[i], nil
+}
+
+func (m *Memory) DeleteTwoFactorChallenge(ctx context.Context, id uuid.UUID) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	before := len(m.challenges)
+	m.challenges = slices.DeleteFunc(m.challenges, func(c database.TwoFactorChallenge) bool { return c.ID == id })
+	return int64(before - len(m.challenges)), nil
+}
+
+func (m *Memory) ResetTwoFactorChallenges(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.challenges = nil
+	return nil
+}
+
+func (m *Memory) CreateAPIToken(ctx context.Context, arg database.CreateAPITokenParams) (database.ApiToken, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.userExists(arg.UserID); err != nil {
+		return database.ApiToken{}, err
+	}
+	for _, t := range m.apiTokens {
+		if t.ID == arg.ID {
+			return database.ApiToken{}, duplicate("api_tokens", "id")
+		}
+		if t.TokenHash == arg.TokenHash {
+			return database.ApiToken{}, duplicate("api_tokens", "token_hash")
+		}
+	}
+	token := database.ApiToken{
+		ID:        arg.ID,
+		CreatedAt: arg.CreatedAt,
+		UpdatedAt: arg.UpdatedAt,
+		UserID:    arg.UserID,
+		Name:      arg.Name,
+		TokenHash: arg.TokenHash,
+		Scopes:    arg.Scopes,
+		ExpiresAt: arg.ExpiresAt,
+	}
+	m.apiTokens = append(m.apiTokens, token)
+	return token, nil
+}
+
+func (m *Memory) GetAPITokenByHash(ctx context.Context, tokenHash string) (database.ApiToken, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	i := slices.IndexFunc(m.apiTokens, func(t database.ApiToken) bool { return t.TokenHash == tokenHash })
+	if i < 0 {
+		return database.ApiToken{}, sql.ErrNoRows
+	}
+	return m.apiTokens[i], nil
+}
+
+func (m *Memory) GetAPITokensByUser(ctx context.Context, userID uuid.UUID) ([]database.ApiToken, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tokens := []database.ApiToken{}
+	for _, t := range m.apiTokens {
+		if t.UserID == userID && !t.RevokedAt.Valid {
+			tokens = append(tokens, t)
+		}
+	}
+	slices.SortStableFunc(tokens, func(a, b database.ApiToken) int {
+		return b.CreatedAt.Compare(a.CreatedAt)
+	})
+	return tokens, nil
+}
+
+func (m *Memory) TouchAPIToken(ctx context.Context, arg database.TouchAPITokenParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i := range m.apiTokens {
+		if m.apiTokens[i].ID == arg.ID {
+			m.apiTokens[i].LastUsedAt = arg.LastUsedAt
+		}
+	}
+	return nil
+}
+
+func (m *Memory) RevokeAPIToken(ctx context.Context, arg database.RevokeAPITokenParams) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var revoked int64
+	for i := range m.apiTokens {
+		t := &m.apiTokens[i]
+		if t.ID == arg.ID && t.UserID == arg.UserID && !t.RevokedAt.Valid {
+			t.UpdatedAt = arg.UpdatedAt
+			t.RevokedAt = arg.RevokedAt
+			revoked++
+		}
+	}
+	return revoked, nil
+}
+
+func (m *Memory) ResetAPITokens(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.apiTokens = nil
+	return nil
+}
+
+func (m *Memory) CreateOAuthClient(ctx context.Context, arg database.CreateOAuthClientParams) (database.OauthClient, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.userExists(arg.UserID); err != nil {
+		return database.OauthClient{}, err
+	}
+	if slices.ContainsFunc(m.oauthClients, func(c database.OauthClient) bool { return c.ID == arg.ID }) {
+		return database.OauthClient{}, duplicate("oauth_clients", "id")
+	}
+	client := database.OauthClient{
+		ID:         arg.ID,
+		CreatedAt:  arg.CreatedAt,
+		UpdatedAt:  arg.UpdatedAt,
+		UserID:     arg.UserID,
+		Name:       arg.Name,
+		SecretHash: arg.SecretHash,
+	}
+	m.oauthClients = append(m.oauthClients, client)
+	return client, nil
+}
+
+func (m *Memory) GetOAuthClient(ctx context.Context, id uuid.UUID) (database.OauthClient, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	i := slices.IndexFunc(m.oauthClients, func(c database.OauthClient) bool { return c.ID == id })
+	if i < 0 {
+		return database.OauthClient{}, sql.ErrNoRows
+	}
+	return m.oauthClients[i], nil
+}
+
+func (m *Memory) CreateOAuthRedirectURI(ctx context.Context, arg database.CreateOAuthRedirectURIParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !slices.ContainsFunc(m.oauthClients, func(c database.OauthClient) bool { return c.ID == arg.ClientID }) {
+		return fmt.Errorf("store: OAuth client %s does not exist", arg.ClientID)
+	}
+	if slices.Contains(m.redirectURIs, database.OauthRedirectUri(arg)) {
+		return duplicate("oauth_redirect_uris", "client_id, redirect_uri")
+	}
+	m.redirectURIs = append(m.redirectURIs, database.OauthRedirectUri(arg))
+	return nil
+}
+
+func (m *Memory) GetOAuthRedirectURIs(ctx context.Context, clientID uuid.UUID) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	uris := []string{}
+	for _, u := range m.redirectURIs {
+		if u.ClientID == clientID {
+			uris = append(uris, u.RedirectUri)
+		}
+	}
+	slices.Sort(uris)
+	return uris, nil
+}
+
+func (m *Memory) CreateOAuthCode(ctx context.Context, arg database.CreateOAuthCodeParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.userExists(arg.UserID); err != nil {
+		return err
+	}
+	if !slices.ContainsFunc(m.oauthClients, func(c database.OauthClient) bool { return c.ID == arg.ClientID }) {
+		return fmt.Errorf("store: OAuth client %s does not exist", arg.ClientID)
+	}
+	if slices.ContainsFunc(m.oauthCodes, func(c database.OauthCode) bool { return c.CodeHash == arg.CodeHash }) {
+		return duplicate("oauth_codes", "code_hash")
+	}
+	m.oauthCodes = append(m.oauthCodes, database.OauthCode{
+		CodeHash:         arg.CodeHash,
+		CreatedAt:        arg.CreatedAt,
+		ClientID:         arg.ClientID,
+		UserID:           arg.UserID,
+		RedirectUri:      arg.RedirectUri,
+		Scopes:           arg.Scopes,
+		CodeChallenge:    arg.CodeChallenge,
+		ExpiresAt:        arg.ExpiresAt,
+		RedirectUriGiven: arg.RedirectUriGiven,
+	})
+	return nil
+}
+
+func (m *Memory) ConsumeOAuthCode(ctx context.Context, arg database.ConsumeOAuthCodeParams) (database.OauthCode, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	i := slices.IndexFunc(m.oauthCodes, func(c database.OauthCode) bool {
+		return c.CodeHash == arg.CodeHash && c.ClientID == arg.ClientID && !c.UsedAt.Valid
+	})
+	if i < 0 {
+		return database.OauthCode{}, sql.ErrNoRows
+	}
+	m.oauthCodes[i].UsedAt = arg.UsedAt
+	return m.oauthCodes[i], nil
+}
+
+func (m *Memory) ResetOAuthCodes(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.oauthCodes = nil
+	return nil
+}
+
+func (m *Memory) ResetOAuthClients(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.oauthClients = nil
+	m.redirectURIs = nil
+	m.oauthCodes = nil
+	m.refreshTokens = slices.DeleteFunc(m.refreshTokens, func(t database.RefreshToken) bool { return t.ClientID.Valid })
+	return nil
+}
+
+func (m *Memory) CreateOIDCLogin(ctx context.Context, arg database.CreateOIDCLoginParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if slices.ContainsFunc(m.oidcLogins, func(l database.OidcLogin) bool { return l.StateHash == arg.StateHash }) {
+		return duplicate("oidc_logins", "state_hash")
+	}
+	m.oidcLogins = append(m.oidcLogins, database.OidcLogin{
+		StateHash:    arg.StateHash,
+		CreatedAt:    arg.CreatedAt,
+		Nonce:        arg.Nonce,
+		CodeVerifier: arg.CodeVerifier,
+		ExpiresAt:    arg.ExpiresAt,
+	})
+	return nil
+}
+
+func (m *Memory) ConsumeOIDCLogin(ctx context.Context, stateHash string) (database.OidcLogin, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	i := slices.IndexFunc(m.oidcLogins, func(l database.OidcLogin) bool { return l.StateHash == stateHash })
+	if i < 0 {
+		return database.OidcLogin{}, sql.ErrNoRows
+	}
+	login := m.oidcLogins[i]
+	m.oidcLogins = slices.Delete(m.oidcLogins, i, i+1)
+	return login, nil
+}
+
+func (m *Memory) CreateUserIdentity(ctx context.Context, arg database.CreateUserIdentityParams) (database.UserIdentity, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.userExists(arg.UserID); err != nil {
+		return database.UserIdentity{}, err
+	}
+	for _, id := range m.identities {
+		if id.ID == arg.ID {
+			return database.UserIdentity{}, duplicate("user_identities", "id")
+		}
+		if id.Issuer == arg.Issuer && id.Subject == arg.Subject {
+			return database.UserIdentity{}, duplicate("user_identities", "issuer, subject")
+		}
+	}
+	identity := database.UserIdentity{
+		ID:        arg.ID,
+		CreatedAt: arg.CreatedAt,
+		UpdatedAt: arg.UpdatedAt,
+		UserID:    arg.UserID,
+		Issuer:    arg.Issuer,
+		Subject:   arg.Subject,
+		Email:     arg.Email,
+	}
+	m.identities = append(m.identities, identity)
+	return identity, nil
+}
+
+func (m *Memory) GetUserIdentity(ctx context.Context, arg database.GetUserIdentityParams) (database.UserIdentity, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	i := slices.IndexFunc(m.identities, func(id database.UserIdentity) bool {
+		return id.Issuer == arg.Issuer && id.Subject == arg.Subject
+	})
+	if i < 0 {
+		return database.UserIdentity{}, sql.ErrNoRows
+	}
+	return m.identities[i], nil
+}
+
+func (m *Memory) ResetUserIdentities(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.identities = nil
+	return nil
+}
+
+func (m *Memory) ResetOIDCLogins(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.oidcLogins = nil
+	return nil
+}
+
+func (m *Memory) CreateOIDCLink(ctx context.Context, arg database.CreateOIDCLinkParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.userExists(arg.UserID); err != nil {
+		return err
+	}
+	if slices.ContainsFunc(m.oidcLinks, func(l database.OidcLink) bool { return l.TokenHash == arg.TokenHash }) {
+		return duplicate("oidc_links", "token_hash")
+	}
+	m.oidcLinks = append(m.oidcLinks, database.OidcLink(arg))
+	return nil
+}
+
+func (m *Memory) GetOIDCLink(ctx context.Context, tokenHash string) (database.OidcLink, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	i := slices.IndexFunc(m.oidcLinks, func(l database.OidcLink) bool { return l.TokenHash == tokenHash })
+	if i < 0 {
+		return database.OidcLink{}, sql.ErrNoRows
+	}
+	return m.oidcLinks[i], nil
+}
+
+func (m *Memory) DeleteOIDCLink(ctx context.Context, tokenHash string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	before := len(m.oidcLinks)
+	m.oidcLinks = slices.DeleteFunc(m.oidcLinks, func(l database.OidcLink) bool { return l.TokenHash == tokenHash })
+	return int64(before - len(m.oidcLinks)), nil
+}
+
+func (m *Memory) ResetOIDCLinks(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.oidcLinks = nil
+	return nil
+}
+
+func (m *Memory) CountUsers(ctx context.Context) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return int64(len(m.users)), nil
+}
+
+func (m *Memory) CountChirpyRed(ctx context.Context) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var n int64
+	for _, u := range m.users {
+		if u.IsChirpyRed {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (m *Memory) CountChirps(ctx context.Context) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return int64(len(m.chirps)), nil
+}
+
+// perDay counts the times at or after since by UTC day, oldest first.
+func perDay(times []time.Time, since time.Time) ([]string, map[string]int64) {
+	counts := map[string]int64{}
+	for _, t := range times {
+		if !t.Before(since) {
+			counts[t.UTC().Format(time.DateOnly)]++
+		}
+	}
+	days := make([]string, 0, len(counts))
+	for day := range counts {
+		days = append(days, day)
+	}
+	slices.Sort(days)
+	return days, counts
+}
+
+func (m *Memory) SignupsPerDay(ctx context.Context, createdAt time.Time) ([]database.SignupsPerDayRow, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	times := make([]time.Time, 0, len(m.users))
+	for _, u := range m.users {
+		times = append(times, u.CreatedAt)
+	}
+	days, counts := perDay(times, createdAt)
+	rows := make([]database.SignupsPerDayRow, 0, len(days))
+	for _, day := range days {
+		rows = append(rows, database.SignupsPerDayRow{Day: day, Count: counts[day]})
+	}
+	return rows, nil
+}
+
+func (m *Memory) ChirpsPerDay(ctx context.Context, createdAt time.Time) ([]database.ChirpsPerDayRow, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	times := make([]time.Time, 0, len(m.chirps))
+	for _, c := range m.chirps {
+		times = append(times, c.CreatedAt)
+	}
+	days, counts := perDay(times, createdAt)
+	rows := make([]database.ChirpsPerDayRow, 0, len(days))
+	for _, day := range days {
+		rows = append(rows, database.ChirpsPerDayRow{Day: day, Count: counts[day]})
+	}
+	return rows, nil
+}
+
+func (m *Memory) TopPosters(ctx context.Context, limit int32) ([]database.TopPostersRow, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	counts := map[uuid.UUID]int64{}
+	for _, c := range m.chirps {
+		counts[c.UserID]++
+	}
+	rows := []database.TopPostersRow{}
+	for _, u := range m.users {
+		if counts[u.ID] > 0 {
+			rows = append(rows, database.TopPostersRow{ID: u.ID, Email: u.Email, ChirpCount: counts[u.ID]})
+		}
+	}
+	slices.SortFunc(rows, func(a, b database.TopPostersRow) int {
+		if c := cmp.Compare(b.ChirpCount, a.ChirpCount); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Email, b.Email)
+	})
+	if len(rows) > int(limit) {
+		rows = rows[:limit]
+	}
+	return rows, nil
+}
@@ -0,0 +1,96 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	_ "github.com/lib/pq"
+)
+
+// Open picks a store from the scheme of dbURL:
+//
+//	postgres://... or postgresql://...  PostgreSQL
+//	sqlite://path or file:path          SQLite, created if missing
+//	memory                              Memory, lost on exit
+//
+// The returned *sql.DB is nil for the in-memory store, which makes no
+// queries for hooks to see.
+func Open(dbURL string, hooks ...QueryHook) (Store, *sql.DB, error) {
+	switch {
+	case dbURL == "memory":
+		return NewMemory(), nil, nil
+	case strings.HasPrefix(dbURL, "postgres://"), strings.HasPrefix(dbURL, "postgresql://"):
+		db, err := sql.Open("postgres", dbURL)
+		if err != nil {
+			return nil, nil, err
+		}
+		return NewPostgres(db, hooks...), db, nil
+	case strings.HasPrefix(dbURL, "sqlite:"), strings.HasPrefix(dbURL, "file:"):
+		// sqlite:///var/lib/chirpy.db is an absolute path, sqlite://chirpy.db
+		// a relative one. file: URIs are understood by the driver itself.
+		dsn := strings.TrimPrefix(strings.TrimPrefix(dbURL, "sqlite:"), "//")
+		if dsn == "" {
+			return nil, nil, fmt.Errorf("store: no path in %q", dbURL)
+		}
+		db, err := OpenSQLite(dsn)
+		if err != nil {
+			return nil, nil, err
+		}
+		return NewSQLite(db, hooks...), db, nil
+	default:
+		// The URL is left out of the error as it may hold a password.
+		return nil, nil, errors.New("store: DB_URL is not a postgres://, sqlite:// or file: URL, or memory")
+	}
+}
+
+// Pool limits the connections kept open to the database. Zero fields leave
+// the database/sql defaults alone.
+type Pool struct {
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+}
+
+func (p Pool) Apply(db *sql.DB) {
+	if p.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(p.MaxOpenConns)
+	}
+	if p.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(p.MaxIdleConns)
+	}
+	if p.ConnMaxLifetime > 0 {
+		db.SetConnMaxLifetime(p.ConnMaxLifetime)
+	}
+	if p.ConnMaxIdleTime > 0 {
+		db.SetConnMaxIdleTime(p.ConnMaxIdleTime)
+	}
+}
+
+const (
+	pingFirstDelay = 100 * time.Millisecond
+	pingMaxDelay   = 5 * time.Second
+)
+
+// Ping waits for db to answer, retrying with exponential backoff until ctx
+// is done. sql.Open never connects, so without this a bad DB_URL only shows
+// up on the first request.
+func Ping(ctx context.Context, db *sql.DB) error {
+	delay := pingFirstDelay
+	for attempt := 1; ; attempt++ {
+		err := db.PingContext(ctx)
+		if err == nil {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("store: database unreachable after %d attempts: %w", attempt, err)
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, pingMaxDelay)
+	}
+}
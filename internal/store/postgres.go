@@ -0,0 +1,48 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/Senaphim/Chirpy/internal/database"
+)
+
+// Postgres is a Store backed by the sqlc queries generated from the schema,
+// which it runs as they are.
+type Postgres struct {
+	*database.Queries
+	// db is nil for a store within a transaction.
+	db    *sql.DB
+	hooks []QueryHook
+}
+
+var _ Store = (*Postgres)(nil)
+
+func NewPostgres(db *sql.DB, hooks ...QueryHook) *Postgres {
+	return &Postgres{Queries: database.New(withHooks(db, hooks)), db: db, hooks: hooks}
+}
+
+// InTx runs fn within a transaction. Called on a store that is already
+// within one, fn simply joins it.
+func (p *Postgres) InTx(ctx context.Context, fn func(Store) error) error {
+	if p.db == nil {
+		return fn(p)
+	}
+	return inTx(ctx, p.db, func(tx *sql.Tx) error {
+		return fn(&Postgres{Queries: database.New(withHooks(tx, p.hooks)), hooks: p.hooks})
+	})
+}
+
+// inTx runs fn within a transaction on db, committing if it returns nil.
+func inTx(ctx context.Context, db *sql.DB, fn func(*sql.Tx) error) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		// The rollback's own error matters less than why it was needed.
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,61 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"slices"
+)
+
+// Tables are the tables Reset can empty, in the order it empties them, so
+// rows go before the rows they refer to.
+var Tables = []string{
+	"oauth_codes",
+	"oauth_clients",
+	"refresh_tokens",
+	"recovery_codes",
+	"two_factor_challenges",
+	"api_tokens",
+	"user_identities",
+	"oidc_logins",
+	"oidc_links",
+	"reports",
+	"chirps",
+	"users",
+}
+
+// Reset empties the given tables, or every table when none are given.
+// Emptying users also removes everything that belongs to a user, emptying
+// chirps the reports about them, and emptying oauth_clients the refresh
+// tokens issued to them. Run it within InTx for the tables to be emptied
+// together or not at all.
+func Reset(ctx context.Context, st Store, tables []string) error {
+	for _, table := range tables {
+		if !slices.Contains(Tables, table) {
+			return fmt.Errorf("store: no table %q to reset", table)
+		}
+	}
+
+	resets := map[string]func(context.Context) error{
+		"oauth_codes":           st.ResetOAuthCodes,
+		"oauth_clients":         st.ResetOAuthClients,
+		"refresh_tokens":        st.ResetRefreshTokens,
+		"recovery_codes":        st.ResetRecoveryCodes,
+		"two_factor_challenges": st.ResetTwoFactorChallenges,
+		"api_tokens":            st.ResetAPITokens,
+		"user_identities":       st.ResetUserIdentities,
+		"oidc_logins":           st.ResetOIDCLogins,
+		"oidc_links":            st.ResetOIDCLinks,
+		"reports":               st.ResetReports,
+		"chirps":                st.ResetChirps,
+		"users":                 st.DeleteAll,
+	}
+	for _, table := range Tables {
+		if len(tables) > 0 && !slices.Contains(tables, table) {
+			continue
+		}
+		if err := resets[table](ctx); err != nil {
+			return fmt.Errorf("store: resetting %s: %w", table, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,428 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Senaphim/Chirpy/internal/database"
+	"github.com/Senaphim/Chirpy/internal/database/sqlite"
+	"github.com/google/uuid"
+	driver "modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// sqliteParams are added to every connection. SQLite leaves foreign keys off
+// unless asked, and without a busy timeout concurrent writers fail at once
+// instead of waiting for the lock. Times are written in a format that sorts
+// as text. Transactions take the write lock as they begin, rather than on
+// their first write, which is what keeps concurrent migrations apart.
+var sqliteParams = []string{
+	"_pragma=foreign_keys(1)",
+	"_pragma=busy_timeout(5000)",
+	"_pragma=journal_mode(WAL)",
+	"_time_format=sqlite",
+	"_txlock=immediate",
+}
+
+// OpenSQLite opens the SQLite database at dsn, a file path optionally
+// followed by driver parameters, with the settings the store relies on.
+func OpenSQLite(dsn string) (*sql.DB, error) {
+	sep := "?"
+	if strings.Contains(dsn, "?") {
+		sep = "&"
+	}
+	for _, param := range sqliteParams {
+		dsn += sep + param
+		sep = "&"
+	}
+	return sql.Open("sqlite", dsn)
+}
+
+// utcDB passes every time to SQLite in UTC. SQLite keeps times as text, so
+// times with different offsets would not compare or sort correctly.
+type utcDB struct {
+	sqlite.DBTX
+}
+
+func utcArgs(args []any) []any {
+	for i, arg := range args {
+		switch v := arg.(type) {
+		case time.Time:
+			args[i] = v.UTC()
+		case sql.NullTime:
+			args[i] = sql.NullTime{Time: v.Time.UTC(), Valid: v.Valid}
+		}
+	}
+	return args
+}
+
+func (db utcDB) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return db.DBTX.ExecContext(ctx, query, utcArgs(args)...)
+}
+
+func (db utcDB) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	return db.DBTX.QueryContext(ctx, query, utcArgs(args)...)
+}
+
+func (db utcDB) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	return db.DBTX.QueryRowContext(ctx, query, utcArgs(args)...)
+}
+
+// SQLite is a Store backed by the sqlc queries generated from the SQLite
+// port of the schema. The generated types have the same shape as the
+// Postgres ones, so results are converted rather than copied field by field.
+type SQLite struct {
+	q *sqlite.Queries
+	// db is nil for a store within a transaction.
+	db    *sql.DB
+	hooks []QueryHook
+}
+
+var _ Store = (*SQLite)(nil)
+
+func NewSQLite(db *sql.DB, hooks ...QueryHook) *SQLite {
+	return &SQLite{q: sqlite.New(utcDB{withHooks(db, hooks)}), db: db, hooks: hooks}
+}
+
+// InTx runs fn within a transaction. Called on a store that is already
+// within one, fn simply joins it.
+func (s *SQLite) InTx(ctx context.Context, fn func(Store) error) error {
+	if s.db == nil {
+		return fn(s)
+	}
+	return inTx(ctx, s.db, func(tx *sql.Tx) error {
+		return fn(&SQLite{q: sqlite.New(utcDB{withHooks(tx, s.hooks)}), hooks: s.hooks})
+	})
+}
+
+// sqliteError turns SQLite unique constraint failures into ErrDuplicate.
+func sqliteError(err error) error {
+	var sqliteErr *driver.Error
+	if errors.As(err, &sqliteErr) {
+		switch sqliteErr.Code() {
+		case sqlite3.SQLITE_CONSTRAINT_UNIQUE, sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY:
+			return fmt.Errorf("%w: %w", ErrDuplicate, err)
+		}
+	}
+	return err
+}
+
+func convertAll[T, U any](in []T, convert func(T) U) []U {
+	out := make([]U, 0, len(in))
+	for _, v := range in {
+		out = append(out, convert(v))
+	}
+	return out
+}
+
+func (s *SQLite) CreateUser(ctx context.Context, arg database.CreateUserParams) (database.User, error) {
+	user, err := s.q.CreateUser(ctx, sqlite.CreateUserParams(arg))
+	return database.User(user), sqliteError(err)
+}
+
+func (s *SQLite) GetUserByEmail(ctx context.Context, email string) (database.User, error) {
+	user, err := s.q.GetUserByEmail(ctx, email)
+	return database.User(user), err
+}
+
+func (s *SQLite) GetUserById(ctx context.Context, id uuid.UUID) (database.User, error) {
+	user, err := s.q.GetUserById(ctx, id)
+	return database.User(user), err
+}
+
+func (s *SQLite) UpdateUsrEmailPwd(ctx context.Context, arg database.UpdateUsrEmailPwdParams) (database.User, error) {
+	user, err := s.q.UpdateUsrEmailPwd(ctx, sqlite.UpdateUsrEmailPwdParams(arg))
+	return database.User(user), sqliteError(err)
+}
+
+func (s *SQLite) UpdateUsrPassword(ctx context.Context, arg database.UpdateUsrPasswordParams) error {
+	return s.q.UpdateUsrPassword(ctx, sqlite.UpdateUsrPasswordParams(arg))
+}
+
+func (s *SQLite) UpdateUsrChirpyRed(ctx context.Context, arg database.UpdateUsrChirpyRedParams) (database.User, error) {
+	user, err := s.q.UpdateUsrChirpyRed(ctx, sqlite.UpdateUsrChirpyRedParams(arg))
+	return database.User(user), err
+}
+
+func (s *SQLite) SetUsrTotpSecret(ctx context.Context, arg database.SetUsrTotpSecretParams) (database.User, error) {
+	user, err := s.q.SetUsrTotpSecret(ctx, sqlite.SetUsrTotpSecretParams(arg))
+	return database.User(user), err
+}
+
+func (s *SQLite) EnableUsrTotp(ctx context.Context, arg database.EnableUsrTotpParams) (database.User, error) {
+	user, err := s.q.EnableUsrTotp(ctx, sqlite.EnableUsrTotpParams(arg))
+	return database.User(user), err
+}
+
+func (s *SQLite) DisableUsrTotp(ctx context.Context, arg database.DisableUsrTotpParams) (database.User, error) {
+	user, err := s.q.DisableUsrTotp(ctx, sqlite.DisableUsrTotpParams(arg))
+	return database.User(user), err
+}
+
+func (s *SQLite) UpdateUsrAdmin(ctx context.Context, arg database.UpdateUsrAdminParams) (database.User, error) {
+	user, err := s.q.UpdateUsrAdmin(ctx, sqlite.UpdateUsrAdminParams(arg))
+	return database.User(user), err
+}
+
+func (s *SQLite) AcceptUsrTotpStep(ctx context.Context, arg database.AcceptUsrTotpStepParams) (int64, error) {
+	return s.q.AcceptUsrTotpStep(ctx, sqlite.AcceptUsrTotpStepParams(arg))
+}
+
+func (s *SQLite) RecordUsrTotpFailure(ctx context.Context, arg database.RecordUsrTotpFailureParams) (int64, error) {
+	return s.q.RecordUsrTotpFailure(ctx, sqlite.RecordUsrTotpFailureParams(arg))
+}
+
+func (s *SQLite) DeleteAll(ctx context.Context) error {
+	return s.q.DeleteAll(ctx)
+}
+
+func (s *SQLite) CreateChirp(ctx context.Context, arg database.CreateChirpParams) (database.Chirp, error) {
+	chirp, err := s.q.CreateChirp(ctx, sqlite.CreateChirpParams(arg))
+	return database.Chirp(chirp), sqliteError(err)
+}
+
+func (s *SQLite) AllChirps(ctx context.Context) ([]database.Chirp, error) {
+	chirps, err := s.q.AllChirps(ctx)
+	return convertAll(chirps, func(c sqlite.Chirp) database.Chirp { return database.Chirp(c) }), err
+}
+
+func (s *SQLite) GetChirpsByAuthor(ctx context.Context, userID uuid.UUID) ([]database.Chirp, error) {
+	chirps, err := s.q.GetChirpsByAuthor(ctx, userID)
+	return convertAll(chirps, func(c sqlite.Chirp) database.Chirp { return database.Chirp(c) }), err
+}
+
+func (s *SQLite) GetChirpById(ctx context.Context, id uuid.UUID) (database.Chirp, error) {
+	chirp, err := s.q.GetChirpById(ctx, id)
+	return database.Chirp(chirp), err
+}
+
+func (s *SQLite) DeleteChirpById(ctx context.Context, id uuid.UUID) error {
+	return s.q.DeleteChirpById(ctx, id)
+}
+
+func (s *SQLite) ResetChirps(ctx context.Context) error {
+	return s.q.ResetChirps(ctx)
+}
+
+func (s *SQLite) CreateRefreshToken(ctx context.Context, arg database.CreateRefreshTokenParams) (database.RefreshToken, error) {
+	token, err := s.q.CreateRefreshToken(ctx, sqlite.CreateRefreshTokenParams(arg))
+	return database.RefreshToken(token), sqliteError(err)
+}
+
+func (s *SQLite) GetRefreshToken(ctx context.Context, tokenHash string) (database.RefreshToken, error) {
+	token, err := s.q.GetRefreshToken(ctx, tokenHash)
+	return database.RefreshToken(token), err
+}
+
+func (s *SQLite) TouchRefreshToken(ctx context.Context, arg database.TouchRefreshTokenParams) error {
+	return s.q.TouchRefreshToken(ctx, sqlite.TouchRefreshTokenParams(arg))
+}
+
+func (s *SQLite) RevokeRefreshToken(ctx context.Context, arg database.RevokeRefreshTokenParams) (int64, error) {
+	return s.q.RevokeRefreshToken(ctx, sqlite.RevokeRefreshTokenParams(arg))
+}
+
+func (s *SQLite) RevokeRefreshTokenFamily(ctx context.Context, arg database.RevokeRefreshTokenFamilyParams) error {
+	return s.q.RevokeRefreshTokenFamily(ctx, sqlite.RevokeRefreshTokenFamilyParams(arg))
+}
+
+func (s *SQLite) GetActiveSessionsByUser(ctx context.Context, arg database.GetActiveSessionsByUserParams) ([]database.RefreshToken, error) {
+	tokens, err := s.q.GetActiveSessionsByUser(ctx, sqlite.GetActiveSessionsByUserParams(arg))
+	return convertAll(tokens, func(t sqlite.RefreshToken) database.RefreshToken { return database.RefreshToken(t) }), err
+}
+
+func (s *SQLite) RevokeSessionById(ctx context.Context, arg database.RevokeSessionByIdParams) (int64, error) {
+	return s.q.RevokeSessionById(ctx, sqlite.RevokeSessionByIdParams(arg))
+}
+
+func (s *SQLite) RevokeAllSessionsByUser(ctx context.Context, arg database.RevokeAllSessionsByUserParams) error {
+	return s.q.RevokeAllSessionsByUser(ctx, sqlite.RevokeAllSessionsByUserParams(arg))
+}
+
+func (s *SQLite) ResetRefreshTokens(ctx context.Context) error {
+	return s.q.ResetRefreshTokens(ctx)
+}
+
+func (s *SQLite) CreateRecoveryCode(ctx context.Context, arg database.CreateRecoveryCodeParams) error {
+	return sqliteError(s.q.CreateRecoveryCode(ctx, sqlite.CreateRecoveryCodeParams(arg)))
+}
+
+func (s *SQLite) UseRecoveryCode(ctx context.Context, arg database.UseRecoveryCodeParams) (int64, error) {
+	return s.q.UseRecoveryCode(ctx, sqlite.UseRecoveryCodeParams(arg))
+}
+
+func (s *SQLite) DeleteRecoveryCodesByUser(ctx context.Context, userID uuid.UUID) error {
+	return s.q.DeleteRecoveryCodesByUser(ctx, userID)
+}
+
+func (s *SQLite) ResetRecoveryCodes(ctx context.Context) error {
+	return s.q.ResetRecoveryCodes(ctx)
+}
+
+func (s *SQLite) CreateReport(ctx context.Context, arg database.CreateReportParams) (database.Report, error) {
+	report, err := s.q.CreateReport(ctx, sqlite.CreateReportParams(arg))
+	return database.Report(report), sqliteError(err)
+}
+
+func (s *SQLite) RecentReports(ctx context.Context, limit int32) ([]database.RecentReportsRow, error) {
+	rows, err := s.q.RecentReports(ctx, int64(limit))
+	return convertAll(rows, func(r sqlite.RecentReportsRow) database.RecentReportsRow { return database.RecentReportsRow(r) }), err
+}
+
+func (s *SQLite) ResetReports(ctx context.Context) error {
+	return s.q.ResetReports(ctx)
+}
+
+func (s *SQLite) CreateTwoFactorChallenge(ctx context.Context, arg database.CreateTwoFactorChallengeParams) error {
+	return sqliteError(s.q.CreateTwoFactorChallenge(ctx, sqlite.CreateTwoFactorChallengeParams(arg)))
+}
+
+func (s *SQLite) AttemptTwoFactorChallenge(ctx context.Context, arg database.AttemptTwoFactorChallengeParams) (database.TwoFactorChallenge, error) {
+	challenge, err := s.q.AttemptTwoFactorChallenge(ctx, sqlite.AttemptTwoFactorChallengeParams(arg))
+	return database.TwoFactorChallenge(challenge), err
+}
+
+func (s *SQLite) DeleteTwoFactorChallenge(ctx context.Context, id uuid.UUID) (int64, error) {
+	return s.q.DeleteTwoFactorChallenge(ctx, id)
+}
+
+func (s *SQLite) ResetTwoFactorChallenges(ctx context.Context) error {
+	return s.q.ResetTwoFactorChallenges(ctx)
+}
+
+func (s *SQLite) CreateAPIToken(ctx context.Context, arg database.CreateAPITokenParams) (database.ApiToken, error) {
+	token, err := s.q.CreateAPIToken(ctx, sqlite.CreateAPITokenParams(arg))
+	return database.ApiToken(token), sqliteError(err)
+}
+
+func (s *SQLite) GetAPITokenByHash(ctx context.Context, tokenHash string) (database.ApiToken, error) {
+	token, err := s.q.GetAPITokenByHash(ctx, tokenHash)
+	return database.ApiToken(token), err
+}
+
+func (s *SQLite) GetAPITokensByUser(ctx context.Context, userID uuid.UUID) ([]database.ApiToken, error) {
+	tokens, err := s.q.GetAPITokensByUser(ctx, userID)
+	return convertAll(tokens, func(t sqlite.ApiToken) database.ApiToken { return database.ApiToken(t) }), err
+}
+
+func (s *SQLite) TouchAPIToken(ctx context.Context, arg database.TouchAPITokenParams) error {
+	return s.q.TouchAPIToken(ctx, sqlite.TouchAPITokenParams(arg))
+}
+
+func (s *SQLite) RevokeAPIToken(ctx context.Context, arg database.RevokeAPITokenParams) (int64, error) {
+	return s.q.RevokeAPIToken(ctx, sqlite.RevokeAPITokenParams(arg))
+}
+
+func (s *SQLite) ResetAPITokens(ctx context.Context) error {
+	return s.q.ResetAPITokens(ctx)
+}
+
+func (s *SQLite) CreateOAuthClient(ctx context.Context, arg database.CreateOAuthClientParams) (database.OauthClient, error) {
+	client, err := s.q.CreateOAuthClient(ctx, sqlite.CreateOAuthClientParams(arg))
+	return database.OauthClient(client), sqliteError(err)
+}
+
+func (s *SQLite) GetOAuthClient(ctx context.Context, id uuid.UUID) (database.OauthClient, error) {
+	client, err := s.q.GetOAuthClient(ctx, id)
+	return database.OauthClient(client), err
+}
+
+func (s *SQLite) CreateOAuthRedirectURI(ctx context.Context, arg database.CreateOAuthRedirectURIParams) error {
+	return sqliteError(s.q.CreateOAuthRedirectURI(ctx, sqlite.CreateOAuthRedirectURIParams(arg)))
+}
+
+func (s *SQLite) GetOAuthRedirectURIs(ctx context.Context, clientID uuid.UUID) ([]string, error) {
+	return s.q.GetOAuthRedirectURIs(ctx, clientID)
+}
+
+func (s *SQLite) CreateOAuthCode(ctx context.Context, arg database.CreateOAuthCodeParams) error {
+	return sqliteError(s.q.CreateOAuthCode(ctx, sqlite.CreateOAuthCodeParams(arg)))
+}
+
+func (s *SQLite) ConsumeOAuthCode(ctx context.Context, arg database.ConsumeOAuthCodeParams) (database.OauthCode, error) {
+	code, err := s.q.ConsumeOAuthCode(ctx, sqlite.ConsumeOAuthCodeParams(arg))
+	return database.OauthCode(code), err
+}
+
+func (s *SQLite) ResetOAuthCodes(ctx context.Context) error {
+	return s.q.ResetOAuthCodes(ctx)
+}
+
+func (s *SQLite) ResetOAuthClients(ctx context.Context) error {
+	return s.q.ResetOAuthClients(ctx)
+}
+
+func (s *SQLite) CreateOIDCLogin(ctx context.Context, arg database.CreateOIDCLoginParams) error {
+	return sqliteError(s.q.CreateOIDCLogin(ctx, sqlite.CreateOIDCLoginParams(arg)))
+}
+
+func (s *SQLite) ConsumeOIDCLogin(ctx context.Context, stateHash string) (database.OidcLogin, error) {
+	login, err := s.q.ConsumeOIDCLogin(ctx, stateHash)
+	return database.OidcLogin(login), err
+}
+
+func (s *SQLite) CreateUserIdentity(ctx context.Context, arg database.CreateUserIdentityParams) (database.UserIdentity, error) {
+	identity, err := s.q.CreateUserIdentity(ctx, sqlite.CreateUserIdentityParams(arg))
+	return database.UserIdentity(identity), sqliteError(err)
+}
+
+func (s *SQLite) GetUserIdentity(ctx context.Context, arg database.GetUserIdentityParams) (database.UserIdentity, error) {
+	identity, err := s.q.GetUserIdentity(ctx, sqlite.GetUserIdentityParams(arg))
+	return database.UserIdentity(identity), err
+}
+
+func (s *SQLite) CreateOIDCLink(ctx context.Context, arg database.CreateOIDCLinkParams) error {
+	return sqliteError(s.q.CreateOIDCLink(ctx, sqlite.CreateOIDCLinkParams(arg)))
+}
+
+func (s *SQLite) GetOIDCLink(ctx context.Context, tokenHash string) (database.OidcLink, error) {
+	link, err := s.q.GetOIDCLink(ctx, tokenHash)
+	return database.OidcLink(link), err
+}
+
+func (s *SQLite) DeleteOIDCLink(ctx context.Context, tokenHash string) (int64, error) {
+	return s.q.DeleteOIDCLink(ctx, tokenHash)
+}
+
+func (s *SQLite) ResetUserIdentities(ctx context.Context) error {
+	return s.q.ResetUserIdentities(ctx)
+}
+
+func (s *SQLite) ResetOIDCLogins(ctx context.Context) error {
+	return s.q.ResetOIDCLogins(ctx)
+}
+
+func (s *SQLite) ResetOIDCLinks(ctx context.Context) error {
+	return s.q.ResetOIDCLinks(ctx)
+}
+
+func (s *SQLite) CountUsers(ctx context.Context) (int64, error) {
+	return s.q.CountUsers(ctx)
+}
+
+func (s *SQLite) CountChirpyRed(ctx context.Context) (int64, error) {
+	return s.q.CountChirpyRed(ctx)
+}
+
+func (s *SQLite) CountChirps(ctx context.Context) (int64, error) {
+	return s.q.CountChirps(ctx)
+}
+
+func (s *SQLite) SignupsPerDay(ctx context.Context, createdAt time.Time) ([]database.SignupsPerDayRow, error) {
+	rows, err := s.q.SignupsPerDay(ctx, createdAt)
+	return convertAll(rows, func(r sqlite.SignupsPerDayRow) database.SignupsPerDayRow { return database.SignupsPerDayRow(r) }), err
+}
+
+func (s *SQLite) ChirpsPerDay(ctx context.Context, createdAt time.Time) ([]database.ChirpsPerDayRow, error) {
+	rows, err := s.q.ChirpsPerDay(ctx, createdAt)
+	return convertAll(rows, func(r sqlite.ChirpsPerDayRow) database.ChirpsPerDayRow { return database.ChirpsPerDayRow(r) }), err
+}
+
+func (s *SQLite) TopPosters(ctx context.Context, limit int32) ([]database.TopPostersRow, error) {
+	rows, err := s.q.TopPosters(ctx, int64(limit))
+	return convertAll(rows, func(r sqlite.TopPostersRow) database.TopPostersRow { return database.TopPostersRow(r) }), err
+}
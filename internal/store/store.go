@@ -0,0 +1,183 @@
+// Package store defines the data access the API needs, so handlers can run
+// against Postgres through the Postgres store, against SQLite through the
+// SQLite store, or against the in-memory Memory store in tests and demos.
+//
+// Implementations follow the sqlc conventions: lookups that find nothing
+// return sql.ErrNoRows, and inserts that break a unique constraint return an
+// error wrapping ErrDuplicate (or, for Postgres, a *pq.Error with code 23505).
+// Open picks the store from a DB_URL.
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Senaphim/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// ErrDuplicate is returned when a write would break a unique constraint.
+var ErrDuplicate = errors.New("store: duplicate key")
+
+// Store is everything the handlers read and write.
+type Store interface {
+	UserStore
+	ChirpStore
+	ReportStore
+	RefreshTokenStore
+	RecoveryCodeStore
+	APITokenStore
+	OAuthStore
+	IdentityStore
+	TwoFactorChallengeStore
+	StatsStore
+	TxStore
+}
+
+// TxStore groups reads and writes into transactions.
+type TxStore interface {
+	// InTx runs fn against a store whose queries all belong to one
+	// transaction. It commits if fn returns nil and rolls back otherwise.
+	InTx(ctx context.Context, fn func(Store) error) error
+}
+
+type UserStore interface {
+	CreateUser(ctx context.Context, arg database.CreateUserParams) (database.User, error)
+	GetUserByEmail(ctx context.Context, email string) (database.User, error)
+	GetUserById(ctx context.Context, id uuid.UUID) (database.User, error)
+	UpdateUsrEmailPwd(ctx context.Context, arg database.UpdateUsrEmailPwdParams) (database.User, error)
+	UpdateUsrPassword(ctx context.Context, arg database.UpdateUsrPasswordParams) error
+	UpdateUsrChirpyRed(ctx context.Context, arg database.UpdateUsrChirpyRedParams) (database.User, error)
+	SetUsrTotpSecret(ctx context.Context, arg database.SetUsrTotpSecretParams) (database.User, error)
+	EnableUsrTotp(ctx context.Context, arg database.EnableUsrTotpParams) (database.User, error)
+	// DisableUsrTotp turns two factor authentication off and forgets the
+	// authenticator's secret.
+	DisableUsrTotp(ctx context.Context, arg database.DisableUsrTotpParams) (database.User, error)
+	// UpdateUsrAdmin finds the user by email, as admins are granted from the
+	// command line.
+	UpdateUsrAdmin(ctx context.Context, arg database.UpdateUsrAdminParams) (database.User, error)
+	// AcceptUsrTotpStep records the TOTP step of a correct code and clears
+	// the failures. It affects no rows when the step was already used, so
+	// each code is only accepted once.
+	AcceptUsrTotpStep(ctx context.Context, arg database.AcceptUsrTotpStepParams) (int64, error)
+	// RecordUsrTotpFailure counts a wrong TOTP code, starting the count
+	// again when the last failure was before WindowStart, and returns the
+	// count.
+	RecordUsrTotpFailure(ctx context.Context, arg database.RecordUsrTotpFailureParams) (int64, error)
+	// DeleteAll removes every user, and with them everything they own.
+	DeleteAll(ctx context.Context) error
+}
+
+type ChirpStore interface {
+	CreateChirp(ctx context.Context, arg database.CreateChirpParams) (database.Chirp, error)
+	// AllChirps is ordered by updated_at, oldest first.
+	AllChirps(ctx context.Context) ([]database.Chirp, error)
+	GetChirpsByAuthor(ctx context.Context, userID uuid.UUID) ([]database.Chirp, error)
+	GetChirpById(ctx context.Context, id uuid.UUID) (database.Chirp, error)
+	DeleteChirpById(ctx context.Context, id uuid.UUID) error
+	ResetChirps(ctx context.Context) error
+}
+
+// RefreshTokenStore holds refresh tokens, which double as login sessions.
+type RefreshTokenStore interface {
+	CreateRefreshToken(ctx context.Context, arg database.CreateRefreshTokenParams) (database.RefreshToken, error)
+	GetRefreshToken(ctx context.Context, tokenHash string) (database.RefreshToken, error)
+	TouchRefreshToken(ctx context.Context, arg database.TouchRefreshTokenParams) error
+	// RevokeRefreshToken affects no rows when the token was already
+	// revoked, so only one caller can rotate a token.
+	RevokeRefreshToken(ctx context.Context, arg database.RevokeRefreshTokenParams) (int64, error)
+	// RevokeRefreshTokenFamily revokes every token rotated from the same
+	// OAuth grant.
+	RevokeRefreshTokenFamily(ctx context.Context, arg database.RevokeRefreshTokenFamilyParams) error
+	// GetActiveSessionsByUser is ordered by last_used_at, most recent first.
+	GetActiveSessionsByUser(ctx context.Context, arg database.GetActiveSessionsByUserParams) ([]database.RefreshToken, error)
+	RevokeSessionById(ctx context.Context, arg database.RevokeSessionByIdParams) (int64, error)
+	RevokeAllSessionsByUser(ctx context.Context, arg database.RevokeAllSessionsByUserParams) error
+	ResetRefreshTokens(ctx context.Context) error
+}
+
+type RecoveryCodeStore interface {
+	CreateRecoveryCode(ctx context.Context, arg database.CreateRecoveryCodeParams) error
+	UseRecoveryCode(ctx context.Context, arg database.UseRecoveryCodeParams) (int64, error)
+	DeleteRecoveryCodesByUser(ctx context.Context, userID uuid.UUID) error
+	ResetRecoveryCodes(ctx context.Context) error
+}
+
+// ReportStore holds the reports users make about chirps. Each user can
+// report a chirp once.
+type ReportStore interface {
+	CreateReport(ctx context.Context, arg database.CreateReportParams) (database.Report, error)
+	// RecentReports is ordered by created_at, newest first.
+	RecentReports(ctx context.Context, limit int32) ([]database.RecentReportsRow, error)
+	ResetReports(ctx context.Context) error
+}
+
+// TwoFactorChallengeStore holds the challenges between the password and
+// second factor steps of a login, so each can only be used once and for a
+// limited number of attempts.
+type TwoFactorChallengeStore interface {
+	CreateTwoFactorChallenge(ctx context.Context, arg database.CreateTwoFactorChallengeParams) error
+	// AttemptTwoFactorChallenge counts an attempt at a challenge that has
+	// not expired by Now and returns it.
+	AttemptTwoFactorChallenge(ctx context.Context, arg database.AttemptTwoFactorChallengeParams) (database.TwoFactorChallenge, error)
+	DeleteTwoFactorChallenge(ctx context.Context, id uuid.UUID) (int64, error)
+	ResetTwoFactorChallenges(ctx context.Context) error
+}
+
+type APITokenStore interface {
+	CreateAPIToken(ctx context.Context, arg database.CreateAPITokenParams) (database.ApiToken, error)
+	GetAPITokenByHash(ctx context.Context, tokenHash string) (database.ApiToken, error)
+	// GetAPITokensByUser skips revoked tokens and is ordered by created_at,
+	// newest first.
+	GetAPITokensByUser(ctx context.Context, userID uuid.UUID) ([]database.ApiToken, error)
+	TouchAPIToken(ctx context.Context, arg database.TouchAPITokenParams) error
+	RevokeAPIToken(ctx context.Context, arg database.RevokeAPITokenParams) (int64, error)
+	ResetAPITokens(ctx context.Context) error
+}
+
+type OAuthStore interface {
+	CreateOAuthClient(ctx context.Context, arg database.CreateOAuthClientParams) (database.OauthClient, error)
+	GetOAuthClient(ctx context.Context, id uuid.UUID) (database.OauthClient, error)
+	CreateOAuthRedirectURI(ctx context.Context, arg database.CreateOAuthRedirectURIParams) error
+	// GetOAuthRedirectURIs is ordered by URI.
+	GetOAuthRedirectURIs(ctx context.Context, clientID uuid.UUID) ([]string, error)
+	CreateOAuthCode(ctx context.Context, arg database.CreateOAuthCodeParams) error
+	ConsumeOAuthCode(ctx context.Context, arg database.ConsumeOAuthCodeParams) (database.OauthCode, error)
+	ResetOAuthCodes(ctx context.Context) error
+	// ResetOAuthClients removes every client, and with them their redirect
+	// URIs, codes and the refresh tokens issued to them.
+	ResetOAuthClients(ctx context.Context) error
+}
+
+// IdentityStore links users to accounts at OpenID Connect providers.
+type IdentityStore interface {
+	CreateOIDCLogin(ctx context.Context, arg database.CreateOIDCLoginParams) error
+	ConsumeOIDCLogin(ctx context.Context, stateHash string) (database.OidcLogin, error)
+	CreateUserIdentity(ctx context.Context, arg database.CreateUserIdentityParams) (database.UserIdentity, error)
+	GetUserIdentity(ctx context.Context, arg database.GetUserIdentityParams) (database.UserIdentity, error)
+	// CreateOIDCLink holds a new identity whose email belongs to an
+	// existing user until they confirm the link. DeleteOIDCLink affects no
+	// rows once the link has been used.
+	CreateOIDCLink(ctx context.Context, arg database.CreateOIDCLinkParams) error
+	GetOIDCLink(ctx context.Context, tokenHash string) (database.OidcLink, error)
+	DeleteOIDCLink(ctx context.Context, tokenHash string) (int64, error)
+	ResetUserIdentities(ctx context.Context) error
+	ResetOIDCLogins(ctx context.Context) error
+	ResetOIDCLinks(ctx context.Context) error
+}
+
+// StatsStore answers the admin dashboard.
+type StatsStore interface {
+	CountUsers(ctx context.Context) (int64, error)
+	CountChirpyRed(ctx context.Context) (int64, error)
+	CountChirps(ctx context.Context) (int64, error)
+	// SignupsPerDay and ChirpsPerDay count what was created on each day
+	// since createdAt, as YYYY-MM-DD, oldest first. Days with nothing are
+	// left out. Days are in UTC, except on Postgres, whose timestamps keep
+	// the server's local time.
+	SignupsPerDay(ctx context.Context, createdAt time.Time) ([]database.SignupsPerDayRow, error)
+	ChirpsPerDay(ctx context.Context, createdAt time.Time) ([]database.ChirpsPerDayRow, error)
+	// TopPosters is ordered by chirps posted, most first, then by email.
+	TopPosters(ctx context.Context, limit int32) ([]database.TopPostersRow, error)
+}
@@ -0,0 +1,36 @@
+// Package storetest sets up stores backed by real databases for tests.
+package storetest
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/Senaphim/Chirpy/internal/migrate"
+	"github.com/Senaphim/Chirpy/internal/store"
+)
+
+// SQLite returns a store backed by a new SQLite database in a temporary
+// directory, with every migration in sql/sqlite/schema applied. The hooks
+// see the store's queries but not the migrations.
+func SQLite(t testing.TB, hooks ...store.QueryHook) *store.SQLite {
+	t.Helper()
+	st, db, err := store.Open("sqlite://"+filepath.Join(t.TempDir(), "chirpy.db"), hooks...)
+	if err != nil {
+		t.Fatalf("Open error = %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	_, file, _, _ := runtime.Caller(0)
+	schemaDir := filepath.Join(filepath.Dir(file), "..", "..", "..", "sql", "sqlite", "schema")
+	migrator, err := migrate.New(db, os.DirFS(schemaDir))
+	if err != nil {
+		t.Fatalf("migrate.New error = %v", err)
+	}
+	if _, err := migrator.Up(context.Background()); err != nil {
+		t.Fatalf("Up error = %v", err)
+	}
+	return st.(*store.SQLite)
+}
@@ -0,0 +1,162 @@
+// Package validate checks decoded request structs against rules given in
+// their `validate` struct tags, for example:
+//
+//	Email string `json:"email" validate:"required,email,max=254"`
+//
+// Supported rules are required, email, min=N and max=N. For strings min and
+// max count characters, for slices and maps they count elements and for
+// integers they bound the value. Nested structs are checked too, with their
+// field names prefixed by the parent's.
+package validate
+
+import (
+	"fmt"
+	"net/mail"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// FieldError describes why a single field was rejected. Field is the JSON
+// name of the field.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Struct returns every rule broken by v, which must be a struct or a pointer
+// to one. A nil result means v is valid.
+func Struct(v any) []FieldError {
+	val := reflect.ValueOf(v)
+	for val.Kind() == reflect.Pointer {
+		if val.IsNil() {
+			return nil
+		}
+		val = val.Elem()
+	}
+	if val.Kind() != reflect.Struct {
+		return nil
+	}
+
+	return checkStruct(val, "")
+}
+
+func checkStruct(val reflect.Value, prefix string) []FieldError {
+	var errs []FieldError
+	typ := val.Type()
+
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name := prefix + jsonName(field)
+		fieldVal := val.Field(i)
+
+		for _, rule := range strings.Split(field.Tag.Get("validate"), ",") {
+			if rule == "" {
+				continue
+			}
+			message, err := checkRule(rule, fieldVal)
+			if err != nil {
+				panic(fmt.Sprintf("validate: bad rule %q on %s.%s: %v", rule, typ.Name(), field.Name, err))
+			}
+			if message != "" {
+				errs = append(errs, FieldError{Field: name, Message: message})
+				// Later rules rarely add anything once a field has failed.
+				break
+			}
+		}
+
+		if fieldVal.Kind() == reflect.Struct {
+			errs = append(errs, checkStruct(fieldVal, name+".")...)
+		}
+	}
+
+	return errs
+}
+
+func jsonName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "" || name == "-" {
+		return field.Name
+	}
+	return name
+}
+
+// checkRule returns a message when val breaks rule, and an error when the
+// rule itself is malformed.
+func checkRule(rule string, val reflect.Value) (string, error) {
+	name, arg, _ := strings.Cut(rule, "=")
+
+	switch name {
+	case "required":
+		if isEmpty(val) {
+			return "is required", nil
+		}
+	case "email":
+		if val.Kind() != reflect.String {
+			return "", fmt.Errorf("email rule on %v", val.Kind())
+		}
+		if val.String() != "" && !validEmail(val.String()) {
+			return "must be a valid email address", nil
+		}
+	case "min", "max":
+		limit, err := strconv.Atoi(arg)
+		if err != nil {
+			return "", err
+		}
+		size, unit, err := length(val)
+		if err != nil {
+			return "", err
+		}
+		if name == "min" && size < limit {
+			return strings.TrimSpace(fmt.Sprintf("must be at least %d %s", limit, unit)), nil
+		}
+		if name == "max" && size > limit {
+			return strings.TrimSpace(fmt.Sprintf("must be at most %d %s", limit, unit)), nil
+		}
+	default:
+		return "", fmt.Errorf("unknown rule")
+	}
+
+	return "", nil
+}
+
+func isEmpty(val reflect.Value) bool {
+	switch val.Kind() {
+	case reflect.String:
+		return strings.TrimSpace(val.String()) == ""
+	case reflect.Slice, reflect.Map:
+		return val.Len() == 0
+	case reflect.Pointer, reflect.Interface:
+		return val.IsNil()
+	default:
+		return val.IsZero()
+	}
+}
+
+func length(val reflect.Value) (int, string, error) {
+	switch val.Kind() {
+	case reflect.String:
+		return utf8.RuneCountInString(val.String()), "characters", nil
+	case reflect.Slice, reflect.Map:
+		return val.Len(), "items", nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return int(val.Int()), "", nil
+	default:
+		return 0, "", fmt.Errorf("length rule on %v", val.Kind())
+	}
+}
+
+// validEmail accepts a bare address such as user@example.com, rejecting
+// display names and other forms net/mail would otherwise allow.
+func validEmail(email string) bool {
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email {
+		return false
+	}
+	_, domain, _ := strings.Cut(email, "@")
+	return domain != "" && !strings.HasPrefix(domain, ".") && !strings.HasSuffix(domain, ".")
+}
@@ -0,0 +1,94 @@
+package validate
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type signup struct {
+	Email    string   `json:"email" validate:"required,email"`
+	Password string   `json:"password" validate:"required,min=8,max=72"`
+	Tags     []string `json:"tags" validate:"max=2"`
+	Age      int      `json:"age" validate:"min=0"`
+	Profile  struct {
+		Bio string `json:"bio" validate:"max=5"`
+	} `json:"profile"`
+	ignored string
+}
+
+func TestStruct(t *testing.T) {
+	tests := []struct {
+		name  string
+		input signup
+		want  []FieldError
+	}{
+		{
+			name:  "Valid",
+			input: signup{Email: "walt@breakingbad.com", Password: "correct horse"},
+			want:  nil,
+		},
+		{
+			name:  "Missing fields",
+			input: signup{Email: "  "},
+			want: []FieldError{
+				{Field: "email", Message: "is required"},
+				{Field: "password", Message: "is required"},
+			},
+		},
+		{
+			name:  "Bad email and short password",
+			input: signup{Email: "Walt <walt@breakingbad.com>", Password: "short"},
+			want: []FieldError{
+				{Field: "email", Message: "must be a valid email address"},
+				{Field: "password", Message: "must be at least 8 characters"},
+			},
+		},
+		{
+			name:  "Lengths count characters and items",
+			input: signup{Email: "walt@breakingbad.com", Password: strings.Repeat("é", 8), Tags: []string{"a", "b", "c"}},
+			want: []FieldError{
+				{Field: "tags", Message: "must be at most 2 items"},
+			},
+		},
+		{
+			name:  "Integers are bounded by value",
+			input: signup{Email: "walt@breakingbad.com", Password: "correct horse", Age: -1},
+			want: []FieldError{
+				{Field: "age", Message: "must be at least 0"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Struct(&tt.input)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Struct = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStructNested(t *testing.T) {
+	input := signup{Email: "walt@breakingbad.com", Password: "correct horse"}
+	input.Profile.Bio = "chemistry teacher"
+
+	want := []FieldError{{Field: "profile.bio", Message: "must be at most 5 characters"}}
+	if got := Struct(input); !reflect.DeepEqual(got, want) {
+		t.Errorf("Struct = %v, want %v", got, want)
+	}
+}
+
+func TestStructPanicsOnBadRule(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Struct accepted an unknown rule")
+		}
+	}()
+
+	type bad struct {
+		Name string `validate:"sometimes"`
+	}
+	Struct(bad{})
+}
@@ -1,823 +1,253 @@
 package main
 
 import (
-	"database/sql"
-	"encoding/json"
+	"context"
 	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
-	"slices"
-	"strings"
-	"sync/atomic"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/Senaphim/Chirpy/internal/auth"
-	"github.com/Senaphim/Chirpy/internal/database"
-	"github.com/google/uuid"
-	"github.com/joho/godotenv"
-	_ "github.com/lib/pq"
+	"github.com/Senaphim/Chirpy/internal/config"
+	"github.com/Senaphim/Chirpy/internal/metrics"
+	"github.com/Senaphim/Chirpy/internal/migrate"
+	"github.com/Senaphim/Chirpy/internal/oidc"
+	"github.com/Senaphim/Chirpy/internal/server"
+	"github.com/Senaphim/Chirpy/internal/store"
+	"go.opentelemetry.io/otel"
 )
 
-type apiConfig struct {
-	fileserverHits atomic.Int32
-	queries        *database.Queries
-	platform       string
-	secret         string
-	polkaKey       string
-}
-
-func (cfg *apiConfig) middlewareMetricInc(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		cfg.fileserverHits.Add(1)
-		next.ServeHTTP(w, r)
-	})
-}
-
-func (cfg *apiConfig) handleHits(w http.ResponseWriter, r *http.Request) {
-	w.Header().Add("Content-Type", "text/html; charset=utf-8")
-	w.WriteHeader(http.StatusOK)
-	fmtStr := `<html>
-  <body>
-    <h1>Welcome, Chirpy Admin</h1>
-    <p>Chirpy has been visited %d times!</p>
-  </body>
-</html>
-	`
-	fmt.Fprintf(w, fmtStr, cfg.fileserverHits.Load())
-}
-
-func (cfg *apiConfig) handleReset(w http.ResponseWriter, r *http.Request) {
-	if cfg.platform != "dev" {
-		w.WriteHeader(http.StatusForbidden)
-		return
-	}
-
-	err := cfg.queries.DeleteAll(r.Context())
-	if err != nil {
-		log.Printf("Error deleteing all users:\n%v", err)
-		w.WriteHeader(500)
-		return
-	}
-
-	err = cfg.queries.ResetChirps(r.Context())
-	if err != nil {
-		log.Printf("Error deleting all chirps:\n%v", err)
-		w.WriteHeader(500)
-		return
-	}
-
-	err = cfg.queries.ResetRefreshTokens(r.Context())
-	if err != nil {
-		log.Printf("Error deleting all refresh tokens:\n%v", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	}
-
-	cfg.fileserverHits.Store(0)
-	w.Header().Add("Content-Type", "text/plain; charset=utf-8")
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte(http.StatusText(http.StatusOK)))
-}
-
 func main() {
-	cfg := &apiConfig{}
-	serveMux := http.NewServeMux()
-	if err := godotenv.Load(); err != nil {
-		log.Printf("Error loading environment variables: %v", err)
-		return
-	}
-	dbUrl := os.Getenv("DB_URL")
-	cfg.platform = os.Getenv("PLATFORM")
-	cfg.secret = os.Getenv("SECRET")
-	cfg.polkaKey = os.Getenv("POLKA_KEY")
-	db, err := sql.Open("postgres", dbUrl)
-	if err != nil {
-		log.Printf("Error opening database: %v", err)
-		return
-	}
-	cfg.queries = database.New(db)
-
-	// File server handler
-	fsHandler := http.StripPrefix("/app", http.FileServer(http.Dir(".")))
-	serveMux.Handle("/app/", cfg.middlewareMetricInc(fsHandler))
-
-	// Other handlers
-	hhe := http.HandlerFunc(handleHealth)
-	serveMux.Handle("GET /api/healthz", hhe)
-	hhi := http.HandlerFunc(cfg.handleHits)
-	serveMux.Handle("GET /admin/metrics", hhi)
-	hr := http.HandlerFunc(cfg.handleReset)
-	serveMux.Handle("POST /admin/reset", hr)
-	hc := http.HandlerFunc(cfg.handleChirp)
-	serveMux.Handle("POST /api/chirps", hc)
-	hcr := http.HandlerFunc(cfg.handlerCreateUser)
-	serveMux.Handle("POST /api/users", hcr)
-	hac := http.HandlerFunc(cfg.handlerAllChirps)
-	serveMux.Handle("GET /api/chirps", hac)
-	hci := http.HandlerFunc(cfg.handlerChirpById)
-	serveMux.Handle("GET /api/chirps/{chirpID}", hci)
-	hl := http.HandlerFunc(cfg.handlerLogin)
-	serveMux.Handle("POST /api/login", hl)
-	hre := http.HandlerFunc(cfg.handlerRefresh)
-	serveMux.Handle("POST /api/refresh", hre)
-	hrev := http.HandlerFunc(cfg.handlerRevoke)
-	serveMux.Handle("POST /api/revoke", hrev)
-	hcpe := http.HandlerFunc(cfg.handleChangePwd)
-	serveMux.Handle("PUT /api/users", hcpe)
-	hdc := http.HandlerFunc(cfg.handlerDeleteChirp)
-	serveMux.Handle("DELETE /api/chirps/{chirpID}", hdc)
-	hpw := http.HandlerFunc(cfg.handlePolkaWebhook)
-	serveMux.Handle("POST /api/polka/webhooks", hpw)
-
-	// Start server
-	server := http.Server{
-		Addr:    ":8080",
-		Handler: serveMux,
-	}
-	err = server.ListenAndServe()
-	if err != nil {
-		fmt.Println(fmt.Errorf("Error serving request:\n%v", err))
+	// Log as JSON from the start, so even configuration errors reach log
+	// aggregation intact.
+	slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stderr, nil)))
+	// run has returned, so its deferred shutdown steps have already flushed
+	// traces and closed the database before the process exits. The logger
+	// writes straight to stderr and holds nothing back.
+	if err := run(os.Args[1:]); err != nil {
+		slog.Error("Error running Chirpy", "error", err)
+		var usage usageError
+		if errors.As(err, &usage) {
+			os.Exit(2)
+		}
+		os.Exit(1)
 	}
-
 }
 
-func handleHealth(w http.ResponseWriter, r *http.Request) {
-	w.Header().Add("Content-Type", "text/plain; charset=utf-8")
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte(http.StatusText(http.StatusOK)))
+// usageError is a mistake in how Chirpy was invoked or configured, which
+// exits with status 2 rather than 1.
+type usageError struct {
+	error
 }
 
-func (cfg *apiConfig) handleChirp(w http.ResponseWriter, r *http.Request) {
-	type parameters struct {
-		Body string    `json:"body"`
-		Id   uuid.UUID `json:"user_id"`
-	}
-
-	jwt, err := auth.GetBearerToken(r.Header)
+// run starts Chirpy with the command line args and returns once it has
+// stopped. Every error means Chirpy failed and should exit non-zero.
+func run(args []string) error {
+	c, args, err := config.Load(args, os.LookupEnv)
 	if err != nil {
-		log.Printf("Error getting bearer token:\n%v", err)
-		w.WriteHeader(500)
-		return
+		return usageError{fmt.Errorf("loading configuration: %w", err)}
 	}
-
-	userId, err := auth.ValidateJWT(jwt, cfg.secret)
-	if err != nil {
-		log.Printf("Invalid JWT:\n%v", err)
-		w.WriteHeader(http.StatusUnauthorized)
-		return
-	}
-
-	decoder := json.NewDecoder(r.Body)
-	params := parameters{
-		Id: userId,
+	if len(args) > 0 && args[0] == "config" {
+		if err := runConfig(c, args[1:]); err != nil {
+			return fmt.Errorf("printing configuration: %w", err)
+		}
+		return nil
 	}
-	if err := decoder.Decode(&params); err != nil {
-		helperJsonError(w, "Error decoding parameters: %s", err)
-		return
+	if err := c.Validate(); err != nil {
+		return usageError{fmt.Errorf("configuration: %w", err)}
 	}
+	// Anything still using the log package, such as net/http, goes through
+	// this logger too.
+	slog.SetDefault(newLogger(c))
 
-	if len(params.Body) > 140 {
-		type responseJson struct {
-			Error string
+	serverConfig := server.Config{}
+	registry := metrics.NewRegistry()
+	metrics.RegisterRuntime(registry)
+	hooks := []store.QueryHook{store.QueryMetrics(registry)}
+	if c.OTLPEndpoint != "" {
+		shutdownTracing, err := setupTracing(context.Background(), c.OTLPEndpoint)
+		if err != nil {
+			return fmt.Errorf("setting up tracing: %w", err)
 		}
-
-		resp := responseJson{
-			Error: "Chirp is too long",
+		defer func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := shutdownTracing(ctx); err != nil {
+				slog.Error("Error flushing traces", "error", err)
+			}
+		}()
+		hooks = append(hooks, store.QueryTracing(otel.GetTracerProvider()))
+	}
+	// The DB_URL scheme picks the database. DB_URL=memory runs without one,
+	// for demos, and nothing is kept once the server stops.
+	st, db, err := store.Open(c.DBURL, hooks...)
+	if err != nil {
+		return fmt.Errorf("opening database: %w", err)
+	}
+	if c.DBURL == "memory" {
+		slog.Warn("Using the in-memory store, data will be lost on exit")
+	}
+
+	var migrator *migrate.Migrator
+	if db != nil {
+		// Deferred after the tracer, so the database closes first and its
+		// last queries still make it into the flushed traces.
+		defer func() {
+			if err := db.Close(); err != nil {
+				slog.Error("Error closing database", "error", err)
+			}
+		}()
+		pool := store.Pool{
+			MaxOpenConns:    c.DBMaxOpenConns,
+			MaxIdleConns:    c.DBMaxIdleConns,
+			ConnMaxLifetime: c.DBConnMaxLifetime,
+			ConnMaxIdleTime: c.DBConnMaxIdleTime,
 		}
-		dat, err := json.Marshal(resp)
+		pool.Apply(db)
+
+		// Wait for the database, which may still be starting alongside us,
+		// rather than failing on the first request.
+		ctx, cancel := context.WithTimeout(context.Background(), c.DBConnectTimeout)
+		err = store.Ping(ctx, db)
+		cancel()
 		if err != nil {
-			helperJsonError(w, "Error marshalling response: %s", err)
-			return
+			return fmt.Errorf("connecting to database: %w", err)
 		}
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(400)
-		w.Write(dat)
-		return
-	}
-
-	cleanString := helperCleanString(params.Body)
-	chirp, err := cfg.helperCreateChirp(cleanString, params.Id, r)
-	if err != nil {
-		log.Printf("Error creating chirp:\n%v", err)
-		w.WriteHeader(500)
-		return
-	}
 
-	type returnVals struct {
-		Id        uuid.UUID `json:"id"`
-		CreatedAt time.Time `json:"created_at"`
-		UpdatedAt time.Time `json:"updated_at"`
-		Body      string    `json:"body"`
-		UserId    uuid.UUID `json:"user_id"`
-	}
-
-	resp := returnVals{
-		Id:        chirp.ID,
-		CreatedAt: chirp.CreatedAt,
-		UpdatedAt: chirp.UpdatedAt,
-		Body:      chirp.Body,
-		UserId:    chirp.UserID,
-	}
-	dat, err := json.Marshal(resp)
-	if err != nil {
-		helperJsonError(w, "Error marshalling response: %s", err)
-		return
-	}
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(201)
-	w.Write(dat)
-}
-
-func helperJsonError(w http.ResponseWriter, responseMsg string, err error) {
-	type responseJson struct {
-		Error string
-	}
-
-	log.Printf(responseMsg, err)
-
-	resp := responseJson{
-		Error: "something went wrong",
-	}
-	dat, err := json.Marshal(resp)
-	if err != nil {
-		log.Printf("Error marshalling error response: %s", err)
-		w.WriteHeader(500)
-		return
-	}
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusInternalServerError)
-	w.Write(dat)
-}
-
-func helperCleanString(post string) string {
-	profanity := []string{"kerfuffle", "sharbert", "fornax", "Kerfuffle", "Sharbert", "Fornax"}
-	cleanPost := post
-
-	for _, profane := range profanity {
-		cleanPost = strings.ReplaceAll(cleanPost, profane, "****")
-	}
-
-	return cleanPost
-}
-
-func (cfg *apiConfig) handlerCreateUser(w http.ResponseWriter, r *http.Request) {
-	type email struct {
-		Email    string `json:"email"`
-		Password string `json:"password"`
-	}
-
-	decoder := json.NewDecoder(r.Body)
-	em := email{}
-	if err := decoder.Decode(&em); err != nil {
-		helperJsonError(w, "Error decoding email:%v", err)
-		return
-	}
-
-	user, err := cfg.helperCreateUser(em.Email, em.Password, r)
-	if err != nil {
-		log.Printf("Error creating user:\n%v", err)
-		w.WriteHeader(500)
-		return
-	}
-
-	type retUser struct {
-		ID          uuid.UUID `json:"id"`
-		CreatedAt   time.Time `json:"created_at"`
-		UpdatedAt   time.Time `json:"updated_at"`
-		Email       string    `json:"email"`
-		IsChirpyRed bool      `json:"is_chirpy_red"`
-	}
-	rUser := retUser{
-		ID:          user.ID,
-		CreatedAt:   user.CreatedAt,
-		UpdatedAt:   user.UpdatedAt,
-		Email:       user.Email,
-		IsChirpyRed: user.IsChirpyRed,
-	}
-	dat, err := json.Marshal(rUser)
-	if err != nil {
-		helperJsonError(w, "Error marshalling response: %s", err)
-		return
+		migrator, err = newMigrator(db)
+		if err != nil {
+			return fmt.Errorf("loading migrations: %w", err)
+		}
 	}
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(201)
-	w.Write(dat)
-}
-
-func (cfg *apiConfig) helperCreateUser(
-	email string,
-	password string,
-	r *http.Request,
-) (database.User, error) {
 
-	hashedPassword, err := auth.HashPassword(password)
-	if err != nil {
-		fmtErr := fmt.Errorf("Error with password:\n%v", err)
-		return database.User{}, fmtErr
+	if len(args) > 0 && args[0] == "migrate" {
+		if err := runMigrate(context.Background(), migrator, args[1:]); err != nil {
+			return fmt.Errorf("migrating database: %w", err)
+		}
+		return nil
 	}
-	userDetails := database.CreateUserParams{
-		ID:             uuid.New(),
-		CreatedAt:      time.Now().Local(),
-		UpdatedAt:      time.Now().Local(),
-		Email:          email,
-		HashedPassword: hashedPassword,
+	if len(args) > 0 && args[0] != "admin" {
+		return usageError{fmt.Errorf("unknown command %q, the commands are migrate, admin and config", args[0])}
 	}
-
-	user, err := cfg.queries.CreateUser(r.Context(), userDetails)
-	if err != nil {
-		fmtErr := fmt.Errorf("Error adding user to database:\n%v", err)
-		return database.User{}, fmtErr
+	// Replicas starting together take turns, so only one applies each
+	// migration.
+	if migrator != nil && !c.SkipMigrations {
+		if err := migrateUp(context.Background(), migrator); err != nil {
+			return fmt.Errorf("migrating database: %w", err)
+		}
 	}
-
-	return user, nil
-}
-
-func (cfg *apiConfig) helperCreateChirp(
-	body string,
-	user uuid.UUID,
-	r *http.Request,
-) (database.Chirp, error) {
-
-	chirpDetails := database.CreateChirpParams{
-		ID:        uuid.New(),
-		CreatedAt: time.Now().Local(),
-		UpdatedAt: time.Now().Local(),
-		Body:      body,
-		UserID:    user,
+	if len(args) > 0 {
+		if err := runAdmin(context.Background(), st, args[1:]); err != nil {
+			return fmt.Errorf("changing admins: %w", err)
+		}
+		return nil
 	}
+	serverConfig.DB = db
+	serverConfig.Migrator = migrator
+	serverConfig.Logger = slog.Default()
+	serverConfig.Metrics = registry
 
-	chirp, err := cfg.queries.CreateChirp(r.Context(), chirpDetails)
-	if err != nil {
-		fmtErr := fmt.Errorf("Error adding chirp to database:\n%v", err)
-		return database.Chirp{}, fmtErr
+	serverConfig.Platform = c.Platform
+	serverConfig.ResetToken = c.ResetToken
+	if c.FixturesDir != "" {
+		serverConfig.Fixtures = os.DirFS(c.FixturesDir)
 	}
-
-	return chirp, nil
-}
-
-func (cfg *apiConfig) handlerAllChirps(w http.ResponseWriter, r *http.Request) {
-	author := r.URL.Query().Get("author_id")
-
-	chirps := []database.Chirp{}
-	err := errors.New("")
-	if author == "" {
-		chirps, err = cfg.queries.AllChirps(r.Context())
+	if c.JWTKeysDir == "" {
+		serverConfig.Keys = auth.NewHMACKeySet(c.Secret)
 	} else {
-		author_uuid, err := uuid.Parse(author)
+		keys, err := auth.LoadKeySet(c.JWTKeysDir)
 		if err != nil {
-			log.Printf("Error parsing query parameter:\n%v", err)
-			w.WriteHeader(http.StatusInternalServerError)
-			return
+			return fmt.Errorf("loading JWT keys: %w", err)
 		}
-		chirps, err = cfg.queries.GetChirpsByAuthor(r.Context(), author_uuid)
-		if err != nil {
-			log.Printf("Error fetching chirps:\n%v", err)
-			w.WriteHeader(http.StatusInternalServerError)
+		// Keep accepting tokens signed with the old shared secret while
+		// moving onto asymmetric keys.
+		if c.Secret != "" {
+			keys.AcceptHMAC(c.Secret)
 		}
-	}
-	if err != nil && err.Error() != "" {
-		log.Printf("Error fetching chirps:\n%v", err)
-		return
-	}
-
-	type returnChirp struct {
-		Id        uuid.UUID `json:"id"`
-		CreatedAt time.Time `json:"created_at"`
-		UpdatedAt time.Time `json:"updated_at"`
-		Body      string    `json:"body"`
-		UserId    uuid.UUID `json:"user_id"`
-	}
-
-	returnArray := []returnChirp{}
-
-	for _, chirp := range chirps {
-		rChirp := returnChirp{
-			Id:        chirp.ID,
-			CreatedAt: chirp.CreatedAt,
-			UpdatedAt: chirp.UpdatedAt,
-			Body:      chirp.Body,
-			UserId:    chirp.UserID,
+		serverConfig.Keys = keys
+	}
+	serverConfig.PolkaKey = c.PolkaKey
+	serverConfig.PasswordPolicy = auth.DefaultPasswordPolicy()
+	serverConfig.PasswordPolicy.MinLength = c.PasswordMinLength
+	if c.PasswordBreachDir != "" {
+		serverConfig.PasswordPolicy.Breached = auth.DirBreachedList(c.PasswordBreachDir)
+	}
+	argon2Params := auth.DefaultArgon2Params
+	argon2Params.Memory = c.Argon2MemoryKiB
+	argon2Params.Iterations = c.Argon2Iterations
+	argon2Params.Parallelism = c.Argon2Parallelism
+	serverConfig.Hasher = auth.NewPasswordHasher(argon2Params)
+
+	// Single sign on is only enabled when a provider is configured.
+	if c.OIDCIssuer != "" {
+		oidcConfig := oidc.Config{
+			Issuer:       c.OIDCIssuer,
+			ClientID:     c.OIDCClientID,
+			ClientSecret: c.OIDCClientSecret,
+			RedirectURL:  c.OIDCRedirectURL,
 		}
-
-		returnArray = append(returnArray, rChirp)
-	}
-
-	sort := r.URL.Query().Get("sort")
-	if sort != "" {
-		if sort == "asc" {
-			slices.SortFunc(returnArray, func(a, b returnChirp) int {
-				return a.CreatedAt.Compare(b.CreatedAt)
-			})
-		} else if sort == "desc" {
-			slices.SortFunc(returnArray, func(a, b returnChirp) int {
-				return -(a.CreatedAt.Compare(b.CreatedAt))
-			})
-		} else {
-			log.Printf("Unexpected query parameter value: %v", sort)
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		provider, err := oidc.Discover(ctx, oidcConfig, nil)
+		cancel()
+		if err != nil {
+			return fmt.Errorf("discovering OIDC provider: %w", err)
 		}
+		serverConfig.OIDC = provider
+		serverConfig.OIDCAutoLink = c.OIDCAutoLink
 	}
 
-	dat, err := json.Marshal(returnArray)
-	if err != nil {
-		helperJsonError(w, "error marshalling json response:%v", err)
-		return
-	}
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(200)
-	w.Write(dat)
-}
-
-func (cfg *apiConfig) handlerChirpById(w http.ResponseWriter, r *http.Request) {
-	id := r.PathValue("chirpID")
-	chirp_uuid, err := uuid.Parse(id)
-	if err != nil {
-		log.Printf("Error parsing chirpID:\n%v", err)
-		w.WriteHeader(http.StatusNotFound)
-		return
-	}
-
-	chirp, err := cfg.queries.GetChirpById(r.Context(), chirp_uuid)
-	if err != nil {
-		log.Printf("Error fetching chirps:\n%v", err)
-		w.WriteHeader(http.StatusNotFound)
-		return
-	}
-
-	type returnChirp struct {
-		Id        uuid.UUID `json:"id"`
-		CreatedAt time.Time `json:"created_at"`
-		UpdatedAt time.Time `json:"updated_at"`
-		Body      string    `json:"body"`
-		UserId    uuid.UUID `json:"user_id"`
-	}
-	rChirp := returnChirp{
-		Id:        chirp.ID,
-		CreatedAt: chirp.CreatedAt,
-		UpdatedAt: chirp.UpdatedAt,
-		Body:      chirp.Body,
-		UserId:    chirp.UserID,
+	// Start server. The timeouts stop slow clients holding connections
+	// open indefinitely.
+	httpServer := &http.Server{
+		Addr:              c.Addr,
+		Handler:           server.NewServer(serverConfig, st),
+		ReadHeaderTimeout: c.HTTPReadHeaderTimeout,
+		ReadTimeout:       c.HTTPReadTimeout,
+		WriteTimeout:      c.HTTPWriteTimeout,
+		IdleTimeout:       c.HTTPIdleTimeout,
 	}
 
-	dat, err := json.Marshal(rChirp)
+	ln, err := net.Listen("tcp", httpServer.Addr)
 	if err != nil {
-		helperJsonError(w, "error marshalling json response:%v", err)
-		return
-	}
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(200)
-	w.Write(dat)
-}
-
-func (cfg *apiConfig) handlerLogin(w http.ResponseWriter, r *http.Request) {
-	type user struct {
-		Email    string `json:"email"`
-		Password string `json:"password"`
+		return fmt.Errorf("listening on %s: %w", httpServer.Addr, err)
 	}
-
-	decoder := json.NewDecoder(r.Body)
-	usr := user{
-		Email:    "",
-		Password: "",
-	}
-	if err := decoder.Decode(&usr); err != nil {
-		helperJsonError(w, "Error decoding user:\n%v", err)
-		return
-	}
-
-	refreshToken, err := auth.MakeRefreshToken()
-	if err != nil {
-		log.Printf("Error making refresh token:\n%v", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	}
-
-	dbUsr, err := cfg.queries.GetUserByEmail(r.Context(), usr.Email)
-	if err != nil {
-		log.Printf("Error fetching user from email:\n%v", err)
-		w.WriteHeader(401)
-		w.Write([]byte("Incorrect email or password"))
-		return
-	}
-
-	err = auth.CheckPasswordHash(usr.Password, dbUsr.HashedPassword)
-	if err != nil {
-		log.Printf("Error bad password:\n%v", err)
-		w.WriteHeader(401)
-		w.Write([]byte("Incorrect email or password"))
-		return
-	}
-
-	jwtExpiration, err := time.ParseDuration("3600s")
-	if err != nil {
-		log.Printf("Error parsing duration string:\n%v", err)
-		w.WriteHeader(500)
-		return
-	}
-	jwt, err := auth.MakeJWT(dbUsr.ID, cfg.secret, jwtExpiration)
-	if err != nil {
-		log.Printf("Error making JWT:\n%v", err)
-		w.WriteHeader(500)
-		return
-	}
-
-	refreshTokenExpiration, err := time.ParseDuration("1440h")
-	if err != nil {
-		log.Printf("Error parsing duration:\n%v", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	}
-	expiryTime := time.Now().Add(refreshTokenExpiration)
-	refreshParams := database.CreateRefreshTokenParams{
-		Token:     refreshToken,
-		CreatedAt: time.Now().Local(),
-		UpdatedAt: time.Now().Local(),
-		UserID:    dbUsr.ID,
-		ExpiresAt: expiryTime,
-	}
-	cfg.queries.CreateRefreshToken(r.Context(), refreshParams)
-
-	type retUser struct {
-		ID           uuid.UUID `json:"id"`
-		CreatedAt    time.Time `json:"created_at"`
-		UpdatedAt    time.Time `json:"updated_at"`
-		Email        string    `json:"email"`
-		IsChirpyRed  bool      `json:"is_chirpy_red"`
-		Token        string    `json:"token"`
-		RefreshToken string    `json:"refresh_token"`
-	}
-	rUser := retUser{
-		ID:           dbUsr.ID,
-		CreatedAt:    dbUsr.CreatedAt,
-		UpdatedAt:    dbUsr.UpdatedAt,
-		Email:        dbUsr.Email,
-		IsChirpyRed:  dbUsr.IsChirpyRed,
-		Token:        jwt,
-		RefreshToken: refreshToken,
-	}
-	dat, err := json.Marshal(rUser)
-	if err != nil {
-		helperJsonError(w, "Error marshalling response: %s", err)
-		return
+	// A second signal while shutting down kills the process straight away.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	context.AfterFunc(ctx, stop)
+	// Requests are the only work in flight: the server starts no background
+	// goroutines, so once serve has drained them nothing else needs waiting
+	// for before the database closes.
+	if err := serve(ctx, httpServer, ln, c.ShutdownTimeout); err != nil {
+		return fmt.Errorf("serving requests: %w", err)
 	}
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	w.Write(dat)
+	return nil
 }
 
-func (cfg *apiConfig) handlerRefresh(w http.ResponseWriter, r *http.Request) {
-	token, err := auth.GetBearerToken(r.Header)
-	if err != nil {
-		log.Printf("Error getting bearer token:\n%v", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	}
-
-	dbToken, err := cfg.queries.GetRefreshToken(r.Context(), token)
-	if err != nil {
-		log.Printf("Refresh token not found:\n%v", err)
-		w.WriteHeader(http.StatusUnauthorized)
-		return
-	}
-	if dbToken.RevokedAt.Valid {
-		log.Printf("Refresh token expired")
-		w.WriteHeader(http.StatusUnauthorized)
-		return
-	}
-
-	tokenExpiration, err := time.ParseDuration("1h")
-	if err != nil {
-		log.Printf("Error parsing duration:\n%v", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	}
-	oneHrToken, err := auth.MakeJWT(dbToken.UserID, cfg.secret, tokenExpiration)
-	if err != nil {
-		log.Printf("Error creating JWT:\n%v", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	}
-
-	type retStruct struct {
-		Token string `json:"token"`
-	}
-	rStruct := retStruct{
-		Token: oneHrToken,
-	}
-	dat, err := json.Marshal(rStruct)
-	if err != nil {
-		helperJsonError(w, "Error marshalling response: %s", err)
-		return
-	}
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	w.Write(dat)
-}
-
-func (cfg *apiConfig) handlerRevoke(w http.ResponseWriter, r *http.Request) {
-	token, err := auth.GetBearerToken(r.Header)
-	if err != nil {
-		log.Printf("Error getting token from header:\n%v", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	}
-
-	dbToken, err := cfg.queries.GetRefreshToken(r.Context(), token)
-	if err != nil {
-		log.Printf("Refresh token not found:\n%v", err)
-		w.WriteHeader(http.StatusUnauthorized)
-		return
-	}
-	if dbToken.RevokedAt.Valid {
-		log.Printf("Refresh token expired")
-		w.WriteHeader(http.StatusUnauthorized)
-		return
+// runConfig runs the config subcommand. config print shows the settings
+// Chirpy would start with, secrets redacted, and reports any that are
+// invalid.
+func runConfig(c *config.Config, args []string) error {
+	if len(args) != 1 || args[0] != "print" {
+		return errors.New("usage: chirpy config print")
 	}
-
-	revokeParams := database.RevokeRefreshTokenParams{
-		Token:     dbToken.Token,
-		UpdatedAt: time.Now().Local(),
-		RevokedAt: sql.NullTime{
-			Time:  time.Now().Local(),
-			Valid: true,
-		},
+	if err := c.Print(os.Stdout); err != nil {
+		return err
 	}
-	err = cfg.queries.RevokeRefreshToken(r.Context(), revokeParams)
-	if err != nil {
-		log.Printf("Error revoking refresh token:\n%v", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	}
-	w.WriteHeader(http.StatusNoContent)
+	return c.Validate()
 }
 
-func (cfg *apiConfig) handleChangePwd(w http.ResponseWriter, r *http.Request) {
-	token, err := auth.GetBearerToken(r.Header)
-	if err != nil {
-		log.Printf("Error getting token from header:\n%v", err)
-		w.WriteHeader(http.StatusUnauthorized)
-		return
+// newLogger writes logs at c.LogLevel and above to stderr, as JSON unless
+// c.LogFormat is text.
+func newLogger(c *config.Config) *slog.Logger {
+	level, _ := c.Level()
+	opts := &slog.HandlerOptions{Level: level}
+	if c.LogFormat == "text" {
+		return slog.New(slog.NewTextHandler(os.Stderr, opts))
 	}
-
-	userId, err := auth.ValidateJWT(token, cfg.secret)
-	if err != nil {
-		log.Printf("Invalid JWT:\n%v", err)
-		w.WriteHeader(http.StatusUnauthorized)
-		return
-	}
-
-	type newData struct {
-		Email    string `json:"email"`
-		Password string `json:"password"`
-	}
-
-	decoder := json.NewDecoder(r.Body)
-	data := newData{}
-	if err := decoder.Decode(&data); err != nil {
-		helperJsonError(w, "Error decoding user:\n%v", err)
-		return
-	}
-
-	hash, err := auth.HashPassword(data.Password)
-	if err != nil {
-		log.Printf("Failed to hash password:\n%v", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	}
-
-	update := database.UpdateUsrEmailPwdParams{
-		ID:             userId,
-		UpdatedAt:      time.Now().Local(),
-		Email:          data.Email,
-		HashedPassword: hash,
-	}
-	user, err := cfg.queries.UpdateUsrEmailPwd(r.Context(), update)
-	if err != nil {
-		log.Printf("Failed to update user information:\n%v", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	}
-
-	type retUser struct {
-		ID        uuid.UUID `json:"id"`
-		CreatedAt time.Time `json:"created_at"`
-		UpdatedAt time.Time `json:"updated_at"`
-		Email     string    `json:"email"`
-	}
-	rUser := retUser{
-		ID:        user.ID,
-		CreatedAt: user.CreatedAt,
-		UpdatedAt: user.UpdatedAt,
-		Email:     user.Email,
-	}
-	dat, err := json.Marshal(rUser)
-	if err != nil {
-		helperJsonError(w, "Error marshalling response: %s", err)
-		return
-	}
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	w.Write(dat)
-}
-
-func (cfg *apiConfig) handlerDeleteChirp(w http.ResponseWriter, r *http.Request) {
-	id := r.PathValue("chirpID")
-	chirp_uuid, err := uuid.Parse(id)
-	if err != nil {
-		log.Printf("Error parsing chirpID:\n%v", err)
-		w.WriteHeader(http.StatusNotFound)
-		return
-	}
-
-	token, err := auth.GetBearerToken(r.Header)
-	if err != nil {
-		log.Printf("Error getting token from header:\n%v", err)
-		w.WriteHeader(http.StatusUnauthorized)
-		return
-	}
-
-	userId, err := auth.ValidateJWT(token, cfg.secret)
-	if err != nil {
-		log.Printf("Invalid JWT:\n%v", err)
-		w.WriteHeader(http.StatusUnauthorized)
-		return
-	}
-
-	chirp, err := cfg.queries.GetChirpById(r.Context(), chirp_uuid)
-	if err != nil {
-		log.Printf("Error fetching chirp:\n%v", err)
-		w.WriteHeader(http.StatusNotFound)
-		return
-	}
-
-	if chirp.UserID != userId {
-		log.Printf("User ids do not match - failed to delete")
-		w.WriteHeader(http.StatusForbidden)
-		return
-	}
-
-	err = cfg.queries.DeleteChirpById(r.Context(), chirp_uuid)
-	if err != nil {
-		log.Printf("Error deleting chirp:\n%v", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	}
-
-	w.WriteHeader(http.StatusNoContent)
-}
-
-func (cfg *apiConfig) handlePolkaWebhook(w http.ResponseWriter, r *http.Request) {
-	apiKey, err := auth.GetAPIKey(r.Header)
-	if err != nil {
-		log.Printf("Bad header in webhook access:\n%v", err)
-		w.WriteHeader(http.StatusUnauthorized)
-		return
-	}
-	if apiKey != cfg.polkaKey {
-		log.Printf("Unauthorised access to webhook")
-		w.WriteHeader(http.StatusUnauthorized)
-		return
-	}
-
-	type req struct {
-		Event string `json:"event"`
-		Data  struct {
-			UserId string `json:"user_id"`
-		} `json:"data"`
-	}
-
-	decoder := json.NewDecoder(r.Body)
-	data := req{}
-	if err := decoder.Decode(&data); err != nil {
-		helperJsonError(w, "Error decoding user:\n%v", err)
-		return
-	}
-
-	if data.Event != "user.upgraded" {
-		log.Printf("Error processing webhook request:\nNot user upgrade")
-		w.WriteHeader(http.StatusNoContent)
-		return
-	}
-
-	userId, err := uuid.Parse(data.Data.UserId)
-	if err != nil {
-		log.Printf("Error parsing uuid:\n%v", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	}
-
-	params := database.UpdateUsrChirpyRedParams{
-		ID:          userId,
-		UpdatedAt:   time.Now().Local(),
-		IsChirpyRed: true,
-	}
-	_, err = cfg.queries.UpdateUsrChirpyRed(r.Context(), params)
-	if err != nil {
-		log.Printf("Error updating user:\n%v", err)
-		w.WriteHeader(http.StatusNotFound)
-		return
-	}
-
-	w.WriteHeader(http.StatusNoContent)
+	return slog.New(slog.NewJSONHandler(os.Stderr, opts))
 }
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"time"
+
+	"github.com/Senaphim/Chirpy/internal/migrate"
+)
+
+//go:embed sql/schema/*.sql sql/sqlite/schema/*.sql
+var migrations embed.FS
+
+// schemaDirs says where in migrations each dialect's files are.
+var schemaDirs = map[migrate.Dialect]string{
+	migrate.Postgres: "sql/schema",
+	migrate.SQLite:   "sql/sqlite/schema",
+}
+
+func newMigrator(db *sql.DB) (*migrate.Migrator, error) {
+	dialect, err := migrate.DialectOf(db)
+	if err != nil {
+		return nil, err
+	}
+	schema, err := fs.Sub(migrations, schemaDirs[dialect])
+	if err != nil {
+		return nil, err
+	}
+	return migrate.New(db, schema)
+}
+
+// migrateUp applies pending migrations before the server starts.
+func migrateUp(ctx context.Context, migrator *migrate.Migrator) error {
+	applied, err := migrator.Up(ctx)
+	for _, migration := range applied {
+		slog.Info("Applied migration", "version", migration.Version, "name", migration.Name)
+	}
+	return err
+}
+
+// runMigrate implements `chirpy migrate up|down|status`.
+func runMigrate(ctx context.Context, migrator *migrate.Migrator, args []string) error {
+	if migrator == nil {
+		return errors.New("the in-memory store has no migrations")
+	}
+	if len(args) != 1 {
+		return errors.New("usage: chirpy migrate up|down|status")
+	}
+
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		for _, migration := range applied {
+			fmt.Printf("Applied %s\n", migration.Name)
+		}
+		if err == nil && len(applied) == 0 {
+			fmt.Println("Already up to date")
+		}
+		return err
+	case "down":
+		migration, err := migrator.Down(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Rolled back %s\n", migration.Name)
+		return nil
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Println("Applied At                  Migration")
+		fmt.Println("=======================================")
+		for _, status := range statuses {
+			appliedAt := "Pending"
+			if status.Applied {
+				appliedAt = status.AppliedAt.Format(time.ANSIC)
+			}
+			fmt.Printf("%-24s -- %s\n", appliedAt, status.Name)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown migrate command %q, want up, down or status", args[0])
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestServeDrainsRequests(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen error = %v", err)
+	}
+	started := make(chan struct{})
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		time.Sleep(200 * time.Millisecond)
+		io.WriteString(w, "done")
+	})}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() {
+		served <- serve(ctx, srv, ln, 5*time.Second)
+	}()
+
+	url := "http://" + ln.Addr().String()
+	body := make(chan string, 1)
+	go func() {
+		resp, err := http.Get(url)
+		if err != nil {
+			t.Errorf("Request in flight error = %v", err)
+			body <- ""
+			return
+		}
+		defer resp.Body.Close()
+		dat, _ := io.ReadAll(resp.Body)
+		body <- string(dat)
+	}()
+
+	<-started
+	cancel()
+	if got := <-body; got != "done" {
+		t.Errorf("Request in flight got %q, want it to finish", got)
+	}
+	if err := <-served; err != nil {
+		t.Errorf("serve error = %v", err)
+	}
+	if _, err := http.Get(url); err == nil {
+		t.Errorf("Request after shutdown succeeded")
+	}
+}
+
+func TestServeShutdownTimeout(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen error = %v", err)
+	}
+	started := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	})}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() {
+		served <- serve(ctx, srv, ln, 100*time.Millisecond)
+	}()
+	go http.Get("http://" + ln.Addr().String())
+
+	<-started
+	cancel()
+	select {
+	case err := <-served:
+		if err == nil {
+			t.Errorf("serve error = nil, want the shutdown deadline to be reported")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("serve did not give up on a stuck request")
+	}
+}
@@ -0,0 +1,48 @@
+-- name: CreateOAuthClient :one
+INSERT INTO oauth_clients(
+  id, created_at, updated_at, user_id, name, secret_hash
+) VALUES (
+  $1,
+  $2,
+  $3,
+  $4,
+  $5,
+  $6
+) RETURNING *;
+
+-- name: GetOAuthClient :one
+SELECT * FROM oauth_clients WHERE id=$1;
+
+-- name: CreateOAuthRedirectURI :exec
+INSERT INTO oauth_redirect_uris(client_id, redirect_uri) VALUES ($1, $2);
+
+-- name: GetOAuthRedirectURIs :many
+SELECT redirect_uri FROM oauth_redirect_uris WHERE client_id=$1 ORDER BY redirect_uri;
+
+-- name: CreateOAuthCode :exec
+INSERT INTO oauth_codes(
+  code_hash, created_at, client_id, user_id, redirect_uri, scopes,
+  code_challenge, expires_at, used_at, redirect_uri_given
+) VALUES (
+  $1,
+  $2,
+  $3,
+  $4,
+  $5,
+  $6,
+  $7,
+  $8,
+  NULL,
+  $9
+);
+
+-- name: ConsumeOAuthCode :one
+UPDATE oauth_codes SET used_at=$1
+WHERE code_hash=$2 AND client_id=$3 AND used_at IS NULL
+RETURNING *;
+
+-- name: ResetOAuthCodes :exec
+DELETE FROM oauth_codes;
+
+-- name: ResetOAuthClients :exec
+DELETE FROM oauth_clients;
@@ -0,0 +1,18 @@
+-- name: CreateRecoveryCode :exec
+INSERT INTO recovery_codes(code_hash, created_at, user_id, used_at)
+VALUES (
+  $1,
+  $2,
+  $3,
+  NULL
+);
+
+-- name: DeleteRecoveryCodesByUser :exec
+DELETE FROM recovery_codes WHERE user_id=$1;
+
+-- name: UseRecoveryCode :execrows
+UPDATE recovery_codes SET used_at=$1
+WHERE code_hash=$2 AND user_id=$3 AND used_at IS NULL;
+
+-- name: ResetRecoveryCodes :exec
+DELETE FROM recovery_codes;
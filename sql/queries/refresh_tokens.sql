@@ -1,21 +1,53 @@
 -- name: CreateRefreshToken :one
 INSERT INTO refresh_tokens(
-  token, created_at, updated_at, user_id, expires_at, revoked_at
+  token_hash, created_at, updated_at, user_id, expires_at, revoked_at,
+  id, device_name, user_agent, ip_address, last_used_at, client_id, scopes,
+  family_id
 ) VALUES (
   $1,
   $2,
   $3,
   $4,
   $5,
-  NULL
+  NULL,
+  $6,
+  $7,
+  $8,
+  $9,
+  $10,
+  $11,
+  $12,
+  $13
 ) RETURNING *;
 
 -- name: ResetRefreshTokens :exec
 DELETE FROM refresh_tokens;
 
 -- name: GetRefreshToken :one
-SELECT * FROM refresh_tokens WHERE token=$1;
+SELECT * FROM refresh_tokens WHERE token_hash=$1;
 
--- name: RevokeRefreshToken :exec
-UPDATE refresh_tokens SET updated_at=$1, revoked_at=$2 WHERE token=$3;
+-- name: RevokeRefreshToken :execrows
+UPDATE refresh_tokens SET updated_at=$1, revoked_at=$2
+WHERE token_hash=$3 AND revoked_at IS NULL;
 
+-- name: RevokeRefreshTokenFamily :exec
+UPDATE refresh_tokens SET updated_at=$1, revoked_at=$2
+WHERE family_id=$3 AND revoked_at IS NULL;
+
+-- name: TouchRefreshToken :exec
+UPDATE refresh_tokens
+SET updated_at=$1, last_used_at=$2, user_agent=$3, ip_address=$4
+WHERE token_hash=$5;
+
+-- name: GetActiveSessionsByUser :many
+SELECT * FROM refresh_tokens
+WHERE user_id=$1 AND revoked_at IS NULL AND expires_at > $2
+ORDER BY last_used_at DESC;
+
+-- name: RevokeSessionById :execrows
+UPDATE refresh_tokens SET updated_at=$1, revoked_at=$2
+WHERE id=$3 AND user_id=$4 AND revoked_at IS NULL;
+
+-- name: RevokeAllSessionsByUser :exec
+UPDATE refresh_tokens SET updated_at=$1, revoked_at=$2
+WHERE user_id=$3 AND revoked_at IS NULL;
@@ -0,0 +1,20 @@
+-- name: CreateReport :one
+INSERT INTO reports(id, created_at, chirp_id, reporter_id, reason)
+VALUES (
+  $1,
+  $2,
+  $3,
+  $4,
+  $5
+) RETURNING *;
+
+-- name: RecentReports :many
+SELECT reports.id, reports.created_at, reports.reason, chirps.id AS chirp_id, chirps.body, users.email AS reporter_email
+FROM reports
+JOIN chirps ON chirps.id = reports.chirp_id
+JOIN users ON users.id = reports.reporter_id
+ORDER BY reports.created_at DESC, reports.id
+LIMIT $1;
+
+-- name: ResetReports :exec
+DELETE FROM reports;
@@ -0,0 +1,15 @@
+-- +goose up
+ALTER TABLE refresh_tokens
+  ADD COLUMN id UUID NOT NULL UNIQUE DEFAULT gen_random_uuid(),
+  ADD COLUMN device_name TEXT NOT NULL DEFAULT '',
+  ADD COLUMN user_agent TEXT NOT NULL DEFAULT '',
+  ADD COLUMN ip_address TEXT NOT NULL DEFAULT '',
+  ADD COLUMN last_used_at TIMESTAMP NOT NULL DEFAULT NOW();
+
+-- +goose down
+ALTER TABLE refresh_tokens
+  DROP COLUMN id,
+  DROP COLUMN device_name,
+  DROP COLUMN user_agent,
+  DROP COLUMN ip_address,
+  DROP COLUMN last_used_at;
@@ -0,0 +1,10 @@
+-- +goose up
+-- Existing raw tokens are converted in place to the hex SHA-256 digest that
+-- auth.HashRefreshToken produces, so live sessions keep working.
+ALTER TABLE refresh_tokens RENAME COLUMN token TO token_hash;
+UPDATE refresh_tokens SET token_hash = encode(sha256(convert_to(token_hash, 'UTF8')), 'hex');
+
+-- +goose down
+-- Hashes cannot be turned back into tokens, so every session is dropped.
+DELETE FROM refresh_tokens;
+ALTER TABLE refresh_tokens RENAME COLUMN token_hash TO token;
@@ -0,0 +1,18 @@
+-- +goose up
+ALTER TABLE users
+  ADD COLUMN totp_secret TEXT,
+  ADD COLUMN totp_enabled BOOLEAN NOT NULL DEFAULT false;
+
+CREATE TABLE recovery_codes(
+  code_hash TEXT PRIMARY KEY,
+  created_at TIMESTAMP NOT NULL,
+  user_id UUID NOT NULL REFERENCES users ON DELETE CASCADE,
+  used_at TIMESTAMP
+);
+
+-- +goose down
+DROP TABLE recovery_codes;
+
+ALTER TABLE users
+  DROP COLUMN totp_secret,
+  DROP COLUMN totp_enabled;
@@ -0,0 +1,12 @@
+-- +goose up
+CREATE TABLE reports(
+  id UUID PRIMARY KEY,
+  created_at TIMESTAMP NOT NULL,
+  chirp_id UUID NOT NULL REFERENCES chirps(id) ON DELETE CASCADE,
+  reporter_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  reason TEXT NOT NULL,
+  UNIQUE(chirp_id, reporter_id)
+);
+
+-- +goose down
+DROP TABLE reports;
@@ -0,0 +1,21 @@
+-- +goose up
+CREATE TABLE oauth_redirect_uris(
+  client_id UUID NOT NULL REFERENCES oauth_clients ON DELETE CASCADE,
+  redirect_uri TEXT NOT NULL,
+  PRIMARY KEY (client_id, redirect_uri)
+);
+
+INSERT INTO oauth_redirect_uris(client_id, redirect_uri)
+SELECT DISTINCT id, unnest(string_to_array(redirect_uris, ' ')) FROM oauth_clients;
+
+ALTER TABLE oauth_clients DROP COLUMN redirect_uris;
+
+-- +goose down
+ALTER TABLE oauth_clients ADD COLUMN redirect_uris TEXT NOT NULL DEFAULT '';
+
+UPDATE oauth_clients SET redirect_uris = COALESCE((
+  SELECT string_agg(redirect_uri, ' ') FROM oauth_redirect_uris
+  WHERE client_id = oauth_clients.id
+), '');
+
+DROP TABLE oauth_redirect_uris;
@@ -0,0 +1,31 @@
+-- name: CreateAPIToken :one
+INSERT INTO api_tokens(
+  id, created_at, updated_at, user_id, name, token_hash, scopes, expires_at
+) VALUES (
+  ?1,
+  ?2,
+  ?3,
+  ?4,
+  ?5,
+  ?6,
+  ?7,
+  ?8
+) RETURNING *;
+
+-- name: GetAPITokenByHash :one
+SELECT * FROM api_tokens WHERE token_hash=?1;
+
+-- name: GetAPITokensByUser :many
+SELECT * FROM api_tokens
+WHERE user_id=?1 AND revoked_at IS NULL
+ORDER BY created_at DESC;
+
+-- name: TouchAPIToken :exec
+UPDATE api_tokens SET last_used_at=?1 WHERE id=?2;
+
+-- name: RevokeAPIToken :execrows
+UPDATE api_tokens SET updated_at=?1, revoked_at=?2
+WHERE id=?3 AND user_id=?4 AND revoked_at IS NULL;
+
+-- name: ResetAPITokens :exec
+DELETE FROM api_tokens;
@@ -0,0 +1,30 @@
+-- name: CountUsers :one
+SELECT count(*) FROM users;
+
+-- name: CountChirpyRed :one
+SELECT count(*) FROM users WHERE is_chirpy_red;
+
+-- name: CountChirps :one
+SELECT count(*) FROM chirps;
+
+-- name: SignupsPerDay :many
+SELECT CAST(date(created_at) AS TEXT) AS day, count(*) AS count
+FROM users
+WHERE created_at >= ?1
+GROUP BY day
+ORDER BY day;
+
+-- name: ChirpsPerDay :many
+SELECT CAST(date(created_at) AS TEXT) AS day, count(*) AS count
+FROM chirps
+WHERE created_at >= ?1
+GROUP BY day
+ORDER BY day;
+
+-- name: TopPosters :many
+SELECT users.id, users.email, count(*) AS chirp_count
+FROM chirps
+JOIN users ON users.id = chirps.user_id
+GROUP BY users.id, users.email
+ORDER BY chirp_count DESC, users.email
+LIMIT ?1;
@@ -0,0 +1,19 @@
+-- name: CreateTwoFactorChallenge :exec
+INSERT INTO two_factor_challenges(id, user_id, created_at, expires_at, attempts)
+VALUES (
+  ?1,
+  ?2,
+  ?3,
+  ?4,
+  0
+);
+
+-- name: AttemptTwoFactorChallenge :one
+UPDATE two_factor_challenges SET attempts = attempts + 1
+WHERE id = sqlc.arg(id) AND expires_at > sqlc.arg(now) RETURNING *;
+
+-- name: DeleteTwoFactorChallenge :execrows
+DELETE FROM two_factor_challenges WHERE id = ?1;
+
+-- name: ResetTwoFactorChallenges :exec
+DELETE FROM two_factor_challenges;
@@ -0,0 +1,55 @@
+-- name: CreateUserIdentity :one
+INSERT INTO user_identities(
+  id, created_at, updated_at, user_id, issuer, subject, email
+) VALUES (
+  ?1,
+  ?2,
+  ?3,
+  ?4,
+  ?5,
+  ?6,
+  ?7
+) RETURNING *;
+
+-- name: GetUserIdentity :one
+SELECT * FROM user_identities WHERE issuer=?1 AND subject=?2;
+
+-- name: CreateOIDCLogin :exec
+INSERT INTO oidc_logins(state_hash, created_at, nonce, code_verifier, expires_at)
+VALUES (
+  ?1,
+  ?2,
+  ?3,
+  ?4,
+  ?5
+);
+
+-- name: ConsumeOIDCLogin :one
+DELETE FROM oidc_logins WHERE state_hash=?1 RETURNING *;
+
+-- name: CreateOIDCLink :exec
+INSERT INTO oidc_links(token_hash, created_at, expires_at, user_id, issuer, subject, email)
+VALUES (
+  ?1,
+  ?2,
+  ?3,
+  ?4,
+  ?5,
+  ?6,
+  ?7
+);
+
+-- name: GetOIDCLink :one
+SELECT * FROM oidc_links WHERE token_hash=?1;
+
+-- name: DeleteOIDCLink :execrows
+DELETE FROM oidc_links WHERE token_hash=?1;
+
+-- name: ResetUserIdentities :exec
+DELETE FROM user_identities;
+
+-- name: ResetOIDCLogins :exec
+DELETE FROM oidc_logins;
+
+-- name: ResetOIDCLinks :exec
+DELETE FROM oidc_links;
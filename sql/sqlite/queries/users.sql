@@ -0,0 +1,49 @@
+-- name: CreateUser :one
+INSERT INTO users(id, created_at, updated_at, email, hashed_password)
+VALUES (
+  ?1,
+  ?2,
+  ?3,
+  ?4,
+  ?5
+) RETURNING *;
+
+-- name: DeleteAll :exec
+DELETE FROM users;
+
+-- name: GetUserByEmail :one
+SELECT * FROM users WHERE email = ?1;
+
+-- name: GetUserById :one
+SELECT * FROM users WHERE id = ?1;
+
+-- name: UpdateUsrEmailPwd :one
+UPDATE users SET updated_at = ?2, email = ?3, hashed_password = ?4 WHERE id = ?1 RETURNING *;
+
+-- name: UpdateUsrChirpyRed :one
+UPDATE users SET updated_at = ?2, is_chirpy_red = ?3 WHERE id = ?1 RETURNING *;
+
+-- name: SetUsrTotpSecret :one
+UPDATE users SET updated_at = ?2, totp_secret = ?3, totp_enabled = false WHERE id = ?1 RETURNING *;
+
+-- name: EnableUsrTotp :one
+UPDATE users SET updated_at = ?2, totp_enabled = true WHERE id = ?1 RETURNING *;
+
+-- name: DisableUsrTotp :one
+UPDATE users SET updated_at = ?2, totp_secret = NULL, totp_enabled = false, totp_last_step = 0
+WHERE id = ?1 RETURNING *;
+
+-- name: UpdateUsrPassword :exec
+UPDATE users SET updated_at = ?2, hashed_password = ?3 WHERE id = ?1;
+
+-- name: UpdateUsrAdmin :one
+UPDATE users SET updated_at = ?2, is_admin = ?3 WHERE email = ?1 RETURNING *;
+
+-- name: AcceptUsrTotpStep :execrows
+UPDATE users SET totp_last_step = ?2, totp_failures = 0 WHERE id = ?1 AND totp_last_step < ?2;
+
+-- name: RecordUsrTotpFailure :one
+UPDATE users SET
+  totp_failures = CASE WHEN totp_failed_at > sqlc.arg(window_start) THEN totp_failures + 1 ELSE 1 END,
+  totp_failed_at = sqlc.arg(failed_at)
+WHERE id = sqlc.arg(id) RETURNING totp_failures;
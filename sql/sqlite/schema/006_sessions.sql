@@ -0,0 +1,26 @@
+-- +goose up
+-- SQLite adds one column per statement, cannot add a UNIQUE column and only
+-- takes constant defaults, so existing rows are backfilled and the id is made
+-- unique with an index instead.
+ALTER TABLE refresh_tokens ADD COLUMN id UUID NOT NULL DEFAULT '';
+ALTER TABLE refresh_tokens ADD COLUMN device_name TEXT NOT NULL DEFAULT '';
+ALTER TABLE refresh_tokens ADD COLUMN user_agent TEXT NOT NULL DEFAULT '';
+ALTER TABLE refresh_tokens ADD COLUMN ip_address TEXT NOT NULL DEFAULT '';
+ALTER TABLE refresh_tokens ADD COLUMN last_used_at TIMESTAMP NOT NULL DEFAULT '1970-01-01 00:00:00';
+UPDATE refresh_tokens SET
+  id = lower(
+    hex(randomblob(4)) || '-' || hex(randomblob(2)) || '-4' ||
+    substr(hex(randomblob(2)), 2) || '-' ||
+    substr('89ab', 1 + (abs(random()) % 4), 1) ||
+    substr(hex(randomblob(2)), 2) || '-' || hex(randomblob(6))
+  ),
+  last_used_at = updated_at;
+CREATE UNIQUE INDEX refresh_tokens_id ON refresh_tokens(id);
+
+-- +goose down
+DROP INDEX refresh_tokens_id;
+ALTER TABLE refresh_tokens DROP COLUMN id;
+ALTER TABLE refresh_tokens DROP COLUMN device_name;
+ALTER TABLE refresh_tokens DROP COLUMN user_agent;
+ALTER TABLE refresh_tokens DROP COLUMN ip_address;
+ALTER TABLE refresh_tokens DROP COLUMN last_used_at;
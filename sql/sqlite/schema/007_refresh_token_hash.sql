@@ -0,0 +1,10 @@
+-- +goose up
+-- SQLite has no SHA-256 function to hash raw tokens in place, so existing
+-- sessions are dropped and their users sign in again.
+DELETE FROM refresh_tokens;
+ALTER TABLE refresh_tokens RENAME COLUMN token TO token_hash;
+
+-- +goose down
+-- Hashes cannot be turned back into tokens, so every session is dropped.
+DELETE FROM refresh_tokens;
+ALTER TABLE refresh_tokens RENAME COLUMN token_hash TO token;
@@ -0,0 +1,52 @@
+-- +goose up
+CREATE TABLE oauth_clients(
+  id UUID PRIMARY KEY,
+  created_at TIMESTAMP NOT NULL,
+  updated_at TIMESTAMP NOT NULL,
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  name TEXT NOT NULL,
+  redirect_uris TEXT NOT NULL,
+  secret_hash TEXT
+);
+
+CREATE TABLE oauth_codes(
+  code_hash TEXT PRIMARY KEY,
+  created_at TIMESTAMP NOT NULL,
+  client_id UUID NOT NULL REFERENCES oauth_clients(id) ON DELETE CASCADE,
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  redirect_uri TEXT NOT NULL,
+  scopes TEXT NOT NULL,
+  code_challenge TEXT NOT NULL,
+  expires_at TIMESTAMP NOT NULL,
+  used_at TIMESTAMP
+);
+
+ALTER TABLE refresh_tokens ADD COLUMN client_id UUID REFERENCES oauth_clients(id) ON DELETE CASCADE;
+ALTER TABLE refresh_tokens ADD COLUMN scopes TEXT NOT NULL DEFAULT '';
+
+-- +goose down
+-- SQLite cannot drop a column that is part of a foreign key, so the table is
+-- rebuilt without them.
+CREATE TABLE refresh_tokens_old(
+  token_hash TEXT PRIMARY KEY,
+  created_at TIMESTAMP NOT NULL,
+  updated_at TIMESTAMP NOT NULL,
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  expires_at TIMESTAMP NOT NULL,
+  revoked_at TIMESTAMP,
+  id UUID NOT NULL DEFAULT '',
+  device_name TEXT NOT NULL DEFAULT '',
+  user_agent TEXT NOT NULL DEFAULT '',
+  ip_address TEXT NOT NULL DEFAULT '',
+  last_used_at TIMESTAMP NOT NULL DEFAULT '1970-01-01 00:00:00'
+);
+INSERT INTO refresh_tokens_old
+  SELECT token_hash, created_at, updated_at, user_id, expires_at, revoked_at,
+    id, device_name, user_agent, ip_address, last_used_at
+  FROM refresh_tokens;
+DROP TABLE refresh_tokens;
+ALTER TABLE refresh_tokens_old RENAME TO refresh_tokens;
+CREATE UNIQUE INDEX refresh_tokens_id ON refresh_tokens(id);
+
+DROP TABLE oauth_codes;
+DROP TABLE oauth_clients;
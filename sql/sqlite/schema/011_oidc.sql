@@ -0,0 +1,23 @@
+-- +goose up
+CREATE TABLE user_identities(
+  id UUID PRIMARY KEY,
+  created_at TIMESTAMP NOT NULL,
+  updated_at TIMESTAMP NOT NULL,
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  issuer TEXT NOT NULL,
+  subject TEXT NOT NULL,
+  email TEXT NOT NULL,
+  UNIQUE (issuer, subject)
+);
+
+CREATE TABLE oidc_logins(
+  state_hash TEXT PRIMARY KEY,
+  created_at TIMESTAMP NOT NULL,
+  nonce TEXT NOT NULL,
+  code_verifier TEXT NOT NULL,
+  expires_at TIMESTAMP NOT NULL
+);
+
+-- +goose down
+DROP TABLE oidc_logins;
+DROP TABLE user_identities;
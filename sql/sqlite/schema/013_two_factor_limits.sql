@@ -0,0 +1,19 @@
+-- +goose up
+ALTER TABLE users ADD COLUMN totp_last_step BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE users ADD COLUMN totp_failures BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE users ADD COLUMN totp_failed_at TIMESTAMP;
+
+CREATE TABLE two_factor_challenges(
+  id UUID PRIMARY KEY,
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  created_at TIMESTAMP NOT NULL,
+  expires_at TIMESTAMP NOT NULL,
+  attempts BIGINT NOT NULL DEFAULT 0
+);
+
+-- +goose down
+DROP TABLE two_factor_challenges;
+
+ALTER TABLE users DROP COLUMN totp_last_step;
+ALTER TABLE users DROP COLUMN totp_failures;
+ALTER TABLE users DROP COLUMN totp_failed_at;
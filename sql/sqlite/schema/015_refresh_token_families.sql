@@ -0,0 +1,9 @@
+-- +goose up
+-- Refresh tokens issued to OAuth clients are rotated on every use. Each
+-- rotation keeps the family of the token it replaces, so a replayed token
+-- can revoke the whole chain. Existing tokens start a family of their own.
+ALTER TABLE refresh_tokens ADD COLUMN family_id UUID;
+UPDATE refresh_tokens SET family_id = id WHERE client_id IS NOT NULL;
+
+-- +goose down
+ALTER TABLE refresh_tokens DROP COLUMN family_id;
@@ -0,0 +1,29 @@
+-- +goose up
+CREATE TABLE oauth_redirect_uris(
+  client_id UUID NOT NULL REFERENCES oauth_clients(id) ON DELETE CASCADE,
+  redirect_uri TEXT NOT NULL,
+  PRIMARY KEY (client_id, redirect_uri)
+);
+
+-- SQLite has no function to split a string, so the space separated list is
+-- taken apart one URI at a time.
+WITH RECURSIVE split(client_id, redirect_uri, rest) AS (
+  SELECT id, '', redirect_uris || ' ' FROM oauth_clients
+  UNION ALL
+  SELECT client_id, substr(rest, 1, instr(rest, ' ') - 1), substr(rest, instr(rest, ' ') + 1)
+  FROM split WHERE rest <> ''
+)
+INSERT OR IGNORE INTO oauth_redirect_uris(client_id, redirect_uri)
+SELECT client_id, redirect_uri FROM split WHERE redirect_uri <> '';
+
+ALTER TABLE oauth_clients DROP COLUMN redirect_uris;
+
+-- +goose down
+ALTER TABLE oauth_clients ADD COLUMN redirect_uris TEXT NOT NULL DEFAULT '';
+
+UPDATE oauth_clients SET redirect_uris = COALESCE((
+  SELECT group_concat(redirect_uri, ' ') FROM oauth_redirect_uris
+  WHERE client_id = oauth_clients.id
+), '');
+
+DROP TABLE oauth_redirect_uris;
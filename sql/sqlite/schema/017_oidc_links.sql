@@ -0,0 +1,15 @@
+-- +goose up
+-- A single sign on login whose email belongs to an existing account waits
+-- here until the account's password confirms the link.
+CREATE TABLE oidc_links(
+  token_hash TEXT PRIMARY KEY,
+  created_at TIMESTAMP NOT NULL,
+  expires_at TIMESTAMP NOT NULL,
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  issuer TEXT NOT NULL,
+  subject TEXT NOT NULL,
+  email TEXT NOT NULL
+);
+
+-- +goose down
+DROP TABLE oidc_links;
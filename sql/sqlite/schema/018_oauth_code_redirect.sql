@@ -0,0 +1,8 @@
+-- +goose up
+-- Clients may leave redirect_uri out of an authorization request when they
+-- registered only one. The token request must then leave it out too, so
+-- codes remember whether it was given.
+ALTER TABLE oauth_codes ADD COLUMN redirect_uri_given BOOLEAN NOT NULL DEFAULT TRUE;
+
+-- +goose down
+ALTER TABLE oauth_codes DROP COLUMN redirect_uri_given;